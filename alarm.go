@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	stuckAlarm        string
+	alarmTopicARN     string
+	stuckAlarmMinutes int
+)
+
+// Stuck-runner alarm kinds: EC2 status checks failing, or the runner's
+// heartbeat metric going missing
+const (
+	alarmStatusCheck = "status-check"
+	alarmHeartbeat   = "heartbeat"
+)
+
+// Names of the stuck-runner alarms and of the heartbeat metric
+const (
+	stuckAlarmPrefix   = "gh-workflow-stuck-"
+	heartbeatNamespace = "GhWorkflow"
+	heartbeatMetric    = "RunnerHeartbeat"
+)
+
+// snsTopicPattern matches an SNS topic ARN
+var snsTopicPattern = regexp.MustCompile(`^arn:aws[a-z-]*:sns:[a-z0-9-]+:[0-9]{12}:[A-Za-z0-9_.-]+$`)
+
+// validateAlarmFlags checks --stuck-alarm, --alarm-topic-arn and --stuck-alarm-minutes
+func validateAlarmFlags() error {
+	if stuckAlarm == "" {
+		return nil
+	}
+	if stuckAlarm != alarmStatusCheck && stuckAlarm != alarmHeartbeat {
+		return fmt.Errorf("stuck-alarm must be '%s' or '%s'", alarmStatusCheck, alarmHeartbeat)
+	}
+	if !snsTopicPattern.MatchString(alarmTopicARN) {
+		return fmt.Errorf("stuck-alarm needs --alarm-topic-arn with the SNS topic to notify (arn:aws:sns:...)")
+	}
+	if stuckAlarmMinutes < 1 || stuckAlarmMinutes > 1440 {
+		return fmt.Errorf("stuck-alarm-minutes must be between 1 and 1440")
+	}
+	if stuckAlarm == alarmHeartbeat && runnerOS != "linux" {
+		return fmt.Errorf("stuck-alarm heartbeat is only supported with --os linux")
+	}
+	return nil
+}
+
+// heartbeatUserData returns user data lines installing a service that
+// publishes the heartbeat metric every minute while the runner listens for
+// jobs, with a RunnerBusy metric telling whether it is running one
+func heartbeatUserData() []string {
+	if stuckAlarm != alarmHeartbeat {
+		return nil
+	}
+	return []string{
+		"# Publish the runner heartbeat for the stuck-runner alarm",
+		"cat > /usr/local/bin/gh-workflow-heartbeat << 'EOF'",
+		"#!/bin/bash",
+		"IMDS=http://169.254.169.254/latest",
+		"while true; do",
+		"    TOKEN=$(curl -s -X PUT $IMDS/api/token -H 'X-aws-ec2-metadata-token-ttl-seconds: 60')",
+		"    IID=$(curl -s -H \"X-aws-ec2-metadata-token: $TOKEN\" $IMDS/meta-data/instance-id)",
+		"    REGION=$(curl -s -H \"X-aws-ec2-metadata-token: $TOKEN\" $IMDS/meta-data/placement/region)",
+		"    if pgrep -f Runner.Listener >/dev/null; then",
+		"        BUSY=0; pgrep -f Runner.Worker >/dev/null && BUSY=1",
+		"        aws cloudwatch put-metric-data --region \"$REGION\" --namespace " + heartbeatNamespace +
+			" --dimensions \"InstanceId=$IID\" \\",
+		"            --metric-data \"MetricName=" + heartbeatMetric + ",Value=1\" \\",
+		"            \"MetricName=RunnerBusy,Value=$BUSY\" || true",
+		"    fi",
+		"    sleep 60",
+		"done",
+		"EOF",
+		"chmod +x /usr/local/bin/gh-workflow-heartbeat",
+		"cat > /etc/systemd/system/gh-workflow-heartbeat.service << 'EOF'",
+		"[Unit]",
+		"Description=gh-workflow runner heartbeat",
+		"After=network-online.target",
+		"[Service]",
+		"ExecStart=/usr/local/bin/gh-workflow-heartbeat",
+		"Restart=always",
+		"[Install]",
+		"WantedBy=multi-user.target",
+		"EOF",
+		"systemctl daemon-reload && systemctl enable --now gh-workflow-heartbeat",
+		"",
+	}
+}
+
+// cloudWatchEndpoint returns the CloudWatch endpoint of cfg's region
+func cloudWatchEndpoint(cfg aws.Config) string {
+	if awsEndpointURL != "" {
+		return awsEndpointURL
+	}
+	host := "monitoring"
+	if useFIPSEndpoint {
+		host = "monitoring-fips"
+	}
+	suffix := "amazonaws.com"
+	if regionPartition(cfg.Region) == "aws-cn" {
+		suffix = "amazonaws.com.cn"
+	}
+	return fmt.Sprintf("https://%s.%s.%s/", host, cfg.Region, suffix)
+}
+
+// cloudWatchError is the error document of the CloudWatch Query API
+type cloudWatchError struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// callCloudWatch calls a CloudWatch Query API action and returns its response
+// document. The CloudWatch SDK client is not a dependency, so the request is
+// signed here, like the EC2 Instance Connect tunnel's, and sent with the SDK's
+// HTTP client so cassettes and --read-only apply
+func callCloudWatch(cfg aws.Config, action string, params url.Values) ([]byte, error) {
+	if readOnly && !isReadOnlyOperation(action) {
+		return nil, checkReadOnly("CloudWatch " + action)
+	}
+	params.Set("Action", action)
+	params.Set("Version", "2010-08-01")
+	body := params.Encode()
+
+	req, err := http.NewRequest("POST", cloudWatchEndpoint(cfg), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	req.Header.Set("User-Agent", userAgent())
+
+	creds, err := cfg.Credentials.Retrieve(context.TODO())
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to retrieve AWS credentials: %v", ErrAuth, err)
+	}
+	hash := sha256.Sum256([]byte(body))
+	if err := v4.NewSigner().SignHTTP(context.TODO(), creds, req, hex.EncodeToString(hash[:]), "monitoring",
+		cfg.Region, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to sign CloudWatch request: %v", err)
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 300 {
+		return data, nil
+	}
+	var apiErr cloudWatchError
+	if xml.Unmarshal(data, &apiErr) != nil || apiErr.Code == "" {
+		return nil, fmt.Errorf("CloudWatch %s returned status %d: %s", action, resp.StatusCode, string(data))
+	}
+	return nil, classifyAWSError(&smithy.GenericAPIError{Code: apiErr.Code, Message: apiErr.Message})
+}
+
+// createStuckAlarm creates the --stuck-alarm alarm of a running runner
+// instance, notifying --alarm-topic-arn, and records it on the instance so
+// terminate deletes it
+func createStuckAlarm(svc *ec2.Client, manifest *RunManifest) error {
+	if stuckAlarm == "" {
+		return nil
+	}
+	name := stuckAlarmPrefix + manifest.InstanceID
+	params := url.Values{
+		"AlarmName": {name},
+		"AlarmDescription": {fmt.Sprintf("gh-workflow runner instance %s of %s is stuck (%s)", manifest.InstanceID,
+			manifest.Repository, stuckAlarm)},
+		"AlarmActions.member.1":     {alarmTopicARN},
+		"Dimensions.member.1.Name":  {"InstanceId"},
+		"Dimensions.member.1.Value": {manifest.InstanceID},
+		"Period":                    {"60"},
+		"EvaluationPeriods":         {fmt.Sprint(stuckAlarmMinutes)},
+		"DatapointsToAlarm":         {fmt.Sprint(stuckAlarmMinutes)},
+		"Tags.member.1.Key":         {"Repository"},
+		"Tags.member.1.Value":       {manifest.Repository},
+		"Tags.member.2.Key":         {"RunnerInstanceId"},
+		"Tags.member.2.Value":       {manifest.InstanceID},
+	}
+	if stuckAlarm == alarmStatusCheck {
+		params.Set("Namespace", "AWS/EC2")
+		params.Set("MetricName", "StatusCheckFailed")
+		params.Set("Statistic", "Maximum")
+		params.Set("Threshold", "1")
+		params.Set("ComparisonOperator", "GreaterThanOrEqualToThreshold")
+		params.Set("TreatMissingData", "notBreaching")
+	} else {
+		// A missing heartbeat is the failure, so missing data breaches
+		params.Set("Namespace", heartbeatNamespace)
+		params.Set("MetricName", heartbeatMetric)
+		params.Set("Statistic", "SampleCount")
+		params.Set("Threshold", "1")
+		params.Set("ComparisonOperator", "LessThanThreshold")
+		params.Set("TreatMissingData", "breaching")
+	}
+
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	if _, err := callCloudWatch(cfg, "PutMetricAlarm", params); err != nil {
+		return fmt.Errorf("failed to create alarm %s: %w", name, err)
+	}
+	resource := AuxResource{Type: auxAlarm, ID: name}
+	if err := trackAuxResource(svc, manifest.InstanceID, resource); err != nil {
+		return err
+	}
+	manifest.Resources = appendAuxResource(manifest.Resources, resource)
+
+	emitEvent("alarm.created", map[string]any{"name": name, "kind": stuckAlarm, "topic_arn": alarmTopicARN})
+	if outputFormat != "github-actions" {
+		fmt.Printf("🚨 Created alarm %s (%s for %d minute(s) notifies %s)\n", name, stuckAlarm, stuckAlarmMinutes,
+			alarmTopicARN)
+	}
+	return nil
+}
+
+// deleteAlarm deletes a CloudWatch alarm
+func deleteAlarm(cfg aws.Config, name string) error {
+	_, err := callCloudWatch(cfg, "DeleteAlarms", url.Values{"AlarmNames.member.1": {name}})
+	return err
+}
+
+// alarmExists reports whether a CloudWatch alarm exists
+func alarmExists(cfg aws.Config, name string) (bool, error) {
+	data, err := callCloudWatch(cfg, "DescribeAlarms", url.Values{"AlarmNames.member.1": {name}})
+	if err != nil {
+		return false, err
+	}
+	var result struct {
+		Alarms []string `xml:"DescribeAlarmsResult>MetricAlarms>member>AlarmName"`
+	}
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return false, fmt.Errorf("failed to parse DescribeAlarms response: %v", err)
+	}
+	return len(result.Alarms) > 0, nil
+}
+
+func init() {
+	createCmd.Flags().StringVar(&stuckAlarm, "stuck-alarm", "",
+		"Create a CloudWatch alarm for a stuck runner: status-check (EC2 status checks failing) or heartbeat "+
+			"(runner heartbeat missing; needs the AWS CLI and cloudwatch:PutMetricData on the instance)")
+	createCmd.Flags().StringVar(&alarmTopicARN, "alarm-topic-arn", "", "SNS topic the stuck-runner alarm notifies")
+	createCmd.Flags().IntVar(&stuckAlarmMinutes, "stuck-alarm-minutes", 15,
+		"Minutes the runner must be stuck before the alarm fires (longer than the bootstrap with heartbeat)")
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	amiCheck        string
+	amiMaxAgeDays   int
+	amiFamily       string
+	amiArchs        string
+	amiRegions      string
+	amiOutputFormat string
+)
+
+// amiFamilies maps an AMI family to the public SSM parameter holding its
+// current AMI ID; %s is replaced with the family's name for the architecture
+var amiFamilies = map[string]struct {
+	Parameter string
+	Archs     map[string]string
+}{
+	"ubuntu-20.04": {
+		Parameter: "/aws/service/canonical/ubuntu/server/20.04/stable/current/%s/hvm/ebs-gp2/ami-id",
+		Archs:     map[string]string{"x64": "amd64", "arm64": "arm64"},
+	},
+	"ubuntu-22.04": {
+		Parameter: "/aws/service/canonical/ubuntu/server/22.04/stable/current/%s/hvm/ebs-gp2/ami-id",
+		Archs:     map[string]string{"x64": "amd64", "arm64": "arm64"},
+	},
+	"ubuntu-24.04": {
+		Parameter: "/aws/service/canonical/ubuntu/server/24.04/stable/current/%s/hvm/ebs-gp3/ami-id",
+		Archs:     map[string]string{"x64": "amd64", "arm64": "arm64"},
+	},
+	"al2023": {
+		Parameter: "/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-%s",
+		Archs:     map[string]string{"x64": "x86_64", "arm64": "arm64"},
+	},
+	"al2": {
+		Parameter: "/aws/service/ami-amazon-linux-latest/amzn2-ami-kernel-5.10-hvm-%s-gp2",
+		Archs:     map[string]string{"x64": "x86_64", "arm64": "arm64"},
+	},
+}
+
+// RecommendedAMI is the current AMI of a family in one region and architecture
+type RecommendedAMI struct {
+	Region  string `json:"region"`
+	Family  string `json:"family"`
+	Arch    string `json:"arch"`
+	ImageID string `json:"image_id"`
+}
+
+// checkAMI verifies that an AMI exists in the current region and, depending on
+// --ami-check, warns about or rejects AMIs that are deprecated or older than
+// --max-ami-age-days
+func checkAMI(svc *ec2.Client, imageID string) error {
+	if amiCheck == "off" {
+		return nil
+	}
+
+	result, err := svc.DescribeImages(context.TODO(), &ec2.DescribeImagesInput{
+		ImageIds:          []string{imageID},
+		IncludeDeprecated: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe AMI %s: %w", imageID, classifyAWSError(err))
+	}
+	if len(result.Images) == 0 {
+		return fmt.Errorf("%w: AMI %s does not exist in %s", ErrNotFound, imageID, resolveRegion())
+	}
+	image := result.Images[0]
+
+	var problems []string
+	if deprecation, err := time.Parse(time.RFC3339, aws.ToString(image.DeprecationTime)); err == nil &&
+		!deprecation.After(time.Now()) {
+		problems = append(problems, fmt.Sprintf("was deprecated on %s", deprecation.Format("2006-01-02")))
+	}
+	if created, err := time.Parse(time.RFC3339, aws.ToString(image.CreationDate)); err == nil && amiMaxAgeDays > 0 {
+		if age := int(time.Since(created).Hours() / 24); age > amiMaxAgeDays {
+			problems = append(problems, fmt.Sprintf("is %d days old (max %d)", age, amiMaxAgeDays))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("AMI %s (%s) %s", imageID, aws.ToString(image.Name), strings.Join(problems, " and "))
+	emitEvent("ami.outdated", map[string]any{"image_id": imageID, "problems": problems})
+	if amiCheck == "fail" {
+		return fmt.Errorf("%s (use --ami-check warn to launch anyway)", message)
+	}
+	if outputFormat == "github-actions" {
+		fmt.Fprintf(os.Stderr, "::warning::%s\n", message)
+	} else {
+		fmt.Fprintf(os.Stderr, "⚠️  %s\n", message)
+	}
+	return nil
+}
+
+// latestAMIs looks up the current AMI of a family for each region and architecture
+func latestAMIs(cfg aws.Config, family string, regions, archs []string) ([]RecommendedAMI, error) {
+	spec, ok := amiFamilies[family]
+	if !ok {
+		return nil, fmt.Errorf("unknown AMI family %q (supported: %s)", family, strings.Join(amiFamilyNames(), ", "))
+	}
+
+	amis := []RecommendedAMI{}
+	var errs []error
+	for _, region := range regions {
+		client := ssm.NewFromConfig(cfg, func(o *ssm.Options) { o.Region = region })
+		for _, arch := range archs {
+			name, ok := spec.Archs[arch]
+			if !ok {
+				return nil, fmt.Errorf("unsupported arch %q (supported: x64, arm64)", arch)
+			}
+			result, err := client.GetParameter(context.TODO(), &ssm.GetParameterInput{
+				Name: aws.String(fmt.Sprintf(spec.Parameter, name)),
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s/%s: %w", region, arch, classifyAWSError(err)))
+				continue
+			}
+			amis = append(amis, RecommendedAMI{
+				Region:  region,
+				Family:  family,
+				Arch:    arch,
+				ImageID: aws.ToString(result.Parameter.Value),
+			})
+		}
+	}
+	return amis, errors.Join(errs...)
+}
+
+// amiFamilyNames returns the supported AMI families in sorted order
+func amiFamilyNames() []string {
+	names := make([]string, 0, len(amiFamilies))
+	for name := range amiFamilies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+var amiCmd = &cobra.Command{
+	Use:   "ami",
+	Short: "Look up runner AMIs",
+	Long:  "Look up recommended AMIs for runner instances",
+}
+
+var amiLatestCmd = &cobra.Command{
+	Use:   "latest",
+	Short: "Print the current AMI of a family per region and architecture",
+	Long:  "Print the current AMI of a family (e.g. ubuntu-22.04) per region and architecture from AWS public SSM parameters",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		regions := splitList(amiRegions)
+		if len(regions) == 0 {
+			regions = []string{resolveRegion()}
+		}
+
+		cfg, err := loadAWSConfig()
+		if err != nil {
+			return err
+		}
+
+		amis, err := latestAMIs(cfg, amiFamily, regions, splitList(amiArchs))
+		if amis == nil {
+			return err
+		}
+
+		if amiOutputFormat == "json" {
+			data, jsonErr := json.MarshalIndent(amis, "", "  ")
+			if jsonErr != nil {
+				return fmt.Errorf("failed to encode AMIs: %v", jsonErr)
+			}
+			fmt.Println(string(data))
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REGION\tARCH\tIMAGE ID")
+		for _, ami := range amis {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", ami.Region, ami.Arch, ami.ImageID)
+		}
+		w.Flush()
+		return err
+	},
+}
+
+func init() {
+	createCmd.Flags().StringVar(&amiCheck, "ami-check", "warn",
+		"What to do when the AMI is deprecated or older than max-ami-age-days (warn, fail or off)")
+	createCmd.Flags().
+		IntVar(&amiMaxAgeDays, "max-ami-age-days", 0, "Flag AMIs older than this many days (0 disables the age check)")
+
+	amiLatestCmd.Flags().StringVar(&amiFamily, "family", "ubuntu-22.04",
+		fmt.Sprintf("AMI family (%s)", strings.Join(amiFamilyNames(), ", ")))
+	amiLatestCmd.Flags().StringVar(&amiArchs, "arch", "x64,arm64", "Architectures (comma-separated: x64, arm64)")
+	amiLatestCmd.Flags().StringVar(&amiRegions, "regions", "", "Regions (comma-separated, default: current region)")
+	amiLatestCmd.Flags().
+		StringVar(&amiOutputFormat, "output-format", "", "Output format (json for machine-readable output)")
+
+	amiCmd.AddCommand(amiLatestCmd)
+	rootCmd.AddCommand(amiCmd)
+}
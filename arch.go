@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// runnerArch is the runner package architecture (x64 or arm64) of the
+// instance being created, derived from its instance type
+var runnerArch string
+
+// runnerArchs maps EC2 architectures to runner package architectures
+var runnerArchs = map[types.ArchitectureType]string{
+	types.ArchitectureTypeX8664: "x64",
+	types.ArchitectureTypeArm64: "arm64",
+}
+
+// instanceTypeArch returns the runner architecture of an instance type
+func instanceTypeArch(svc *ec2.Client, instanceType string) (string, error) {
+	result, err := svc.DescribeInstanceTypes(context.TODO(), &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []types.InstanceType{types.InstanceType(instanceType)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe instance type %s: %w", instanceType, classifyAWSError(err))
+	}
+	if len(result.InstanceTypes) == 0 || result.InstanceTypes[0].ProcessorInfo == nil {
+		return "", fmt.Errorf("%w: instance type %s is not offered in %s", ErrNotFound, instanceType, resolveRegion())
+	}
+	for _, arch := range result.InstanceTypes[0].ProcessorInfo.SupportedArchitectures {
+		if runnerArch, ok := runnerArchs[arch]; ok {
+			return runnerArch, nil
+		}
+	}
+	return "", fmt.Errorf("instance type %s has no architecture the GitHub Actions runner supports", instanceType)
+}
+
+// imageArch returns the runner architecture of an AMI
+func imageArch(svc *ec2.Client, imageID string) (string, error) {
+	result, err := svc.DescribeImages(context.TODO(), &ec2.DescribeImagesInput{
+		ImageIds:          []string{imageID},
+		IncludeDeprecated: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe AMI %s: %w", imageID, classifyAWSError(err))
+	}
+	if len(result.Images) == 0 {
+		return "", fmt.Errorf("%w: AMI %s does not exist in %s", ErrNotFound, imageID, resolveRegion())
+	}
+	arch := result.Images[0].Architecture
+	if arch == types.ArchitectureValuesArm64 || arch == types.ArchitectureValuesArm64Mac {
+		return "arm64", nil
+	}
+	return "x64", nil
+}
+
+// resolveRunnerArch derives the runner architecture from the instance type and
+// checks that the AMI is built for it
+func resolveRunnerArch(svc *ec2.Client, instanceType, imageID string) (string, error) {
+	arch, err := instanceTypeArch(svc, instanceType)
+	if err != nil {
+		return "", err
+	}
+	amiArch, err := imageArch(svc, imageID)
+	if err != nil {
+		return "", err
+	}
+	if amiArch != arch {
+		return "", fmt.Errorf("AMI %s is built for %s but instance type %s is %s", imageID, amiArch, instanceType, arch)
+	}
+	if runnerOS == "windows" && arch != "x64" {
+		return "", fmt.Errorf("windows runners need an x64 instance type, %s is %s", instanceType, arch)
+	}
+	return arch, nil
+}
+
+// archLabels replaces the architecture label in a runner label list with arch
+func archLabels(labels, arch string) string {
+	list := splitList(labels)
+	for i, label := range list {
+		if slices.Contains([]string{"x64", "arm64"}, strings.ToLower(label)) {
+			list[i] = arch
+		}
+	}
+	return strings.Join(list, ",")
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runID             string
+	terminateRunID    string
+	auditRunID        string
+	auditInstanceID   string
+	auditSince        time.Duration
+	auditOutputFormat string
+)
+
+// correlationPrefix marks client tokens generated by this tool
+const correlationPrefix = "ghw"
+
+// newCorrelationID returns a unique launch correlation ID embedding the
+// workflow run ID and attempt, used as the RunInstances ClientToken
+func newCorrelationID(runID string) string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+
+	if runID == "" {
+		return fmt.Sprintf("%s-%s", correlationPrefix, hex.EncodeToString(suffix))
+	}
+	attempt := os.Getenv("GITHUB_RUN_ATTEMPT")
+	if attempt == "" {
+		attempt = "1"
+	}
+	return fmt.Sprintf("%s-%s-%s-%s", correlationPrefix, runID, attempt, hex.EncodeToString(suffix))
+}
+
+// launchCorrelationID returns the correlation ID of a create. With a workflow
+// run ID and a runner name it is derived from them and the repository, so a
+// retried create or a re-run of the same job reuses the RunInstances client
+// token instead of launching a duplicate; otherwise it is random. The run
+// attempt is left out on purpose and only recorded in the RunAttempt tag
+func launchCorrelationID(runID, repository, runnerName string) string {
+	if runID == "" || runnerName == "" {
+		return newCorrelationID(runID)
+	}
+	sum := sha256.Sum256([]byte(repository + "\n" + runnerName))
+	return fmt.Sprintf("%s-%s-%s", correlationPrefix, runID, hex.EncodeToString(sum[:8]))
+}
+
+// workflowTags returns tags identifying the GitHub workflow run that launched an instance
+func workflowTags(correlationID string) map[string]string {
+	tags := map[string]string{"CorrelationId": correlationID}
+	if runID != "" {
+		tags["RunId"] = runID
+	}
+	for tag, env := range map[string]string{
+		"RunAttempt": "GITHUB_RUN_ATTEMPT",
+		"Workflow":   "GITHUB_WORKFLOW",
+		"Actor":      "GITHUB_ACTOR",
+	} {
+		if value := os.Getenv(env); value != "" {
+			tags[tag] = value
+		}
+	}
+	return tags
+}
+
+// findRunInstances returns the live runner instances tagged with a workflow run ID
+func findRunInstances(svc *ec2.Client, runID string) ([]string, error) {
+	input := &ec2.DescribeInstancesInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("tag:Purpose"), Values: []string{"GitHub Actions"}},
+			{Name: aws.String("tag:RunId"), Values: []string{runID}},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []string{"pending", "running", "stopping", "stopped"},
+			},
+		},
+	}
+
+	instanceIDs := []string{}
+	paginator := ec2.NewDescribeInstancesPaginator(svc, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, classifyAWSError(err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				instanceIDs = append(instanceIDs, aws.ToString(instance.InstanceId))
+			}
+		}
+	}
+	return instanceIDs, nil
+}
+
+// terminateByRunID terminates every runner instance launched for a workflow run,
+// across all of its jobs and matrix legs, so one cleanup job can tear them all down
+func terminateByRunID(runID string, force bool, timeoutSeconds int) error {
+	svc, err := createEC2Client()
+	if err != nil {
+		return err
+	}
+
+	instanceIDs, err := findRunInstances(svc, runID)
+	if err != nil {
+		return fmt.Errorf("failed to find instances for run %s: %w", runID, err)
+	}
+	emitEvent("terminate.run_started", map[string]any{"run_id": runID, "instance_ids": instanceIDs, "force": force})
+
+	if len(instanceIDs) == 0 {
+		if outputFormat == "github-actions" {
+			fmt.Printf("Terminated Instances: \n")
+		} else {
+			fmt.Printf("ℹ️  No live runner instances found for run %s\n", runID)
+		}
+		return nil
+	}
+	if outputFormat != "github-actions" {
+		fmt.Printf("🛑 Terminating %d runner instance(s) for run %s: %s\n",
+			len(instanceIDs), runID, strings.Join(instanceIDs, ", "))
+	}
+
+	// One at a time: termination works on package-level state shared with
+	// create and terminate, which isn't safe for concurrent use
+	var errs []error
+	for _, id := range instanceIDs {
+		if err := terminateAndVerify(id, force, timeoutSeconds); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if outputFormat == "github-actions" {
+		fmt.Printf("Terminated Instances: %s\n", strings.Join(instanceIDs, ","))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to terminate %d of %d instance(s) for run %s: %w",
+			len(errs), len(instanceIDs), runID, err)
+	}
+	if outputFormat != "github-actions" {
+		fmt.Printf("✅ Terminated %d runner instance(s) for run %s\n", len(instanceIDs), runID)
+	}
+	return nil
+}
+
+// AuditRecord is a RunInstances call found in CloudTrail
+type AuditRecord struct {
+	EventTime     time.Time `json:"event_time"`
+	EventName     string    `json:"event_name"`
+	InstanceIDs   []string  `json:"instance_ids"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	RunID         string    `json:"run_id,omitempty"`
+	Workflow      string    `json:"workflow,omitempty"`
+	Repository    string    `json:"repository,omitempty"`
+	Principal     string    `json:"principal,omitempty"`
+	SourceIP      string    `json:"source_ip,omitempty"`
+}
+
+// cloudTrailRunInstances is the subset of a RunInstances CloudTrail event we inspect
+type cloudTrailRunInstances struct {
+	UserIdentity struct {
+		ARN string `json:"arn"`
+	} `json:"userIdentity"`
+	SourceIPAddress   string `json:"sourceIPAddress"`
+	RequestParameters struct {
+		ClientToken         string `json:"clientToken"`
+		InstanceType        string `json:"instanceType"`
+		TagSpecificationSet struct {
+			Items []struct {
+				Tags []struct {
+					Key   string `json:"key"`
+					Value string `json:"value"`
+				} `json:"tags"`
+			} `json:"items"`
+		} `json:"tagSpecificationSet"`
+	} `json:"requestParameters"`
+	ResponseElements struct {
+		InstancesSet struct {
+			Items []struct {
+				InstanceID string `json:"instanceId"`
+				Placement  struct {
+					AvailabilityZone string `json:"availabilityZone"`
+				} `json:"placement"`
+			} `json:"items"`
+		} `json:"instancesSet"`
+	} `json:"responseElements"`
+}
+
+// parseAuditRecord converts a CloudTrail event into an audit record
+func parseAuditRecord(event types.Event) (AuditRecord, error) {
+	record := AuditRecord{
+		EventTime: aws.ToTime(event.EventTime),
+		EventName: aws.ToString(event.EventName),
+	}
+
+	var detail cloudTrailRunInstances
+	if err := json.Unmarshal([]byte(aws.ToString(event.CloudTrailEvent)), &detail); err != nil {
+		return record, fmt.Errorf("failed to parse CloudTrail event: %v", err)
+	}
+
+	record.Principal = detail.UserIdentity.ARN
+	record.SourceIP = detail.SourceIPAddress
+	record.CorrelationID = detail.RequestParameters.ClientToken
+	for _, item := range detail.ResponseElements.InstancesSet.Items {
+		record.InstanceIDs = append(record.InstanceIDs, item.InstanceID)
+	}
+	for _, spec := range detail.RequestParameters.TagSpecificationSet.Items {
+		for _, tag := range spec.Tags {
+			switch tag.Key {
+			case "RunId":
+				record.RunID = tag.Value
+			case "Workflow":
+				record.Workflow = tag.Value
+			case "Repository":
+				record.Repository = tag.Value
+			case "CorrelationId":
+				record.CorrelationID = tag.Value
+			}
+		}
+	}
+	return record, nil
+}
+
+// lookupLaunchEvents searches CloudTrail for RunInstances calls matching the
+// workflow run ID or instance ID
+func lookupLaunchEvents(cfg aws.Config, runID, instanceID string, since time.Duration) ([]AuditRecord, error) {
+	input := &cloudtrail.LookupEventsInput{
+		StartTime: aws.Time(time.Now().Add(-since)),
+		EndTime:   aws.Time(time.Now()),
+	}
+	if instanceID != "" {
+		input.LookupAttributes = []types.LookupAttribute{
+			{AttributeKey: types.LookupAttributeKeyResourceName, AttributeValue: aws.String(instanceID)},
+		}
+	} else {
+		input.LookupAttributes = []types.LookupAttribute{
+			{AttributeKey: types.LookupAttributeKeyEventName, AttributeValue: aws.String("RunInstances")},
+		}
+	}
+
+	records := []AuditRecord{}
+	paginator := cloudtrail.NewLookupEventsPaginator(cloudtrail.NewFromConfig(cfg), input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up CloudTrail events: %w", classifyAWSError(err))
+		}
+
+		for _, event := range page.Events {
+			if aws.ToString(event.EventName) != "RunInstances" {
+				continue
+			}
+			record, err := parseAuditRecord(event)
+			if err != nil {
+				return nil, err
+			}
+			if runID != "" && record.RunID != runID &&
+				!strings.HasPrefix(record.CorrelationID, fmt.Sprintf("%s-%s-", correlationPrefix, runID)) {
+				continue
+			}
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit runner launches",
+	Long:  "Answer which workflow run launched which runner instance using CloudTrail",
+}
+
+var auditLookupCmd = &cobra.Command{
+	Use:   "lookup",
+	Short: "Look up RunInstances events for a workflow run or instance",
+	Long:  "Query CloudTrail for the RunInstances calls made for a workflow run ID, or the call that launched an instance",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (auditRunID == "") == (auditInstanceID == "") {
+			return fmt.Errorf("exactly one of run-id or instance-id is required")
+		}
+		if auditSince <= 0 || auditSince > 90*24*time.Hour {
+			return fmt.Errorf("since must be between 0 and 90 days (CloudTrail event history retention)")
+		}
+
+		cfg, err := loadAWSConfig()
+		if err != nil {
+			return err
+		}
+
+		records, err := lookupLaunchEvents(cfg, auditRunID, auditInstanceID, auditSince)
+		if err != nil {
+			return err
+		}
+
+		if auditOutputFormat == "json" {
+			data, err := json.MarshalIndent(records, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode audit records: %v", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(records) == 0 {
+			fmt.Printf("ℹ️  No matching RunInstances events found in the last %s\n", auditSince)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tINSTANCE IDS\tRUN ID\tWORKFLOW\tREPOSITORY\tPRINCIPAL\tSOURCE IP")
+		for _, record := range records {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				formatTime(record.EventTime),
+				strings.Join(record.InstanceIDs, ","),
+				record.RunID,
+				record.Workflow,
+				record.Repository,
+				record.Principal,
+				record.SourceIP,
+			)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	auditLookupCmd.Flags().StringVar(&auditRunID, "run-id", "", "GitHub Actions workflow run ID")
+	auditLookupCmd.Flags().StringVar(&auditInstanceID, "instance-id", "", "EC2 instance ID")
+	auditLookupCmd.Flags().
+		DurationVar(&auditSince, "since", 7*24*time.Hour, "How far back to search CloudTrail event history")
+	auditLookupCmd.Flags().
+		StringVar(&auditOutputFormat, "output-format", "", "Output format (json for machine-readable output)")
+
+	auditCmd.AddCommand(auditLookupCmd)
+	rootCmd.AddCommand(auditCmd)
+}
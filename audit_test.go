@@ -0,0 +1,52 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLaunchCorrelationID(t *testing.T) {
+	derived := regexp.MustCompile(`^ghw-123-[0-9a-f]{16}$`)
+
+	id := launchCorrelationID("123", "myorg/api", "runner-1")
+	if !derived.MatchString(id) {
+		t.Fatalf("launchCorrelationID = %q, want it to match %s", id, derived)
+	}
+	if again := launchCorrelationID("123", "myorg/api", "runner-1"); again != id {
+		t.Errorf("launchCorrelationID is not stable: %q then %q", id, again)
+	}
+
+	others := map[string]string{
+		"another runner":     launchCorrelationID("123", "myorg/api", "runner-2"),
+		"another repository": launchCorrelationID("123", "myorg/web", "runner-1"),
+		"another run":        launchCorrelationID("124", "myorg/api", "runner-1"),
+	}
+	for name, other := range others {
+		if other == id {
+			t.Errorf("launchCorrelationID for %s = %q, want it to differ from %q", name, other, id)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		runID      string
+		runnerName string
+		prefix     string
+	}{
+		{"without a runner name", "123", "", "ghw-123-"},
+		{"without a run ID", "", "runner-1", "ghw-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := launchCorrelationID(tt.runID, "myorg/api", tt.runnerName)
+			second := launchCorrelationID(tt.runID, "myorg/api", tt.runnerName)
+			if !strings.HasPrefix(first, tt.prefix) {
+				t.Errorf("launchCorrelationID = %q, want prefix %q", first, tt.prefix)
+			}
+			if first == second {
+				t.Errorf("launchCorrelationID = %q twice, want random IDs", first)
+			}
+		})
+	}
+}
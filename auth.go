@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/spf13/cobra"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+var (
+	authHostname    string
+	authStore       string
+	authWithToken   bool
+	githubTokenFile string
+)
+
+// keyringService is the OS keychain service name credentials are stored under
+const keyringService = "gh-workflow"
+
+// defaultGitHubHost is the host credentials are stored for unless --hostname is given
+const defaultGitHubHost = "github.com"
+
+// StoredCredential is a GitHub credential saved by `auth login`
+type StoredCredential struct {
+	Host   string    `json:"host"`
+	Token  string    `json:"token"`
+	User   string    `json:"user,omitempty"`
+	Source string    `json:"source,omitempty"`
+	Saved  time.Time `json:"saved"`
+}
+
+// githubAPIBase returns the REST API base URL for a GitHub host
+func githubAPIBase(host string) string {
+	if host == "" || host == defaultGitHubHost {
+		return "https://api.github.com"
+	}
+	return "https://" + host + "/api/v3"
+}
+
+// credentialFilePath returns the location of the age-encrypted credential file
+func credentialFilePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %v", err)
+	}
+	return filepath.Join(dir, "gh-workflow", "credentials.age"), nil
+}
+
+// credentialPassphrase returns the passphrase for the credential file from
+// GH_WORKFLOW_PASSPHRASE, prompting on a terminal when interactive is set
+func credentialPassphrase(interactive bool) (string, error) {
+	if passphrase := os.Getenv("GH_WORKFLOW_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	if !interactive || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("GH_WORKFLOW_PASSPHRASE is required to use the encrypted credential file")
+	}
+
+	fmt.Fprint(os.Stderr, "Credential file passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %v", err)
+	}
+	if len(passphrase) == 0 {
+		return "", fmt.Errorf("passphrase must not be empty")
+	}
+	return string(passphrase), nil
+}
+
+// readCredentialFile decrypts the credential file into credentials keyed by host;
+// a missing file yields an empty map
+func readCredentialFile(interactive bool) (map[string]StoredCredential, error) {
+	credentials := map[string]StoredCredential{}
+
+	path, err := credentialFilePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return credentials, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credential file: %v", err)
+	}
+
+	passphrase, err := credentialPassphrase(interactive)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := age.Decrypt(bytes.NewReader(data), identity)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decrypt credential file: %v", ErrAuth, err)
+	}
+	if err := json.NewDecoder(reader).Decode(&credentials); err != nil {
+		return nil, fmt.Errorf("failed to parse credential file: %v", err)
+	}
+	return credentials, nil
+}
+
+// writeCredentialFile encrypts credentials with the passphrase and writes the file
+func writeCredentialFile(credentials map[string]StoredCredential) error {
+	path, err := credentialFilePath()
+	if err != nil {
+		return err
+	}
+	passphrase, err := credentialPassphrase(true)
+	if err != nil {
+		return err
+	}
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	writer, err := age.Encrypt(&buf, recipient)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credentials: %v", err)
+	}
+	if err := json.NewEncoder(writer).Encode(credentials); err != nil {
+		return fmt.Errorf("failed to encode credentials: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt credentials: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write credential file: %v", err)
+	}
+	return nil
+}
+
+// saveCredential stores a credential in the OS keychain or the encrypted file
+func saveCredential(cred StoredCredential, store string) (string, error) {
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode credential: %v", err)
+	}
+
+	if store == "keychain" || store == "auto" {
+		err := keyring.Set(keyringService, cred.Host, string(data))
+		if err == nil {
+			return "OS keychain", nil
+		}
+		if store == "keychain" {
+			return "", fmt.Errorf("failed to store credential in OS keychain: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "⚠️  OS keychain unavailable (%v), using encrypted file\n", err)
+	}
+
+	credentials, err := readCredentialFile(true)
+	if err != nil {
+		return "", err
+	}
+	credentials[cred.Host] = cred
+	if err := writeCredentialFile(credentials); err != nil {
+		return "", err
+	}
+	path, _ := credentialFilePath()
+	return path, nil
+}
+
+// loadCredential returns the stored credential for host from the OS keychain or
+// the encrypted file, or nil when none is stored
+func loadCredential(host string, interactive bool) (*StoredCredential, error) {
+	if data, err := keyring.Get(keyringService, host); err == nil {
+		var cred StoredCredential
+		if err := json.Unmarshal([]byte(data), &cred); err != nil {
+			return nil, fmt.Errorf("failed to parse keychain credential: %v", err)
+		}
+		return &cred, nil
+	}
+
+	credentials, err := readCredentialFile(interactive)
+	if err != nil {
+		return nil, err
+	}
+	if cred, ok := credentials[host]; ok {
+		return &cred, nil
+	}
+	return nil, nil
+}
+
+// deleteCredential removes the credential for host from every store
+func deleteCredential(host string) (bool, error) {
+	removed := keyring.Delete(keyringService, host) == nil
+
+	credentials, err := readCredentialFile(true)
+	if err != nil {
+		return removed, err
+	}
+	if _, ok := credentials[host]; ok {
+		delete(credentials, host)
+		if err := writeCredentialFile(credentials); err != nil {
+			return removed, err
+		}
+		removed = true
+	}
+	return removed, nil
+}
+
+// githubUser returns the login of the user owning token, verifying it works
+func githubUser(host, token string) (string, error) {
+	resp, body, err := doGitHubRequest("GET", githubAPIBase(host)+"/user", token, nil)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", classifyGitHubResponse(resp, body)
+	}
+
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return "", fmt.Errorf("failed to parse response: %v", err)
+	}
+	return user.Login, nil
+}
+
+// readTokenInput reads a token from stdin, hiding input on a terminal
+func readTokenInput() (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprint(os.Stderr, "Paste your GitHub token: ")
+		token, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token: %v", err)
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read token from stdin: %v", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// readGitHubTokenInput replaces --github-token - with the token on standard
+// input, or fills --github-token from --github-token-file, so wrapping
+// automation can pass the token without it showing up in ps output or shell
+// history
+func readGitHubTokenInput(cmd *cobra.Command) error {
+	// Commands without GitHub access ignore a token file set in the config file
+	tokenFlag := cmd.Flags().Lookup("github-token")
+	if tokenFlag == nil {
+		return nil
+	}
+
+	switch {
+	case githubTokenFile != "" && tokenFlag.Value.String() != "":
+		return fmt.Errorf("github-token and github-token-file cannot be combined")
+	case githubTokenFile != "":
+		data, err := os.ReadFile(githubTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read github-token-file: %v", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return fmt.Errorf("github-token-file %s is empty", githubTokenFile)
+		}
+		return tokenFlag.Value.Set(token)
+	case tokenFlag.Value.String() == "-":
+		token, err := readTokenInput()
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			return fmt.Errorf("no GitHub token on standard input")
+		}
+		return tokenFlag.Value.Set(token)
+	}
+	return nil
+}
+
+// resolveGitHubToken fills an empty --github-token from, in order, GH_TOKEN and
+// friends, the credential saved by `auth login` and the gh CLI's login; none of
+// these override an explicit or configured token
+func resolveGitHubToken(cmd *cobra.Command) error {
+	tokenFlag := cmd.Flags().Lookup("github-token")
+	if tokenFlag == nil || tokenFlag.Value.String() != "" {
+		return nil
+	}
+
+	host := githubHost()
+	if token := envGitHubToken(host); token != "" {
+		return tokenFlag.Value.Set(token)
+	}
+
+	// Missing or locked credentials fall through to the next source
+	if cred, err := loadCredential(host, false); err == nil && cred != nil {
+		return tokenFlag.Value.Set(cred.Token)
+	}
+
+	if token := ghCLIToken(host); token != "" {
+		if outputFormat != "github-actions" {
+			fmt.Fprintf(os.Stderr, "🔑 Using the gh CLI login for %s\n", host)
+		}
+		return tokenFlag.Value.Set(token)
+	}
+	return nil
+}
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage stored GitHub credentials",
+	Long:  "Store a GitHub token locally so interactive commands don't need --github-token",
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Store a GitHub token in the OS keychain or an encrypted file",
+	Long: "Verify a GitHub token and store it in the OS keychain, or in an age-encrypted file " +
+		"(passphrase from GH_WORKFLOW_PASSPHRASE or prompted) when no keychain is available",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if authStore != "auto" && authStore != "keychain" && authStore != "file" {
+			return fmt.Errorf("store must be 'auto', 'keychain' or 'file'")
+		}
+		if authDevice && authWithToken {
+			return fmt.Errorf("device and with-token cannot be used together")
+		}
+
+		var token, source string
+		var err error
+		if authDevice {
+			if authClientID == "" {
+				return fmt.Errorf("client-id (or GH_WORKFLOW_OAUTH_CLIENT_ID) is required with device")
+			}
+			token, err = deviceFlowToken(authHostname, authClientID, authScopes)
+			source = "device"
+		} else {
+			if authWithToken && term.IsTerminal(int(os.Stdin.Fd())) {
+				return fmt.Errorf("with-token expects the token on standard input")
+			}
+			token, err = readTokenInput()
+			source = "token"
+		}
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			return fmt.Errorf("no token provided")
+		}
+
+		user, err := githubUser(authHostname, token)
+		if err != nil {
+			return fmt.Errorf("failed to verify token: %w", err)
+		}
+
+		location, err := saveCredential(StoredCredential{
+			Host:   authHostname,
+			Token:  token,
+			User:   user,
+			Source: source,
+			Saved:  time.Now().UTC(),
+		}, authStore)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Logged in to %s as %s\n", authHostname, user)
+		fmt.Printf("🔐 Credential stored in %s\n", location)
+		return nil
+	},
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the stored GitHub credential",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		removed, err := deleteCredential(authHostname)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			fmt.Printf("ℹ️  No stored credential for %s\n", authHostname)
+			return nil
+		}
+		fmt.Printf("✅ Logged out of %s\n", authHostname)
+		return nil
+	},
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the stored GitHub credential",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cred, err := loadCredential(authHostname, true)
+		if err != nil {
+			return err
+		}
+		if cred == nil {
+			return fmt.Errorf("%w: not logged in to %s", ErrNotFound, authHostname)
+		}
+
+		user, err := githubUser(cred.Host, cred.Token)
+		if err != nil {
+			return fmt.Errorf("stored credential for %s is no longer valid: %w", cred.Host, err)
+		}
+		fmt.Printf("✅ Logged in to %s as %s (stored %s via %s)\n",
+			cred.Host, user, formatTimeRelative(cred.Saved), cred.Source)
+		return nil
+	},
+}
+
+func init() {
+	authCmd.PersistentFlags().StringVar(&authHostname, "hostname", githubHost(), "GitHub host")
+	authLoginCmd.Flags().
+		StringVar(&authStore, "store", "auto", "Where to store the credential (auto, keychain or file)")
+	authLoginCmd.Flags().BoolVar(&authWithToken, "with-token", false, "Read the token from standard input")
+	rootCmd.PersistentFlags().StringVar(&githubTokenFile, "github-token-file", "",
+		"Read the GitHub token from this file (or pass --github-token - to read it from standard input)")
+
+	authCmd.AddCommand(authLoginCmd)
+	authCmd.AddCommand(authLogoutCmd)
+	authCmd.AddCommand(authStatusCmd)
+	rootCmd.AddCommand(authCmd)
+}
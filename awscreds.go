@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+var (
+	assumeRoleARN        string
+	assumeRoleExtID      string
+	roleSessionName      string
+	webIdentity          bool
+	webIdentityTokenFile string
+	oidcAudience         string
+	awsEndpointURL       string
+	awsProfile           string
+)
+
+// iamRoleARNRegex matches IAM role ARNs in any partition
+var iamRoleARNRegex = regexp.MustCompile(`^arn:aws[\w-]*:iam::\d{12}:role/[\w+=,.@/-]+$`)
+
+// roleSessionNameRegex matches the session names STS accepts
+var roleSessionNameRegex = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+
+// validateAssumeRoleFlags checks the --assume-role-arn flags before any AWS call
+func validateAssumeRoleFlags() error {
+	if webIdentityTokenFile != "" {
+		webIdentity = true
+	}
+	if assumeRoleARN == "" {
+		if assumeRoleExtID != "" {
+			return fmt.Errorf("external-id requires assume-role-arn")
+		}
+		if webIdentity {
+			return fmt.Errorf("web-identity requires assume-role-arn")
+		}
+		return nil
+	}
+	if webIdentity && assumeRoleExtID != "" {
+		return fmt.Errorf("external-id cannot be used with web-identity")
+	}
+	if !iamRoleARNRegex.MatchString(assumeRoleARN) {
+		return fmt.Errorf("invalid assume-role-arn %q (expected arn:aws:iam::<account>:role/<name>)", assumeRoleARN)
+	}
+	if !roleSessionNameRegex.MatchString(roleSessionName) {
+		return fmt.Errorf("invalid role-session-name %q (2-64 letters, digits or +=,.@_-)", roleSessionName)
+	}
+	return nil
+}
+
+// selectedAWSProfile returns the shared config profile chosen with
+// --aws-profile or AWS_PROFILE, or "" for the default chain
+func selectedAWSProfile() string {
+	if awsProfile != "" {
+		return awsProfile
+	}
+	return os.Getenv("AWS_PROFILE")
+}
+
+// profileRegion returns the region of the selected shared config profile, if any
+func profileRegion() string {
+	profile := selectedAWSProfile()
+	if profile == "" {
+		return ""
+	}
+	shared, err := config.LoadSharedConfigProfile(context.TODO(), profile)
+	if err != nil {
+		return ""
+	}
+	return shared.Region
+}
+
+// validateAWSEndpointURL checks --aws-endpoint-url, which replaces the endpoint
+// of every AWS service (e.g. LocalStack at http://localhost:4566)
+func validateAWSEndpointURL() error {
+	if awsEndpointURL == "" {
+		return nil
+	}
+	parsed, err := url.Parse(awsEndpointURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return fmt.Errorf("aws-endpoint-url must be an http(s) URL such as http://localhost:4566, got %q",
+			awsEndpointURL)
+	}
+	return nil
+}
+
+// webIdentityToken supplies the web identity token: the --web-identity-token-file
+// contents, or else a GitHub Actions OIDC token, requested anew on every refresh
+// as they expire after a few minutes
+type webIdentityToken struct{}
+
+func (webIdentityToken) GetIdentityToken() ([]byte, error) {
+	if webIdentityTokenFile != "" {
+		token, err := os.ReadFile(webIdentityTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read web identity token: %v", err)
+		}
+		return []byte(strings.TrimSpace(string(token))), nil
+	}
+	return githubOIDCToken(oidcAudience)
+}
+
+// githubOIDCToken requests an OIDC token for audience from GitHub Actions,
+// which needs the job's "id-token: write" permission
+func githubOIDCToken(audience string) ([]byte, error) {
+	requestURL, requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"), os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL == "" || requestToken == "" {
+		return nil, fmt.Errorf("%w: no GitHub Actions OIDC token available (give the job the id-token: write "+
+			"permission, or pass --web-identity-token-file)", ErrAuth)
+	}
+
+	req, err := http.NewRequest("GET", requestURL+"&audience="+url.QueryEscape(audience), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+	req.Header.Set("Accept", "application/json")
+	resp, err := githubHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request GitHub Actions OIDC token: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: GitHub Actions OIDC token request returned status %d: %s",
+			ErrAuth, resp.StatusCode, string(body))
+	}
+
+	var token struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.Value == "" {
+		return nil, fmt.Errorf("failed to parse GitHub Actions OIDC token response")
+	}
+	return []byte(token.Value), nil
+}
+
+// credentialsRefreshWindow is how long before they expire temporary
+// credentials are refreshed, so requests are not signed with credentials
+// that run out in flight
+const credentialsRefreshWindow = time.Minute
+
+// refreshingCredentials caches temporary credentials and fetches new ones
+// shortly before they expire, e.g. during an hour-long termination wait
+func refreshingCredentials(provider aws.CredentialsProvider) *aws.CredentialsCache {
+	return aws.NewCredentialsCache(provider, func(o *aws.CredentialsCacheOptions) {
+		o.ExpiryWindow = credentialsRefreshWindow
+	})
+}
+
+// withAssumedRole returns cfg unchanged, or a copy that uses credentials from
+// assuming --assume-role-arn, e.g. to launch runners in a member account from a
+// central CI account; with --web-identity the role is assumed with a web
+// identity token instead of the AWS credentials, so none need to be stored
+func withAssumedRole(cfg aws.Config) aws.Config {
+	if assumeRoleARN == "" {
+		return cfg
+	}
+	if !webIdentity {
+		return assumeRoleConfig(cfg, assumeRoleARN, assumeRoleExtID, roleSessionName)
+	}
+
+	assumed := cfg.Copy()
+	provider := stscreds.NewWebIdentityRoleProvider(sts.NewFromConfig(cfg), assumeRoleARN, webIdentityToken{},
+		func(o *stscreds.WebIdentityRoleOptions) {
+			o.RoleSessionName = roleSessionName
+		})
+	assumed.Credentials = refreshingCredentials(provider)
+	return assumed
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&assumeRoleARN, "assume-role-arn", "", "IAM role ARN to assume before calling AWS")
+	flags.StringVar(&assumeRoleExtID, "external-id", "", "External ID required by the role's trust policy")
+	flags.StringVar(&roleSessionName, "role-session-name", "gh-workflow", "Session name of the assumed role")
+	flags.BoolVar(&webIdentity, "web-identity", false,
+		"Assume --assume-role-arn with a web identity token (the GitHub Actions OIDC token by default)")
+	flags.StringVar(&webIdentityTokenFile, "web-identity-token-file", "",
+		"File holding the web identity token, instead of requesting one from GitHub Actions")
+	flags.StringVar(&oidcAudience, "oidc-audience", "sts.amazonaws.com", "Audience of the GitHub Actions OIDC token")
+	flags.StringVar(&awsProfile, "aws-profile", "",
+		"Profile of the AWS shared config files, including SSO profiles (default: AWS_PROFILE)")
+	flags.StringVar(&awsEndpointURL, "aws-endpoint-url", "",
+		"Send every AWS call to this endpoint, e.g. LocalStack (default: AWS_ENDPOINT_URL or the AWS endpoints)")
+}
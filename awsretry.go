@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+var awsMaxAttempts int
+
+// awsMaxBackoff caps the jittered exponential backoff between AWS attempts
+const awsMaxBackoff = 20 * time.Second
+
+// reportingRetryer reports every retry of an AWS call, like GitHub API retries
+type reportingRetryer struct {
+	aws.RetryerV2
+}
+
+func (r reportingRetryer) RetryDelay(attempt int, err error) (time.Duration, error) {
+	delay, delayErr := r.RetryerV2.RetryDelay(attempt, err)
+	if delayErr != nil {
+		return delay, delayErr
+	}
+	reason := awsErrorCode(err)
+	if reason == "" {
+		reason = err.Error()
+	}
+	emitEvent("aws.retry", map[string]any{"attempt": attempt, "reason": reason, "delay": delay.String()})
+	if outputFormat != "github-actions" {
+		fmt.Fprintf(os.Stderr, "⏳ AWS call failed (%s), retrying in %s (attempt %d/%d)...\n",
+			reason, delay.Round(time.Millisecond), attempt+1, r.MaxAttempts())
+	}
+	return delay, nil
+}
+
+// newAWSRetryer returns the retryer of AWS calls: adaptive mode, which backs
+// off exponentially with jitter and slows down the whole client while AWS
+// throttles it, so a burst of RequestLimitExceeded from parallel launches of
+// RunInstances, DescribeInstances or TerminateInstances is ridden out
+func newAWSRetryer() aws.Retryer {
+	return reportingRetryer{retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+		o.StandardOptions = append(o.StandardOptions, func(so *retry.StandardOptions) {
+			so.MaxAttempts = awsMaxAttempts
+			so.MaxBackoff = awsMaxBackoff
+			so.Retryables = append(so.Retryables, retry.IsErrorRetryableFunc(isRetryableAWSError))
+		})
+	})}
+}
+
+// validateAWSRetryFlags checks --aws-max-attempts
+func validateAWSRetryFlags() error {
+	if awsMaxAttempts < 1 {
+		return fmt.Errorf("aws-max-attempts must be at least 1")
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().IntVar(&awsMaxAttempts, "aws-max-attempts", 8,
+		"Attempts of each AWS call, retrying throttling and transient errors with jittered exponential backoff")
+}
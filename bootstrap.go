@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// bootstrapStatusTag is the instance tag the user data writes its final status
+// to, when the AMI has the AWS CLI and the instance profile allows
+// ec2:CreateTags on the instance itself
+const bootstrapStatusTag = "BootstrapStatus"
+
+// bootstrapStatusPrefix starts the console line and tag value of the final
+// bootstrap status, followed by OK or FAILED: <summary>
+const bootstrapStatusPrefix = "GH-WORKFLOW-BOOTSTRAP: "
+
+// Console lines of a bootstrap that started the runner, and that failed
+// before the runner was registered
+const (
+	bootstrapOKMarker     = bootstrapStatusPrefix + "OK"
+	bootstrapFailedMarker = bootstrapStatusPrefix + "FAILED"
+)
+
+// bootstrapStatusUserData returns the shell functions the bootstrap steps use
+// to report the final status on the console and in the BootstrapStatus tag
+func bootstrapStatusUserData() []string {
+	return []string{
+		"# Report the final bootstrap status on the console and in the " + bootstrapStatusTag + " tag",
+		"bootstrap_status() {",
+		fmt.Sprintf(`    echo "%s$1"`, bootstrapStatusPrefix),
+		"    if command -v aws >/dev/null; then",
+		"        local IMDS=http://169.254.169.254/latest TOKEN IID REGION",
+		"        TOKEN=$(curl -s -X PUT $IMDS/api/token -H 'X-aws-ec2-metadata-token-ttl-seconds: 60')",
+		"        IID=$(curl -s -H \"X-aws-ec2-metadata-token: $TOKEN\" $IMDS/meta-data/instance-id)",
+		"        REGION=$(curl -s -H \"X-aws-ec2-metadata-token: $TOKEN\" $IMDS/meta-data/placement/region)",
+		"        aws ec2 create-tags --region \"$REGION\" --resources \"$IID\" \\",
+		fmt.Sprintf(`            --tags "[{\"Key\":\"%s\",\"Value\":\"${1//\"/}\"}]" >/dev/null 2>&1 || true`,
+			bootstrapStatusTag),
+		"    fi",
+		"}",
+		"bootstrap_failed() {",
+		`    bootstrap_status "FAILED: $1"`,
+		"    exit 1",
+		"}",
+		"",
+	}
+}
+
+// bootstrapFailure returns the summary of a failed bootstrap reported by the
+// instance, or "" while it has not failed; the tag is read first, then the
+// console output for AMIs without the AWS CLI, which is best effort as it
+// lags behind and needs ec2:GetConsoleOutput
+func bootstrapFailure(svc *ec2.Client, instanceID string) (string, error) {
+	result, err := svc.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe instance %s: %w", instanceID, classifyAWSError(err))
+	}
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			for _, tag := range instance.Tags {
+				if aws.ToString(tag.Key) == bootstrapStatusTag {
+					value := bootstrapStatusPrefix + aws.ToString(tag.Value)
+					if value == bootstrapOKMarker {
+						return "", nil
+					}
+					return strings.TrimPrefix(value, bootstrapFailedMarker+": "), nil
+				}
+			}
+		}
+	}
+
+	output, err := svc.GetConsoleOutput(context.TODO(), &ec2.GetConsoleOutputInput{
+		InstanceId: aws.String(instanceID),
+		Latest:     aws.Bool(true),
+	})
+	if err != nil {
+		err = classifyAWSError(err)
+		if errors.Is(err, ErrAuth) || errors.Is(err, ErrNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read console output of %s: %w", instanceID, err)
+	}
+	console, err := base64.StdEncoding.DecodeString(aws.ToString(output.Output))
+	if err != nil {
+		return "", nil
+	}
+	for _, line := range strings.Split(string(console), "\n") {
+		if _, rest, found := strings.Cut(line, bootstrapFailedMarker+": "); found {
+			return strings.TrimSpace(rest), nil
+		}
+	}
+	return "", nil
+}
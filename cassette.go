@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	recordPath string
+	replayPath string
+)
+
+// cassetteVersion is the format of cassette files
+const cassetteVersion = 1
+
+// Interaction is a recorded API request and its response; the request is
+// matched on Key, and secrets are scrubbed from both bodies
+type Interaction struct {
+	Key         string            `json:"key"`
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	RequestBody string            `json:"request_body,omitempty"`
+	Status      int               `json:"status"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        string            `json:"body"`
+}
+
+// Cassette holds the AWS and GitHub API interactions of a command, in order
+type Cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// cassetteHeaders are the response headers kept in a cassette; the retry and
+// pagination logic depends on them
+var cassetteHeaders = []string{
+	"Content-Type", "Link", "Retry-After", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-Amzn-Requestid",
+}
+
+// cassetteSecrets match secrets in request and response bodies; the first
+// group is kept and the rest replaced. User data carries registration and
+// removal tokens, whether sent to RunInstances, a launch template
+// (LaunchTemplateData.UserData) or ModifyInstanceAttribute (UserData.Value),
+// or read back with DescribeInstanceAttribute
+var cassetteSecrets = []*regexp.Regexp{
+	regexp.MustCompile(`("(?:token|access_token|refresh_token|client_secret|password|secret|SecretAccessKey|` +
+		`SessionToken)"\s*:\s*)"[^"]*"`),
+	regexp.MustCompile(`(<(?:SecretAccessKey|SessionToken)>)[^<]*`),
+	regexp.MustCompile(`((?:^|&)(?:[\w.]+\.)?(?:UserData(?:\.Value)?|WebIdentityToken)=)[^&]*`),
+	regexp.MustCompile(`(<userData>\s*(?:<value>)?)[^<]*`),
+	regexp.MustCompile(`()eyJ[\w-]+\.[\w-]+\.[\w-]+`),
+}
+
+// scrubSecrets replaces the secrets in a request or response body
+func scrubSecrets(body string) string {
+	for _, pattern := range cassetteSecrets {
+		body = pattern.ReplaceAllStringFunc(body, func(match string) string {
+			kept := pattern.FindStringSubmatch(match)[1]
+			if strings.HasSuffix(strings.TrimSpace(kept), ":") {
+				return kept + `"REDACTED"`
+			}
+			return kept + "REDACTED"
+		})
+	}
+	return body
+}
+
+// interactionKey identifies a request independently of credentials, signatures
+// and timestamps: the method, service host label, path, query and AWS action
+func interactionKey(req *http.Request, body []byte) string {
+	service, _, _ := strings.Cut(req.URL.Host, ".")
+	key := req.Method + " " + service + req.URL.Path
+	if query := req.URL.Query().Encode(); query != "" {
+		key += "?" + query
+	}
+	if target := req.Header.Get("X-Amz-Target"); target != "" {
+		return key + " " + target
+	}
+	if form, err := url.ParseQuery(string(body)); err == nil && form.Get("Action") != "" {
+		return key + " " + form.Get("Action")
+	}
+	return key
+}
+
+// cassetteTransport records the interactions that pass through it to a
+// cassette file, or answers requests from a recorded cassette
+type cassetteTransport struct {
+	next      http.RoundTripper
+	path      string
+	recording bool
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// activeCassette is the transport of --record or --replay, if given
+var activeCassette *cassetteTransport
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.exchange(req, t.next.RoundTrip)
+}
+
+// cassetteAWSClient passes the calls of the AWS SDK through a cassette; the
+// SDK keeps building its own client, which adds the custom CA bundle
+type cassetteAWSClient struct {
+	next     aws.HTTPClient
+	cassette *cassetteTransport
+}
+
+func (c cassetteAWSClient) Do(req *http.Request) (*http.Response, error) {
+	return c.cassette.exchange(req, c.next.Do)
+}
+
+// exchange records the request and the response of send, or replays them
+func (t *cassetteTransport) exchange(req *http.Request,
+	send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	key := interactionKey(req, body)
+
+	if !t.recording {
+		return t.replay(req, key)
+	}
+
+	resp, err := send(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction{
+		Key:         key,
+		Method:      req.Method,
+		URL:         req.URL.Scheme + "://" + req.URL.Host + req.URL.Path,
+		RequestBody: scrubSecrets(string(body)),
+		Status:      resp.StatusCode,
+		Headers:     map[string]string{},
+		Body:        scrubSecrets(string(respBody)),
+	}
+	for _, name := range cassetteHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			interaction.Headers[name] = value
+		}
+	}
+
+	// The file is rewritten after every interaction so a failed command still leaves its trace
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	if err := t.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+	}
+	return resp, nil
+}
+
+// replay answers a request with the first unused interaction recorded for its key
+func (t *cassetteTransport) replay(req *http.Request, key string) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, interaction := range t.cassette.Interactions {
+		if t.used[i] || interaction.Key != key {
+			continue
+		}
+		t.used[i] = true
+		header := http.Header{}
+		for name, value := range interaction.Headers {
+			header.Set(name, value)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+			StatusCode:    interaction.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(interaction.Body)),
+			ContentLength: int64(len(interaction.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("replay: no recorded interaction left for %s in %s", key, t.path)
+}
+
+// save writes the cassette file; the caller holds t.mu
+func (t *cassetteTransport) save() error {
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(t.cassette); err != nil {
+		return fmt.Errorf("failed to encode cassette: %v", err)
+	}
+	if err := os.WriteFile(t.path, data.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write cassette %s: %v", t.path, err)
+	}
+	return nil
+}
+
+// setupCassette installs the --record or --replay transport for AWS and GitHub calls
+func setupCassette() error {
+	if recordPath != "" && replayPath != "" {
+		return fmt.Errorf("record and replay cannot be combined")
+	}
+	switch {
+	case recordPath != "":
+		activeCassette = &cassetteTransport{
+			next:      http.DefaultTransport,
+			path:      recordPath,
+			recording: true,
+			cassette:  Cassette{Version: cassetteVersion, Interactions: []Interaction{}},
+		}
+	case replayPath != "":
+		data, err := os.ReadFile(replayPath)
+		if err != nil {
+			return fmt.Errorf("failed to read cassette %s: %v", replayPath, err)
+		}
+		var cassette Cassette
+		if err := json.Unmarshal(data, &cassette); err != nil {
+			return fmt.Errorf("failed to parse cassette %s: %v", replayPath, err)
+		}
+		if cassette.Version != cassetteVersion {
+			return fmt.Errorf("cassette %s has unsupported version %d", replayPath, cassette.Version)
+		}
+		activeCassette = &cassetteTransport{
+			path:     replayPath,
+			cassette: cassette,
+			used:     make([]bool, len(cassette.Interactions)),
+		}
+	default:
+		return nil
+	}
+	githubHTTPClient.Transport = activeCassette
+	return nil
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&recordPath, "record", "",
+		"Record the AWS and GitHub API calls to this cassette file, with secrets scrubbed")
+	flags.StringVar(&replayPath, "replay", "",
+		"Answer the AWS and GitHub API calls from this cassette file instead of calling the APIs")
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+var keepVolumes bool
+
+// auxResourceTagPrefix prefixes the instance tags recording auxiliary resources;
+// the full key is <prefix><type>/<id> and the value holds type-specific detail
+const auxResourceTagPrefix = "gh-workflow:resource/"
+
+// Auxiliary resource types deleted when their runner instance terminates
+const (
+	auxVolume       = "volume"
+	auxEIP          = "eip"
+	auxSSMParameter = "ssm-parameter"
+	auxDNSRecord    = "dns-record"
+	auxAlarm        = "alarm"
+)
+
+// AuxResource is a resource created for a runner instance besides the instance
+// itself; DNS records use "<type>/<name>" as ID and the hosted zone ID as Detail
+type AuxResource struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// auxResourceTag returns the instance tag recording an auxiliary resource
+func auxResourceTag(resource AuxResource) types.Tag {
+	return types.Tag{
+		Key:   aws.String(auxResourceTagPrefix + resource.Type + "/" + resource.ID),
+		Value: aws.String(resource.Detail),
+	}
+}
+
+// auxResourcesFromInstance returns the auxiliary resources recorded in an instance's tags
+func auxResourcesFromInstance(instance types.Instance) []AuxResource {
+	resources := []AuxResource{}
+	for _, tag := range instance.Tags {
+		rest, ok := strings.CutPrefix(aws.ToString(tag.Key), auxResourceTagPrefix)
+		if !ok {
+			continue
+		}
+		kind, id, ok := strings.Cut(rest, "/")
+		if !ok || id == "" {
+			continue
+		}
+		resources = append(resources, AuxResource{Type: kind, ID: id, Detail: aws.ToString(tag.Value)})
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Type+resources[i].ID < resources[j].Type+resources[j].ID
+	})
+	return resources
+}
+
+// appendAuxResource appends a resource unless it is already in the list
+func appendAuxResource(resources []AuxResource, resource AuxResource) []AuxResource {
+	for _, existing := range resources {
+		if existing.Type == resource.Type && existing.ID == resource.ID {
+			return resources
+		}
+	}
+	return append(resources, resource)
+}
+
+// trackAuxResource records an auxiliary resource on its runner instance so
+// terminate can find and delete it, even without the manifest file
+func trackAuxResource(svc *ec2.Client, instanceID string, resource AuxResource) error {
+	_, err := svc.CreateTags(context.TODO(), &ec2.CreateTagsInput{
+		Resources: []string{instanceID},
+		Tags:      []types.Tag{auxResourceTag(resource)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record %s %s on instance %s: %w",
+			resource.Type, resource.ID, instanceID, classifyAWSError(err))
+	}
+	return nil
+}
+
+// finishTermination waits for the instance to terminate, then deletes its
+// auxiliary resources (volumes can only be deleted once detached)
+func finishTermination(svc *ec2.Client, instanceID string, timeoutSeconds int, resources []AuxResource) error {
+	if err := waitForInstanceTermination(svc, instanceID, timeoutSeconds); err != nil {
+		return err
+	}
+	return deleteAuxResources(resources, keepVolumes)
+}
+
+// findDNSRecord returns the record set with the given name and type in a
+// hosted zone, or nil if there is none
+func findDNSRecord(client *route53.Client, zoneID, name, recordType string) (*route53types.ResourceRecordSet, error) {
+	result, err := client.ListResourceRecordSets(context.TODO(), &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(zoneID),
+		StartRecordName: aws.String(name),
+		StartRecordType: route53types.RRType(recordType),
+		MaxItems:        aws.Int32(1),
+	})
+	if err != nil {
+		return nil, classifyAWSError(err)
+	}
+
+	for _, record := range result.ResourceRecordSets {
+		if strings.TrimSuffix(aws.ToString(record.Name), ".") == strings.TrimSuffix(name, ".") &&
+			string(record.Type) == recordType {
+			return &record, nil
+		}
+	}
+	return nil, nil
+}
+
+// deleteDNSRecord deletes the record set with the given name and type from a hosted zone
+func deleteDNSRecord(client *route53.Client, zoneID, name, recordType string) error {
+	record, err := findDNSRecord(client, zoneID, name, recordType)
+	if err != nil || record == nil {
+		// A record that is already gone is deleted
+		return err
+	}
+	_, err = client.ChangeResourceRecordSets(context.TODO(), &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+		ChangeBatch: &route53types.ChangeBatch{
+			Changes: []route53types.Change{
+				{Action: route53types.ChangeActionDelete, ResourceRecordSet: record},
+			},
+		},
+	})
+	return classifyAWSError(err)
+}
+
+// deleteAuxResources deletes the auxiliary resources of a terminated instance,
+// keeping volumes when keepVolumes is set; resources that no longer exist are
+// treated as deleted
+func deleteAuxResources(resources []AuxResource, keepVolumes bool) error {
+	if len(resources) == 0 {
+		return nil
+	}
+
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	return deleteAuxResourcesWithConfig(cfg, resources, keepVolumes)
+}
+
+// deleteAuxResourcesWithConfig deletes auxiliary resources using the given AWS
+// configuration, e.g. an assumed role in another fleet account
+func deleteAuxResourcesWithConfig(cfg aws.Config, resources []AuxResource, keepVolumes bool) error {
+	svc := ec2.NewFromConfig(cfg)
+
+	var errs []error
+	for _, resource := range resources {
+		var err error
+		switch resource.Type {
+		case auxVolume:
+			if keepVolumes {
+				if outputFormat != "github-actions" {
+					fmt.Printf("💾 Keeping volume %s\n", resource.ID)
+				}
+				continue
+			}
+			_, err = svc.DeleteVolume(context.TODO(), &ec2.DeleteVolumeInput{VolumeId: aws.String(resource.ID)})
+		case auxEIP:
+			_, err = svc.ReleaseAddress(context.TODO(), &ec2.ReleaseAddressInput{
+				AllocationId: aws.String(resource.ID),
+			})
+		case auxSSMParameter:
+			_, err = ssm.NewFromConfig(cfg).DeleteParameter(context.TODO(), &ssm.DeleteParameterInput{
+				Name: aws.String(resource.ID),
+			})
+		case auxDNSRecord:
+			recordType, name, _ := strings.Cut(resource.ID, "/")
+			err = deleteDNSRecord(route53.NewFromConfig(cfg), resource.Detail, name, recordType)
+		case auxAlarm:
+			err = deleteAlarm(cfg, resource.ID)
+		default:
+			err = fmt.Errorf("unknown resource type")
+		}
+
+		if err != nil && !errors.Is(classifyAWSError(err), ErrNotFound) {
+			errs = append(errs, fmt.Errorf("failed to delete %s %s: %w", resource.Type, resource.ID, err))
+			continue
+		}
+		emitEvent("resource.deleted", map[string]any{"type": resource.Type, "id": resource.ID})
+		if outputFormat != "github-actions" {
+			fmt.Printf("🧹 Deleted %s %s\n", resource.Type, resource.ID)
+		}
+	}
+	return errors.Join(errs...)
+}
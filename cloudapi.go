@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// providerRunnerTag marks the machines created by the REST API providers, which
+// list runners by tag
+const providerRunnerTag = "gh-workflow"
+
+// cloudAPIClient calls the JSON REST API of a cloud with a bearer token
+type cloudAPIClient struct {
+	name    string
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func newCloudAPIClient(name, baseURL, token string) *cloudAPIClient {
+	return &cloudAPIClient{name: name, baseURL: baseURL, token: token, client: &http.Client{Timeout: 60 * time.Second}}
+}
+
+// request sends an API request, JSON-encoding payload if it is not nil, and
+// decodes the response into result if the status is the expected one
+func (c *cloudAPIClient) request(method, path string, headers map[string]string, payload, result any,
+	expectedStatus int) error {
+	var reqBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	if resp.StatusCode != expectedStatus {
+		err := fmt.Errorf("%s API returned status %d: %s", c.name, resp.StatusCode, string(body))
+		switch resp.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Errorf("%w: %w", ErrAuth, err)
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %w", ErrNotFound, err)
+		case http.StatusTooManyRequests:
+			return fmt.Errorf("%w: %w", ErrThrottle, err)
+		}
+		return err
+	}
+	if result != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, result); err != nil {
+			return fmt.Errorf("failed to parse response: %v", err)
+		}
+	}
+	return nil
+}
+
+// requireSizeAndImage returns the machine size and image of a spec, which the
+// REST API providers need
+func requireSizeAndImage(provider string, spec ProviderSpec) (string, string, error) {
+	if spec.InstanceType == "" || spec.Image == "" {
+		return "", "", fmt.Errorf("%s provider needs --instance-type and --image-id", provider)
+	}
+	return spec.InstanceType, spec.Image, nil
+}
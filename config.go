@@ -0,0 +1,380 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	configPath     string
+	configProfile  string
+	configRepo     string
+	configResolved bool
+)
+
+// configFileName is looked up in the working directory when --config is not given
+const configFileName = ".gh-workflow.yml"
+
+// Settings maps flag names (e.g. "instance-type") to their configured values
+type Settings map[string]any
+
+// Config is the on-disk configuration: org-wide defaults, hierarchical
+// profiles (e.g. "team" and "team/backend") and per-repository overrides
+type Config struct {
+	Version      int                    `yaml:"version,omitempty"`
+	Defaults     Settings               `yaml:"defaults,omitempty"`
+	Profiles     map[string]Settings    `yaml:"profiles,omitempty"`
+	Repos        map[string]Settings    `yaml:"repos,omitempty"`
+	Hooks        Hooks                  `yaml:"hooks,omitempty"`
+	Accounts     []FleetAccount         `yaml:"accounts,omitempty"`
+	Organization *OrganizationDiscovery `yaml:"organization,omitempty"`
+	Telemetry    *Telemetry             `yaml:"telemetry,omitempty"`
+	History      *History               `yaml:"history,omitempty"`
+}
+
+// activeConfig is the config file loaded for the current command, if any
+var activeConfig *Config
+
+// resolvedSetting is a single effective setting and the layer it came from
+type resolvedSetting struct {
+	Value  string
+	Source string
+	// Items holds the elements of a list value, for repeatable flags
+	Items []string
+}
+
+// findConfigFile returns the config file to load, or "" when none exists
+func findConfigFile() (string, error) {
+	if configPath != "" {
+		if _, err := os.Stat(configPath); err != nil {
+			return "", fmt.Errorf("config file %s: %w", configPath, err)
+		}
+		return configPath, nil
+	}
+
+	candidates := []string{}
+	if env := os.Getenv("GH_WORKFLOW_CONFIG"); env != "" {
+		candidates = append(candidates, env)
+	}
+	candidates = append(candidates, configFileName)
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return userConfigFile(), nil
+}
+
+// userConfigFile returns the config file in the user config directory, or ""
+// when there is none
+func userConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(dir, "gh-workflow", "config.yml")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// foundInWorkingDir reports whether path was picked up from the working
+// directory rather than chosen with --config or $GH_WORKFLOW_CONFIG
+func foundInWorkingDir(path string) bool {
+	return configPath == "" && path == configFileName && os.Getenv("GH_WORKFLOW_CONFIG") != configFileName
+}
+
+// warnIgnoredConfig tells which sections of a config file found in the
+// working directory were not applied
+func warnIgnoredConfig(path string) {
+	ignored := []string{}
+	var sections map[string]any
+	if data, err := os.ReadFile(path); err == nil && yaml.Unmarshal(data, &sections) == nil {
+		for section, value := range sections {
+			switch settings, ok := value.(map[string]any); {
+			case section == "version":
+			case section == "defaults" && ok:
+				for key := range settings {
+					ignored = append(ignored, "defaults."+key)
+				}
+			default:
+				ignored = append(ignored, section)
+			}
+		}
+	}
+	sort.Strings(ignored)
+	fmt.Fprintf(os.Stderr, "⚠️  Ignoring %s found in the working directory (%s); "+
+		"pass it with --config %s to use it\n", path, strings.Join(ignored, ", "), path)
+}
+
+// loadConfig reads and parses the config file at path
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	var cfg Config
+	if doc.Kind == 0 {
+		return &cfg, nil
+	}
+
+	// Older files keep working; their settings are renamed in memory
+	version, _, changed, err := migrateConfigDocument(doc.Content[0])
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	if changed {
+		fmt.Fprintf(os.Stderr, "⚠️  Config file %s is version %d; run `gh-workflow config migrate` to update it\n",
+			path, version)
+	}
+	if err := doc.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	return &cfg, nil
+}
+
+// profileChain expands "team/backend" into ["team", "team/backend"]
+func profileChain(profile string) []string {
+	if profile == "" {
+		return nil
+	}
+
+	parts := strings.Split(strings.Trim(profile, "/"), "/")
+	chain := make([]string, 0, len(parts))
+	for i := range parts {
+		chain = append(chain, strings.Join(parts[:i+1], "/"))
+	}
+	return chain
+}
+
+// resolve merges defaults, the profile chain and the repository overrides,
+// later layers taking precedence over earlier ones
+func (c *Config) resolve(profile, repo string) (map[string]resolvedSetting, error) {
+	resolved := map[string]resolvedSetting{}
+	merge := func(settings Settings, source string) {
+		for key, value := range settings {
+			resolved[key] = resolvedSetting{Value: settingString(value), Source: source, Items: settingItems(value)}
+		}
+	}
+
+	merge(c.Defaults, "defaults")
+
+	if profile != "" {
+		if _, ok := c.Profiles[profile]; !ok {
+			return nil, fmt.Errorf("%w: profile %q is not defined in the config file", ErrNotFound, profile)
+		}
+		for _, name := range profileChain(profile) {
+			merge(c.Profiles[name], "profile "+name)
+		}
+	}
+
+	if repo != "" {
+		merge(c.Repos[repo], "repo "+repo)
+	}
+
+	return resolved, nil
+}
+
+// settingString converts a YAML value to its flag string form; lists become comma-separated
+func settingString(value any) string {
+	if list, ok := value.([]any); ok {
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// settingItems returns the elements of a YAML list value, or nil for other values
+func settingItems(value any) []string {
+	list, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		items = append(items, fmt.Sprint(item))
+	}
+	return items
+}
+
+// configRepoFor returns the owner/name used to select repository overrides
+func configRepoFor(cmd *cobra.Command, layered map[string]resolvedSetting) string {
+	if configRepo != "" {
+		return configRepo
+	}
+
+	lookup := func(name string) string {
+		if flag := cmd.Flags().Lookup(name); flag != nil && flag.Changed {
+			return flag.Value.String()
+		}
+		return layered[name].Value
+	}
+
+	owner, name := lookup("repo-owner"), lookup("repo-name")
+	if owner != "" && name != "" {
+		return owner + "/" + name
+	}
+	return os.Getenv("GITHUB_REPOSITORY")
+}
+
+// applyConfig fills flags that were not set on the command line from the
+// resolved configuration
+func applyConfig(cmd *cobra.Command) error {
+	path, err := findConfigFile()
+	if err != nil {
+		return err
+	}
+	if foundInWorkingDir(path) {
+		// A checked-out repository could send credentials to its own endpoints
+		// or run its own hooks, so its config file must be chosen explicitly
+		warnIgnoredConfig(path)
+		path = userConfigFile()
+	}
+	if path == "" {
+		if configProfile != "" {
+			return fmt.Errorf("--profile %q requires a config file (--config, $GH_WORKFLOW_CONFIG or %s)",
+				configProfile, filepath.Join("~", ".config", "gh-workflow", "config.yml"))
+		}
+		return nil
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	activeConfig = cfg
+
+	layered, err := cfg.resolve(configProfile, "")
+	if err != nil {
+		return err
+	}
+	resolved, err := cfg.resolve(configProfile, configRepoFor(cmd, layered))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		setting, ok := resolved[flag.Name]
+		if !ok || flag.Changed {
+			return
+		}
+		// Repeatable flags such as --volume and --tag take a list item by item, as items may contain commas
+		slice, ok := flag.Value.(pflag.SliceValue)
+		if ok && setting.Items != nil && flag.Value.Type() == "stringArray" {
+			if err := slice.Replace(setting.Items); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value for %s from %s: %v", flag.Name, setting.Source, err))
+			}
+			return
+		}
+		if err := flag.Value.Set(setting.Value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value for %s from %s: %v", flag.Name, setting.Source, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// isSecretSetting reports whether a setting should be masked when printed
+func isSecretSetting(key string) bool {
+	return strings.Contains(key, "token") || strings.Contains(key, "secret") || strings.Contains(key, "password")
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and edit the gh-workflow configuration",
+	Long: "Inspect, edit, validate and migrate the layered gh-workflow configuration (defaults, profiles and " +
+		"repository overrides)",
+	// The config file is read by the subcommands, so a broken one can still be validated and fixed
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateEventsFormat()
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:     "show",
+	Aliases: []string{"view"},
+	Short:   "Show the configuration",
+	Long:    "Show the configuration file, or with --resolved the effective settings for a profile and repository",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := findConfigFile()
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			return fmt.Errorf("%w: no config file found (looked for --config, $GH_WORKFLOW_CONFIG and %s)",
+				ErrNotFound, configFileName)
+		}
+
+		cfg, err := loadConfig(path)
+		if err != nil {
+			return err
+		}
+
+		if !configResolved {
+			data, err := yaml.Marshal(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to render config: %v", err)
+			}
+			fmt.Printf("# %s\n%s", path, data)
+			return nil
+		}
+
+		repo := configRepo
+		if repo == "" {
+			repo = os.Getenv("GITHUB_REPOSITORY")
+		}
+		resolved, err := cfg.resolve(configProfile, repo)
+		if err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(resolved))
+		for key := range resolved {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fmt.Printf("# %s (profile: %q, repo: %q)\n", path, configProfile, repo)
+		for _, key := range keys {
+			value := resolved[key].Value
+			if isSecretSetting(key) && value != "" {
+				value = "********"
+			}
+			fmt.Printf("%s: %s  # %s\n", key, value, resolved[key].Source)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().
+		StringVar(&configPath, "config", "", "Path to config file (default: $GH_WORKFLOW_CONFIG or "+
+			"~/.config/gh-workflow/config.yml; ./"+configFileName+" is only used when passed)")
+	rootCmd.PersistentFlags().
+		StringVar(&configProfile, "profile", "", "Config profile to apply, e.g. team/backend (inherits from team)")
+
+	configShowCmd.Flags().BoolVar(&configResolved, "resolved", false, "Print the effective settings after inheritance")
+	configShowCmd.Flags().
+		StringVar(&configRepo, "repo", "", "Repository (owner/name) whose overrides to apply (default: $GITHUB_REPOSITORY)")
+
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
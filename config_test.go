@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestProfileChain(t *testing.T) {
+	tests := []struct {
+		profile string
+		want    []string
+	}{
+		{"", nil},
+		{"team", []string{"team"}},
+		{"team/backend", []string{"team", "team/backend"}},
+		{"org/team/backend", []string{"org", "org/team", "org/team/backend"}},
+		{"/team/backend/", []string{"team", "team/backend"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			if got := profileChain(tt.profile); !slices.Equal(got, tt.want) {
+				t.Errorf("profileChain(%q) = %q, want %q", tt.profile, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigResolve(t *testing.T) {
+	cfg := &Config{
+		Defaults: Settings{"instance-type": "t3.micro", "region": "us-east-1"},
+		Profiles: map[string]Settings{
+			"team":          {"instance-type": "t3.large", "labels": []any{"team", "linux"}},
+			"team/backend":  {"region": "eu-west-1"},
+			"other/backend": {"region": "ap-south-1"},
+		},
+		Repos: map[string]Settings{
+			"myorg/api": {"instance-type": "c6i.xlarge"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		profile string
+		repo    string
+		want    map[string]resolvedSetting
+		wantErr error
+	}{
+		{
+			name: "defaults only",
+			want: map[string]resolvedSetting{
+				"instance-type": {Value: "t3.micro", Source: "defaults"},
+				"region":        {Value: "us-east-1", Source: "defaults"},
+			},
+		},
+		{
+			name:    "each level of the profile overrides the one before",
+			profile: "team/backend",
+			want: map[string]resolvedSetting{
+				"instance-type": {Value: "t3.large", Source: "profile team"},
+				"region":        {Value: "eu-west-1", Source: "profile team/backend"},
+				"labels":        {Value: "team,linux", Source: "profile team", Items: []string{"team", "linux"}},
+			},
+		},
+		{
+			name:    "repository overrides win",
+			profile: "team/backend",
+			repo:    "myorg/api",
+			want: map[string]resolvedSetting{
+				"instance-type": {Value: "c6i.xlarge", Source: "repo myorg/api"},
+				"region":        {Value: "eu-west-1", Source: "profile team/backend"},
+				"labels":        {Value: "team,linux", Source: "profile team", Items: []string{"team", "linux"}},
+			},
+		},
+		{
+			name:    "undefined parent levels add nothing",
+			profile: "other/backend",
+			want: map[string]resolvedSetting{
+				"instance-type": {Value: "t3.micro", Source: "defaults"},
+				"region":        {Value: "ap-south-1", Source: "profile other/backend"},
+			},
+		},
+		{
+			name:    "undefined profile",
+			profile: "team/frontend",
+			wantErr: ErrNotFound,
+		},
+		{
+			name:    "undefined profile whose children are defined",
+			profile: "other",
+			wantErr: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cfg.resolve(tt.profile, tt.repo)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("resolve(%q, %q) error = %v, want %v", tt.profile, tt.repo, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve(%q, %q) error = %v", tt.profile, tt.repo, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("resolve(%q, %q) = %v, want %v", tt.profile, tt.repo, got, tt.want)
+			}
+			for key, want := range tt.want {
+				setting := got[key]
+				if setting.Value != want.Value || setting.Source != want.Source ||
+					!slices.Equal(setting.Items, want.Items) {
+					t.Errorf("resolve(%q, %q)[%q] = %+v, want %+v", tt.profile, tt.repo, key, setting, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,631 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+var configMigrateDryRun bool
+
+// currentConfigVersion is the config file format written by this version;
+// older files are migrated when loaded and rewritten by `config migrate`
+const currentConfigVersion = 2
+
+// configMigration upgrades a config file from one version to the next
+type configMigration struct {
+	From        int
+	Description string
+	// Renames maps old setting keys to their new names
+	Renames map[string]string
+}
+
+var configMigrations = []configMigration{
+	{From: 0, Description: "add the version field"},
+	{From: 1, Description: "rename aws-region to region", Renames: map[string]string{"aws-region": "region"}},
+}
+
+// settingsNode is a mapping of settings in the config file and where it is
+type settingsNode struct {
+	Source string
+	Node   *yaml.Node
+}
+
+// mappingValue returns the value of key in a YAML mapping, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets key in a YAML mapping, appending it if missing
+func setMappingValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+}
+
+// deleteMappingKey removes key from a YAML mapping and reports whether it was there
+func deleteMappingKey(mapping *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// renameMappingKey renames key in a YAML mapping in place, dropping it if the
+// new key is already set, and reports whether it was there
+func renameMappingKey(mapping *yaml.Node, oldKey, newKey string) bool {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != oldKey {
+			continue
+		}
+		if mappingValue(mapping, newKey) != nil {
+			deleteMappingKey(mapping, oldKey)
+		} else {
+			mapping.Content[i].Value = newKey
+		}
+		return true
+	}
+	return false
+}
+
+// ensureMapping returns the mapping under key, creating it if missing
+func ensureMapping(mapping *yaml.Node, key string) (*yaml.Node, error) {
+	value := mappingValue(mapping, key)
+	if value == nil || (value.Kind == yaml.ScalarNode && value.Tag == "!!null") {
+		value = &yaml.Node{Kind: yaml.MappingNode}
+		setMappingValue(mapping, key, value)
+	}
+	if value.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: %s is not a mapping", value.Line, key)
+	}
+	return value, nil
+}
+
+// settingsNodes returns the defaults, profile and repository settings of a config document
+func settingsNodes(root *yaml.Node) []settingsNode {
+	nodes := []settingsNode{}
+	if defaults := mappingValue(root, "defaults"); defaults != nil && defaults.Kind == yaml.MappingNode {
+		nodes = append(nodes, settingsNode{Source: "defaults", Node: defaults})
+	}
+	for _, section := range []string{"profiles", "repos"} {
+		entries := mappingValue(root, section)
+		if entries == nil || entries.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(entries.Content); i += 2 {
+			if entries.Content[i+1].Kind == yaml.MappingNode {
+				source := strings.TrimSuffix(section, "s") + " " + entries.Content[i].Value
+				nodes = append(nodes, settingsNode{Source: source, Node: entries.Content[i+1]})
+			}
+		}
+	}
+	return nodes
+}
+
+// migrateConfigDocument upgrades a config document to currentConfigVersion in
+// place, returning the version it had and the migrations applied; changed
+// reports whether any setting was renamed
+func migrateConfigDocument(root *yaml.Node) (version int, applied []string, changed bool, err error) {
+	if node := mappingValue(root, "version"); node != nil {
+		if err := node.Decode(&version); err != nil {
+			return 0, nil, false, fmt.Errorf("line %d: version %q is not a number", node.Line, node.Value)
+		}
+	}
+	if version > currentConfigVersion {
+		return version, nil, false, fmt.Errorf("config version %d was written by a newer gh-workflow "+
+			"(this one supports up to version %d)", version, currentConfigVersion)
+	}
+
+	for _, migration := range configMigrations {
+		if migration.From < version {
+			continue
+		}
+		for _, settings := range settingsNodes(root) {
+			for oldKey, newKey := range migration.Renames {
+				changed = renameMappingKey(settings.Node, oldKey, newKey) || changed
+			}
+		}
+		applied = append(applied, fmt.Sprintf("%d → %d: %s", migration.From, migration.From+1, migration.Description))
+	}
+
+	if version < currentConfigVersion {
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(currentConfigVersion)}
+		if node := mappingValue(root, "version"); node != nil {
+			setMappingValue(root, "version", value)
+		} else {
+			root.Content = append([]*yaml.Node{{Kind: yaml.ScalarNode, Value: "version"}, value}, root.Content...)
+		}
+	}
+	return version, applied, changed, nil
+}
+
+// readConfigDocument parses the config file at path; a missing file yields an
+// empty document when allowMissing is set
+func readConfigDocument(path string, allowMissing bool) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !(allowMissing && errors.Is(err, os.ErrNotExist)) {
+		return nil, fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file %s is not a YAML mapping", path)
+	}
+	return doc, nil
+}
+
+// encodeConfigDocument renders a config document, keeping its comments
+func encodeConfigDocument(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to render config: %v", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to render config: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeConfigDocument writes a config document to path, keeping the mode of an
+// existing file; new files are private since they may hold tokens
+func writeConfigDocument(path string, doc *yaml.Node) error {
+	data, err := encodeConfigDocument(doc)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0o600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return fmt.Errorf("failed to write config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// editableConfigFile returns the config file set and unset change: the one
+// found, or a new one in the user config directory, which commands load
+// without --config, when there is none yet
+func editableConfigFile() (string, error) {
+	if configPath != "" {
+		return configPath, nil
+	}
+	path, err := findConfigFile()
+	if err != nil || path != "" {
+		return path, err
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return configFileName, nil
+	}
+	path = filepath.Join(dir, "gh-workflow", "config.yml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %v", err)
+	}
+	return path, nil
+}
+
+// settingFlags returns the flags of every command by name, which are the keys
+// the config file accepts
+func settingFlags() map[string]*pflag.Flag {
+	flags := map[string]*pflag.Flag{}
+	add := func(flag *pflag.Flag) {
+		if _, ok := flags[flag.Name]; !ok {
+			flags[flag.Name] = flag
+		}
+	}
+	var visit func(cmd *cobra.Command)
+	visit = func(cmd *cobra.Command) {
+		cmd.PersistentFlags().VisitAll(add)
+		cmd.Flags().VisitAll(add)
+		for _, sub := range cmd.Commands() {
+			visit(sub)
+		}
+	}
+	visit(rootCmd)
+
+	// These select the config itself
+	for _, name := range []string{"config", "profile", "help", "version"} {
+		delete(flags, name)
+	}
+	return flags
+}
+
+// checkSettingValue checks that value parses as the type of flag
+func checkSettingValue(flag *pflag.Flag, value string) error {
+	var err error
+	switch flag.Value.Type() {
+	case "bool":
+		_, err = strconv.ParseBool(value)
+	case "int", "int64":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "duration":
+		_, err = time.ParseDuration(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%q is not a valid %s", value, flag.Value.Type())
+	}
+	return nil
+}
+
+// settingValueNode returns the YAML node for a setting, typed after its flag
+func settingValueNode(flag *pflag.Flag, value string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	switch flag.Value.Type() {
+	case "bool":
+		parsed, _ := strconv.ParseBool(value)
+		node.Tag, node.Value = "!!bool", strconv.FormatBool(parsed)
+	case "int", "int64":
+		node.Tag = "!!int"
+	}
+	return node
+}
+
+// configTarget returns the settings mapping that set and unset change: the
+// defaults, or those of --profile or --repo
+func configTarget(root *yaml.Node, create bool) (*yaml.Node, string, error) {
+	if configProfile != "" && configRepo != "" {
+		return nil, "", fmt.Errorf("--profile and --repo cannot be combined")
+	}
+	section, name := "defaults", ""
+	switch {
+	case configProfile != "":
+		section, name = "profiles", strings.Trim(configProfile, "/")
+	case configRepo != "":
+		if owner, repo, ok := strings.Cut(configRepo, "/"); !ok || owner == "" || repo == "" {
+			return nil, "", fmt.Errorf("--repo %q is not owner/name", configRepo)
+		}
+		section, name = "repos", configRepo
+	}
+	source := section
+	if name != "" {
+		source = strings.TrimSuffix(section, "s") + " " + name
+	}
+
+	if !create {
+		node := mappingValue(root, section)
+		if node != nil && name != "" && node.Kind == yaml.MappingNode {
+			node = mappingValue(node, name)
+		}
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil, source, nil
+		}
+		return node, source, nil
+	}
+
+	node, err := ensureMapping(root, section)
+	if err != nil {
+		return nil, "", err
+	}
+	if name != "" {
+		if node, err = ensureMapping(node, name); err != nil {
+			return nil, "", err
+		}
+	}
+	return node, source, nil
+}
+
+// pruneEmptySettings removes profiles and repositories left without settings,
+// and the sections left without entries
+func pruneEmptySettings(root *yaml.Node) {
+	for _, section := range []string{"profiles", "repos"} {
+		entries := mappingValue(root, section)
+		if entries == nil || entries.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(entries.Content); {
+			if value := entries.Content[i+1]; value.Kind == yaml.MappingNode && len(value.Content) == 0 {
+				entries.Content = append(entries.Content[:i], entries.Content[i+2:]...)
+				continue
+			}
+			i += 2
+		}
+	}
+	for _, section := range []string{"defaults", "profiles", "repos"} {
+		value := mappingValue(root, section)
+		if value != nil && value.Kind == yaml.MappingNode && len(value.Content) == 0 {
+			deleteMappingKey(root, section)
+		}
+	}
+}
+
+// configFileProblems checks a config file against the schema: known sections
+// and fields, setting keys that are flags, values of the flag's type,
+// repository keys and account role ARNs
+func configFileProblems(path string) (warnings, problems []string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+	doc, err := readConfigDocument(path, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	root := doc.Content[0]
+
+	version, _, changed, err := migrateConfigDocument(root)
+	if err != nil {
+		return nil, []string{err.Error()}, nil
+	}
+	if changed {
+		warnings = append(warnings, fmt.Sprintf("config version %d uses old setting names; run `gh-workflow config "+
+			"migrate` to update it to version %d", version, currentConfigVersion))
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var cfg Config
+	if err := decoder.Decode(&cfg); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+		}
+		for _, message := range typeErr.Errors {
+			problems = append(problems, strings.Replace(message, "main.", "", 1))
+		}
+	}
+
+	flags := settingFlags()
+	for _, settings := range settingsNodes(root) {
+		for i := 0; i+1 < len(settings.Node.Content); i += 2 {
+			key, value := settings.Node.Content[i], settings.Node.Content[i+1]
+			flag, ok := flags[key.Value]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("line %d: %s: unknown setting %q (keys are flag names)",
+					key.Line, settings.Source, key.Value))
+				continue
+			}
+			var decoded any
+			if err := value.Decode(&decoded); err != nil {
+				problems = append(problems, fmt.Sprintf("line %d: %s: %s: %v",
+					value.Line, settings.Source, key.Value, err))
+				continue
+			}
+			if _, isMap := decoded.(map[string]any); isMap {
+				problems = append(problems, fmt.Sprintf("line %d: %s: %s must be a value or a list, not a mapping",
+					value.Line, settings.Source, key.Value))
+				continue
+			}
+			if err := checkSettingValue(flag, settingString(decoded)); err != nil {
+				problems = append(problems, fmt.Sprintf("line %d: %s: %s: %v",
+					value.Line, settings.Source, key.Value, err))
+			}
+		}
+	}
+
+	for repo := range cfg.Repos {
+		if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			problems = append(problems, fmt.Sprintf("repos: %q is not owner/name", repo))
+		}
+	}
+	for i, account := range cfg.Accounts {
+		if !iamRoleARNRegex.MatchString(account.RoleARN) {
+			problems = append(problems,
+				fmt.Sprintf("accounts[%d]: role-arn %q is not an IAM role ARN", i, account.RoleARN))
+		}
+	}
+	return warnings, problems, nil
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a setting in the config file",
+	Long: "Set a setting in the defaults of the config file, or with --profile or --repo in a profile or " +
+		"repository override. The key must be a flag name and the value must be valid for that flag; the file " +
+		"is created if needed and migrated to the current version",
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, value := args[0], args[1]
+		flag, ok := settingFlags()[key]
+		if !ok {
+			return fmt.Errorf("unknown setting %q: keys are flag names (e.g. instance-type)", key)
+		}
+		if err := checkSettingValue(flag, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", key, err)
+		}
+
+		path, err := editableConfigFile()
+		if err != nil {
+			return err
+		}
+		doc, err := readConfigDocument(path, true)
+		if err != nil {
+			return err
+		}
+		root := doc.Content[0]
+		if _, _, _, err := migrateConfigDocument(root); err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		settings, source, err := configTarget(root, true)
+		if err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		setMappingValue(settings, key, settingValueNode(flag, value))
+		if err := writeConfigDocument(path, doc); err != nil {
+			return err
+		}
+
+		if isSecretSetting(key) {
+			value = "********"
+		}
+		fmt.Printf("✅ Set %s: %s in %s of %s\n", key, value, source, path)
+		return nil
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a setting from the config file",
+	Long: "Remove a setting from the defaults of the config file, or with --profile or --repo from a profile or " +
+		"repository override; profiles and repositories left empty are removed",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := args[0]
+		path, err := findConfigFile()
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			return fmt.Errorf("%w: no config file found (looked for --config, $GH_WORKFLOW_CONFIG and %s)",
+				ErrNotFound, configFileName)
+		}
+		doc, err := readConfigDocument(path, false)
+		if err != nil {
+			return err
+		}
+		root := doc.Content[0]
+		if _, _, _, err := migrateConfigDocument(root); err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		settings, source, err := configTarget(root, false)
+		if err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		if settings == nil || !deleteMappingKey(settings, key) {
+			return fmt.Errorf("%w: %s is not set in %s of %s", ErrNotFound, key, source, path)
+		}
+		pruneEmptySettings(root)
+		if err := writeConfigDocument(path, doc); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Removed %s from %s of %s\n", key, source, path)
+		return nil
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file against the schema",
+	Long: "Check the config file for unknown sections and fields, settings that are not flag names, values of " +
+		"the wrong type, malformed repository keys and account role ARNs, and an outdated version",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := findConfigFile()
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			return fmt.Errorf("%w: no config file found (looked for --config, $GH_WORKFLOW_CONFIG and %s)",
+				ErrNotFound, configFileName)
+		}
+		warnings, problems, err := configFileProblems(path)
+		if err != nil {
+			return err
+		}
+
+		for _, warning := range warnings {
+			if outputFormat == "github-actions" {
+				fmt.Printf("::warning file=%s::%s\n", path, warning)
+			} else {
+				fmt.Printf("⚠️  %s\n", warning)
+			}
+		}
+		if len(problems) == 0 {
+			fmt.Printf("✅ Config file %s is valid\n", path)
+			return nil
+		}
+		for _, problem := range problems {
+			if outputFormat == "github-actions" {
+				fmt.Printf("::error file=%s::%s\n", path, problem)
+			} else {
+				fmt.Printf("❌ %s\n", problem)
+			}
+		}
+		return fmt.Errorf("found %d problem(s) in %s", len(problems), path)
+	},
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Update the config file to the current version",
+	Long: "Rewrite the config file in the current format (version " + strconv.Itoa(currentConfigVersion) +
+		"), renaming settings that changed; the previous file is kept as a .bak copy",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := findConfigFile()
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			return fmt.Errorf("%w: no config file found (looked for --config, $GH_WORKFLOW_CONFIG and %s)",
+				ErrNotFound, configFileName)
+		}
+		original, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read config file %s: %v", path, err)
+		}
+		doc, err := readConfigDocument(path, false)
+		if err != nil {
+			return err
+		}
+		version, applied, _, err := migrateConfigDocument(doc.Content[0])
+		if err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		if len(applied) == 0 {
+			fmt.Printf("✅ Config file %s is already at version %d\n", path, currentConfigVersion)
+			return nil
+		}
+
+		if configMigrateDryRun {
+			data, err := encodeConfigDocument(doc)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("# %s migrated from version %d (%s)\n%s", path, version, strings.Join(applied, "; "), data)
+			return nil
+		}
+		if err := os.WriteFile(path+".bak", original, 0o600); err != nil {
+			return fmt.Errorf("failed to back up config file %s: %v", path, err)
+		}
+		if err := writeConfigDocument(path, doc); err != nil {
+			return err
+		}
+		for _, step := range applied {
+			fmt.Printf("   %s\n", step)
+		}
+		fmt.Printf("✅ Migrated %s from version %d to %d (backup: %s.bak)\n",
+			path, version, currentConfigVersion, path)
+		return nil
+	},
+}
+
+func init() {
+	configSetCmd.Flags().StringVar(&configRepo, "repo", "", "Set the override of this repository (owner/name)")
+	configUnsetCmd.Flags().StringVar(&configRepo, "repo", "", "Remove the override of this repository (owner/name)")
+	configValidateCmd.Flags().
+		StringVar(&outputFormat, "output-format", "", "Output format (github-actions for annotations)")
+	configMigrateCmd.Flags().
+		BoolVar(&configMigrateDryRun, "dry-run", false, "Print the migrated file instead of writing it")
+
+	configCmd.AddCommand(configSetCmd, configUnsetCmd, configValidateCmd, configMigrateCmd)
+}
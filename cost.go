@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/pricing"
+	"github.com/aws/aws-sdk-go-v2/service/pricing/types"
+	"github.com/spf13/cobra"
+)
+
+// InstanceCost is the estimated compute cost accrued by a runner instance
+type InstanceCost struct {
+	FleetInstance
+	Hours      float64 `json:"hours"`
+	HourlyRate float64 `json:"hourly_rate_usd"`
+	Cost       float64 `json:"cost_usd"`
+}
+
+// priceCache memoizes hourly prices by market, type, region and zone
+var priceCache = map[string]float64{}
+
+// onDemandPrice returns the Linux on-demand hourly price in USD for an instance type in a region
+func onDemandPrice(cfg aws.Config, instanceType, region string) (float64, error) {
+	key := "on-demand/" + instanceType + "/" + region
+	if price, ok := priceCache[key]; ok {
+		return price, nil
+	}
+
+	// USD prices are only served in the commercial partition (China's are in CNY)
+	partition := regionPartition(region)
+	pricingCfg := cfg.Copy()
+	pricingCfg.Region = awsPartitions[partition].PricingRegion
+	if pricingCfg.Region == "" {
+		return 0, fmt.Errorf("%w: the AWS Price List API is not available in partition %s", ErrNotFound, partition)
+	}
+	filter := func(field, value string) types.Filter {
+		return types.Filter{Type: types.FilterTypeTermMatch, Field: aws.String(field), Value: aws.String(value)}
+	}
+
+	result, err := pricing.NewFromConfig(pricingCfg).GetProducts(context.TODO(), &pricing.GetProductsInput{
+		ServiceCode: aws.String("AmazonEC2"),
+		Filters: []types.Filter{
+			filter("instanceType", instanceType),
+			filter("regionCode", region),
+			filter("operatingSystem", "Linux"),
+			filter("tenancy", "Shared"),
+			filter("preInstalledSw", "NA"),
+			filter("capacitystatus", "Used"),
+		},
+		MaxResults: aws.Int32(1),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get on-demand price for %s: %w", instanceType, classifyAWSError(err))
+	}
+	if len(result.PriceList) == 0 {
+		return 0, fmt.Errorf("%w: no on-demand price for %s in %s", ErrNotFound, instanceType, region)
+	}
+
+	price, err := parseOnDemandPrice(result.PriceList[0])
+	if err != nil {
+		return 0, err
+	}
+	priceCache[key] = price
+	return price, nil
+}
+
+// parseOnDemandPrice extracts the hourly USD price from a Price List product document
+func parseOnDemandPrice(document string) (float64, error) {
+	var product struct {
+		Terms struct {
+			OnDemand map[string]struct {
+				PriceDimensions map[string]struct {
+					PricePerUnit map[string]string `json:"pricePerUnit"`
+				} `json:"priceDimensions"`
+			} `json:"OnDemand"`
+		} `json:"terms"`
+	}
+	if err := json.Unmarshal([]byte(document), &product); err != nil {
+		return 0, fmt.Errorf("failed to parse price list: %v", err)
+	}
+
+	for _, term := range product.Terms.OnDemand {
+		for _, dimension := range term.PriceDimensions {
+			if usd, ok := dimension.PricePerUnit["USD"]; ok {
+				return strconv.ParseFloat(usd, 64)
+			}
+		}
+	}
+	return 0, fmt.Errorf("price list entry has no on-demand USD price")
+}
+
+// currentSpotPrice returns the latest Linux spot price in USD for an instance type,
+// in the given zone when set, otherwise the lowest across the region
+func currentSpotPrice(svc *ec2.Client, instanceType, zone string) (float64, error) {
+	key := "spot/" + instanceType + "/" + zone
+	if price, ok := priceCache[key]; ok {
+		return price, nil
+	}
+
+	input := &ec2.DescribeSpotPriceHistoryInput{
+		InstanceTypes:       []ec2types.InstanceType{ec2types.InstanceType(instanceType)},
+		ProductDescriptions: []string{"Linux/UNIX"},
+		StartTime:           aws.Time(time.Now()),
+	}
+	if zone != "" {
+		input.AvailabilityZone = aws.String(zone)
+	}
+
+	result, err := svc.DescribeSpotPriceHistory(context.TODO(), input)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get spot price for %s: %w", instanceType, classifyAWSError(err))
+	}
+
+	prices := []float64{}
+	for _, entry := range result.SpotPriceHistory {
+		if price, err := strconv.ParseFloat(aws.ToString(entry.SpotPrice), 64); err == nil {
+			prices = append(prices, price)
+		}
+	}
+	if len(prices) == 0 {
+		return 0, fmt.Errorf("%w: no spot price for %s", ErrNotFound, instanceType)
+	}
+	sort.Float64s(prices)
+
+	priceCache[key] = prices[0]
+	return prices[0], nil
+}
+
+// estimateCost estimates the cost accrued so far by a runner instance
+func estimateCost(cfg aws.Config, instance FleetInstance) (InstanceCost, error) {
+	cost := InstanceCost{FleetInstance: instance}
+	if instance.LaunchTime != nil {
+		cost.Hours = time.Since(*instance.LaunchTime).Hours()
+	}
+
+	var err error
+	if instance.InstanceMarketType == "spot" {
+		cost.HourlyRate, err = currentSpotPrice(ec2.NewFromConfig(cfg), instance.InstanceType, instance.AvailabilityZone)
+	} else {
+		cost.HourlyRate, err = onDemandPrice(cfg, instance.InstanceType, cfg.Region)
+	}
+	if err != nil {
+		return cost, err
+	}
+
+	cost.Cost = cost.Hours * cost.HourlyRate
+	return cost, nil
+}
+
+var costCmd = &cobra.Command{
+	Use:   "cost",
+	Short: "Estimate the cost of running runner instances",
+	Long:  "Estimate the compute cost accrued by live runner instances, optionally across multiple AWS accounts",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targets, err := fleetTargets(cmd)
+		if err != nil {
+			return err
+		}
+
+		costs := []InstanceCost{}
+		totals := map[string]float64{}
+		var errs []error
+		for _, target := range targets {
+			fleet, err := listFleet([]fleetTarget{target})
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			for _, instance := range fleet {
+				cost, err := estimateCost(target.Config, instance)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("account %s: %s: %w", target.Account, instance.InstanceID, err))
+				}
+				costs = append(costs, cost)
+				totals[target.Account] += cost.Cost
+			}
+		}
+
+		if outputFormat == "json" {
+			data, err := json.MarshalIndent(costs, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode costs: %v", err)
+			}
+			fmt.Println(string(data))
+			return errors.Join(errs...)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tINSTANCE ID\tTYPE\tMARKET\tHOURS\tRATE ($/h)\tCOST ($)")
+		for _, cost := range costs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.1f\t%.4f\t%.2f\n",
+				cost.Account, cost.InstanceID, cost.InstanceType, cost.InstanceMarketType,
+				cost.Hours, cost.HourlyRate, cost.Cost)
+		}
+		w.Flush()
+
+		grandTotal := 0.0
+		for _, target := range targets {
+			grandTotal += totals[target.Account]
+			if len(targets) > 1 {
+				fmt.Printf("💰 %s: $%.2f\n", target.Account, totals[target.Account])
+			}
+		}
+		fmt.Printf("💰 Total: $%.2f\n", grandTotal)
+
+		return errors.Join(errs...)
+	},
+}
+
+func init() {
+	addFleetFlags(costCmd)
+	costCmd.Flags().StringVar(&outputFormat, "output-format", "", "Output format (json for machine-readable output)")
+
+	rootCmd.AddCommand(costCmd)
+}
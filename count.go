@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var launchCount int
+
+// maxLaunchCount bounds --count, keeping a typo from launching a fleet
+const maxLaunchCount = 50
+
+// launchMu guards the package-level state the user data generators read, which
+// each of the concurrent --count creates installs from its launchState
+var launchMu sync.Mutex
+
+// launchState is the state of a single create that the user data generators
+// read from package-level variables
+type launchState struct {
+	arch         string
+	removalToken string
+}
+
+// userData renders the runner user data of a create and its hash with the
+// create's state installed, so concurrent --count creates don't see each other's
+func (s launchState) userData(registrationToken, repoOwner, repoName, runnerLabels, preRunnerScript,
+	runnerName string) (string, string) {
+	launchMu.Lock()
+	defer launchMu.Unlock()
+	runnerArch, spotRemovalToken = s.arch, s.removalToken
+	return generateUserData(registrationToken, repoOwner, repoName, runnerLabels, preRunnerScript, runnerName),
+		userDataHash(repoOwner, repoName, runnerLabels, preRunnerScript)
+}
+
+// validateCountFlags checks --count
+func validateCountFlags() error {
+	if launchCount < 1 || launchCount > maxLaunchCount {
+		return fmt.Errorf("count must be between 1 and %d, got %d", maxLaunchCount, launchCount)
+	}
+	if launchCount > 1 && manifestPath != "" {
+		return fmt.Errorf("manifest records a single create; it can't be used with count")
+	}
+	return nil
+}
+
+// countRunnerName returns the runner name of the i-th of --count runners; an
+// empty name stays empty, as the default name is derived from each host name
+func countRunnerName(runnerName string, i int) string {
+	if runnerName == "" || launchCount == 1 {
+		return runnerName
+	}
+	return fmt.Sprintf("%s-%d", runnerName, i)
+}
+
+// createManyEC2Instances launches --count runner instances concurrently with
+// create, each with its own registration token and runner name, and reports
+// the instances launched; a launch that fails doesn't stop the others
+func createManyEC2Instances(githubToken, runnerName string, create func(runnerName string) (string, error)) error {
+	if err := checkRunnerLimit(githubToken, runnerScope(repoOwner, repoName), launchCount); err != nil {
+		return err
+	}
+
+	var (
+		wg          sync.WaitGroup
+		instanceIDs = make([]string, launchCount)
+		names       = make([]string, launchCount)
+		errs        = make([]error, launchCount)
+	)
+	for i := range launchCount {
+		names[i] = countRunnerName(runnerName, i+1)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			instanceIDs[i], errs[i] = create(names[i])
+			if errs[i] != nil && names[i] != "" {
+				errs[i] = fmt.Errorf("runner %s: %w", names[i], errs[i])
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	launched := []string{}
+	for _, id := range instanceIDs {
+		if id != "" {
+			launched = append(launched, id)
+		}
+	}
+	err := errors.Join(errs...)
+	failed := 0
+	for _, launchErr := range errs {
+		if launchErr != nil {
+			failed++
+		}
+	}
+	emitEvent("create.count_completed", map[string]any{
+		"count": launchCount, "instance_ids": launched, "runner_names": names, "failed": failed,
+	})
+
+	if outputFormat == "github-actions" {
+		ids, _ := json.Marshal(launched)
+		fmt.Printf("Instance IDs: %s\n", strings.Join(launched, ","))
+		fmt.Printf("Instance IDs JSON: %s\n", ids)
+		fmt.Printf("Runner Names: %s\n", strings.Join(names, ","))
+	} else {
+		fmt.Printf("📦 Launched %d of %d runner instance(s): %s\n", len(launched), launchCount,
+			strings.Join(launched, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("%d of %d runner(s) failed: %w", failed, launchCount, err)
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().IntVar(&launchCount, "count", 1,
+		fmt.Sprintf("Number of runner instances to launch in parallel (1-%d); runner names get a -1..N suffix",
+			maxLaunchCount))
+}
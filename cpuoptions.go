@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var (
+	cpuCoreCount        int32
+	threadsPerCore      int32
+	creditSpecification string
+)
+
+// validateCPUFlags checks --cpu-core-count, --threads-per-core and
+// --credit-specification; the core and thread counts an instance type accepts
+// are checked when launching
+func validateCPUFlags() error {
+	if cpuCoreCount < 0 {
+		return fmt.Errorf("cpu-core-count must not be negative, got %d", cpuCoreCount)
+	}
+	if threadsPerCore != 0 && threadsPerCore != 1 && threadsPerCore != 2 {
+		return fmt.Errorf("threads-per-core must be 1 (hyperthreading disabled) or 2, got %d", threadsPerCore)
+	}
+	switch creditSpecification {
+	case "", "standard", "unlimited":
+	default:
+		return fmt.Errorf("credit-specification must be standard or unlimited, got %q", creditSpecification)
+	}
+	if creditSpecification != "" && !isBurstable(instanceType) {
+		return fmt.Errorf("credit-specification only applies to burstable (T) instance types, not %s", instanceType)
+	}
+	return nil
+}
+
+// isBurstable reports whether instances of the type earn CPU credits
+func isBurstable(instanceType string) bool {
+	family, _, _ := strings.Cut(instanceType, ".")
+	return slices.Contains([]string{"t2", "t3", "t3a", "t4g"}, family)
+}
+
+// applyCPUOptions sets the credit specification and the CPU options of the
+// instance; a core or thread count left unset uses the instance type's default
+func applyCPUOptions(svc *ec2.Client, runInput *ec2.RunInstancesInput) error {
+	// The placement script may have picked another instance type than the flags
+	if creditSpecification != "" {
+		if !isBurstable(string(runInput.InstanceType)) {
+			return fmt.Errorf("credit-specification only applies to burstable (T) instance types, not %s",
+				runInput.InstanceType)
+		}
+		runInput.CreditSpecification = &types.CreditSpecificationRequest{CpuCredits: aws.String(creditSpecification)}
+	}
+	if cpuCoreCount == 0 && threadsPerCore == 0 {
+		return nil
+	}
+
+	result, err := svc.DescribeInstanceTypes(context.TODO(), &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []types.InstanceType{runInput.InstanceType},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe instance type %s: %w", runInput.InstanceType, classifyAWSError(err))
+	}
+	if len(result.InstanceTypes) == 0 || result.InstanceTypes[0].VCpuInfo == nil {
+		return fmt.Errorf("%w: instance type %s is not offered in %s", ErrNotFound, runInput.InstanceType,
+			resolveRegion())
+	}
+	vcpu := result.InstanceTypes[0].VCpuInfo
+	if len(vcpu.ValidCores) == 0 && len(vcpu.ValidThreadsPerCore) == 0 {
+		return fmt.Errorf("instance type %s does not support setting the CPU cores or threads per core",
+			runInput.InstanceType)
+	}
+
+	cores, threads := cpuCoreCount, threadsPerCore
+	if cores == 0 {
+		cores = aws.ToInt32(vcpu.DefaultCores)
+	}
+	if threads == 0 {
+		threads = aws.ToInt32(vcpu.DefaultThreadsPerCore)
+	}
+	if len(vcpu.ValidCores) > 0 && !slices.Contains(vcpu.ValidCores, cores) {
+		return fmt.Errorf("instance type %s supports %s cores, not %d",
+			runInput.InstanceType, joinInt32s(vcpu.ValidCores), cores)
+	}
+	if len(vcpu.ValidThreadsPerCore) > 0 && !slices.Contains(vcpu.ValidThreadsPerCore, threads) {
+		return fmt.Errorf("instance type %s supports %s threads per core, not %d",
+			runInput.InstanceType, joinInt32s(vcpu.ValidThreadsPerCore), threads)
+	}
+	runInput.CpuOptions = &types.CpuOptionsRequest{CoreCount: aws.Int32(cores), ThreadsPerCore: aws.Int32(threads)}
+	if outputFormat != "github-actions" {
+		fmt.Printf("🧮 Using %d core(s) with %d thread(s) per core (%d vCPUs)\n", cores, threads, cores*threads)
+	}
+	return nil
+}
+
+// joinInt32s formats a list of counts for error messages
+func joinInt32s(values []int32) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func init() {
+	createCmd.Flags().Int32Var(&cpuCoreCount, "cpu-core-count", 0,
+		"Number of CPU cores, e.g. to match a per-core license (default: the instance type's)")
+	createCmd.Flags().Int32Var(&threadsPerCore, "threads-per-core", 0,
+		"Threads per CPU core; 1 disables hyperthreading (default: the instance type's)")
+	createCmd.Flags().StringVar(&creditSpecification, "credit-specification", "",
+		"CPU credit option of burstable (T) instance types: standard or unlimited")
+}
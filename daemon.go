@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/spf13/cobra"
+)
+
+var daemonPollInterval time.Duration
+
+// spotReclaimReason is the state reason code of spot instances EC2 terminated
+// to reclaim their capacity
+const spotReclaimReason = "Server.SpotInstanceTermination"
+
+// reclaimedSpotRunners returns the runner instances of the repository or
+// organization and labels that EC2 reclaimed. Terminated instances stay
+// visible for about an hour
+func reclaimedSpotRunners(svc *ec2.Client, repository, labels string) ([]RunManifest, error) {
+	input := &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("tag:Purpose"), Values: []string{"GitHub Actions"}},
+			{Name: aws.String("tag:Repository"), Values: []string{repository}},
+			{Name: aws.String("instance-state-name"), Values: []string{"terminated"}},
+			{Name: aws.String("state-reason-code"), Values: []string{spotReclaimReason}},
+		},
+	}
+	reclaimed := []RunManifest{}
+	paginator := ec2.NewDescribeInstancesPaginator(svc, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list reclaimed runners: %w", classifyAWSError(err))
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if manifest := manifestFromInstance(instance); samePool(manifest, repository, labels) {
+					reclaimed = append(reclaimed, manifest)
+				}
+			}
+		}
+	}
+	return reclaimed, nil
+}
+
+// relaunchReclaimedRunner launches a replacement for a runner EC2 reclaimed
+// and deletes the auxiliary resources its instance left behind; only a
+// failed launch is an error
+func relaunchReclaimedRunner(reclaimed RunManifest) error {
+	emitEvent("instance.reclaimed", map[string]any{
+		"instance_id": reclaimed.InstanceID,
+		"runner_name": reclaimed.RunnerName,
+	})
+	if outputFormat != "github-actions" {
+		fmt.Printf("♻️  EC2 reclaimed %s (runner %s), launching a replacement...\n",
+			reclaimed.InstanceID, reclaimed.RunnerName)
+	}
+	replacementID, err := launchReplacement()
+	if err != nil {
+		return fmt.Errorf("failed to relaunch %s: %w", reclaimed.InstanceID, err)
+	}
+	emitEvent("instance.relaunched", map[string]any{
+		"instance_id":    reclaimed.InstanceID,
+		"replacement_id": replacementID,
+	})
+	if err := deleteAuxResources(reclaimed.Resources, keepVolumes); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to clean up the resources of %s: %v\n", reclaimed.InstanceID, err)
+	}
+	return nil
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Relaunch spot runners EC2 reclaims",
+	Long: "Watch the spot runners of the repository and labels, and launch a runner with the create flags " +
+		"whenever EC2 reclaims one, so the pool keeps its size. Runners retired on a rebalance recommendation " +
+		"(--spot-rebalance replace) are replaced as well. Runs until interrupted",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireGitHubToken(); err != nil {
+			return err
+		}
+		if err := validateCreateFlags(); err != nil {
+			return err
+		}
+		if launchCount != 1 {
+			return fmt.Errorf("daemon launches one runner per reclaimed runner; count is not supported")
+		}
+		if err := validateReplacementFlags("daemon"); err != nil {
+			return err
+		}
+		if daemonPollInterval < 10*time.Second {
+			return fmt.Errorf("poll-interval must be at least 10s")
+		}
+		svc, err := createEC2Client()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		repository := runnerScope(repoOwner, repoName)
+		emitEvent("daemon.started", map[string]any{"repository": repository, "labels": runnerLabels})
+		if outputFormat != "github-actions" {
+			fmt.Printf("👀 Watching the spot runners of %s (%s) every %s...\n", repository, runnerLabels,
+				daemonPollInterval)
+		}
+
+		// Runners reclaimed before the daemon started may have been relaunched already
+		handled := map[string]bool{}
+		first := true
+		// Replacements of retired runners, in case tagging the retired instance failed
+		replaced := map[string]string{}
+		for {
+			reclaimed, err := reclaimedSpotRunners(svc, repository, runnerLabels)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+			}
+			for _, runner := range reclaimed {
+				if handled[runner.InstanceID] {
+					continue
+				}
+				handled[runner.InstanceID] = true
+				if first {
+					continue
+				}
+				if err := relaunchReclaimedRunner(runner); err != nil {
+					// Retried on the next poll, e.g. once there is capacity again
+					fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+					delete(handled, runner.InstanceID)
+				}
+			}
+			first = first && err != nil
+
+			if spotRebalance == rebalanceReplace {
+				rebalanced, err := rebalancedRunners(repository, runnerLabels)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+				}
+				for _, runner := range rebalanced {
+					runner.ReplacedBy = firstNonEmpty(runner.ReplacedBy, replaced[runner.InstanceID])
+					replacementID, err := replaceRetiredRunner(runner)
+					if replacementID != "" {
+						replaced[runner.InstanceID] = replacementID
+					}
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+					}
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				emitEvent("daemon.stopped", nil)
+				if outputFormat != "github-actions" {
+					fmt.Printf("👋 Stopped watching %s\n", repository)
+				}
+				return nil
+			case <-time.After(daemonPollInterval):
+			}
+		}
+	},
+}
+
+func init() {
+	daemonCmd.Flags().DurationVar(&daemonPollInterval, "poll-interval", 30*time.Second,
+		"How often to look for reclaimed runners")
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	createDeadline time.Duration
+	tokenTimeout   time.Duration
+	launchTimeout  time.Duration
+	runningTimeout time.Duration
+)
+
+// defaultRunningWait is how long create waits for the instance to run without
+// --running-timeout; running out then only prints a warning, as the runner may
+// still come up
+const defaultRunningWait = 5 * time.Minute
+
+// createDeadlineAt is when the current create must be done by; zero without --deadline
+var createDeadlineAt time.Time
+
+// startCreateDeadline starts the --deadline clock of a create
+func startCreateDeadline() {
+	createDeadlineAt = time.Time{}
+	if createDeadline > 0 {
+		createDeadlineAt = time.Now().Add(createDeadline)
+	}
+}
+
+// phaseTimeout returns how long a phase may take: its own timeout capped by
+// what is left of --deadline, 0 meaning unbounded and a negative value that
+// the deadline has already passed
+func phaseTimeout(timeout time.Duration) time.Duration {
+	if createDeadlineAt.IsZero() {
+		return timeout
+	}
+	remaining := time.Until(createDeadlineAt)
+	if remaining <= 0 {
+		return -1
+	}
+	if timeout <= 0 || remaining < timeout {
+		return remaining
+	}
+	return timeout
+}
+
+// runPhase runs a create phase under its timeout and fails with ErrDeadline
+// when the timeout or --deadline is exceeded; fn should honour ctx, and is
+// abandoned when it does not
+func runPhase(phase string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	limit := phaseTimeout(timeout)
+	if limit < 0 {
+		return fmt.Errorf("%w: deadline of %s exceeded before %s", ErrDeadline, createDeadline, phase)
+	}
+	if limit == 0 {
+		return fn(context.Background())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), limit)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+
+	select {
+	case err := <-done:
+		if err != nil && ctx.Err() != nil {
+			return fmt.Errorf("%w: %s did not finish within %s: %v", ErrDeadline, phase, limit.Round(time.Second), err)
+		}
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %s did not finish within %s", ErrDeadline, phase, limit.Round(time.Second))
+	}
+}
+
+// rollbackCreate terminates the instance of a create that ran out of time,
+// deregistering its runner and deleting its auxiliary resources; an instance
+// whose launch timed out is looked up by its correlation ID
+func rollbackCreate(manifest RunManifest) {
+	instanceID := manifest.InstanceID
+	if instanceID == "" {
+		svc, err := createEC2Client()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Rollback failed: %v\n", err)
+			return
+		}
+		instance, err := findInstanceByCorrelationID(svc, manifest.CorrelationID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Rollback failed to look up the launched instance: %v\n", err)
+			return
+		}
+		if instance == nil {
+			return
+		}
+		instanceID = *instance.InstanceId
+	}
+
+	if outputFormat != "github-actions" {
+		fmt.Printf("↩️  Rolling back: terminating instance %s\n", instanceID)
+	}
+	emitEvent("create.rollback", map[string]any{"instance_id": instanceID})
+	if err := terminateEC2Instance(instanceID, true, terminationTimeout); err != nil && !errors.Is(err, ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "⚠️  Rollback failed to terminate instance %s: %v\n", instanceID, err)
+	}
+}
+
+// validateDeadlineFlags checks --deadline and the phase timeouts
+func validateDeadlineFlags() error {
+	for name, value := range map[string]time.Duration{
+		"deadline":        createDeadline,
+		"token-timeout":   tokenTimeout,
+		"launch-timeout":  launchTimeout,
+		"running-timeout": runningTimeout,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().DurationVar(&createDeadline, "deadline", 0,
+		"Abort the whole create after this long, terminating a launched instance (0 for no deadline)")
+	createCmd.Flags().DurationVar(&tokenTimeout, "token-timeout", 0,
+		"Abort if fetching the registration token takes longer (0 for no limit)")
+	createCmd.Flags().DurationVar(&launchTimeout, "launch-timeout", 0,
+		"Abort if launching the instance takes longer (0 for no limit)")
+	createCmd.Flags().DurationVar(&runningTimeout, "running-timeout", 0,
+		"Abort if the instance is not running within this long (0 for no limit: warn after 5m)")
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	authDevice   bool
+	authClientID string
+	authScopes   []string
+)
+
+// deviceCodeResponse is GitHub's response to a device authorization request
+type deviceCodeResponse struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// deviceTokenResponse is GitHub's response while polling for a device flow token
+type deviceTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	Scope            string `json:"scope"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	Interval         int    `json:"interval"`
+}
+
+// githubWebBase returns the web (OAuth) base URL for a GitHub host
+func githubWebBase(host string) string {
+	if host == "" {
+		host = defaultGitHubHost
+	}
+	return "https://" + host
+}
+
+// postOAuthForm posts a form to a GitHub OAuth endpoint and decodes the JSON response
+func postOAuthForm(endpoint string, form url.Values, out any) error {
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent())
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return classifyGitHubResponse(resp, body)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+	return nil
+}
+
+// deviceFlowToken runs GitHub's OAuth device flow and returns the access token
+// once the user has authorized the code in a browser
+func deviceFlowToken(host, clientID string, scopes []string) (string, error) {
+	var code deviceCodeResponse
+	err := postOAuthForm(githubWebBase(host)+"/login/device/code", url.Values{
+		"client_id": {clientID},
+		"scope":     {strings.Join(scopes, " ")},
+	}, &code)
+	if err != nil {
+		return "", fmt.Errorf("failed to start device flow: %w", err)
+	}
+	if code.DeviceCode == "" {
+		return "", fmt.Errorf("device flow is not enabled for OAuth app %s", clientID)
+	}
+
+	fmt.Fprintf(os.Stderr, "! First copy your one-time code: %s\n", code.UserCode)
+	fmt.Fprintf(os.Stderr, "🌐 Open %s in your browser and enter the code\n", code.VerificationURI)
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+
+		var token deviceTokenResponse
+		err := postOAuthForm(githubWebBase(host)+"/login/oauth/access_token", url.Values{
+			"client_id":   {clientID},
+			"device_code": {code.DeviceCode},
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		}, &token)
+		if err != nil {
+			return "", fmt.Errorf("failed to poll for device flow token: %w", err)
+		}
+
+		switch token.Error {
+		case "":
+			if token.AccessToken == "" {
+				return "", fmt.Errorf("device flow returned no access token")
+			}
+			return token.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			// GitHub asks for an extra 5 seconds and returns the new minimum interval
+			if token.Interval > 0 {
+				interval = time.Duration(token.Interval) * time.Second
+			} else {
+				interval += 5 * time.Second
+			}
+		case "access_denied":
+			return "", fmt.Errorf("%w: device authorization was denied", ErrAuth)
+		case "expired_token":
+			return "", fmt.Errorf("%w: device code expired, run auth login --device again", ErrAuth)
+		default:
+			return "", fmt.Errorf("device flow failed: %s: %s", token.Error, token.ErrorDescription)
+		}
+	}
+	return "", fmt.Errorf("%w: device code expired, run auth login --device again", ErrAuth)
+}
+
+func init() {
+	authLoginCmd.Flags().
+		BoolVar(&authDevice, "device", false, "Authenticate in a browser using GitHub's OAuth device flow")
+	authLoginCmd.Flags().StringVar(&authClientID, "client-id", os.Getenv("GH_WORKFLOW_OAUTH_CLIENT_ID"),
+		"Client ID of the OAuth app used for the device flow")
+	authLoginCmd.Flags().
+		StringSliceVar(&authScopes, "scopes", []string{"repo"}, "OAuth scopes to request with the device flow")
+}
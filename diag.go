@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	diagS3URI  string
+	diagUpload string
+)
+
+// diagFailurePattern matches the worker log lines of a failed or cancelled
+// step or job; the job-completed hook runs before the job's final result is logged
+const diagFailurePattern = `result.{0,40}(Failed|Canceled|Cancelled)`
+
+// diagHookLines returns the job-completed hook lines of --diag-s3-uri: they
+// tar the runner's _diag directory, which holds the runner and worker logs,
+// with the user data log and upload it to
+// <uri>/<owner>/<repo>/<run id>-<attempt>-<job>/<instance id>.tar.gz
+func diagHookLines() []string {
+	if diagS3URI == "" {
+		return nil
+	}
+	lines := []string{
+		`if [ "$PHASE" = job-completed ] && command -v aws >/dev/null; then`,
+		"    WORKER_LOG=$(ls -t /actions-runner/_diag/Worker_*.log 2>/dev/null | head -1)",
+	}
+	if diagUpload == "failure" {
+		lines = append(lines, fmt.Sprintf(
+			`    if [ -n "$WORKER_LOG" ] && grep -qE '%s' "$WORKER_LOG"; then UPLOAD=1; fi`, diagFailurePattern))
+	} else {
+		lines = append(lines, "    UPLOAD=1")
+	}
+	return append(lines,
+		`    if [ -n "$UPLOAD" ]; then`,
+		`        ARCHIVE="/tmp/gh-workflow-diag-${JOB}.tar.gz"`,
+		`        tar czf "$ARCHIVE" -C /actions-runner _diag -C /var/log user-data.log 2>/dev/null`,
+		`        aws s3 cp --region "$REGION" --only-show-errors "$ARCHIVE" \`,
+		fmt.Sprintf(`            "%s/${GITHUB_REPOSITORY}/${JOB}/${IID}.tar.gz" || true`,
+			strings.TrimSuffix(diagS3URI, "/")),
+		`        rm -f "$ARCHIVE"`,
+		"    fi",
+		"fi",
+	)
+}
+
+// validateDiagFlags checks --diag-s3-uri and --diag-upload
+func validateDiagFlags() error {
+	if diagUpload != "failure" && diagUpload != "always" {
+		return fmt.Errorf("diag-upload must be 'failure' or 'always'")
+	}
+	if diagS3URI == "" {
+		return nil
+	}
+	if !strings.HasPrefix(diagS3URI, "s3://") || len(diagS3URI) <= len("s3://") ||
+		strings.ContainsAny(diagS3URI, " '\"$`;") {
+		return fmt.Errorf("diag-s3-uri must be an S3 URI such as s3://bucket/prefix, got %q", diagS3URI)
+	}
+	if runnerOS != "linux" {
+		return fmt.Errorf("diag-s3-uri is only supported with --os linux")
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().StringVar(&diagS3URI, "diag-s3-uri", "",
+		"Upload the runner diagnostics of completed jobs to this S3 prefix "+
+			"(needs the AWS CLI and s3:PutObject on the instance)")
+	createCmd.Flags().StringVar(&diagUpload, "diag-upload", "failure",
+		"Which jobs to upload diagnostics for with --diag-s3-uri (failure or always)")
+}
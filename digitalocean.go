@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// digitaloceanProvider creates droplets with cloud-init user data; the token
+// comes from --provider-opt token or DIGITALOCEAN_TOKEN
+type digitaloceanProvider struct {
+	api     *cloudAPIClient
+	options map[string]string
+}
+
+type digitaloceanDroplet struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Networks struct {
+		V4 []struct {
+			IPAddress string `json:"ip_address"`
+			Type      string `json:"type"`
+		} `json:"v4"`
+	} `json:"networks"`
+}
+
+func newDigitalOceanProvider(options map[string]string) (Provider, error) {
+	token := providerOption(options, "token", "DIGITALOCEAN_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("%w: digitalocean provider needs --provider-opt token=... or DIGITALOCEAN_TOKEN",
+			ErrAuth)
+	}
+	return &digitaloceanProvider{
+		api:     newCloudAPIClient("DigitalOcean", "https://api.digitalocean.com/v2", token),
+		options: options,
+	}, nil
+}
+
+func (p *digitaloceanProvider) instance(droplet digitaloceanDroplet) ProviderInstance {
+	instance := ProviderInstance{
+		ID:    strconv.FormatInt(droplet.ID, 10),
+		Name:  droplet.Name,
+		State: droplet.Status,
+		Tags:  map[string]string{"Purpose": "GitHub Actions", "RunnerName": droplet.Name},
+	}
+	for _, network := range droplet.Networks.V4 {
+		if network.Type == "public" && instance.PublicIP == "" {
+			instance.PublicIP = network.IPAddress
+		} else if network.Type == "private" && instance.PrivateIP == "" {
+			instance.PrivateIP = network.IPAddress
+		}
+	}
+	return instance
+}
+
+func (p *digitaloceanProvider) Create(spec ProviderSpec) (ProviderInstance, error) {
+	size, image, err := requireSizeAndImage("digitalocean", spec)
+	if err != nil {
+		return ProviderInstance{}, err
+	}
+	region := p.options["region"]
+	if region == "" {
+		return ProviderInstance{}, fmt.Errorf("digitalocean provider needs --provider-opt region=... (e.g. nyc3)")
+	}
+
+	request := map[string]any{
+		"name":      spec.Name,
+		"region":    region,
+		"size":      size,
+		"image":     image,
+		"user_data": spec.UserData,
+		"tags":      []string{providerRunnerTag},
+	}
+	if keys := splitList(p.options["ssh-keys"]); len(keys) > 0 {
+		// Keys are given by ID or fingerprint
+		sshKeys := []any{}
+		for _, key := range keys {
+			if id, err := strconv.ParseInt(key, 10, 64); err == nil {
+				sshKeys = append(sshKeys, id)
+			} else {
+				sshKeys = append(sshKeys, key)
+			}
+		}
+		request["ssh_keys"] = sshKeys
+	}
+	if vpc := p.options["vpc"]; vpc != "" {
+		request["vpc_uuid"] = vpc
+	}
+
+	var response struct {
+		Droplet digitaloceanDroplet `json:"droplet"`
+	}
+	if err := p.api.request("POST", "/droplets", nil, request, &response, http.StatusAccepted); err != nil {
+		return ProviderInstance{}, err
+	}
+	instance := p.instance(response.Droplet)
+	instance.Tags = spec.Tags
+	return instance, nil
+}
+
+func (p *digitaloceanProvider) Terminate(id string) error {
+	return p.api.request("DELETE", "/droplets/"+id, nil, nil, nil, http.StatusNoContent)
+}
+
+func (p *digitaloceanProvider) List() ([]ProviderInstance, error) {
+	instances := []ProviderInstance{}
+	path := "/droplets?per_page=200&tag_name=" + providerRunnerTag
+	for path != "" {
+		var response struct {
+			Droplets []digitaloceanDroplet `json:"droplets"`
+			Links    struct {
+				Pages struct {
+					Next string `json:"next"`
+				} `json:"pages"`
+			} `json:"links"`
+		}
+		if err := p.api.request("GET", path, nil, nil, &response, http.StatusOK); err != nil {
+			return nil, err
+		}
+		for _, droplet := range response.Droplets {
+			instances = append(instances, p.instance(droplet))
+		}
+		path = strings.TrimPrefix(response.Links.Pages.Next, p.api.baseURL)
+	}
+	return instances, nil
+}
+
+func init() {
+	providerFactories["digitalocean"] = newDigitalOceanProvider
+}
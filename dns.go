@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+var (
+	dnsZoneID       string
+	dnsNameTemplate string
+	dnsPublicIP     bool
+	dnsTTL          int64
+)
+
+// renderDNSName renders --dns-name-template against the run manifest, e.g.
+// "{{.RunnerName}}.runners.example.com"
+func renderDNSName(manifest RunManifest) (string, error) {
+	tmpl, err := template.New("dns-name").Option("missingkey=error").Parse(dnsNameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid dns-name-template: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, manifest); err != nil {
+		return "", fmt.Errorf("failed to render dns-name-template: %v", err)
+	}
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(buf.String()), "."))
+	if name == "" {
+		return "", fmt.Errorf("dns-name-template rendered an empty name")
+	}
+	return name, nil
+}
+
+// registerRunnerDNS upserts A (and, when the instance has IPv6, AAAA) records
+// for a running instance and records them for deletion on terminate
+func registerRunnerDNS(svc *ec2.Client, manifest *RunManifest) error {
+	if dnsZoneID == "" {
+		return nil
+	}
+
+	name, err := renderDNSName(*manifest)
+	if err != nil {
+		return err
+	}
+
+	result, err := svc.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{
+		InstanceIds: []string{manifest.InstanceID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe instance %s: %w", manifest.InstanceID, classifyAWSError(err))
+	}
+	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
+		return fmt.Errorf("%w: instance %s", ErrNotFound, manifest.InstanceID)
+	}
+	instance := result.Reservations[0].Instances[0]
+
+	addresses := map[types.RRType]string{}
+	if dnsPublicIP {
+		addresses[types.RRTypeA] = aws.ToString(instance.PublicIpAddress)
+	} else {
+		addresses[types.RRTypeA] = aws.ToString(instance.PrivateIpAddress)
+	}
+	addresses[types.RRTypeAaaa] = aws.ToString(instance.Ipv6Address)
+
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	client := route53.NewFromConfig(cfg)
+
+	for _, recordType := range []types.RRType{types.RRTypeA, types.RRTypeAaaa} {
+		address := addresses[recordType]
+		if address == "" {
+			if recordType == types.RRTypeA {
+				return fmt.Errorf("instance %s has no IPv4 address for %s", manifest.InstanceID, name)
+			}
+			continue
+		}
+
+		_, err := client.ChangeResourceRecordSets(context.TODO(), &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(dnsZoneID),
+			ChangeBatch: &types.ChangeBatch{
+				Comment: aws.String(fmt.Sprintf("gh-workflow runner %s", manifest.InstanceID)),
+				Changes: []types.Change{
+					{
+						Action: types.ChangeActionUpsert,
+						ResourceRecordSet: &types.ResourceRecordSet{
+							Name:            aws.String(name),
+							Type:            recordType,
+							TTL:             aws.Int64(dnsTTL),
+							ResourceRecords: []types.ResourceRecord{{Value: aws.String(address)}},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to register %s record %s: %w", recordType, name, classifyAWSError(err))
+		}
+
+		resource := AuxResource{Type: auxDNSRecord, ID: string(recordType) + "/" + name, Detail: dnsZoneID}
+		if err := trackAuxResource(svc, manifest.InstanceID, resource); err != nil {
+			return err
+		}
+		manifest.Resources = appendAuxResource(manifest.Resources, resource)
+
+		emitEvent("dns.registered", map[string]any{"name": name, "type": string(recordType), "address": address})
+		if outputFormat != "github-actions" {
+			fmt.Printf("🌐 Registered %s %s -> %s\n", recordType, name, address)
+		}
+	}
+
+	if outputFormat == "github-actions" {
+		fmt.Printf("DNS Name: %s\n", name)
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().
+		StringVar(&dnsZoneID, "dns-zone-id", "", "Route53 hosted zone ID to register the runner's DNS name in")
+	createCmd.Flags().StringVar(&dnsNameTemplate, "dns-name-template", "{{.RunnerName}}",
+		"Go template for the runner's DNS name, e.g. {{.RunnerName}}.runners.example.com")
+	createCmd.Flags().
+		BoolVar(&dnsPublicIP, "dns-public-ip", false, "Register the public instead of the private IPv4 address")
+	createCmd.Flags().Int64Var(&dnsTTL, "dns-ttl", 60, "TTL in seconds of the runner's DNS records")
+}
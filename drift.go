@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var failOnDrift bool
+
+// DriftReport compares a runner instance with the desired configuration
+type DriftReport struct {
+	InstanceID    string   `json:"instance_id"`
+	Repository    string   `json:"repository"`
+	RunnerName    string   `json:"runner_name"`
+	RunnerVersion string   `json:"runner_version,omitempty"`
+	ImageID       string   `json:"image_id"`
+	UserDataHash  string   `json:"user_data_hash,omitempty"`
+	Status        string   `json:"status"`
+	Reasons       []string `json:"reasons,omitempty"`
+}
+
+// Drift statuses; instances launched before the tags existed are unknown
+const (
+	driftCurrent = "current"
+	driftStale   = "stale"
+	driftUnknown = "unknown"
+)
+
+// compareDrift compares a runner instance's recorded configuration with the
+// desired runner version, AMI and user data hash
+func compareDrift(instance RunManifest, wantVersion, wantImage, wantHash string) DriftReport {
+	report := DriftReport{
+		InstanceID:    instance.InstanceID,
+		Repository:    instance.Repository,
+		RunnerName:    instance.RunnerName,
+		RunnerVersion: instance.RunnerVersion,
+		ImageID:       instance.ImageID,
+		UserDataHash:  instance.UserDataHash,
+		Status:        driftCurrent,
+	}
+	if instance.ImageID != wantImage {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("AMI %s, want %s", instance.ImageID, wantImage))
+	}
+	if wantVersion != "" && instance.RunnerVersion != "" && instance.RunnerVersion != wantVersion {
+		report.Reasons = append(report.Reasons,
+			fmt.Sprintf("runner %s, want %s", instance.RunnerVersion, wantVersion))
+	}
+	if instance.UserDataHash != "" && instance.UserDataHash != wantHash {
+		report.Reasons = append(report.Reasons,
+			fmt.Sprintf("user data %s, want %s", instance.UserDataHash, wantHash))
+	}
+
+	switch {
+	case len(report.Reasons) > 0:
+		report.Status = driftStale
+	case instance.UserDataHash == "":
+		report.Status = driftUnknown
+		report.Reasons = append(report.Reasons, "launched without the UserDataHash tag")
+	}
+	return report
+}
+
+var driftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Report runner instances that differ from the desired configuration",
+	Long: "Compare the runner version, AMI and user data hash recorded on each runner instance with what create " +
+		"would launch with the given flags and config file, and report the stale instances",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateCreateFlags(); err != nil {
+			return err
+		}
+		svc, err := createEC2Client()
+		if err != nil {
+			return err
+		}
+
+		// Resolve the labels the way create does, as they are part of the user data
+		runnerArch, err = resolveRunnerArch(svc, instanceType, imageID)
+		if err != nil {
+			return err
+		}
+		labels := archLabels(runnerLabels, runnerArch)
+		wantHash := userDataHash(repoOwner, repoName, labels, preRunnerScript)
+		wantVersion := runnerVersion
+		if useBakedAMI {
+			wantVersion = ""
+		}
+
+		instances, err := listRunnerInstances(svc)
+		if err != nil {
+			return err
+		}
+		scope := runnerScope(repoOwner, repoName)
+		reports := []DriftReport{}
+		stale := 0
+		for _, instance := range instances {
+			manifest := manifestFromInstance(instance)
+			if manifest.Repository != scope {
+				continue
+			}
+			report := compareDrift(manifest, wantVersion, imageID, wantHash)
+			if report.Status == driftStale {
+				stale++
+			}
+			reports = append(reports, report)
+		}
+
+		if outputFormat == "json" {
+			data, err := json.MarshalIndent(reports, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode drift report: %v", err)
+			}
+			fmt.Println(string(data))
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "INSTANCE ID\tRUNNER\tSTATUS\tREASONS")
+			for _, report := range reports {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", report.InstanceID, report.RunnerName, report.Status,
+					strings.Join(report.Reasons, "; "))
+			}
+			w.Flush()
+			fmt.Printf("\n%d of %d instance(s) of %s are stale (want user data %s)\n",
+				stale, len(reports), scope, wantHash)
+		}
+
+		emitEvent("drift.checked", map[string]any{"repository": scope, "instances": len(reports), "stale": stale})
+		if failOnDrift && stale > 0 {
+			return fmt.Errorf("%d runner instance(s) are stale", stale)
+		}
+		return nil
+	},
+}
+
+func init() {
+	driftCmd.Flags().BoolVar(&failOnDrift, "fail-on-drift", false, "Exit with an error when any instance is stale")
+	rootCmd.AddCommand(driftCmd)
+}
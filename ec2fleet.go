@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	launchMode             string
+	fleetInstanceTypes     string
+	spotAllocationStrategy string
+)
+
+// Launch modes: a RunInstances call per instance type and subnet tried, or one
+// EC2 Fleet (CreateFleet) request choosing among all of them
+const (
+	launchModeRunInstances = "run-instances"
+	launchModeFleet        = "fleet"
+)
+
+// maxFleetInstanceTypes bounds --fleet-instance-types; every type is paired
+// with every subnet in the fleet request
+const maxFleetInstanceTypes = 20
+
+// spotAllocationStrategies are the --spot-allocation-strategy values
+var spotAllocationStrategies = []string{"lowest-price", "capacity-optimized", "price-capacity-optimized"}
+
+// validateFleetFlags checks --launch-mode, --fleet-instance-types and
+// --spot-allocation-strategy
+func validateFleetFlags() error {
+	switch launchMode {
+	case launchModeRunInstances:
+		if fleetInstanceTypes != "" {
+			return fmt.Errorf("fleet-instance-types needs --launch-mode fleet")
+		}
+		return nil
+	case launchModeFleet:
+	default:
+		return fmt.Errorf("launch-mode must be %s or %s, got %q", launchModeRunInstances, launchModeFleet, launchMode)
+	}
+
+	if len(fleetTypes()) > maxFleetInstanceTypes {
+		return fmt.Errorf("a fleet can choose among at most %d instance types", maxFleetInstanceTypes)
+	}
+	if !slices.Contains(spotAllocationStrategies, spotAllocationStrategy) {
+		return fmt.Errorf("spot-allocation-strategy must be one of %s, got %q",
+			strings.Join(spotAllocationStrategies, ", "), spotAllocationStrategy)
+	}
+	// CPU options and credits are per instance type and would be applied to every type of the fleet
+	if cpuCoreCount != 0 || threadsPerCore != 0 || creditSpecification != "" {
+		return fmt.Errorf("cpu-core-count, threads-per-core and credit-specification can't be used with " +
+			"--launch-mode fleet")
+	}
+	return nil
+}
+
+// fleetTypes returns the instance types a fleet chooses among: --instance-type
+// with its fallback instance types first, then --fleet-instance-types
+func fleetTypes() []string {
+	types := withFallbackInstanceTypes(instanceType)
+	for _, fleetType := range splitList(fleetInstanceTypes) {
+		if !slices.Contains(types, fleetType) {
+			types = append(types, fleetType)
+		}
+	}
+	return types
+}
+
+// launchTemplateData converts a launch request into launch template data; the
+// instance type, subnet and market are left to the fleet request
+func launchTemplateData(runInput *ec2.RunInstancesInput) *types.RequestLaunchTemplateData {
+	data := &types.RequestLaunchTemplateData{
+		ImageId:                           runInput.ImageId,
+		KeyName:                           runInput.KeyName,
+		UserData:                          runInput.UserData,
+		DisableApiTermination:             runInput.DisableApiTermination,
+		InstanceInitiatedShutdownBehavior: runInput.InstanceInitiatedShutdownBehavior,
+	}
+	if len(runInput.NetworkInterfaces) == 0 {
+		data.SecurityGroupIds = runInput.SecurityGroupIds
+	}
+	for _, nic := range runInput.NetworkInterfaces {
+		data.NetworkInterfaces = append(data.NetworkInterfaces,
+			types.LaunchTemplateInstanceNetworkInterfaceSpecificationRequest{
+				DeviceIndex:              nic.DeviceIndex,
+				Groups:                   nic.Groups,
+				AssociatePublicIpAddress: nic.AssociatePublicIpAddress,
+				DeleteOnTermination:      nic.DeleteOnTermination,
+			})
+	}
+	if profile := runInput.IamInstanceProfile; profile != nil {
+		data.IamInstanceProfile = &types.LaunchTemplateIamInstanceProfileSpecificationRequest{
+			Arn:  profile.Arn,
+			Name: profile.Name,
+		}
+	}
+	for _, mapping := range runInput.BlockDeviceMappings {
+		request := types.LaunchTemplateBlockDeviceMappingRequest{
+			DeviceName:  mapping.DeviceName,
+			VirtualName: mapping.VirtualName,
+			NoDevice:    mapping.NoDevice,
+		}
+		if ebs := mapping.Ebs; ebs != nil {
+			request.Ebs = &types.LaunchTemplateEbsBlockDeviceRequest{
+				DeleteOnTermination: ebs.DeleteOnTermination,
+				Encrypted:           ebs.Encrypted,
+				Iops:                ebs.Iops,
+				KmsKeyId:            ebs.KmsKeyId,
+				SnapshotId:          ebs.SnapshotId,
+				Throughput:          ebs.Throughput,
+				VolumeSize:          ebs.VolumeSize,
+				VolumeType:          ebs.VolumeType,
+			}
+		}
+		data.BlockDeviceMappings = append(data.BlockDeviceMappings, request)
+	}
+	for _, spec := range runInput.TagSpecifications {
+		data.TagSpecifications = append(data.TagSpecifications, types.LaunchTemplateTagSpecificationRequest{
+			ResourceType: spec.ResourceType,
+			Tags:         spec.Tags,
+		})
+	}
+	return data
+}
+
+// launchSubnet returns the subnet a launch request targets
+func launchSubnet(runInput *ec2.RunInstancesInput) string {
+	if len(runInput.NetworkInterfaces) > 0 {
+		return aws.ToString(runInput.NetworkInterfaces[0].SubnetId)
+	}
+	return aws.ToString(runInput.SubnetId)
+}
+
+// createFleetInstance launches the instance with an instant EC2 Fleet choosing
+// among every instance type of fleetTypes in every subnet. CreateFleet only
+// takes launch templates, so the launch request becomes a temporary launch
+// template, deleted once the fleet has launched. The result has the shape of
+// a RunInstances result with the chosen instance type and subnet
+func createFleetInstance(ctx context.Context, svc *ec2.Client, runInput *ec2.RunInstancesInput,
+	subnets []string) (*ec2.RunInstancesOutput, error) {
+	if len(subnets) == 0 {
+		subnets = []string{launchSubnet(runInput)}
+	}
+	token := aws.ToString(runInput.ClientToken)
+
+	template, err := svc.CreateLaunchTemplate(ctx, &ec2.CreateLaunchTemplateInput{
+		LaunchTemplateName: aws.String("gh-workflow-" + token),
+		LaunchTemplateData: launchTemplateData(runInput),
+		ClientToken:        aws.String(token),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the fleet's launch template: %w", classifyAWSError(err))
+	}
+	templateID := aws.ToString(template.LaunchTemplate.LaunchTemplateId)
+	defer func() {
+		_, err := svc.DeleteLaunchTemplate(context.TODO(), &ec2.DeleteLaunchTemplateInput{
+			LaunchTemplateId: aws.String(templateID),
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Failed to delete launch template %s: %v\n",
+				templateID, classifyAWSError(err))
+		}
+	}()
+
+	spot := runInput.InstanceMarketOptions != nil
+	var maxPrice *string
+	if spot && runInput.InstanceMarketOptions.SpotOptions != nil {
+		maxPrice = runInput.InstanceMarketOptions.SpotOptions.MaxPrice
+	}
+	// On-demand fleets launch the types in the order given, the first being --instance-type
+	var overrides []types.FleetLaunchTemplateOverridesRequest
+	for priority, fleetType := range fleetTypes() {
+		for _, subnet := range subnets {
+			overrides = append(overrides, types.FleetLaunchTemplateOverridesRequest{
+				InstanceType: types.InstanceType(fleetType),
+				SubnetId:     aws.String(subnet),
+				MaxPrice:     maxPrice,
+				Priority:     aws.Float64(float64(priority)),
+			})
+		}
+	}
+
+	input := &ec2.CreateFleetInput{
+		Type:        types.FleetTypeInstant,
+		ClientToken: aws.String(token),
+		LaunchTemplateConfigs: []types.FleetLaunchTemplateConfigRequest{{
+			LaunchTemplateSpecification: &types.FleetLaunchTemplateSpecificationRequest{
+				LaunchTemplateId: aws.String(templateID),
+				Version:          aws.String("$Latest"),
+			},
+			Overrides: overrides,
+		}},
+		TargetCapacitySpecification: &types.TargetCapacitySpecificationRequest{
+			TotalTargetCapacity:       aws.Int32(1),
+			DefaultTargetCapacityType: types.DefaultTargetCapacityTypeOnDemand,
+		},
+		OnDemandOptions: &types.OnDemandOptionsRequest{
+			AllocationStrategy: types.FleetOnDemandAllocationStrategyPrioritized,
+		},
+	}
+	if spot {
+		input.TargetCapacitySpecification.DefaultTargetCapacityType = types.DefaultTargetCapacityTypeSpot
+		input.SpotOptions = &types.SpotOptionsRequest{
+			AllocationStrategy: types.SpotAllocationStrategy(spotAllocationStrategy),
+		}
+	}
+
+	result, err := svc.CreateFleet(ctx, input)
+	if awsErrorCode(err) == "IdempotentParameterMismatch" {
+		// An earlier try of this create used the token; its instance is gone (see findRetriedLaunch)
+		suffix := make([]byte, 4)
+		_, _ = rand.Read(suffix)
+		input.ClientToken = aws.String(token + "-" + hex.EncodeToString(suffix))
+		result, err = svc.CreateFleet(ctx, input)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, launched := range result.Instances {
+		if len(launched.InstanceIds) == 0 {
+			continue
+		}
+		instance := types.Instance{
+			InstanceId:   aws.String(launched.InstanceIds[0]),
+			InstanceType: launched.InstanceType,
+		}
+		if chosen := launched.LaunchTemplateAndOverrides; chosen != nil && chosen.Overrides != nil {
+			instance.SubnetId = chosen.Overrides.SubnetId
+		}
+		emitEvent("instance.fleet_launched", map[string]any{
+			"fleet_id":      aws.ToString(result.FleetId),
+			"instance_type": string(launched.InstanceType),
+			"subnet_id":     aws.ToString(instance.SubnetId),
+			"lifecycle":     string(launched.Lifecycle),
+		})
+		if outputFormat != "github-actions" {
+			fmt.Printf("🛳️  Fleet %s launched %s (%s) in %s\n", aws.ToString(result.FleetId),
+				launched.InstanceType, launched.Lifecycle, aws.ToString(instance.SubnetId))
+		}
+		return &ec2.RunInstancesOutput{Instances: []types.Instance{instance}}, nil
+	}
+
+	// An instant fleet that launched nothing reports why per instance type and subnet
+	if len(result.Errors) > 0 {
+		first := result.Errors[0]
+		messages := []string{}
+		for _, fleetErr := range result.Errors {
+			message := aws.ToString(fleetErr.ErrorMessage)
+			if !slices.Contains(messages, message) {
+				messages = append(messages, message)
+			}
+		}
+		return nil, &smithy.GenericAPIError{
+			Code:    aws.ToString(first.ErrorCode),
+			Message: fmt.Sprintf("fleet launched no instance: %s", strings.Join(messages, "; ")),
+		}
+	}
+	return nil, fmt.Errorf("fleet %s launched no instance", aws.ToString(result.FleetId))
+}
+
+func init() {
+	createCmd.Flags().StringVar(&launchMode, "launch-mode", launchModeRunInstances,
+		"How to launch: run-instances, or fleet (EC2 CreateFleet choosing among instance types and subnets)")
+	createCmd.Flags().StringVar(&fleetInstanceTypes, "fleet-instance-types", "",
+		"Further instance types a fleet launch may choose besides --instance-type (comma-separated)")
+	createCmd.Flags().StringVar(&spotAllocationStrategy, "spot-allocation-strategy", "price-capacity-optimized",
+		"How a spot fleet picks the instance type and subnet: lowest-price, capacity-optimized or "+
+			"price-capacity-optimized")
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/websocket"
+)
+
+var (
+	sshEICEndpoint       string
+	sshCreateEICEndpoint bool
+	tunnelEndpointID     string
+	tunnelEndpointHost   string
+	tunnelPrivateIP      string
+	tunnelPort           int
+)
+
+// eicServiceName is the SigV4 signing name of EC2 Instance Connect Endpoint tunnels
+const eicServiceName = "ec2-instance-connect"
+
+// maxTunnelDuration is the longest tunnel an EIC Endpoint allows, in seconds
+const maxTunnelDuration = 3600
+
+// findEICEndpoint returns the EC2 Instance Connect Endpoint to use for a VPC:
+// the given endpoint ID, which must be in that VPC, or any ready endpoint in it
+func findEICEndpoint(svc *ec2.Client, vpcID, endpointID string) (*types.Ec2InstanceConnectEndpoint, error) {
+	input := &ec2.DescribeInstanceConnectEndpointsInput{
+		Filters: []types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}},
+	}
+	if endpointID != "" && endpointID != "auto" {
+		input = &ec2.DescribeInstanceConnectEndpointsInput{InstanceConnectEndpointIds: []string{endpointID}}
+	}
+
+	result, err := svc.DescribeInstanceConnectEndpoints(context.TODO(), input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe EC2 Instance Connect Endpoints: %w", classifyAWSError(err))
+	}
+	for _, endpoint := range result.InstanceConnectEndpoints {
+		if aws.ToString(endpoint.VpcId) != vpcID {
+			return nil, fmt.Errorf("EC2 Instance Connect Endpoint %s is in VPC %s, but the instance is in %s",
+				aws.ToString(endpoint.InstanceConnectEndpointId), aws.ToString(endpoint.VpcId), vpcID)
+		}
+		if endpoint.State == types.Ec2InstanceConnectEndpointStateCreateComplete {
+			return &endpoint, nil
+		}
+	}
+	return nil, nil
+}
+
+// createEICEndpoint creates an EC2 Instance Connect Endpoint in the subnet and
+// waits until it is ready
+func createEICEndpoint(svc *ec2.Client, subnetID string) (*types.Ec2InstanceConnectEndpoint, error) {
+	fmt.Fprintf(os.Stderr, "🔧 Creating EC2 Instance Connect Endpoint in %s (this takes a few minutes)...\n",
+		subnetID)
+	result, err := svc.CreateInstanceConnectEndpoint(context.TODO(), &ec2.CreateInstanceConnectEndpointInput{
+		SubnetId: aws.String(subnetID),
+		TagSpecifications: []types.TagSpecification{
+			{
+				ResourceType: types.ResourceTypeInstanceConnectEndpoint,
+				Tags: []types.Tag{
+					{Key: aws.String("Purpose"), Value: aws.String("GitHub Actions")},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create EC2 Instance Connect Endpoint: %w", classifyAWSError(err))
+	}
+	endpointID := aws.ToString(result.InstanceConnectEndpoint.InstanceConnectEndpointId)
+
+	deadline := time.Now().Add(10 * time.Minute)
+	for time.Now().Before(deadline) {
+		time.Sleep(15 * time.Second)
+		described, err := svc.DescribeInstanceConnectEndpoints(context.TODO(),
+			&ec2.DescribeInstanceConnectEndpointsInput{InstanceConnectEndpointIds: []string{endpointID}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe EC2 Instance Connect Endpoint: %w", classifyAWSError(err))
+		}
+		if len(described.InstanceConnectEndpoints) == 0 {
+			continue
+		}
+		endpoint := described.InstanceConnectEndpoints[0]
+		switch endpoint.State {
+		case types.Ec2InstanceConnectEndpointStateCreateComplete:
+			emitEvent("eic_endpoint.created", map[string]any{"endpoint_id": endpointID, "subnet_id": subnetID})
+			return &endpoint, nil
+		case types.Ec2InstanceConnectEndpointStateCreateFailed:
+			return nil, fmt.Errorf("EC2 Instance Connect Endpoint %s failed: %s",
+				endpointID, aws.ToString(endpoint.StateMessage))
+		}
+	}
+	return nil, fmt.Errorf("timeout waiting for EC2 Instance Connect Endpoint %s", endpointID)
+}
+
+// eicProxyCommand validates (or creates) the EC2 Instance Connect Endpoint for
+// an instance's VPC and returns the instance's private IP and an ssh
+// ProxyCommand tunnelling through the endpoint
+func eicProxyCommand(svc *ec2.Client, instanceID string) (string, string, error) {
+	result, err := svc.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to find instance %s: %w", instanceID, classifyAWSError(err))
+	}
+	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
+		return "", "", fmt.Errorf("%w: instance %s", ErrNotFound, instanceID)
+	}
+	instance := result.Reservations[0].Instances[0]
+	vpcID, privateIP := aws.ToString(instance.VpcId), aws.ToString(instance.PrivateIpAddress)
+	if privateIP == "" {
+		return "", "", fmt.Errorf("instance %s has no private IP address", instanceID)
+	}
+
+	endpoint, err := findEICEndpoint(svc, vpcID, sshEICEndpoint)
+	if err != nil {
+		return "", "", err
+	}
+	if endpoint == nil {
+		if !sshCreateEICEndpoint {
+			return "", "", fmt.Errorf("%w: no ready EC2 Instance Connect Endpoint in VPC %s "+
+				"(use --create-eic-endpoint to create one)", ErrNotFound, vpcID)
+		}
+		if endpoint, err = createEICEndpoint(svc, aws.ToString(instance.SubnetId)); err != nil {
+			return "", "", err
+		}
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to locate gh-workflow executable: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "🔌 Tunnelling through EC2 Instance Connect Endpoint %s\n",
+		aws.ToString(endpoint.InstanceConnectEndpointId))
+
+	proxy := fmt.Sprintf("%q eic-tunnel --endpoint-id %s --endpoint-host %s --private-ip %s --port %%p",
+		self, aws.ToString(endpoint.InstanceConnectEndpointId), aws.ToString(endpoint.DnsName), privateIP)
+	return privateIP, proxy, nil
+}
+
+// openEICTunnel opens a WebSocket tunnel to a private IP and port through an
+// EC2 Instance Connect Endpoint, authenticated with a SigV4 presigned URL
+func openEICTunnel(cfg aws.Config, endpointID, endpointHost, privateIP string, port int) (*websocket.Conn, error) {
+	query := url.Values{
+		"instanceConnectEndpointId": {endpointID},
+		"remotePort":                {fmt.Sprint(port)},
+		"privateIpAddress":          {privateIP},
+		"maxTunnelDuration":         {fmt.Sprint(maxTunnelDuration)},
+		"X-Amz-Expires":             {"60"},
+	}
+	req, err := http.NewRequest("GET", "https://"+endpointHost+"/openTunnel?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tunnel request: %v", err)
+	}
+
+	creds, err := cfg.Credentials.Retrieve(context.TODO())
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to retrieve AWS credentials: %v", ErrAuth, err)
+	}
+	emptyHash := sha256.Sum256(nil)
+	signed, _, err := v4.NewSigner().PresignHTTP(context.TODO(), creds, req,
+		hex.EncodeToString(emptyHash[:]), eicServiceName, cfg.Region, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign tunnel request: %v", err)
+	}
+
+	wsConfig, err := websocket.NewConfig(strings.Replace(signed, "https://", "wss://", 1), "https://"+endpointHost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure tunnel: %v", err)
+	}
+	wsConfig.Header.Set("User-Agent", userAgent())
+	conn, err := websocket.DialConfig(wsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tunnel through %s: %v", endpointID, err)
+	}
+	conn.PayloadType = websocket.BinaryFrame
+	return conn, nil
+}
+
+var eicTunnelCmd = &cobra.Command{
+	Use:    "eic-tunnel",
+	Short:  "Tunnel stdin/stdout through an EC2 Instance Connect Endpoint (used as ssh ProxyCommand)",
+	Hidden: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadAWSConfig()
+		if err != nil {
+			return err
+		}
+		conn, err := openEICTunnel(cfg, tunnelEndpointID, tunnelEndpointHost, tunnelPrivateIP, tunnelPort)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		done := make(chan error, 2)
+		go func() {
+			_, err := io.Copy(conn, os.Stdin)
+			done <- err
+		}()
+		go func() {
+			_, err := io.Copy(os.Stdout, conn)
+			done <- err
+		}()
+		if err := <-done; err != nil && err != io.EOF {
+			return fmt.Errorf("tunnel closed: %v", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	sshCmd.Flags().StringVar(&sshEICEndpoint, "eic-endpoint", "",
+		"Connect through an EC2 Instance Connect Endpoint: its ID, or 'auto' to use the one in the instance's VPC")
+	sshCmd.Flags().BoolVar(&sshCreateEICEndpoint, "create-eic-endpoint", false,
+		"Create an EC2 Instance Connect Endpoint in the instance's subnet if the VPC has none")
+
+	eicTunnelCmd.Flags().StringVar(&tunnelEndpointID, "endpoint-id", "", "EC2 Instance Connect Endpoint ID")
+	eicTunnelCmd.Flags().StringVar(&tunnelEndpointHost, "endpoint-host", "", "EC2 Instance Connect Endpoint DNS name")
+	eicTunnelCmd.Flags().StringVar(&tunnelPrivateIP, "private-ip", "", "Private IP address of the instance")
+	eicTunnelCmd.Flags().IntVar(&tunnelPort, "port", 22, "Remote port")
+
+	rootCmd.AddCommand(eicTunnelCmd)
+}
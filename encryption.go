@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var (
+	ebsEncrypted bool
+	kmsKeyID     string
+)
+
+// kmsKeyPattern matches the forms of a KMS key EC2 accepts: a key ID
+// (including multi-Region keys), an alias, or the ARN of either
+var kmsKeyPattern = regexp.MustCompile(`^([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|` +
+	`mrk-[0-9a-f]{32}|alias/[A-Za-z0-9/_-]+|arn:aws[a-z-]*:kms:[a-z0-9-]+:[0-9]{12}:(key|alias)/[A-Za-z0-9/_-]+)$`)
+
+// validateEncryptionFlags checks --kms-key-id, which implies --ebs-encrypted
+func validateEncryptionFlags() error {
+	if kmsKeyID == "" {
+		return nil
+	}
+	if !kmsKeyPattern.MatchString(kmsKeyID) {
+		return fmt.Errorf("kms-key-id %q is not a KMS key ID, alias (alias/...) or ARN", kmsKeyID)
+	}
+	ebsEncrypted = true
+	return nil
+}
+
+// encryptedEBS sets the encryption of an EBS block device, with --kms-key-id
+// or else the account's default EBS key
+func encryptedEBS(ebs *types.EbsBlockDevice) *types.EbsBlockDevice {
+	if ebs == nil {
+		ebs = &types.EbsBlockDevice{}
+	}
+	ebs.Encrypted = aws.Bool(true)
+	if kmsKeyID != "" {
+		ebs.KmsKeyId = aws.String(kmsKeyID)
+	}
+	return ebs
+}
+
+// applyEncryption encrypts every EBS volume of the instance with
+// --ebs-encrypted: the --volume volumes and the root and other volumes of the
+// AMI, whose mappings are added with only the encryption set, so their
+// snapshots, sizes and types stay those of the AMI
+func applyEncryption(svc *ec2.Client, runInput *ec2.RunInstancesInput) error {
+	if !ebsEncrypted {
+		return nil
+	}
+	result, err := svc.DescribeImages(context.TODO(), &ec2.DescribeImagesInput{
+		ImageIds:          []string{aws.ToString(runInput.ImageId)},
+		IncludeDeprecated: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe AMI %s: %w", aws.ToString(runInput.ImageId), classifyAWSError(err))
+	}
+	if len(result.Images) == 0 {
+		return fmt.Errorf("%w: AMI %s does not exist in %s", ErrNotFound, aws.ToString(runInput.ImageId),
+			resolveRegion())
+	}
+	image := result.Images[0]
+
+	mapped := map[string]bool{}
+	for i := range runInput.BlockDeviceMappings {
+		mapping := &runInput.BlockDeviceMappings[i]
+		mapped[aws.ToString(mapping.DeviceName)] = true
+		if mapping.NoDevice == nil && mapping.VirtualName == nil {
+			mapping.Ebs = encryptedEBS(mapping.Ebs)
+		}
+	}
+	rootMapped := false
+	for _, mapping := range image.BlockDeviceMappings {
+		device := aws.ToString(mapping.DeviceName)
+		if mapping.Ebs == nil || mapped[device] {
+			continue
+		}
+		rootMapped = rootMapped || device == aws.ToString(image.RootDeviceName)
+		runInput.BlockDeviceMappings = append(runInput.BlockDeviceMappings, types.BlockDeviceMapping{
+			DeviceName: aws.String(device),
+			Ebs:        encryptedEBS(nil),
+		})
+	}
+	if !rootMapped && image.RootDeviceType == types.DeviceTypeEbs && !mapped[aws.ToString(image.RootDeviceName)] {
+		runInput.BlockDeviceMappings = append(runInput.BlockDeviceMappings, types.BlockDeviceMapping{
+			DeviceName: image.RootDeviceName,
+			Ebs:        encryptedEBS(nil),
+		})
+	}
+
+	if outputFormat != "github-actions" {
+		key := kmsKeyID
+		if key == "" {
+			key = "the account's default EBS key"
+		}
+		fmt.Printf("🔐 Encrypting %d EBS volume(s) with %s\n", len(runInput.BlockDeviceMappings), key)
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().BoolVar(&ebsEncrypted, "ebs-encrypted", false,
+		"Encrypt the root and extra EBS volumes (with the account's default EBS key unless --kms-key-id is set)")
+	createCmd.Flags().StringVar(&kmsKeyID, "kms-key-id", "",
+		"Customer managed KMS key (ID, alias or ARN) to encrypt the EBS volumes with; implies --ebs-encrypted")
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// Error taxonomy shared by AWS and GitHub failures. Errors returned by the
+// create/terminate paths wrap one of these with %w, so callers can use
+// errors.Is instead of matching on error strings.
+var (
+	ErrCapacity = errors.New("insufficient capacity")
+	ErrAuth     = errors.New("authentication or authorization failed")
+	ErrQuota    = errors.New("quota exceeded")
+	ErrNotFound = errors.New("resource not found")
+	ErrThrottle = errors.New("request throttled")
+	ErrReadOnly = errors.New("refused in read-only mode")
+	ErrDeadline = errors.New("deadline exceeded")
+
+	ErrIncompleteCleanup = errors.New("cleanup incomplete")
+)
+
+// Process exit codes for the taxonomy above; anything unclassified exits with 1
+const (
+	exitCodeGeneric  = 1
+	exitCodeAuth     = 3
+	exitCodeNotFound = 4
+	exitCodeCapacity = 5
+	exitCodeQuota    = 6
+	exitCodeThrottle = 7
+	exitCodeReadOnly = 8
+	exitCodeDeadline = 9
+	exitCodeCleanup  = 10
+)
+
+// awsErrorCodes maps AWS API error codes to the error taxonomy
+var awsErrorCodes = map[string]error{
+	// Capacity
+	"InsufficientInstanceCapacity":         ErrCapacity,
+	"InsufficientHostCapacity":             ErrCapacity,
+	"InsufficientReservedInstanceCapacity": ErrCapacity,
+	"InsufficientCapacity":                 ErrCapacity,
+	"InsufficientFreeAddressesInSubnet":    ErrCapacity,
+	"SpotMaxPriceTooLow":                   ErrCapacity,
+
+	// Authentication / authorization
+	"AuthFailure":                 ErrAuth,
+	"UnauthorizedOperation":       ErrAuth,
+	"InvalidClientTokenId":        ErrAuth,
+	"SignatureDoesNotMatch":       ErrAuth,
+	"ExpiredToken":                ErrAuth,
+	"RequestExpired":              ErrAuth,
+	"AccessDenied":                ErrAuth,
+	"AccessDeniedException":       ErrAuth,
+	"OptInRequired":               ErrAuth,
+	"Blocked":                     ErrAuth,
+	"PendingVerification":         ErrAuth,
+	"UnrecognizedClientException": ErrAuth,
+
+	// Quotas and limits
+	"InstanceLimitExceeded":         ErrQuota,
+	"VcpuLimitExceeded":             ErrQuota,
+	"MaxSpotInstanceCountExceeded":  ErrQuota,
+	"ResourceLimitExceeded":         ErrQuota,
+	"AddressLimitExceeded":          ErrQuota,
+	"VolumeLimitExceeded":           ErrQuota,
+	"TagLimitExceeded":              ErrQuota,
+	"NetworkInterfaceLimitExceeded": ErrQuota,
+	"AttachmentLimitExceeded":       ErrQuota,
+
+	// Not found (codes without the ".NotFound" suffix)
+	"ParameterNotFound": ErrNotFound,
+	"NoSuchHostedZone":  ErrNotFound,
+	"NotFoundException": ErrNotFound,
+	"ResourceNotFound":  ErrNotFound,
+
+	// Throttling
+	"RequestLimitExceeded":      ErrThrottle,
+	"Throttling":                ErrThrottle,
+	"ThrottlingException":       ErrThrottle,
+	"TooManyRequestsException":  ErrThrottle,
+	"RequestThrottled":          ErrThrottle,
+	"RequestThrottledException": ErrThrottle,
+}
+
+// awsErrorCode returns the AWS API error code carried by err, or "" if err is
+// not an AWS API error
+func awsErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+// classifyAWSError wraps err with the matching taxonomy error based on its AWS
+// API error code. Errors that do not map to a category are returned unchanged.
+func classifyAWSError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	code := awsErrorCode(err)
+	if code == "" {
+		return err
+	}
+
+	kind, ok := awsErrorCodes[code]
+	if !ok && strings.HasSuffix(code, ".NotFound") {
+		kind, ok = ErrNotFound, true
+	}
+	if !ok || errors.Is(err, kind) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+// classifyGitHubResponse builds an error for a failed GitHub API response,
+// wrapping the matching taxonomy error based on the HTTP status and headers
+func classifyGitHubResponse(resp *http.Response, body []byte) error {
+	err := fmt.Errorf("GitHub API returned status %d: %s", resp.StatusCode, string(body))
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("%w: %w", ErrAuth, err)
+	case http.StatusForbidden:
+		// GitHub reports primary and secondary rate limits as 403
+		if isGitHubThrottled(resp) {
+			return fmt.Errorf("%w: %w%s", ErrThrottle, err, rateLimitReset(resp))
+		}
+		return fmt.Errorf("%w: %w", ErrAuth, err)
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %w%s", ErrThrottle, err, rateLimitReset(resp))
+	}
+
+	return err
+}
+
+// rateLimitReset describes when an exhausted GitHub rate limit resets, if known
+func rateLimitReset(resp *http.Response) string {
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return ""
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf(" (rate limit resets at %s)", formatTimeRelative(time.Unix(reset, 0)))
+}
+
+// exitCode returns the process exit code for err
+func exitCode(err error) int {
+	switch {
+	// Leftovers come first: the errors that caused them are joined to them
+	case errors.Is(err, ErrIncompleteCleanup):
+		return exitCodeCleanup
+	case errors.Is(err, ErrAuth):
+		return exitCodeAuth
+	case errors.Is(err, ErrNotFound):
+		return exitCodeNotFound
+	case errors.Is(err, ErrCapacity):
+		return exitCodeCapacity
+	case errors.Is(err, ErrQuota):
+		return exitCodeQuota
+	case errors.Is(err, ErrThrottle):
+		return exitCodeThrottle
+	case errors.Is(err, ErrReadOnly):
+		return exitCodeReadOnly
+	case errors.Is(err, ErrDeadline):
+		return exitCodeDeadline
+	}
+	return exitCodeGeneric
+}
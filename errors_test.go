@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func apiError(code string) error {
+	return &smithy.GenericAPIError{Code: code, Message: code + " message"}
+}
+
+func TestClassifyAWSError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+		exit int
+	}{
+		{"capacity", apiError("InsufficientInstanceCapacity"), ErrCapacity, exitCodeCapacity},
+		{"spot price", apiError("SpotMaxPriceTooLow"), ErrCapacity, exitCodeCapacity},
+		{"auth", apiError("UnauthorizedOperation"), ErrAuth, exitCodeAuth},
+		{"expired token", apiError("ExpiredToken"), ErrAuth, exitCodeAuth},
+		{"quota", apiError("VcpuLimitExceeded"), ErrQuota, exitCodeQuota},
+		{"throttle", apiError("RequestLimitExceeded"), ErrThrottle, exitCodeThrottle},
+		{"not found suffix", apiError("InvalidInstanceID.NotFound"), ErrNotFound, exitCodeNotFound},
+		{"not found code", apiError("ParameterNotFound"), ErrNotFound, exitCodeNotFound},
+		{"wrapped", fmt.Errorf("failed to launch: %w", apiError("InstanceLimitExceeded")), ErrQuota, exitCodeQuota},
+		{"unknown code", apiError("InvalidParameterValue"), nil, exitCodeGeneric},
+		{"not an API error", errors.New("connection reset"), nil, exitCodeGeneric},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyAWSError(tt.err)
+			if !errors.Is(got, tt.err) {
+				t.Errorf("classifyAWSError(%v) = %v, does not wrap the original error", tt.err, got)
+			}
+			if tt.want == nil && got != tt.err {
+				t.Errorf("classifyAWSError(%v) = %v, want it unchanged", tt.err, got)
+			}
+			if tt.want != nil && !errors.Is(got, tt.want) {
+				t.Errorf("classifyAWSError(%v) = %v, want it to wrap %v", tt.err, got, tt.want)
+			}
+			if code := exitCode(got); code != tt.exit {
+				t.Errorf("exitCode(%v) = %d, want %d", got, code, tt.exit)
+			}
+		})
+	}
+}
+
+func TestClassifyAWSErrorIdempotent(t *testing.T) {
+	once := classifyAWSError(apiError("Throttling"))
+	if twice := classifyAWSError(once); twice != once {
+		t.Errorf("classifying %v again = %v, want it unchanged", once, twice)
+	}
+	if classifyAWSError(nil) != nil {
+		t.Error("classifyAWSError(nil) != nil")
+	}
+}
+
+func TestClassifyGitHubResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		want    error
+	}{
+		{"unauthorized", http.StatusUnauthorized, nil, ErrAuth},
+		{"forbidden", http.StatusForbidden, nil, ErrAuth},
+		{"primary rate limit", http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0"}, ErrThrottle},
+		{"secondary rate limit", http.StatusForbidden, map[string]string{"Retry-After": "60"}, ErrThrottle},
+		{"not found", http.StatusNotFound, nil, ErrNotFound},
+		{"too many requests", http.StatusTooManyRequests, nil, ErrThrottle},
+		{"unprocessable", http.StatusUnprocessableEntity, nil, nil},
+		{"server error", http.StatusBadGateway, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			for key, value := range tt.headers {
+				resp.Header.Set(key, value)
+			}
+			got := classifyGitHubResponse(resp, []byte(`{"message":"error"}`))
+			for _, kind := range []error{ErrAuth, ErrThrottle, ErrNotFound} {
+				if errors.Is(got, kind) != (kind == tt.want) {
+					t.Errorf("classifyGitHubResponse(%d) = %v, want it to wrap %v", tt.status, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"generic", errors.New("boom"), exitCodeGeneric},
+		{"read-only", fmt.Errorf("%w: terminate", ErrReadOnly), exitCodeReadOnly},
+		{"deadline", fmt.Errorf("%w: waiting for runner", ErrDeadline), exitCodeDeadline},
+		{"cleanup wins over its cause", errors.Join(fmt.Errorf("%w: i-1", ErrIncompleteCleanup),
+			fmt.Errorf("%w: boom", ErrCapacity)), exitCodeCleanup},
+		{"auth wins over not found", fmt.Errorf("%w: %w", ErrAuth, ErrNotFound), exitCodeAuth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var eventsFormat string
+
+// eventsWriter is where lifecycle events are written; stdout stays reserved for final outputs
+var (
+	eventsWriter io.Writer = os.Stderr
+	eventsMu     sync.Mutex
+)
+
+// LifecycleEvent is a single machine-readable progress event
+type LifecycleEvent struct {
+	Phase     string         `json:"phase"`
+	Timestamp time.Time      `json:"timestamp"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// validateEventsFormat checks the --events-format flag value
+func validateEventsFormat() error {
+	if eventsFormat != "" && eventsFormat != "ndjson" {
+		return fmt.Errorf("events-format must be 'ndjson' when set")
+	}
+	return nil
+}
+
+// emitEvent writes a lifecycle event to the events stream when enabled
+func emitEvent(phase string, data map[string]any) {
+	noteHistoryEvent(phase, data)
+	if eventsFormat != "ndjson" {
+		return
+	}
+
+	line, err := json.Marshal(LifecycleEvent{
+		Phase:     phase,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return
+	}
+
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	fmt.Fprintf(eventsWriter, "%s\n", line)
+}
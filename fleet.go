@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fleetRoleARNs    []string
+	fleetAllAccounts bool
+	gcMaxAge         time.Duration
+	gcDryRun         bool
+)
+
+// FleetAccount is an AWS account reached by assuming a role, as configured
+// under "accounts" in the config file
+type FleetAccount struct {
+	Name       string `yaml:"name,omitempty"`
+	RoleARN    string `yaml:"role-arn"`
+	ExternalID string `yaml:"external-id,omitempty"`
+}
+
+// fleetTarget is an account together with its AWS configuration
+type fleetTarget struct {
+	Account string
+	Config  aws.Config
+}
+
+// FleetInstance is a runner instance found in one of the fleet accounts
+type FleetInstance struct {
+	Account string `json:"account"`
+	RunManifest
+}
+
+// accountFromRoleARN extracts the account ID from an IAM role ARN
+func accountFromRoleARN(roleARN string) string {
+	parts := strings.Split(roleARN, ":")
+	if len(parts) >= 5 && parts[4] != "" {
+		return parts[4]
+	}
+	return roleARN
+}
+
+// fleetAccounts returns the accounts selected by --role-arn / --all-accounts;
+// an empty list means the current credentials only
+func fleetAccounts() ([]FleetAccount, error) {
+	accounts := []FleetAccount{}
+	for _, roleARN := range fleetRoleARNs {
+		accounts = append(accounts, FleetAccount{RoleARN: roleARN})
+	}
+
+	if fleetAllAccounts {
+		if activeConfig == nil || len(activeConfig.Accounts) == 0 {
+			return nil, fmt.Errorf("--all-accounts requires an 'accounts' list in the config file")
+		}
+		accounts = append(accounts, activeConfig.Accounts...)
+	}
+	return accounts, nil
+}
+
+// assumeRoleConfig returns a copy of base that uses credentials from assuming roleARN
+func assumeRoleConfig(base aws.Config, roleARN, externalID, sessionName string) aws.Config {
+	cfg := base.Copy()
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(base), roleARN,
+		func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = sessionName
+			if externalID != "" {
+				o.ExternalID = aws.String(externalID)
+			}
+		})
+	cfg.Credentials = refreshingCredentials(provider)
+	return cfg
+}
+
+// fleetTargets resolves the selected and discovered accounts into AWS configurations
+func fleetTargets(cmd *cobra.Command) ([]fleetTarget, error) {
+	base, err := loadAWSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := fleetAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	if orgDiscovery {
+		settings, err := orgDiscoverySettings(cmd)
+		if err != nil {
+			return nil, err
+		}
+		discovered, err := discoverOrgAccounts(base, settings)
+		if err != nil {
+			return nil, err
+		}
+		if len(discovered) == 0 {
+			return nil, fmt.Errorf("%w: no active organization accounts matched the OU/tag filters", ErrNotFound)
+		}
+		accounts = append(accounts, discovered...)
+	}
+	if len(accounts) == 0 {
+		return []fleetTarget{{Account: "current", Config: base}}, nil
+	}
+
+	targets := make([]fleetTarget, 0, len(accounts))
+	for _, account := range accounts {
+		name := account.Name
+		if name == "" {
+			name = accountFromRoleARN(account.RoleARN)
+		}
+		targets = append(targets, fleetTarget{
+			Account: name,
+			Config:  assumeRoleConfig(base, account.RoleARN, account.ExternalID, "gh-workflow-fleet"),
+		})
+	}
+	return targets, nil
+}
+
+// listRunnerInstances returns all live instances launched by this tool
+func listRunnerInstances(svc *ec2.Client) ([]types.Instance, error) {
+	input := &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("tag:Purpose"), Values: []string{"GitHub Actions"}},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []string{"pending", "running", "stopping", "stopped"},
+			},
+		},
+	}
+
+	instances := []types.Instance{}
+	paginator := ec2.NewDescribeInstancesPaginator(svc, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, classifyAWSError(err)
+		}
+		for _, reservation := range page.Reservations {
+			instances = append(instances, reservation.Instances...)
+		}
+	}
+	return instances, nil
+}
+
+// listFleet lists runner instances across all targets; failures in one
+// account are reported but do not stop the others
+func listFleet(targets []fleetTarget) ([]FleetInstance, error) {
+	fleet := []FleetInstance{}
+	var errs []error
+
+	for _, target := range targets {
+		instances, err := listRunnerInstances(ec2.NewFromConfig(target.Config))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("account %s: %w", target.Account, err))
+			continue
+		}
+		for _, instance := range instances {
+			fleet = append(fleet, FleetInstance{Account: target.Account, RunManifest: manifestFromInstance(instance)})
+		}
+	}
+	return fleet, errors.Join(errs...)
+}
+
+// instanceAge returns how long ago the instance was launched
+func instanceAge(instance FleetInstance) time.Duration {
+	if instance.LaunchTime == nil {
+		return 0
+	}
+	return time.Since(*instance.LaunchTime).Round(time.Minute)
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List runner instances",
+	Long:  "List EC2 instances launched by gh-workflow, optionally across multiple AWS accounts",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if providerName != defaultProvider {
+			return listWithProvider()
+		}
+		targets, err := fleetTargets(cmd)
+		if err != nil {
+			return err
+		}
+
+		fleet, listErr := listFleet(targets)
+		if listJob != "" {
+			matching := []FleetInstance{}
+			for _, instance := range fleet {
+				if ranJob(instance.Jobs, listJob) {
+					matching = append(matching, instance)
+				}
+			}
+			fleet = matching
+		}
+
+		if outputFormat == "json" {
+			data, err := json.MarshalIndent(fleet, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode instances: %v", err)
+			}
+			fmt.Println(string(data))
+			return listErr
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tINSTANCE ID\tSTATE\tTYPE\tMARKET\tREPOSITORY\tRUNNER\tLAUNCHED\tAGE\tJOBS")
+		for _, instance := range fleet {
+			jobs := make([]string, 0, len(instance.Jobs))
+			for _, job := range instance.Jobs {
+				jobs = append(jobs, job.ID)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				instance.Account,
+				instance.InstanceID,
+				instance.State,
+				instance.InstanceType,
+				instance.InstanceMarketType,
+				instance.Repository,
+				instance.RunnerName,
+				formatTime(aws.ToTime(instance.LaunchTime)),
+				humanizeDuration(instanceAge(instance)),
+				strings.Join(jobs, ","),
+			)
+		}
+		w.Flush()
+
+		if listErr != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Some accounts could not be listed: %v\n", listErr)
+		}
+		return listErr
+	},
+}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Terminate stale runner instances",
+	Long:  "Terminate runner instances older than --max-age, optionally across multiple AWS accounts",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if gcMaxAge <= 0 {
+			return fmt.Errorf("max-age must be positive")
+		}
+
+		targets, err := fleetTargets(cmd)
+		if err != nil {
+			return err
+		}
+
+		var errs []error
+		for _, target := range targets {
+			fleet, err := listFleet([]fleetTarget{target})
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			stale := []string{}
+			resources := []AuxResource{}
+			spotRequests := []string{}
+			for _, instance := range fleet {
+				if instance.LaunchTime != nil && time.Since(*instance.LaunchTime) > gcMaxAge {
+					stale = append(stale, instance.InstanceID)
+					resources = append(resources, instance.Resources...)
+					if instance.SpotRequestID != "" {
+						spotRequests = append(spotRequests, instance.SpotRequestID)
+					}
+					fmt.Printf("🗑️  [%s] %s (%s, %s old, runner %s)\n", target.Account, instance.InstanceID,
+						instance.State, humanizeDuration(instanceAge(instance)), instance.RunnerName)
+				}
+			}
+			if len(stale) == 0 || gcDryRun {
+				continue
+			}
+
+			svc := ec2.NewFromConfig(target.Config)
+			if err := cancelSpotRequests(svc, spotRequests...); err != nil {
+				errs = append(errs, fmt.Errorf("account %s: %w", target.Account, err))
+				continue
+			}
+			_, err = svc.TerminateInstances(context.TODO(), &ec2.TerminateInstancesInput{
+				InstanceIds: stale,
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("account %s: failed to terminate %s: %w",
+					target.Account, strings.Join(stale, ", "), classifyAWSError(err)))
+				continue
+			}
+			emitEvent("gc.terminated", map[string]any{"account": target.Account, "instance_ids": stale})
+			fmt.Printf("✅ [%s] Terminated %d stale instance(s)\n", target.Account, len(stale))
+
+			if len(resources) > 0 {
+				// Auxiliary resources such as volumes can only be deleted once the instances are gone
+				err := ec2.NewInstanceTerminatedWaiter(svc).Wait(context.TODO(), &ec2.DescribeInstancesInput{
+					InstanceIds: stale,
+				}, 10*time.Minute)
+				if err == nil {
+					err = deleteAuxResourcesWithConfig(target.Config, resources, keepVolumes)
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("account %s: failed to clean up resources: %w", target.Account, err))
+				}
+			}
+		}
+
+		if gcDryRun {
+			fmt.Printf("ℹ️  Dry run: no instances were terminated\n")
+		}
+		return errors.Join(errs...)
+	},
+}
+
+// addFleetFlags registers the account selection flags shared by fleet commands
+func addFleetFlags(cmd *cobra.Command) {
+	cmd.Flags().
+		StringSliceVar(&fleetRoleARNs, "role-arn", nil, "IAM role ARN to assume per account (repeatable)")
+	cmd.Flags().
+		BoolVar(&fleetAllAccounts, "all-accounts", false, "Include every account listed under 'accounts' in the config file")
+	addOrgFlags(cmd)
+}
+
+func init() {
+	addFleetFlags(listCmd)
+	listCmd.Flags().StringVar(&outputFormat, "output-format", "", "Output format (json for machine-readable output)")
+
+	addFleetFlags(gcCmd)
+	gcCmd.Flags().DurationVar(&gcMaxAge, "max-age", 24*time.Hour, "Terminate runner instances older than this")
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "Only print the instances that would be terminated")
+	gcCmd.Flags().
+		BoolVar(&keepVolumes, "keep-volumes", false, "Keep extra EBS volumes instead of deleting them")
+
+	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(gcCmd)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// githubHost returns the GitHub host API calls are made against: the host of
+// --github-server-url, or GH_HOST like the gh CLI does
+func githubHost() string {
+	if host := serverURLHost(); host != "" {
+		return host
+	}
+	if host := os.Getenv("GH_HOST"); host != "" {
+		return host
+	}
+	return defaultGitHubHost
+}
+
+// envGitHubToken returns the token from the environment variables the gh CLI
+// reads for host: GH_TOKEN/GITHUB_TOKEN for github.com and
+// GH_ENTERPRISE_TOKEN/GITHUB_ENTERPRISE_TOKEN for GitHub Enterprise Server
+func envGitHubToken(host string) string {
+	names := []string{"GH_TOKEN", "GITHUB_TOKEN"}
+	if host != defaultGitHubHost {
+		names = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+	}
+	for _, name := range names {
+		if token := os.Getenv(name); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// ghConfigDir returns the gh CLI configuration directory
+func ghConfigDir() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh")
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("AppData"); dir != "" {
+			return filepath.Join(dir, "GitHub CLI")
+		}
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gh")
+}
+
+// ghCLIToken returns the token the gh CLI is logged in with for host, asking
+// gh itself (which also covers tokens kept in the OS keychain) and falling
+// back to the plain-text hosts.yml
+func ghCLIToken(host string) string {
+	if path, err := exec.LookPath("gh"); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, path, "auth", "token", "--hostname", host)
+		// Keep gh from answering with the very variables we already checked
+		cmd.Env = append(os.Environ(),
+			"GH_TOKEN=", "GITHUB_TOKEN=", "GH_ENTERPRISE_TOKEN=", "GITHUB_ENTERPRISE_TOKEN=")
+		if out, err := cmd.Output(); err == nil {
+			if token := strings.TrimSpace(string(out)); token != "" {
+				return token
+			}
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(ghConfigDir(), "hosts.yml"))
+	if err != nil {
+		return ""
+	}
+	var hosts map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	if err := yaml.Unmarshal(data, &hosts); err != nil {
+		return ""
+	}
+	return hosts[host].OAuthToken
+}
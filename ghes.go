@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	githubServerURLFlag string
+	githubAPIURLFlag    string
+)
+
+// githubServerURL returns the web URL of the GitHub instance runners register
+// with, e.g. https://github.com or https://ghe.example.com
+func githubServerURL() string {
+	if githubServerURLFlag != "" {
+		return strings.TrimSuffix(githubServerURLFlag, "/")
+	}
+	return "https://" + githubHost()
+}
+
+// githubAPIURL returns the REST API base URL: --github-api-url if given,
+// otherwise the one derived from the server URL or host
+func githubAPIURL() string {
+	if githubAPIURLFlag != "" {
+		return strings.TrimSuffix(githubAPIURLFlag, "/")
+	}
+	if githubServerURLFlag != "" && githubHost() != defaultGitHubHost {
+		return githubServerURL() + "/api/v3"
+	}
+	return githubAPIBase(githubHost())
+}
+
+// serverURLHost returns the host of --github-server-url, if it is set and valid
+func serverURLHost() string {
+	if githubServerURLFlag == "" {
+		return ""
+	}
+	parsed, err := url.Parse(githubServerURLFlag)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// validateGitHubURLs checks that --github-server-url and --github-api-url are absolute http(s) URLs
+func validateGitHubURLs() error {
+	for name, value := range map[string]string{
+		"github-server-url": githubServerURLFlag,
+		"github-api-url":    githubAPIURLFlag,
+	} {
+		if value == "" {
+			continue
+		}
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return fmt.Errorf("%s must be an http(s) URL such as https://ghe.example.com, got %q", name, value)
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&githubServerURLFlag, "github-server-url", "",
+		"GitHub Enterprise Server URL runners register with, e.g. https://ghe.example.com (default https://github.com)")
+	rootCmd.PersistentFlags().StringVar(&githubAPIURLFlag, "github-api-url", "",
+		"GitHub REST API base URL (default derived from --github-server-url, e.g. https://ghe.example.com/api/v3)")
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// githubHTTPClient is shared by all GitHub API calls
+var githubHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// Retry policy of GitHub API calls
+const (
+	githubMaxAttempts  = 4
+	githubBaseBackoff  = time.Second
+	githubMaxRetryWait = time.Minute
+)
+
+// githubLowRateLimit is the number of remaining requests below which a warning is printed once
+const githubLowRateLimit = 100
+
+// githubRateLimitWarned is shared by the concurrent creates of --count
+var githubRateLimitWarned atomic.Bool
+
+// isGitHubThrottled reports whether a response is a primary or secondary rate limit
+func isGitHubThrottled(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// githubRetryDelay returns how long to wait before retrying a response, from
+// Retry-After, X-RateLimit-Reset or exponential backoff
+func githubRetryDelay(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+				return time.Until(time.Unix(reset, 0)) + time.Second
+			}
+		}
+	}
+	return githubBaseBackoff << (attempt - 1)
+}
+
+// noteGitHubRateLimit warns once when few GitHub API requests remain
+func noteGitHubRateLimit(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil || remaining >= githubLowRateLimit || !githubRateLimitWarned.CompareAndSwap(false, true) {
+		return
+	}
+
+	reset, displayReset := "", "unknown"
+	if seconds, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		reset = time.Unix(seconds, 0).UTC().Format(time.RFC3339)
+		displayReset = formatTimeRelative(time.Unix(seconds, 0))
+	}
+	emitEvent("github.rate_limit", map[string]any{
+		"remaining": remaining,
+		"limit":     resp.Header.Get("X-RateLimit-Limit"),
+		"reset":     reset,
+	})
+	if outputFormat != "github-actions" {
+		fmt.Fprintf(os.Stderr, "⚠️  Only %d GitHub API requests left until %s\n", remaining, displayReset)
+	}
+}
+
+// githubTokenEndpoints are the POST endpoints that only mint a runner token;
+// minting another one has no side effects, so they are retried like a GET
+var githubTokenEndpoints = []string{"/registration-token", "/remove-token"}
+
+// isGitHubRetryable reports whether a request can be repeated after a network
+// error or 5xx response. Other requests may have taken effect despite such a
+// failure, e.g. a second workflow dispatch
+func isGitHubRetryable(method, url string) bool {
+	if method == http.MethodGet || method == http.MethodDelete {
+		return true
+	}
+	for _, endpoint := range githubTokenEndpoints {
+		if method == http.MethodPost && strings.HasSuffix(url, endpoint) {
+			return true
+		}
+	}
+	return false
+}
+
+// doGitHubRequest sends a GitHub API request, retrying rate limits and, for
+// retryable requests, network errors and 5xx responses, and returns the last
+// response with its body
+func doGitHubRequest(method, url, githubToken string, payload []byte) (*http.Response, []byte, error) {
+	idempotent := isGitHubRetryable(method, url)
+	for attempt := 1; ; attempt++ {
+		var reqBody io.Reader
+		if payload != nil {
+			reqBody = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequest(method, url, reqBody)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		setGitHubHeaders(req, githubToken)
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		var body []byte
+		resp, err := githubHTTPClient.Do(req)
+		if err == nil {
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				err = fmt.Errorf("failed to read response body: %v", err)
+			}
+		} else {
+			err = fmt.Errorf("failed to make request: %v", err)
+		}
+
+		retry := err != nil && idempotent
+		if resp != nil && err == nil {
+			noteGitHubRateLimit(resp)
+			retry = (resp.StatusCode >= 500 && idempotent) || isGitHubThrottled(resp)
+		}
+		if !retry || attempt == githubMaxAttempts {
+			return resp, body, err
+		}
+
+		delay := githubRetryDelay(resp, attempt)
+		if delay > githubMaxRetryWait {
+			return resp, body, err
+		}
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = fmt.Sprintf("status %d", resp.StatusCode)
+		}
+		emitEvent("github.retry", map[string]any{"method": method, "url": url, "attempt": attempt, "reason": reason})
+		if outputFormat != "github-actions" {
+			fmt.Fprintf(os.Stderr, "⏳ GitHub API %s %s failed (%s), retrying in %s...\n", method, url, reason, delay)
+		}
+		time.Sleep(delay)
+	}
+}
+
+// githubAPIRequest makes a GitHub API call, JSON-encoding payload if it is not
+// nil, and returns the response body if the status is the expected one
+func githubAPIRequest(method, path, githubToken string, payload any, expectedStatus int) ([]byte, error) {
+	if method != "GET" {
+		if err := checkReadOnly(fmt.Sprintf("GitHub %s %s", method, path)); err != nil {
+			return nil, err
+		}
+	}
+
+	var data []byte
+	if payload != nil {
+		var err error
+		data, err = json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %v", err)
+		}
+	}
+
+	resp, body, err := doGitHubRequest(method, githubAPIURL()+path, githubToken, data)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != expectedStatus {
+		return nil, classifyGitHubResponse(resp, body)
+	}
+	return body, nil
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	historySince   string
+	historyCommand string
+	historyRepo    string
+	historyRemote  bool
+	// historyStarted is set once the command's flags and credentials were
+	// accepted, so usage errors are not recorded as runs
+	historyStarted bool
+)
+
+// History configures the run history, read from the "history" section of the
+// config file; records are appended to a local file unless disabled, and also
+// posted to an endpoint shared by a team when one is set
+type History struct {
+	Disabled bool   `yaml:"disabled"`
+	File     string `yaml:"file"`
+	Endpoint string `yaml:"endpoint"`
+}
+
+// HistoryRecord is a create, terminate or other state-changing command that ran
+type HistoryRecord struct {
+	Time         time.Time `json:"time"`
+	Command      string    `json:"command"`
+	Outcome      string    `json:"outcome"`
+	ExitCode     int       `json:"exit_code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	DurationMS   int64     `json:"duration_ms"`
+	User         string    `json:"user"`
+	Host         string    `json:"host,omitempty"`
+	RunID        string    `json:"run_id,omitempty"`
+	Profile      string    `json:"profile,omitempty"`
+	Repository   string    `json:"repository,omitempty"`
+	Labels       string    `json:"labels,omitempty"`
+	InstanceType string    `json:"instance_type,omitempty"`
+	InstanceIDs  []string  `json:"instance_ids,omitempty"`
+}
+
+// historyCommands are the commands recorded in the history
+var historyCommands = []string{"create", "terminate", "run", "resume", "gc"}
+
+// historyInstances collects the instances launched and terminated by the
+// current command from its lifecycle events
+var (
+	historyInstances   []string
+	historyInstancesMu sync.Mutex
+)
+
+// noteHistoryEvent records the instance of a launch or termination event
+func noteHistoryEvent(phase string, data map[string]any) {
+	if phase != "instance.launched" && phase != "instance.terminated" {
+		return
+	}
+	id, ok := data["instance_id"].(string)
+	if !ok || id == "" {
+		return
+	}
+	historyInstancesMu.Lock()
+	defer historyInstancesMu.Unlock()
+	if !slices.Contains(historyInstances, id) {
+		historyInstances = append(historyInstances, id)
+	}
+}
+
+// historyFilePath returns the local history file: history.file from the config
+// file, or history.jsonl in the user config directory
+func historyFilePath() (string, error) {
+	if activeConfig != nil && activeConfig.History != nil && activeConfig.History.File != "" {
+		return activeConfig.History.File, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %v", err)
+	}
+	return filepath.Join(dir, "gh-workflow", "history.jsonl"), nil
+}
+
+// historyUser returns who ran the command: the GitHub Actions actor in a
+// workflow, or else the local user
+func historyUser() string {
+	if actor := os.Getenv("GITHUB_ACTOR"); actor != "" {
+		return actor
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return "unknown"
+}
+
+// recordHistory appends the outcome of a state-changing command to the
+// history; failures only print a warning, as the command itself has finished
+func recordHistory(cmd *cobra.Command, started time.Time, err error) {
+	if cmd == nil || !historyStarted || !slices.Contains(historyCommands, cmd.Name()) || cmd.Parent() != rootCmd {
+		return
+	}
+	if activeConfig != nil && activeConfig.History != nil && activeConfig.History.Disabled {
+		return
+	}
+
+	record := HistoryRecord{
+		Time:        started.UTC(),
+		Command:     cmd.Name(),
+		Outcome:     "success",
+		DurationMS:  time.Since(started).Milliseconds(),
+		User:        historyUser(),
+		RunID:       runID,
+		Profile:     configProfile,
+		InstanceIDs: historyInstances,
+	}
+	record.Host, _ = os.Hostname()
+	if err != nil {
+		record.Outcome = "error"
+		record.ExitCode = exitCode(err)
+		record.Error = err.Error()
+	}
+	if repoOwner != "" {
+		record.Repository = runnerScope(repoOwner, repoName)
+	}
+	if cmd.Flags().Lookup("labels") != nil {
+		record.Labels = runnerLabels
+	}
+	if cmd.Flags().Lookup("instance-type") != nil {
+		record.InstanceType = strings.Join(withFallbackInstanceTypes(instanceType), ",")
+	}
+	if flag := cmd.Flags().Lookup("instance-id"); flag != nil && flag.Value.String() != "" &&
+		!slices.Contains(record.InstanceIDs, flag.Value.String()) {
+		record.InstanceIDs = append(record.InstanceIDs, flag.Value.String())
+	}
+
+	if err := appendHistory(record); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to record history: %v\n", err)
+	}
+	if activeConfig != nil && activeConfig.History != nil && activeConfig.History.Endpoint != "" {
+		if err := postHistory(activeConfig.History.Endpoint, record); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Failed to send history to %s: %v\n", activeConfig.History.Endpoint, err)
+		}
+	}
+}
+
+// appendHistory appends record to the local history file as a JSON line
+func appendHistory(record HistoryRecord) error {
+	path, err := historyFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// postHistory sends record to the shared history endpoint
+func postHistory(endpoint string, record HistoryRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// readLocalHistory returns the records of the local history file since the given time
+func readLocalHistory(since time.Time) ([]HistoryRecord, error) {
+	path, err := historyFilePath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	records := []HistoryRecord{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record HistoryRecord
+		// Skip lines torn by concurrent writers rather than failing the whole history
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if !record.Time.Before(since) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return records, nil
+}
+
+// readRemoteHistory fetches the records since the given time from the shared
+// endpoint, which answers GET ?since=<RFC 3339 time> with a JSON array
+func readRemoteHistory(endpoint string, since time.Time) ([]HistoryRecord, error) {
+	req, err := http.NewRequest("GET", endpoint+"?since="+url.QueryEscape(since.UTC().Format(time.RFC3339)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch history from %s: %v", endpoint, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history from %s: %v", endpoint, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("history endpoint %s returned status %d", endpoint, resp.StatusCode)
+	}
+	records := []HistoryRecord{}
+	if err := json.Unmarshal(body, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse history from %s: %v", endpoint, err)
+	}
+	return records, nil
+}
+
+// daysPattern matches a duration in days such as 7d
+var daysPattern = regexp.MustCompile(`^([0-9]+)d$`)
+
+// parseSince parses a --since duration, which also accepts days such as 7d
+func parseSince(value string) (time.Duration, error) {
+	if match := daysPattern.FindStringSubmatch(value); match != nil {
+		days, err := strconv.Atoi(match[1])
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show past create, terminate and other runs",
+	Long: "Show the create, terminate, run, resume and gc commands recorded in the history, with their " +
+		"duration, outcome and who ran them",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		window, err := parseSince(historySince)
+		if err != nil || window <= 0 {
+			return fmt.Errorf("since must be a positive duration such as 7d or 12h, got %q", historySince)
+		}
+		since := time.Now().Add(-window)
+
+		var records []HistoryRecord
+		if historyRemote {
+			if activeConfig == nil || activeConfig.History == nil || activeConfig.History.Endpoint == "" {
+				return fmt.Errorf("remote requires history.endpoint in the config file")
+			}
+			records, err = readRemoteHistory(activeConfig.History.Endpoint, since)
+		} else {
+			records, err = readLocalHistory(since)
+		}
+		if err != nil {
+			return err
+		}
+
+		filtered := []HistoryRecord{}
+		for _, record := range records {
+			if historyCommand != "" && record.Command != historyCommand {
+				continue
+			}
+			if historyRepo != "" && record.Repository != historyRepo {
+				continue
+			}
+			filtered = append(filtered, record)
+		}
+		sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].Time.After(filtered[j].Time) })
+
+		if outputFormat == "json" {
+			data, err := json.MarshalIndent(filtered, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode history: %v", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		if len(filtered) == 0 {
+			fmt.Printf("ℹ️  No recorded runs in the last %s\n", historySince)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tCOMMAND\tOUTCOME\tDURATION\tUSER\tREPOSITORY\tLABELS\tINSTANCES")
+		for _, record := range filtered {
+			outcome := "✅ success"
+			if record.Outcome != "success" {
+				outcome = fmt.Sprintf("❌ exit %d", record.ExitCode)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				formatTime(record.Time),
+				record.Command,
+				outcome,
+				humanizeDuration(time.Duration(record.DurationMS)*time.Millisecond),
+				record.User,
+				record.Repository,
+				record.Labels,
+				strings.Join(record.InstanceIDs, ","),
+			)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historySince, "since", "7d", "How far back to show, e.g. 7d or 12h")
+	historyCmd.Flags().StringVar(&historyCommand, "command", "", "Only show this command (create, terminate, ...)")
+	historyCmd.Flags().StringVar(&historyRepo, "repo", "", "Only show runs for this repository (owner/name) or owner")
+	historyCmd.Flags().BoolVar(&historyRemote, "remote", false, "Read the shared history from history.endpoint")
+	historyCmd.Flags().StringVar(&outputFormat, "output-format", "", "Output format (json for machine-readable output)")
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// Lifecycle points at which hooks run
+const (
+	hookPreCreate    = "pre-create"
+	hookPostCreate   = "post-create"
+	hookPreTerminate = "pre-terminate"
+)
+
+// defaultHookTimeout bounds a hook that does not configure its own timeout
+const defaultHookTimeout = 5 * time.Minute
+
+// Hook is a single command or HTTP endpoint invoked at a lifecycle point.
+// The run manifest is passed as JSON on stdin (commands) or as the request body (URLs).
+type Hook struct {
+	Command string            `yaml:"command,omitempty"`
+	URL     string            `yaml:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Timeout string            `yaml:"timeout,omitempty"`
+}
+
+// Hooks groups the configured hooks by lifecycle point
+type Hooks struct {
+	PreCreate    []Hook `yaml:"pre-create,omitempty"`
+	PostCreate   []Hook `yaml:"post-create,omitempty"`
+	PreTerminate []Hook `yaml:"pre-terminate,omitempty"`
+}
+
+// forEvent returns the hooks configured for a lifecycle point
+func (h Hooks) forEvent(event string) []Hook {
+	switch event {
+	case hookPreCreate:
+		return h.PreCreate
+	case hookPostCreate:
+		return h.PostCreate
+	case hookPreTerminate:
+		return h.PreTerminate
+	}
+	return nil
+}
+
+// runHooks invokes every hook configured for event in order, stopping at the first failure
+func runHooks(event string, manifest RunManifest) error {
+	if activeConfig == nil {
+		return nil
+	}
+
+	hooks := activeConfig.Hooks.forEvent(event)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	manifest.Event = event
+	payload, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode run manifest: %v", err)
+	}
+
+	for i, hook := range hooks {
+		if outputFormat != "github-actions" {
+			fmt.Printf("🪝 Running %s hook %d/%d...\n", event, i+1, len(hooks))
+		}
+		emitEvent("hook.started", map[string]any{"hook": event, "index": i})
+
+		if err := hook.run(event, payload); err != nil {
+			emitEvent("hook.failed", map[string]any{"hook": event, "index": i, "error": err.Error()})
+			return fmt.Errorf("%s hook %d failed: %w", event, i+1, err)
+		}
+		emitEvent("hook.completed", map[string]any{"hook": event, "index": i})
+	}
+	return nil
+}
+
+// run executes a single hook with its timeout
+func (h Hook) run(event string, payload []byte) error {
+	timeout := defaultHookTimeout
+	if h.Timeout != "" {
+		parsed, err := time.ParseDuration(h.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %v", h.Timeout, err)
+		}
+		timeout = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	switch {
+	case h.Command != "" && h.URL != "":
+		return fmt.Errorf("hook must set either command or url, not both")
+	case h.Command != "":
+		return h.runCommand(ctx, event, payload)
+	case h.URL != "":
+		return h.runHTTP(ctx, event, payload)
+	}
+	return fmt.Errorf("hook must set command or url")
+}
+
+// runCommand runs the hook command through the system shell with the manifest on stdin
+func (h Hook) runCommand(ctx context.Context, event string, payload []byte) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", h.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", h.Command)
+	}
+
+	cmd.Stdin = bytes.NewReader(payload)
+	// Keep stdout reserved for the tool's own output
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GH_WORKFLOW_HOOK="+event)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command %q: %v", h.Command, err)
+	}
+	return nil
+}
+
+// runHTTP posts the manifest to the hook URL and expects a 2xx response
+func (h Hook) runHTTP(ctx context.Context, event string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", h.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	req.Header.Set("X-GH-Workflow-Hook", event)
+	for key, value := range h.Headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %v", h.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s returned status %d: %s", h.URL, resp.StatusCode, string(body))
+	}
+	return nil
+}
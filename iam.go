@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var iamFeatures string
+
+// PolicyStatement is a statement of an IAM policy document
+type PolicyStatement struct {
+	Sid       string                       `json:"Sid"`
+	Effect    string                       `json:"Effect"`
+	Action    []string                     `json:"Action"`
+	Resource  []string                     `json:"Resource"`
+	Condition map[string]map[string]string `json:"Condition,omitempty"`
+}
+
+// PolicyDocument is an IAM policy document
+type PolicyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+// runnerTagCondition limits a statement to resources tagged as gh-workflow runners
+var runnerTagCondition = map[string]map[string]string{
+	"StringEquals": {"aws:ResourceTag/Purpose": "GitHub Actions"},
+}
+
+// allow builds an Allow statement
+func allow(sid string, actions, resources []string, condition map[string]map[string]string) PolicyStatement {
+	return PolicyStatement{Sid: sid, Effect: "Allow", Action: actions, Resource: resources, Condition: condition}
+}
+
+// iamFeatureStatements maps each feature to the statements it needs; statements
+// shared by several features use the same Sid and are emitted once
+var iamFeatureStatements = map[string][]PolicyStatement{
+	"create": {
+		allow("DescribeRunners", []string{"ec2:DescribeInstances", "ec2:DescribeImages", "ec2:DescribeInstanceTypes"},
+			[]string{"*"}, nil),
+		allow("DescribeInstanceTypes", []string{"ec2:DescribeInstanceTypes"}, []string{"*"}, nil),
+		allow("LaunchTaggedRunners", []string{"ec2:RunInstances"}, []string{"arn:aws:ec2:*:*:instance/*"},
+			map[string]map[string]string{"StringEquals": {"aws:RequestTag/Purpose": "GitHub Actions"}}),
+		allow("LaunchRunnerResources", []string{"ec2:RunInstances"}, []string{
+			"arn:aws:ec2:*::image/*",
+			"arn:aws:ec2:*:*:subnet/*",
+			"arn:aws:ec2:*:*:security-group/*",
+			"arn:aws:ec2:*:*:network-interface/*",
+			"arn:aws:ec2:*:*:volume/*",
+		}, nil),
+		allow("TagOnLaunch", []string{"ec2:CreateTags"}, []string{
+			"arn:aws:ec2:*:*:instance/*",
+			"arn:aws:ec2:*:*:volume/*",
+			"arn:aws:ec2:*:*:network-interface/*",
+		}, map[string]map[string]string{"StringEquals": {"ec2:CreateAction": "RunInstances"}}),
+		allow("TagRunners", []string{"ec2:CreateTags"}, []string{"arn:aws:ec2:*:*:instance/*"}, runnerTagCondition),
+		allow("ScoreSpotPlacement", []string{"ec2:GetSpotPlacementScores", "ec2:DescribeSubnets"}, []string{"*"}, nil),
+	},
+	"terminate": {
+		allow("DescribeRunners", []string{"ec2:DescribeInstances", "ec2:DescribeImages", "ec2:DescribeInstanceTypes"},
+			[]string{"*"}, nil),
+		allow("TerminateRunners",
+			[]string{"ec2:TerminateInstances", "ec2:StopInstances", "ec2:ModifyInstanceAttribute"},
+			[]string{"arn:aws:ec2:*:*:instance/*"}, runnerTagCondition),
+		allow("CancelPersistentSpotRequests", []string{"ec2:CancelSpotInstanceRequests"}, []string{"*"}, nil),
+	},
+	"cleanup": {
+		allow("DeleteRunnerResources", []string{
+			"ec2:DeleteVolume",
+			"ec2:ReleaseAddress",
+			"ssm:DeleteParameter",
+			"route53:ListResourceRecordSets",
+			"route53:ChangeResourceRecordSets",
+			"cloudwatch:DeleteAlarms",
+		}, []string{"*"}, nil),
+		allow("VerifyRunnerCleanup", []string{
+			"ec2:DescribeVolumes",
+			"ec2:DescribeAddresses",
+			"ssm:GetParameter",
+			"cloudwatch:DescribeAlarms",
+		}, []string{"*"}, nil),
+	},
+	"list": {
+		allow("DescribeRunners", []string{"ec2:DescribeInstances", "ec2:DescribeImages", "ec2:DescribeInstanceTypes"},
+			[]string{"*"}, nil),
+	},
+	"cost": {
+		allow("EstimateCost", []string{"ec2:DescribeSpotPriceHistory", "ec2:DescribeSubnets", "pricing:GetProducts"},
+			[]string{"*"}, nil),
+	},
+	"fleet": {
+		allow("AssumeFleetRoles", []string{"sts:AssumeRole"}, []string{"arn:aws:iam::*:role/*"}, nil),
+	},
+	"organization": {
+		allow("DiscoverAccounts", []string{
+			"organizations:ListAccounts",
+			"organizations:ListAccountsForParent",
+			"organizations:ListOrganizationalUnitsForParent",
+			"organizations:ListTagsForResource",
+		}, []string{"*"}, nil),
+	},
+	"audit": {
+		allow("LookupLaunchEvents", []string{"cloudtrail:LookupEvents"}, []string{"*"}, nil),
+	},
+	"dns": {
+		allow("RegisterRunnerDNS", []string{"route53:ChangeResourceRecordSets", "route53:ListResourceRecordSets"},
+			[]string{"arn:aws:route53:::hostedzone/*"}, nil),
+	},
+	"ssh": {
+		allow("DescribeRunners", []string{"ec2:DescribeInstances", "ec2:DescribeImages", "ec2:DescribeInstanceTypes"},
+			[]string{"*"}, nil),
+		allow("DescribeInstanceConnectEndpoints", []string{"ec2:DescribeInstanceConnectEndpoints"},
+			[]string{"*"}, nil),
+		allow("OpenTunnel", []string{"ec2-instance-connect:OpenTunnel"},
+			[]string{"arn:aws:ec2:*:*:instance-connect-endpoint/*"}, nil),
+		allow("CreateInstanceConnectEndpoint", []string{
+			"ec2:CreateInstanceConnectEndpoint",
+			"ec2:CreateNetworkInterface",
+			"iam:CreateServiceLinkedRole",
+		}, []string{"*"}, nil),
+		allow("TagInstanceConnectEndpoint", []string{"ec2:CreateTags"},
+			[]string{"arn:aws:ec2:*:*:instance-connect-endpoint/*"},
+			map[string]map[string]string{"StringEquals": {"ec2:CreateAction": "CreateInstanceConnectEndpoint"}}),
+	},
+	"ami": {
+		allow("DescribeRunners", []string{"ec2:DescribeInstances", "ec2:DescribeImages", "ec2:DescribeInstanceTypes"},
+			[]string{"*"}, nil),
+		allow("ReadPublicAMIParameters", []string{"ssm:GetParameter"},
+			[]string{"arn:aws:ssm:*::parameter/aws/service/*"}, nil),
+	},
+	"instance-profile": {
+		allow("PassRunnerInstanceRole", []string{"iam:PassRole"}, []string{"arn:aws:iam::*:role/*"},
+			map[string]map[string]string{"StringEquals": {"iam:PassedToService": "ec2.amazonaws.com"}}),
+	},
+	"eip": {
+		allow("AssociateRunnerEIP", []string{"ec2:AssociateAddress"},
+			[]string{"arn:aws:ec2:*:*:instance/*", "arn:aws:ec2:*:*:elastic-ip/*"}, nil),
+	},
+	"fleet-launch": {
+		allow("LaunchRunnerFleets", []string{
+			"ec2:CreateFleet",
+			"ec2:CreateLaunchTemplate",
+			"ec2:DeleteLaunchTemplate",
+			"ec2:CreateTags",
+		}, []string{"*"}, nil),
+		allow("CreateFleetServiceLinkedRole", []string{"iam:CreateServiceLinkedRole"}, []string{"*"},
+			map[string]map[string]string{"StringEquals": {"iam:AWSServiceName": "ec2fleet.amazonaws.com"}}),
+	},
+	"spot-resume": {
+		allow("ReadRunnerUserData", []string{"ec2:DescribeInstanceAttribute"}, []string{"*"}, nil),
+		allow("ReregisterSpotRunners", []string{"ec2:ModifyInstanceAttribute"},
+			[]string{"arn:aws:ec2:*:*:instance/*"}, runnerTagCondition),
+	},
+	"alarm": {
+		allow("ManageStuckRunnerAlarms", []string{"cloudwatch:PutMetricAlarm", "cloudwatch:TagResource"},
+			[]string{"arn:aws:cloudwatch:*:*:alarm:" + stuckAlarmPrefix + "*"}, nil),
+	},
+	"ebs-encryption": {
+		allow("UseEBSKey", []string{
+			"kms:CreateGrant",
+			"kms:Decrypt",
+			"kms:DescribeKey",
+			"kms:GenerateDataKeyWithoutPlaintext",
+			"kms:ReEncrypt*",
+		}, []string{"arn:aws:kms:*:*:key/*"}, map[string]map[string]string{
+			"StringLike": {"kms:ViaService": "ec2.*.amazonaws.com"},
+		}),
+	},
+	"probe": {
+		allow("ReadRunnerConsole", []string{"ec2:GetConsoleOutput"}, []string{"arn:aws:ec2:*:*:instance/*"},
+			runnerTagCondition),
+	},
+	"lightsail": {
+		allow("ManageLightsailRunners", []string{
+			"lightsail:CreateInstances",
+			"lightsail:DeleteInstance",
+			"lightsail:GetInstance",
+			"lightsail:GetInstances",
+			"lightsail:TagResource",
+			"lightsail:AllocateStaticIp",
+			"lightsail:AttachStaticIp",
+			"lightsail:GetStaticIp",
+			"lightsail:ReleaseStaticIp",
+		}, []string{"*"}, nil),
+	},
+	"ami-build": {
+		allow("ReadPublicAMIParameters", []string{"ssm:GetParameter"},
+			[]string{"arn:aws:ssm:*::parameter/aws/service/*"}, nil),
+		allow("RunImagePipelines", []string{
+			"imagebuilder:ListImagePipelines",
+			"imagebuilder:CreateComponent",
+			"imagebuilder:CreateImageRecipe",
+			"imagebuilder:CreateInfrastructureConfiguration",
+			"imagebuilder:CreateImagePipeline",
+			"imagebuilder:StartImagePipelineExecution",
+			"imagebuilder:GetImage",
+			"imagebuilder:TagResource",
+		}, []string{"*"}, nil),
+		allow("PassBuildInstanceRole", []string{"iam:PassRole", "iam:GetInstanceProfile"}, []string{"*"}, nil),
+	},
+}
+
+// iamFeatureAliases expands composite features into the features they need
+var iamFeatureAliases = map[string][]string{
+	"gc":           {"list", "terminate", "cleanup"},
+	"run":          {"create", "terminate", "cleanup"},
+	"replace":      {"list", "create", "terminate", "cleanup"},
+	"daemon":       {"list", "create", "terminate", "cleanup"},
+	"resume":       {"list", "spot-resume"},
+	"drift":        {"list"},
+	"list-runners": {"list"},
+	"spot-advisor": {"cost"},
+	"report":       {"audit", "list", "cost"},
+}
+
+// iamFeatureNames returns all supported features in sorted order
+func iamFeatureNames() []string {
+	names := []string{}
+	for name := range iamFeatureStatements {
+		names = append(names, name)
+	}
+	for name := range iamFeatureAliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildIAMPolicy returns the policy document covering the given features, with
+// the resource ARNs of partition
+func buildIAMPolicy(features []string, partition string) (PolicyDocument, error) {
+	policy := PolicyDocument{Version: "2012-10-17", Statement: []PolicyStatement{}}
+	seen := map[string]bool{}
+
+	expanded := []string{}
+	for _, feature := range features {
+		if alias, ok := iamFeatureAliases[feature]; ok {
+			expanded = append(expanded, alias...)
+			continue
+		}
+		if _, ok := iamFeatureStatements[feature]; !ok {
+			return policy, fmt.Errorf("unknown feature %q (supported: %s)",
+				feature, strings.Join(iamFeatureNames(), ", "))
+		}
+		expanded = append(expanded, feature)
+	}
+
+	for _, feature := range expanded {
+		for _, statement := range iamFeatureStatements[feature] {
+			if seen[statement.Sid] {
+				continue
+			}
+			seen[statement.Sid] = true
+			resources := make([]string, 0, len(statement.Resource))
+			for _, resource := range statement.Resource {
+				resources = append(resources, partitionARN(resource, partition))
+			}
+			statement.Resource = resources
+			policy.Statement = append(policy.Statement, statement)
+		}
+	}
+	return policy, nil
+}
+
+var iamCmd = &cobra.Command{
+	Use:   "iam",
+	Short: "Generate IAM policies",
+	Long:  "Generate IAM policies for the AWS permissions gh-workflow needs",
+}
+
+var iamPolicyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Print the minimal IAM policy for a set of features",
+	Long: "Print the least-privilege IAM policy JSON for the selected features, scoped to runner instances " +
+		"(tagged Purpose=GitHub Actions) where the API allows it, for the partition of --partition or the region",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		features := splitList(iamFeatures)
+		if len(features) == 0 {
+			return fmt.Errorf("features is required (supported: %s)", strings.Join(iamFeatureNames(), ", "))
+		}
+
+		policy, err := buildIAMPolicy(features, awsPartition())
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(policy, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode policy: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	},
+}
+
+func init() {
+	iamPolicyCmd.Flags().StringVar(&iamFeatures, "features", "create,terminate",
+		"Comma-separated features to grant (e.g. create,terminate,cleanup,dns)")
+
+	iamCmd.AddCommand(iamPolicyCmd)
+	rootCmd.AddCommand(iamCmd)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// reattachedInstanceID is the instance the last findRetriedLaunch re-attached
+// to, for callers that must launch a new runner; it is set under launchMu
+var reattachedInstanceID string
+
+// findRetriedLaunch returns the live instance an earlier try of this create
+// launched with the same correlation ID, or nil. The client token alone can't
+// deduplicate a retried create: its user data carries a new registration
+// token, which EC2 rejects as IdempotentParameterMismatch
+func findRetriedLaunch(svc *ec2.Client, correlationID string) (*types.Instance, error) {
+	instance, err := findInstanceByCorrelationID(svc, correlationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up instances launched with correlation ID %s: %w", correlationID, err)
+	}
+	if instance == nil {
+		return nil, nil
+	}
+
+	instanceID := aws.ToString(instance.InstanceId)
+	launchMu.Lock()
+	reattachedInstanceID = instanceID
+	launchMu.Unlock()
+	emitEvent("create.deduplicated", map[string]any{"instance_id": instanceID, "correlation_id": correlationID})
+	if outputFormat != "github-actions" {
+		fmt.Printf("♻️  Instance %s was already launched for this job (correlation ID %s); "+
+			"re-attaching instead of launching a duplicate\n", instanceID, correlationID)
+	}
+	return instance, nil
+}
+
+// runInstancesIdempotent launches the instance. A client token EC2 refuses as
+// used with other parameters belongs to an earlier try of this create: its
+// instance is returned if it is still live, or else, it having been
+// terminated, a new one is launched with a fresh token
+func runInstancesIdempotent(ctx context.Context, svc *ec2.Client, runInput *ec2.RunInstancesInput,
+	correlationID string) (*ec2.RunInstancesOutput, error) {
+	result, err := svc.RunInstances(ctx, runInput)
+	if awsErrorCode(err) != "IdempotentParameterMismatch" {
+		return result, err
+	}
+
+	instance, findErr := findRetriedLaunch(svc, correlationID)
+	if findErr != nil {
+		return nil, findErr
+	}
+	if instance != nil {
+		return &ec2.RunInstancesOutput{Instances: []types.Instance{*instance}}, nil
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	runInput.ClientToken = aws.String(aws.ToString(runInput.ClientToken) + "-" + hex.EncodeToString(suffix))
+	if outputFormat != "github-actions" {
+		fmt.Printf("ℹ️  The instance launched earlier for this job is gone, launching a new one...\n")
+	}
+	return svc.RunInstances(ctx, runInput)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/imagebuilder"
+	"github.com/aws/aws-sdk-go-v2/service/imagebuilder/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	buildPipelineARN     string
+	buildName            string
+	buildParentImage     string
+	buildInstanceType    string
+	buildInstanceProfile string
+	buildSubnetID        string
+	buildSecurityGroup   string
+	buildTimeout         time.Duration
+)
+
+// runnerComponentDocument is the Image Builder component installing the
+// GitHub Actions runner into /actions-runner, where --use-baked-ami expects it
+const runnerComponentDocument = `name: %[1]s
+description: Install the GitHub Actions runner %[2]s
+schemaVersion: 1.0
+phases:
+  - name: build
+    steps:
+      - name: InstallRunner
+        action: ExecuteBash
+        inputs:
+          commands:
+            - if command -v apt-get >/dev/null; then apt-get update -y && apt-get install -y curl jq git; else yum install -y curl jq git tar; fi
+            - mkdir -p /actions-runner && cd /actions-runner
+            - case $(uname -m) in aarch64) ARCH=arm64 ;; *) ARCH=x64 ;; esac
+            - curl -fsSL -o runner.tar.gz https://github.com/actions/runner/releases/download/v%[2]s/actions-runner-linux-${ARCH}-%[2]s.tar.gz
+            - tar xzf runner.tar.gz && rm runner.tar.gz
+            - ./bin/installdependencies.sh
+`
+
+// findImagePipeline returns the ARN of the Image Builder pipeline with the given name, or ""
+func findImagePipeline(client *imagebuilder.Client, name string) (string, error) {
+	result, err := client.ListImagePipelines(context.TODO(), &imagebuilder.ListImagePipelinesInput{
+		Filters: []types.Filter{{Name: aws.String("name"), Values: []string{name}}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list image pipelines: %w", classifyAWSError(err))
+	}
+	for _, pipeline := range result.ImagePipelineList {
+		if aws.ToString(pipeline.Name) == name {
+			return aws.ToString(pipeline.Arn), nil
+		}
+	}
+	return "", nil
+}
+
+// createRunnerPipeline creates the runner component, image recipe,
+// infrastructure configuration and pipeline named name, returning the pipeline ARN
+func createRunnerPipeline(client *imagebuilder.Client, name, parentImage string) (string, error) {
+	tags := map[string]string{"Purpose": "GitHub Actions"}
+	if outputFormat != "github-actions" {
+		fmt.Printf("🔧 Creating Image Builder pipeline %s (runner %s on %s)...\n", name, runnerVersion, parentImage)
+	}
+
+	component, err := client.CreateComponent(context.TODO(), &imagebuilder.CreateComponentInput{
+		Name:            aws.String(name),
+		SemanticVersion: aws.String(runnerVersion),
+		Platform:        types.PlatformLinux,
+		Data:            aws.String(fmt.Sprintf(runnerComponentDocument, name, runnerVersion)),
+		Description:     aws.String("GitHub Actions runner " + runnerVersion),
+		Tags:            tags,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create runner component: %w", classifyAWSError(err))
+	}
+
+	recipe, err := client.CreateImageRecipe(context.TODO(), &imagebuilder.CreateImageRecipeInput{
+		Name:            aws.String(name),
+		SemanticVersion: aws.String(runnerVersion),
+		ParentImage:     aws.String(parentImage),
+		Components: []types.ComponentConfiguration{
+			{ComponentArn: component.ComponentBuildVersionArn},
+		},
+		Tags: tags,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create image recipe: %w", classifyAWSError(err))
+	}
+
+	infraInput := &imagebuilder.CreateInfrastructureConfigurationInput{
+		Name:                       aws.String(name),
+		InstanceProfileName:        aws.String(buildInstanceProfile),
+		InstanceTypes:              []string{buildInstanceType},
+		TerminateInstanceOnFailure: aws.Bool(true),
+		ResourceTags:               tags,
+		Tags:                       tags,
+	}
+	if buildSubnetID != "" {
+		infraInput.SubnetId = aws.String(buildSubnetID)
+		infraInput.SecurityGroupIds = []string{buildSecurityGroup}
+	}
+	infra, err := client.CreateInfrastructureConfiguration(context.TODO(), infraInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to create infrastructure configuration: %w", classifyAWSError(err))
+	}
+
+	pipeline, err := client.CreateImagePipeline(context.TODO(), &imagebuilder.CreateImagePipelineInput{
+		Name:                           aws.String(name),
+		Description:                    aws.String("GitHub Actions runner AMIs built by gh-workflow"),
+		ImageRecipeArn:                 recipe.ImageRecipeArn,
+		InfrastructureConfigurationArn: infra.InfrastructureConfigurationArn,
+		Tags:                           tags,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create image pipeline: %w", classifyAWSError(err))
+	}
+	emitEvent("ami.pipeline_created", map[string]any{"pipeline_arn": aws.ToString(pipeline.ImagePipelineArn)})
+	return aws.ToString(pipeline.ImagePipelineArn), nil
+}
+
+// buildRunnerAMI starts an Image Builder pipeline and waits for the AMI it produces
+func buildRunnerAMI(client *imagebuilder.Client, pipelineARN string, timeout time.Duration) (string, error) {
+	started, err := client.StartImagePipelineExecution(context.TODO(),
+		&imagebuilder.StartImagePipelineExecutionInput{ImagePipelineArn: aws.String(pipelineARN)})
+	if err != nil {
+		return "", fmt.Errorf("failed to start image pipeline %s: %w", pipelineARN, classifyAWSError(err))
+	}
+	buildARN := aws.ToString(started.ImageBuildVersionArn)
+	emitEvent("ami.build_started", map[string]any{"pipeline_arn": pipelineARN, "build_arn": buildARN})
+	if outputFormat != "github-actions" {
+		fmt.Printf("🏗️  Building %s (this usually takes 20-40 minutes)...\n", buildARN)
+	}
+
+	deadline := time.Now().Add(timeout)
+	lastStatus := types.ImageStatus("")
+	for time.Now().Before(deadline) {
+		time.Sleep(30 * time.Second)
+		result, err := client.GetImage(context.TODO(), &imagebuilder.GetImageInput{
+			ImageBuildVersionArn: aws.String(buildARN),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to get image build %s: %w", buildARN, classifyAWSError(err))
+		}
+		if result.Image == nil || result.Image.State == nil {
+			continue
+		}
+
+		state := result.Image.State
+		if state.Status != lastStatus {
+			lastStatus = state.Status
+			emitEvent("ami.build_state", map[string]any{"build_arn": buildARN, "status": string(state.Status)})
+			if outputFormat != "github-actions" {
+				fmt.Printf("📊 Build status: %s\n", strings.ToLower(string(state.Status)))
+			}
+		}
+
+		switch state.Status {
+		case types.ImageStatusAvailable:
+			if result.Image.OutputResources != nil {
+				for _, ami := range result.Image.OutputResources.Amis {
+					if aws.ToString(ami.Region) == resolveRegion() {
+						return aws.ToString(ami.Image), nil
+					}
+				}
+			}
+			return "", fmt.Errorf("image build %s finished without an AMI in %s", buildARN, resolveRegion())
+		case types.ImageStatusFailed, types.ImageStatusCancelled:
+			return "", fmt.Errorf("image build %s %s: %s",
+				buildARN, strings.ToLower(string(state.Status)), aws.ToString(state.Reason))
+		}
+	}
+	return "", fmt.Errorf("timeout waiting for image build %s after %s", buildARN, timeout)
+}
+
+var amiBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build a runner AMI with EC2 Image Builder",
+	Long: "Run an EC2 Image Builder pipeline that bakes the GitHub Actions runner into an AMI, creating the " +
+		"pipeline first if needed, and print the AMI ID for create --image-id ... --use-baked-ami",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateRunnerVersion(); err != nil {
+			return err
+		}
+		cfg, err := loadAWSConfig()
+		if err != nil {
+			return err
+		}
+		client := imagebuilder.NewFromConfig(cfg)
+
+		pipelineARN := buildPipelineARN
+		if pipelineARN == "" {
+			if pipelineARN, err = findImagePipeline(client, buildName); err != nil {
+				return err
+			}
+		}
+		if pipelineARN == "" {
+			if buildInstanceProfile == "" {
+				return fmt.Errorf("instance-profile is required to create the image pipeline %s", buildName)
+			}
+			if buildSubnetID != "" && buildSecurityGroup == "" {
+				return fmt.Errorf("security-group is required with subnet-id")
+			}
+			parentImage := buildParentImage
+			if parentImage == "" {
+				amis, err := latestAMIs(cfg, "ubuntu-22.04", []string{resolveRegion()}, []string{"x64"})
+				if err != nil {
+					return fmt.Errorf("failed to look up the parent image: %w", err)
+				}
+				parentImage = amis[0].ImageID
+			}
+			if pipelineARN, err = createRunnerPipeline(client, buildName, parentImage); err != nil {
+				return err
+			}
+		}
+
+		imageID, err := buildRunnerAMI(client, pipelineARN, buildTimeout)
+		if err != nil {
+			return err
+		}
+		emitEvent("ami.built", map[string]any{"image_id": imageID, "pipeline_arn": pipelineARN})
+
+		if outputFormat == "github-actions" {
+			fmt.Printf("Image ID: %s\n", imageID)
+		} else {
+			fmt.Printf("✅ AMI built: %s\n", imageID)
+			fmt.Printf("💡 Launch runners from it with --image-id %s --use-baked-ami\n", imageID)
+		}
+		return nil
+	},
+}
+
+func init() {
+	amiBuildCmd.Flags().
+		StringVar(&buildPipelineARN, "pipeline-arn", "", "Existing Image Builder pipeline to run (skips creation)")
+	amiBuildCmd.Flags().
+		StringVar(&buildName, "name", "gh-workflow-runner", "Name of the pipeline to run, or to create if missing")
+	amiBuildCmd.Flags().StringVar(&buildParentImage, "parent-image", "",
+		"Parent AMI ID or Image Builder image ARN (default: current Ubuntu 22.04 x64 AMI)")
+	amiBuildCmd.Flags().StringVar(&buildInstanceType, "instance-type", "t3.medium", "Instance type for builds")
+	amiBuildCmd.Flags().StringVar(&buildInstanceProfile, "instance-profile", "",
+		"Instance profile for build instances (needs EC2InstanceProfileForImageBuilder)")
+	amiBuildCmd.Flags().StringVar(&buildSubnetID, "subnet-id", "", "Subnet for build instances (default VPC if empty)")
+	amiBuildCmd.Flags().StringVar(&buildSecurityGroup, "security-group", "", "Security group for build instances")
+	amiBuildCmd.Flags().DurationVar(&buildTimeout, "timeout", 90*time.Minute, "Maximum time to wait for the build")
+	amiBuildCmd.Flags().StringVar(&runnerVersion, "runner-version", defaultRunnerVersion,
+		"GitHub Actions runner release baked by a newly created pipeline")
+	amiBuildCmd.Flags().
+		StringVar(&outputFormat, "output-format", "", "Output format (github-actions for GitHub Actions compatibility)")
+
+	amiCmd.AddCommand(amiBuildCmd)
+}
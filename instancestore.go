@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var instanceStore string
+
+// Directories moved onto the NVMe instance store: the runner's work directory
+// and Docker's data directory
+const (
+	instanceStoreMount = "/mnt/instance-store"
+	runnerWorkDir      = "/actions-runner/_work"
+	dockerDataDir      = "/var/lib/docker"
+)
+
+// validateInstanceStoreFlags checks --instance-store; whether the instance type
+// has an NVMe instance store is checked when launching
+func validateInstanceStoreFlags() error {
+	switch instanceStore {
+	case "":
+		return nil
+	case "auto", "require":
+	default:
+		return fmt.Errorf("instance-store must be auto or require, got %q", instanceStore)
+	}
+	if runnerOS != "linux" {
+		return fmt.Errorf("instance-store is only supported with --os linux")
+	}
+	if len(instanceStoreDirs()) == 0 {
+		return fmt.Errorf("instance-store has nothing to hold: --volume already mounts %s and %s",
+			runnerWorkDir, dockerDataDir)
+	}
+	return nil
+}
+
+// instanceStoreDirs returns the directories to move onto the instance store,
+// leaving out those a --volume mounts
+func instanceStoreDirs() []string {
+	volumes, _ := dataVolumes()
+	dirs := []string{}
+	for _, dir := range []string{runnerWorkDir, dockerDataDir} {
+		mounted := false
+		for _, volume := range volumes {
+			if strings.TrimSuffix(volume.Mount, "/") == dir {
+				mounted = true
+			}
+		}
+		if !mounted {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// checkInstanceStore reports whether the instance type has an NVMe instance
+// store for --instance-store; with require, a type without one is an error
+func checkInstanceStore(svc *ec2.Client, instanceType types.InstanceType) error {
+	if instanceStore == "" {
+		return nil
+	}
+
+	result, err := svc.DescribeInstanceTypes(context.TODO(), &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []types.InstanceType{instanceType},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe instance type %s: %w", instanceType, classifyAWSError(err))
+	}
+	if len(result.InstanceTypes) == 0 {
+		return fmt.Errorf("%w: instance type %s is not offered in %s", ErrNotFound, instanceType, resolveRegion())
+	}
+	storage := result.InstanceTypes[0].InstanceStorageInfo
+	if storage == nil || storage.NvmeSupport == types.EphemeralNvmeSupportUnsupported {
+		if instanceStore == "require" {
+			return fmt.Errorf("instance type %s has no NVMe instance store; pick a type such as c6id, m6id or i4i, "+
+				"or use --instance-store auto", instanceType)
+		}
+		if outputFormat != "github-actions" {
+			fmt.Printf("💽 Instance type %s has no NVMe instance store; the runner works on its root volume\n",
+				instanceType)
+		}
+		return nil
+	}
+
+	disks := int32(0)
+	for _, disk := range storage.Disks {
+		disks += aws.ToInt32(disk.Count)
+	}
+	emitEvent("instance_store.detected", map[string]any{
+		"instance_type": string(instanceType),
+		"size_gb":       aws.ToInt64(storage.TotalSizeInGB),
+		"disks":         disks,
+	})
+	if outputFormat != "github-actions" {
+		fmt.Printf("💽 Using the %d GB NVMe instance store (%d disk(s)) of %s for %s\n",
+			aws.ToInt64(storage.TotalSizeInGB), disks, instanceType, strings.Join(instanceStoreDirs(), " and "))
+	}
+	return nil
+}
+
+// instanceStoreUserData returns user data lines striping the NVMe instance
+// store disks, formatting them and bind-mounting the runner's work directory
+// and Docker's data directory onto them. The instance store is wiped when the
+// instance stops, so it is not added to /etc/fstab
+func instanceStoreUserData() []string {
+	if instanceStore == "" {
+		return nil
+	}
+	missing := "echo 'No NVMe instance store found, using the root volume'"
+	if instanceStore == "require" {
+		missing = "bootstrap_failed 'no NVMe instance store found'"
+	}
+	lines := []string{
+		"# Move the runner work and Docker directories onto the NVMe instance store",
+		"DISKS=$(for LINK in /dev/disk/by-id/nvme-Amazon_EC2_NVMe_Instance_Storage_*; do",
+		`    [ -e "$LINK" ] && [ "${LINK%-part*}" = "$LINK" ] && readlink -f "$LINK"`,
+		"done | sort -u)",
+		`if [ -n "$DISKS" ]; then`,
+		`    if [ "$(echo "$DISKS" | wc -l)" -gt 1 ]; then`,
+		"        command -v mdadm >/dev/null || (apt-get install -y mdadm || yum install -y mdadm) >/dev/null 2>&1",
+		`        mdadm --create /dev/md0 --run --level=0 --raid-devices="$(echo "$DISKS" | wc -l)" $DISKS ||`,
+		"            bootstrap_failed 'instance store RAID creation failed'",
+		"        DEV=/dev/md0",
+		"    else",
+		`        DEV="$DISKS"`,
+		"    fi",
+		`    mkfs -t ext4 -F -E nodiscard "$DEV" >/dev/null || bootstrap_failed 'instance store format failed'`,
+		fmt.Sprintf(`    mkdir -p %[1]s && mount -o noatime "$DEV" %[1]s`, instanceStoreMount),
+		"    systemctl is-active --quiet docker && systemctl stop docker && RESTART_DOCKER=1",
+	}
+	for _, dir := range instanceStoreDirs() {
+		store := instanceStoreMount + "/" + dir[strings.LastIndex(dir, "/")+1:]
+		lines = append(lines,
+			fmt.Sprintf("    mkdir -p %s %s && mount --bind %s %s", store, dir, store, dir))
+	}
+	lines = append(lines,
+		`    [ -n "$RESTART_DOCKER" ] && systemctl start docker`,
+		"else",
+		"    "+missing,
+		"fi",
+		"",
+	)
+	return lines
+}
+
+func init() {
+	createCmd.Flags().StringVar(&instanceStore, "instance-store", "",
+		"Put the runner work and Docker directories on the instance type's NVMe instance store (e.g. c6id, m6id, "+
+			"i4i): auto (if it has one) or require")
+}
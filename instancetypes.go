@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// fallbackInstanceTypes are the instance types after the first of a
+// comma-separated --instance-type, tried in order when the types before them
+// can't be launched
+var fallbackInstanceTypes []string
+
+// splitInstanceTypes splits a comma-separated --instance-type into the
+// instance type create launches first, left in instanceType for the checks
+// that need one, and fallbackInstanceTypes
+func splitInstanceTypes() {
+	instanceTypes := splitList(instanceType)
+	if len(instanceTypes) < 2 {
+		return
+	}
+	instanceType, fallbackInstanceTypes = instanceTypes[0], nil
+	for _, fallbackType := range instanceTypes[1:] {
+		if fallbackType != instanceType && !slices.Contains(fallbackInstanceTypes, fallbackType) {
+			fallbackInstanceTypes = append(fallbackInstanceTypes, fallbackType)
+		}
+	}
+}
+
+// validateFallbackInstanceTypes checks that the fallback instance types meet
+// the requirements checked for the first one
+func validateFallbackInstanceTypes() error {
+	if len(fallbackInstanceTypes) == 0 {
+		return nil
+	}
+	// The core and thread counts an instance type accepts differ between types
+	if cpuCoreCount != 0 || threadsPerCore != 0 {
+		return fmt.Errorf("cpu-core-count and threads-per-core can't be used with several instance types")
+	}
+	preset := presets[runnerPreset]
+	for _, fallbackType := range fallbackInstanceTypes {
+		if (requireNestedVirt || preset.RequireKVM) && !isKVMCapable(fallbackType) {
+			return fmt.Errorf("fallback instance type %s is not a metal instance type, which KVM needs", fallbackType)
+		}
+		if preset.RequireGPU && !isGPUCapable(fallbackType) {
+			return fmt.Errorf("fallback instance type %s is not an NVIDIA GPU instance type", fallbackType)
+		}
+		if creditSpecification != "" && !isBurstable(fallbackType) {
+			return fmt.Errorf("credit-specification only applies to burstable (T) instance types, not %s",
+				fallbackType)
+		}
+	}
+	return nil
+}
+
+// withFallbackInstanceTypes returns first followed by the fallback instance
+// types it is not one of
+func withFallbackInstanceTypes(first string) []string {
+	instanceTypes := []string{first}
+	for _, fallbackType := range fallbackInstanceTypes {
+		if !slices.Contains(instanceTypes, fallbackType) {
+			instanceTypes = append(instanceTypes, fallbackType)
+		}
+	}
+	return instanceTypes
+}
+
+// checkInstanceTypes checks that every other instance type create may launch,
+// a fallback instance type or one of a fleet, has the runner architecture of
+// the primary type, which the AMI was checked against
+func checkInstanceTypes(svc *ec2.Client, primaryType, primaryArch string) error {
+	for _, otherType := range fleetTypes() {
+		if otherType == primaryType {
+			continue
+		}
+		arch, err := instanceTypeArch(svc, otherType)
+		if err != nil {
+			return err
+		}
+		if arch != primaryArch {
+			return fmt.Errorf("instance type %s is %s but %s is %s", otherType, arch, primaryType, primaryArch)
+		}
+	}
+	return nil
+}
+
+// launchInstanceTypes tries launchInSubnets with each of the instance types in
+// turn until one is not short of capacity or is offered in the subnets, and
+// returns the subnet it launched in; the launched instance type is left in the
+// launch request. Every instance type gets its own client tokens. A fleet
+// launch is a single try, the fleet choosing among the instance types itself
+func launchInstanceTypes(runInput *ec2.RunInstancesInput, instanceTypes, subnets []string, clientToken string,
+	launch func() error) (string, error) {
+	if len(instanceTypes) < 2 || launchMode == launchModeFleet {
+		return launchInSubnets(runInput, subnets, clientToken, launch)
+	}
+	var err error
+	for i, launchType := range instanceTypes {
+		runInput.InstanceType = types.InstanceType(launchType)
+		token := clientToken
+		if i > 0 {
+			token = fmt.Sprintf("%s-t%d", clientToken, i)
+		}
+		var subnet string
+		subnet, err = launchInSubnets(runInput, subnets, token, launch)
+		if err == nil || !isSubnetFallbackError(err) || i == len(instanceTypes)-1 {
+			return subnet, err
+		}
+
+		if outputFormat != "github-actions" {
+			fmt.Printf("⚠️  Can't launch %s (%s), trying %s...\n",
+				launchType, awsErrorCode(err), instanceTypes[i+1])
+		}
+		emitEvent("instance.instance_type_fallback", map[string]any{
+			"instance_type":      launchType,
+			"next_instance_type": instanceTypes[i+1],
+			"reason":             err.Error(),
+		})
+	}
+	return "", err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var (
+	trackJobs bool
+	listJob   string
+)
+
+// jobTagPrefix prefixes the instance tags the runner's job hooks write; the
+// full key is <prefix><run id>-<run attempt>-<job> and the value is
+// "<started|completed> <time>"
+const jobTagPrefix = "gh-workflow:job/"
+
+// RunnerJob is a workflow job a runner instance executed, as recorded by its job hooks
+type RunnerJob struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Time   string `json:"time,omitempty"`
+}
+
+// jobsFromInstance returns the jobs recorded in an instance's tags, oldest first
+func jobsFromInstance(instance types.Instance) []RunnerJob {
+	jobs := []RunnerJob{}
+	for _, tag := range instance.Tags {
+		id, ok := strings.CutPrefix(aws.ToString(tag.Key), jobTagPrefix)
+		if !ok || id == "" {
+			continue
+		}
+		status, at, _ := strings.Cut(aws.ToString(tag.Value), " ")
+		jobs = append(jobs, RunnerJob{ID: id, Status: status, Time: at})
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Time < jobs[j].Time })
+	return jobs
+}
+
+// ranJob reports whether a runner executed the job, given as a workflow run ID,
+// "<run id>-<attempt>" or "<run id>-<attempt>-<job>"
+func ranJob(jobs []RunnerJob, job string) bool {
+	for _, recorded := range jobs {
+		if recorded.ID == job || strings.HasPrefix(recorded.ID, job+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// jobHookUserData returns user data lines installing the runner job hooks of
+// --track-jobs, which log every job to /var/log/gh-workflow-jobs.log and, when
+// the AWS CLI and an instance profile allowing ec2:CreateTags are available,
+// tag the instance with it, and of --diag-s3-uri, which uploads diagnostics
+// when a job completes
+func jobHookUserData() []string {
+	if !trackJobs && diagS3URI == "" {
+		return nil
+	}
+	lines := []string{
+		"# Install the runner job hooks",
+		"cat > /usr/local/bin/gh-workflow-job-hook << 'EOF'",
+		"#!/bin/bash",
+		"PHASE=$(basename \"$0\" .sh)",
+		"JOB=\"${GITHUB_RUN_ID}-${GITHUB_RUN_ATTEMPT}-${GITHUB_JOB}\"",
+		"NOW=$(date -u +%Y-%m-%dT%H:%M:%SZ)",
+		"IMDS=http://169.254.169.254/latest",
+		"TOKEN=$(curl -s -X PUT $IMDS/api/token -H 'X-aws-ec2-metadata-token-ttl-seconds: 60')",
+		"IID=$(curl -s -H \"X-aws-ec2-metadata-token: $TOKEN\" $IMDS/meta-data/instance-id)",
+		"REGION=$(curl -s -H \"X-aws-ec2-metadata-token: $TOKEN\" $IMDS/meta-data/placement/region)",
+	}
+	if trackJobs {
+		lines = append(lines,
+			"echo \"${NOW} ${PHASE#job-} ${GITHUB_REPOSITORY} ${JOB}\" >> /var/log/gh-workflow-jobs.log",
+			"if command -v aws >/dev/null; then",
+			"    aws ec2 create-tags --region \"$REGION\" --resources \"$IID\" \\",
+			"        --tags \"Key="+jobTagPrefix+"${JOB},Value=${PHASE#job-} ${NOW}\" || true",
+			"fi",
+		)
+	}
+	lines = append(lines, diagHookLines()...)
+	return append(lines,
+		"exit 0",
+		"EOF",
+		"chmod +x /usr/local/bin/gh-workflow-job-hook",
+		"ln -sf /usr/local/bin/gh-workflow-job-hook /usr/local/bin/job-started.sh",
+		"ln -sf /usr/local/bin/gh-workflow-job-hook /usr/local/bin/job-completed.sh",
+		"export ACTIONS_RUNNER_HOOK_JOB_STARTED=/usr/local/bin/job-started.sh",
+		"export ACTIONS_RUNNER_HOOK_JOB_COMPLETED=/usr/local/bin/job-completed.sh",
+	)
+}
+
+func init() {
+	createCmd.Flags().BoolVar(&trackJobs, "track-jobs", false,
+		"Tag the instance with every job its runner executes (needs the AWS CLI and ec2:CreateTags on the instance)")
+	listCmd.Flags().StringVar(&listJob, "job", "",
+		"Only list instances that ran this job (run ID, run-attempt or run-attempt-job)")
+}
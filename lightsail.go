@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lightsail"
+	"github.com/aws/aws-sdk-go-v2/service/lightsail/types"
+	"github.com/spf13/cobra"
+)
+
+// lightsailProvider launches Lightsail instances, which need no VPC, subnet or
+// security group; instances are identified by name, which is the runner name
+type lightsailProvider struct {
+	options map[string]string
+	svc     *lightsail.Client
+	region  string
+}
+
+// lightsailStaticIPTag records the static IP allocated for an instance, released on terminate
+const lightsailStaticIPTag = "StaticIp"
+
+func newLightsailProvider(options map[string]string) (Provider, error) {
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &lightsailProvider{options: options, svc: lightsail.NewFromConfig(cfg), region: cfg.Region}, nil
+}
+
+// waitForRunning polls until the instance is running; static IPs can only be attached then
+func (p *lightsailProvider) waitForRunning(name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		out, err := p.svc.GetInstance(context.TODO(), &lightsail.GetInstanceInput{InstanceName: aws.String(name)})
+		if err != nil {
+			return classifyAWSError(err)
+		}
+		if out.Instance.State != nil && aws.ToString(out.Instance.State.Name) == "running" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("instance %s is not running after %s", name, timeout)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (p *lightsailProvider) Create(spec ProviderSpec) (ProviderInstance, error) {
+	bundle := spec.InstanceType
+	if bundle == "" {
+		bundle = p.options["bundle"]
+	}
+	blueprint := spec.Image
+	if blueprint == "" {
+		blueprint = p.options["blueprint"]
+	}
+	if bundle == "" || blueprint == "" {
+		return ProviderInstance{}, fmt.Errorf("lightsail provider needs a bundle (--instance-type or " +
+			"--lightsail-bundle, e.g. medium_3_0) and a blueprint (--image-id or --lightsail-blueprint, " +
+			"e.g. ubuntu_22_04)")
+	}
+	zone := p.options["availability-zone"]
+	if zone == "" {
+		zone = p.region + "a"
+	} else if !strings.HasPrefix(zone, p.region) {
+		return ProviderInstance{}, fmt.Errorf("availability zone %s is not in region %s", zone, p.region)
+	}
+	staticIP := false
+	if value := p.options["static-ip"]; value != "" {
+		var err error
+		if staticIP, err = strconv.ParseBool(value); err != nil {
+			return ProviderInstance{}, fmt.Errorf("invalid static-ip %q", value)
+		}
+	}
+
+	tags := spec.Tags
+	if staticIP {
+		tags[lightsailStaticIPTag] = spec.Name + "-ip"
+	}
+	lightsailTags := make([]types.Tag, 0, len(tags))
+	for key, value := range tags {
+		lightsailTags = append(lightsailTags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	input := &lightsail.CreateInstancesInput{
+		InstanceNames:    []string{spec.Name},
+		AvailabilityZone: aws.String(zone),
+		BundleId:         aws.String(bundle),
+		BlueprintId:      aws.String(blueprint),
+		UserData:         aws.String(spec.UserData),
+		Tags:             lightsailTags,
+	}
+	if keyPair := p.options["key-pair"]; keyPair != "" {
+		input.KeyPairName = aws.String(keyPair)
+	}
+	if _, err := p.svc.CreateInstances(context.TODO(), input); err != nil {
+		return ProviderInstance{}, classifyAWSError(err)
+	}
+	instance := ProviderInstance{ID: spec.Name, Name: spec.Name, State: "pending", Tags: tags}
+	if !staticIP {
+		return instance, nil
+	}
+
+	ipName := tags[lightsailStaticIPTag]
+	if _, err := p.svc.AllocateStaticIp(context.TODO(), &lightsail.AllocateStaticIpInput{
+		StaticIpName: aws.String(ipName),
+	}); err != nil {
+		return instance, fmt.Errorf("instance %s was created but allocating its static IP failed: %w",
+			spec.Name, classifyAWSError(err))
+	}
+	if err := p.waitForRunning(spec.Name, 5*time.Minute); err != nil {
+		return instance, fmt.Errorf("instance %s was created but attaching its static IP failed: %w", spec.Name, err)
+	}
+	if _, err := p.svc.AttachStaticIp(context.TODO(), &lightsail.AttachStaticIpInput{
+		StaticIpName: aws.String(ipName),
+		InstanceName: aws.String(spec.Name),
+	}); err != nil {
+		return instance, fmt.Errorf("instance %s was created but attaching its static IP failed: %w",
+			spec.Name, classifyAWSError(err))
+	}
+	out, err := p.svc.GetStaticIp(context.TODO(), &lightsail.GetStaticIpInput{StaticIpName: aws.String(ipName)})
+	if err == nil {
+		instance.PublicIP = aws.ToString(out.StaticIp.IpAddress)
+	}
+	instance.State = "running"
+	return instance, nil
+}
+
+func (p *lightsailProvider) Terminate(id string) error {
+	out, err := p.svc.GetInstance(context.TODO(), &lightsail.GetInstanceInput{InstanceName: aws.String(id)})
+	if err != nil {
+		return classifyAWSError(err)
+	}
+	ipName := ""
+	for _, tag := range out.Instance.Tags {
+		if aws.ToString(tag.Key) == lightsailStaticIPTag {
+			ipName = aws.ToString(tag.Value)
+		}
+	}
+
+	if _, err := p.svc.DeleteInstance(context.TODO(), &lightsail.DeleteInstanceInput{
+		InstanceName: aws.String(id),
+	}); err != nil {
+		return classifyAWSError(err)
+	}
+	// Deleting the instance detaches the static IP, which keeps being billed until released
+	if ipName != "" {
+		_, err := p.svc.ReleaseStaticIp(context.TODO(), &lightsail.ReleaseStaticIpInput{
+			StaticIpName: aws.String(ipName),
+		})
+		if err = classifyAWSError(err); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("instance %s was deleted but releasing static IP %s failed: %w", id, ipName, err)
+		}
+	}
+	return nil
+}
+
+func (p *lightsailProvider) List() ([]ProviderInstance, error) {
+	instances := []ProviderInstance{}
+	input := &lightsail.GetInstancesInput{}
+	for {
+		out, err := p.svc.GetInstances(context.TODO(), input)
+		if err != nil {
+			return nil, classifyAWSError(err)
+		}
+		for _, instance := range out.Instances {
+			tags := map[string]string{}
+			for _, tag := range instance.Tags {
+				tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+			if tags["Purpose"] != "GitHub Actions" {
+				continue
+			}
+			state := ""
+			if instance.State != nil {
+				state = aws.ToString(instance.State.Name)
+			}
+			instances = append(instances, ProviderInstance{
+				ID:        aws.ToString(instance.Name),
+				Name:      aws.ToString(instance.Name),
+				State:     state,
+				PublicIP:  aws.ToString(instance.PublicIpAddress),
+				PrivateIP: aws.ToString(instance.PrivateIpAddress),
+				Tags:      tags,
+			})
+		}
+		if aws.ToString(out.NextPageToken) == "" {
+			return instances, nil
+		}
+		input.PageToken = out.NextPageToken
+	}
+}
+
+// lightsailZoneRegex matches availability zone names, e.g. us-east-1a
+var lightsailZoneRegex = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d[a-z]$`)
+
+// checkLightsailZone checks that a value is an availability zone name
+func checkLightsailZone(value string) error {
+	if !lightsailZoneRegex.MatchString(value) {
+		return fmt.Errorf("%q is not an availability zone, e.g. us-east-1a", value)
+	}
+	return nil
+}
+
+func init() {
+	providerFactories["lightsail"] = newLightsailProvider
+
+	create := []*cobra.Command{createCmd}
+	addProviderFlag(create, "lightsail", "bundle",
+		"Lightsail bundle ID, if --instance-type isn't given, e.g. medium_3_0", nil)
+	addProviderFlag(create, "lightsail", "blueprint",
+		"Lightsail blueprint ID, if --image-id isn't given, e.g. ubuntu_22_04", nil)
+	addProviderFlag(create, "lightsail", "availability-zone",
+		"Lightsail availability zone (default: the region's a zone)", checkLightsailZone)
+}
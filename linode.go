@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// linodeProvider creates Linodes with cloud-init user data passed through the
+// Metadata service; the token comes from --provider-opt token or LINODE_TOKEN
+type linodeProvider struct {
+	api     *cloudAPIClient
+	options map[string]string
+}
+
+type linodeInstance struct {
+	ID     int64    `json:"id"`
+	Label  string   `json:"label"`
+	Status string   `json:"status"`
+	IPv4   []string `json:"ipv4"`
+}
+
+func newLinodeProvider(options map[string]string) (Provider, error) {
+	token := providerOption(options, "token", "LINODE_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("%w: linode provider needs --provider-opt token=... or LINODE_TOKEN", ErrAuth)
+	}
+	return &linodeProvider{api: newCloudAPIClient("Linode", "https://api.linode.com/v4", token), options: options}, nil
+}
+
+func (p *linodeProvider) instance(linode linodeInstance) ProviderInstance {
+	instance := ProviderInstance{
+		ID:    strconv.FormatInt(linode.ID, 10),
+		Name:  linode.Label,
+		State: linode.Status,
+		Tags:  map[string]string{"Purpose": "GitHub Actions", "RunnerName": linode.Label},
+	}
+	for _, ip := range linode.IPv4 {
+		// Private Linode addresses are in 192.168.128.0/17
+		if strings.HasPrefix(ip, "192.168.") {
+			if instance.PrivateIP == "" {
+				instance.PrivateIP = ip
+			}
+		} else if instance.PublicIP == "" {
+			instance.PublicIP = ip
+		}
+	}
+	return instance
+}
+
+func (p *linodeProvider) Create(spec ProviderSpec) (ProviderInstance, error) {
+	linodeType, image, err := requireSizeAndImage("linode", spec)
+	if err != nil {
+		return ProviderInstance{}, err
+	}
+	region := p.options["region"]
+	if region == "" {
+		return ProviderInstance{}, fmt.Errorf("linode provider needs --provider-opt region=... (e.g. us-east)")
+	}
+
+	// A root password is required when deploying an image; nobody needs to know it
+	password := make([]byte, 24)
+	if _, err := rand.Read(password); err != nil {
+		return ProviderInstance{}, fmt.Errorf("failed to generate root password: %v", err)
+	}
+	request := map[string]any{
+		"label":     spec.Name,
+		"region":    region,
+		"type":      linodeType,
+		"image":     image,
+		"root_pass": base64.RawURLEncoding.EncodeToString(password),
+		"tags":      []string{providerRunnerTag},
+		"metadata":  map[string]string{"user_data": base64.StdEncoding.EncodeToString([]byte(spec.UserData))},
+	}
+	if keys := splitList(p.options["authorized-keys"]); len(keys) > 0 {
+		request["authorized_keys"] = keys
+	}
+
+	var linode linodeInstance
+	if err := p.api.request("POST", "/linode/instances", nil, request, &linode, http.StatusOK); err != nil {
+		return ProviderInstance{}, err
+	}
+	instance := p.instance(linode)
+	instance.Tags = spec.Tags
+	return instance, nil
+}
+
+func (p *linodeProvider) Terminate(id string) error {
+	return p.api.request("DELETE", "/linode/instances/"+id, nil, nil, nil, http.StatusOK)
+}
+
+func (p *linodeProvider) List() ([]ProviderInstance, error) {
+	filter, err := json.Marshal(map[string]string{"tags": providerRunnerTag})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode filter: %v", err)
+	}
+
+	instances := []ProviderInstance{}
+	for page := 1; ; page++ {
+		var response struct {
+			Data  []linodeInstance `json:"data"`
+			Pages int              `json:"pages"`
+		}
+		path := fmt.Sprintf("/linode/instances?page=%d&page_size=500", page)
+		headers := map[string]string{"X-Filter": string(filter)}
+		if err := p.api.request("GET", path, headers, nil, &response, http.StatusOK); err != nil {
+			return nil, err
+		}
+		for _, linode := range response.Data {
+			instances = append(instances, p.instance(linode))
+		}
+		if page >= response.Pages {
+			return instances, nil
+		}
+	}
+}
+
+func init() {
+	providerFactories["linode"] = newLinodeProvider
+}
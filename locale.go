@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	runnerTimezone string
+	runnerLocale   string
+)
+
+// Formats of --timezone and --locale; Linux takes IANA time zones and glibc
+// locales, Windows its own time zone IDs and culture names
+var (
+	linuxTimezonePattern   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_+-]*(/[A-Za-z0-9_+-]+)*$`)
+	windowsTimezonePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9 ().+-]*$`)
+	linuxLocalePattern     = regexp.MustCompile(`^([a-z]{2,3}(_[A-Z]{2})?|C)(\.[A-Za-z0-9-]+)?(@[a-z]+)?$`)
+	windowsLocalePattern   = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z]{2,4})*$`)
+)
+
+// localeUserData returns user data lines setting the system time zone and
+// locale, and passing them to jobs through the runner's .env file
+func localeUserData() []string {
+	if runnerTimezone == "" && runnerLocale == "" {
+		return nil
+	}
+	lines := []string{"# Set the time zone and locale", "mkdir -p /actions-runner"}
+	if runnerTimezone != "" {
+		lines = append(lines,
+			fmt.Sprintf("timedatectl set-timezone %[1]s || ln -sf /usr/share/zoneinfo/%[1]s /etc/localtime",
+				runnerTimezone),
+			fmt.Sprintf("echo 'TZ=%s' >> /actions-runner/.env", runnerTimezone),
+		)
+	}
+	if runnerLocale != "" {
+		lines = append(lines,
+			fmt.Sprintf("if command -v locale-gen >/dev/null; then locale-gen %s; fi", runnerLocale),
+			fmt.Sprintf("update-locale LANG=%[1]s 2>/dev/null || localectl set-locale LANG=%[1]s", runnerLocale),
+			fmt.Sprintf("echo 'LANG=%[1]s' >> /actions-runner/.env && echo 'LC_ALL=%[1]s' >> /actions-runner/.env",
+				runnerLocale),
+		)
+	}
+	return append(lines, "")
+}
+
+// windowsLocaleUserData returns PowerShell lines setting the time zone and culture
+func windowsLocaleUserData() []string {
+	lines := []string{}
+	if runnerTimezone != "" {
+		lines = append(lines, fmt.Sprintf("Set-TimeZone -Id '%s'", runnerTimezone))
+	}
+	if runnerLocale != "" {
+		lines = append(lines,
+			fmt.Sprintf("Set-WinSystemLocale -SystemLocale '%s'", runnerLocale),
+			fmt.Sprintf("Set-Culture -CultureInfo '%s'", runnerLocale),
+		)
+	}
+	return lines
+}
+
+// validateLocaleFlags checks --timezone and --locale against the formats of --os
+func validateLocaleFlags() error {
+	timezonePattern, localePattern := linuxTimezonePattern, linuxLocalePattern
+	if runnerOS == "windows" {
+		timezonePattern, localePattern = windowsTimezonePattern, windowsLocalePattern
+	}
+	if runnerTimezone != "" && !timezonePattern.MatchString(runnerTimezone) {
+		return fmt.Errorf("timezone %q is not a time zone (e.g. Europe/Berlin, or W. Europe Standard Time on Windows)",
+			runnerTimezone)
+	}
+	if runnerLocale != "" && !localePattern.MatchString(runnerLocale) {
+		return fmt.Errorf("locale %q is not a locale (e.g. de_DE.UTF-8, or de-DE on Windows)", runnerLocale)
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().StringVar(&runnerTimezone, "timezone", "",
+		"Time zone of the runner, e.g. Europe/Berlin (Windows time zone IDs with --os windows)")
+	createCmd.Flags().StringVar(&runnerLocale, "locale", "",
+		"Locale of the runner, e.g. de_DE.UTF-8 (a culture such as de-DE with --os windows)")
+}
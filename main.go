@@ -4,18 +4,22 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go/middleware"
 	"github.com/spf13/cobra"
 )
 
@@ -34,10 +38,27 @@ var (
 	outputFormat       string
 	instanceMarketType string
 	spotMaxPrice       string
+	fallbackToOnDemand bool
 	forceTerminate     bool
 	terminationTimeout int
+	useBakedAMI        bool
+	tagResourceTypes   string
+	instanceProfile    string
+	keyName            string
+	awsRegion          string
 )
 
+// taggableResourceTypes are the resources RunInstances can tag at launch
+var taggableResourceTypes = map[string]types.ResourceType{
+	"instance":          types.ResourceTypeInstance,
+	"volume":            types.ResourceTypeVolume,
+	"network-interface": types.ResourceTypeNetworkInterface,
+}
+
+// defaultRunnerVersion is the GitHub Actions runner release installed on
+// instances unless --runner-version pins another
+const defaultRunnerVersion = "2.313.0"
+
 // GitHubRegistrationTokenResponse represents the response from GitHub API
 type GitHubRegistrationTokenResponse struct {
 	Token     string    `json:"token"`
@@ -46,34 +67,14 @@ type GitHubRegistrationTokenResponse struct {
 
 // getGitHubRegistrationToken fetches a runner registration token from GitHub API
 func getGitHubRegistrationToken(githubToken, repoOwner, repoName string) (string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/actions/runners/registration-token", repoOwner, repoName)
-
-	req, err := http.NewRequest("POST", url, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", githubToken))
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to make request: %v", err)
+	if err := checkReadOnly("create a GitHub runner registration token"); err != nil {
+		return "", err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	path := runnersAPIPath(runnerScope(repoOwner, repoName)) + "/registration-token"
+	body, err := githubAPIRequest("POST", path, githubToken, nil, http.StatusCreated)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	if resp.StatusCode != http.StatusCreated {
-		return "", fmt.Errorf("GitHub API returned status %d: %s", resp.StatusCode, string(body))
+		return "", err
 	}
 
 	var tokenResponse GitHubRegistrationTokenResponse
@@ -81,67 +82,158 @@ func getGitHubRegistrationToken(githubToken, repoOwner, repoName string) (string
 		return "", fmt.Errorf("failed to parse response: %v", err)
 	}
 
+	emitEvent("token.obtained", map[string]any{"expires_at": tokenResponse.ExpiresAt})
+
 	if outputFormat != "github-actions" {
 		fmt.Printf("✅ Successfully obtained GitHub runner registration token\n")
-		fmt.Printf("🕐 Token expires at: %s\n", tokenResponse.ExpiresAt.Format(time.RFC3339))
+		fmt.Printf("🕐 Token expires at: %s\n", formatTimeRelative(tokenResponse.ExpiresAt))
 	}
 
 	return tokenResponse.Token, nil
 }
 
-// loadAWSCredentials loads AWS credentials from environment variables
-func loadAWSCredentials() (aws.CredentialsProvider, error) {
-	accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID")
-	secretAccessKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
-
-	if accessKeyID == "" || secretAccessKey == "" {
-		return nil, fmt.Errorf(
-			"AWS credentials not found in environment variables (AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY required)",
-		)
+// loadAWSCredentials returns the credentials fetched from a secrets backend,
+// or nil to use the SDK's default credential chain: environment variables,
+// shared config and SSO profiles, web identity (IRSA) and ECS/EC2 roles
+func loadAWSCredentials() aws.CredentialsProvider {
+	if secretAWSCredentials != nil {
+		return secretAWSCredentials
 	}
-
-	return credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, ""), nil
+	return nil
 }
 
-// createEC2Client creates an AWS EC2 client with credentials
-func createEC2Client() (*ec2.Client, error) {
-	creds, err := loadAWSCredentials()
-	if err != nil {
-		return nil, err
+// resolveRegion returns the AWS region from --region, environment variables or
+// the selected AWS profile, defaulting to us-east-1 (or the main region of --partition)
+func resolveRegion() string {
+	if awsRegion != "" {
+		return awsRegion
 	}
-
-	// Check for region from environment variables, default to us-east-1 if not set
 	region := os.Getenv("AWS_REGION")
 	if region == "" {
 		region = os.Getenv("AWS_DEFAULT_REGION")
 	}
 	if region == "" {
-		region = "us-east-1" // Default region
+		region = profileRegion()
+	}
+	if region == "" {
+		partition, ok := awsPartitions[awsPartitionFlag]
+		if !ok {
+			partition = awsPartitions["aws"]
+		}
+		region = partition.DefaultRegion
 	}
+	return region
+}
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(region),
-		config.WithCredentialsProvider(creds),
-	)
+// loadAWSConfig loads the AWS SDK configuration with credentials and region
+func loadAWSConfig() (aws.Config, error) {
+	options := []func(*config.LoadOptions) error{
+		config.WithRegion(resolveRegion()),
+		config.WithAPIOptions([]func(*middleware.Stack) error{
+			awsmiddleware.AddUserAgentKeyValue("gh-workflow", Version),
+			addReadOnlyMiddleware,
+		}),
+		config.WithRetryer(newAWSRetryer),
+	}
+	// An explicit profile also wins over AWS_ACCESS_KEY_ID; AWS_PROFILE is read by the SDK itself
+	if awsProfile != "" {
+		options = append(options, config.WithSharedConfigProfile(awsProfile))
+	}
+	if creds := loadAWSCredentials(); creds != nil {
+		options = append(options, config.WithCredentialsProvider(creds))
+	}
+	if activeCassette != nil {
+		// A replay signs requests that never leave the process, so any credentials do
+		if !activeCassette.recording {
+			options = append(options, config.WithCredentialsProvider(
+				credentials.NewStaticCredentialsProvider("REPLAY", "REPLAY", "")))
+		}
+	}
+	if useFIPSEndpoint {
+		options = append(options, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	// AWS_ENDPOINT_URL and AWS_ENDPOINT_URL_<SERVICE> are read by the SDK itself
+	if awsEndpointURL != "" {
+		options = append(options, config.WithBaseEndpoint(awsEndpointURL))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO(), options...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %v", err)
+	}
+	if activeCassette != nil {
+		cfg.HTTPClient = cassetteAWSClient{next: cfg.HTTPClient, cassette: activeCassette}
+	}
+	cfg = withAssumedRole(cfg)
+
+	// Fail early with a clear message rather than on the first API call
+	if _, err := cfg.Credentials.Retrieve(context.TODO()); err != nil {
+		if profile := selectedAWSProfile(); profile != "" {
+			return aws.Config{}, fmt.Errorf("%w: no AWS credentials from profile %s (for an SSO profile, run "+
+				"`aws sso login --profile %s`): %v", ErrAuth, profile, profile, err)
+		}
+		return aws.Config{}, fmt.Errorf("%w: no AWS credentials found (environment variables, shared config or "+
+			"SSO profile, web identity token or instance role): %v", ErrAuth, err)
+	}
+
+	return cfg, nil
+}
+
+// createEC2Client creates an AWS EC2 client with credentials
+func createEC2Client() (*ec2.Client, error) {
+	cfg, err := loadAWSConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %v", err)
+		return nil, err
 	}
 
-	fmt.Println("AWS Region: ", region)
+	if outputFormat != "github-actions" {
+		fmt.Println("AWS Region: ", cfg.Region)
+		if awsEndpointURL != "" {
+			fmt.Println("AWS Endpoint: ", awsEndpointURL)
+		}
+	}
 
 	return ec2.NewFromConfig(cfg), nil
 }
 
-// generateUserData creates a comprehensive user data script for GitHub Actions runner
-func generateUserData(registrationToken, repoOwner, repoName, runnerLabels, preRunnerScript, runnerName string) string {
-	// Default pre-runner script if none provided
-	if preRunnerScript == "" {
-		preRunnerScript = `# Default pre-runner script
+// userDataSetup returns the optional host setup lines that run before the runner is installed
+func userDataSetup() []string {
+	lines := timeSyncUserData()
+	lines = append(lines, volumeUserData()...)
+	lines = append(lines, instanceStoreUserData()...)
+	lines = append(lines, swapUserData()...)
+	lines = append(lines, sshCAUserData(sshCAPublicKey)...)
+	lines = append(lines, jobHookUserData()...)
+	lines = append(lines, heartbeatUserData()...)
+	lines = append(lines, spotInterruptionUserData()...)
+	lines = append(lines, presetUserData()...)
+	lines = append(lines, localeUserData()...)
+	lines = append(lines, sysctlUserData()...)
+	return lines
+}
+
+// resolvePreRunnerScript returns the pre-runner script, or the default one if none was provided
+func resolvePreRunnerScript(preRunnerScript string) string {
+	if preRunnerScript != "" {
+		return preRunnerScript
+	}
+	if useBakedAMI {
+		return `echo "Using the GitHub Actions Runner baked into the AMI..."`
+	}
+	return `# Default pre-runner script
 echo "Starting GitHub Actions Runner setup..."
 apt-get update -y
 apt-get install -y curl jq git`
+}
+
+// generateUserData creates a comprehensive user data script for GitHub Actions runner
+func generateUserData(registrationToken, repoOwner, repoName, runnerLabels, preRunnerScript, runnerName string) string {
+	if runnerOS == "windows" {
+		return generateWindowsUserData(registrationToken, repoOwner, repoName, runnerLabels, preRunnerScript, runnerName)
 	}
 
+	preRunnerScript = resolvePreRunnerScript(preRunnerScript)
+
 	// Default labels if none provided
 	if runnerLabels == "" {
 		runnerLabels = "self-hosted,linux,x64"
@@ -156,25 +248,39 @@ apt-get install -y curl jq git`
 		"#!/bin/bash",
 		"exec > >(tee /var/log/user-data.log|logger -t user-data -s 2>/dev/console) 2>&1",
 		"echo 'Starting GitHub Actions Runner setup...'",
+	}
+	userDataLines = append(userDataLines, bootstrapStatusUserData()...)
+	userDataLines = append(userDataLines, userDataSetup()...)
+	userDataLines = append(userDataLines,
 		"mkdir -p actions-runner && cd actions-runner",
 		fmt.Sprintf(`echo "%s" > pre-runner-script.sh`, strings.ReplaceAll(preRunnerScript, `"`, `\"`)),
 		"chmod +x pre-runner-script.sh",
 		"source pre-runner-script.sh",
-		"case $(uname -m) in aarch64) ARCH=\"arm64\" ;; amd64|x86_64) ARCH=\"x64\" ;; esac && export RUNNER_ARCH=${ARCH}",
-		"echo \"Detected architecture: ${RUNNER_ARCH}\"",
-		"curl -O -L https://github.com/actions/runner/releases/download/v2.313.0/actions-runner-linux-${RUNNER_ARCH}-2.313.0.tar.gz",
-		"tar xzf ./actions-runner-linux-${RUNNER_ARCH}-2.313.0.tar.gz",
+	)
+	// A baked AMI (see `ami build`) already has the runner in /actions-runner
+	if !useBakedAMI {
+		userDataLines = append(userDataLines,
+			fmt.Sprintf("export RUNNER_ARCH=%s", runnerArch),
+			fmt.Sprintf(
+				"HTTP_CODE=$(curl -sSL -w '%%{http_code}' -o actions-runner-linux-${RUNNER_ARCH}-%[1]s.tar.gz "+
+					"https://github.com/actions/runner/releases/download/v%[1]s/actions-runner-linux-${RUNNER_ARCH}-%[1]s.tar.gz)",
+				runnerVersion,
+			),
+			`[ "$HTTP_CODE" = 200 ] || bootstrap_failed "runner download $HTTP_CODE"`,
+			fmt.Sprintf("tar xzf ./actions-runner-linux-${RUNNER_ARCH}-%s.tar.gz || bootstrap_failed 'runner extraction failed'",
+				runnerVersion),
+		)
+	}
+	userDataLines = append(userDataLines,
 		"export RUNNER_ALLOW_RUNASROOT=1",
-		fmt.Sprintf(
-			`./config.sh --url https://github.com/%s/%s --token %s --labels %s --name "%s" --work _work --replace`,
-			repoOwner,
-			repoName,
-			registrationToken,
-			runnerLabels,
-			runnerName,
-		),
+		runnerConfigCommand(registrationToken, repoOwner, repoName, runnerLabels, runnerName)+
+			` || bootstrap_failed "config.sh exited $?"`,
 		"echo 'Runner configured successfully'",
 		"",
+	)
+	userDataLines = append(userDataLines,
+		spotRestartUserData(runnerConfigCommand(`"$TOKEN"`, repoOwner, repoName, runnerLabels, runnerName))...)
+	userDataLines = append(userDataLines,
 		"# Create cleanup script for graceful shutdown",
 		"cat > /usr/local/bin/cleanup-runner.sh << 'EOF'",
 		"#!/bin/bash",
@@ -237,38 +343,184 @@ apt-get install -y curl jq git`
 		"# Health check",
 		"if /usr/local/bin/health-check.sh; then",
 		"    echo '✅ GitHub Actions Runner started successfully!'",
+		"    bootstrap_status OK",
 		"else",
 		"    echo '❌ Failed to start GitHub Actions Runner'",
-		"    exit 1",
+		"    bootstrap_failed 'runner did not start'",
 		"fi",
 		"",
 		"# Keep the script running to maintain the instance",
 		"wait $RUNNER_PID",
-	}
+	)
 
 	return strings.Join(userDataLines, "\n")
 }
 
-// createEC2Instance creates an EC2 instance with the specified parameters
+// runnerConfigCommand returns the config.sh command registering the runner
+// with registrationToken
+func runnerConfigCommand(registrationToken, repoOwner, repoName, runnerLabels, runnerName string) string {
+	return fmt.Sprintf(`./config.sh --url %s/%s --token %s --labels %s --name "%s" --work _work --replace%s%s`,
+		githubServerURL(),
+		runnerScope(repoOwner, repoName),
+		registrationToken,
+		runnerLabels,
+		runnerName,
+		runnerGroupArg(),
+		runnerUpdateArg(),
+	)
+}
+
+// isSpotFallbackError reports whether a failed spot launch should be retried
+// on-demand: no spot capacity, a max price below the spot price, or the spot
+// instance quota, which is separate from the on-demand one
+func isSpotFallbackError(err error) bool {
+	return errors.Is(err, ErrCapacity) || awsErrorCode(err) == "MaxSpotInstanceCountExceeded"
+}
+
+// createEC2Instance creates an EC2 instance with the specified parameters and
+// returns its ID, which is set even when a later step fails
 func createEC2Instance(
 	githubToken, imageID, instanceType, subnetID, securityGroupID, repoOwner, repoName, runnerLabels, preRunnerScript, runnerName, instanceMarketType, spotMaxPrice string,
-) error {
+) (string, error) {
+	correlationID := launchCorrelationID(runID, runnerScope(repoOwner, repoName), runnerName)
+	// --subnet-id may list subnets in several AZs, tried in order when one is out of capacity
+	subnets := splitList(subnetID)
+	if len(subnets) > 0 {
+		subnetID = subnets[0]
+	}
+	manifest := RunManifest{
+		CorrelationID:      correlationID,
+		RunID:              runID,
+		RunnerName:         runnerName,
+		Labels:             runnerLabels,
+		Repository:         runnerScope(repoOwner, repoName),
+		InstanceType:       instanceType,
+		InstanceMarketType: instanceMarketType,
+		SpotMaxPrice:       spotMaxPrice,
+		ImageID:            imageID,
+		SubnetID:           subnetID,
+		SecurityGroupID:    securityGroupID,
+		Region:             resolveRegion(),
+	}
+	if err := runHooks(hookPreCreate, manifest); err != nil {
+		return "", err
+	}
+
+	if err := preflightGitHubToken(githubToken, manifest.Repository); err != nil {
+		return "", err
+	}
+	// A --count create checks the limit once for all of its runners
+	if launchCount == 1 {
+		if err := checkRunnerLimit(githubToken, manifest.Repository, 1); err != nil {
+			return "", err
+		}
+	}
+	if err := checkRunnerGroup(githubToken, manifest.Repository); err != nil {
+		return "", err
+	}
+
 	// First, get the GitHub runner registration token
 	if outputFormat != "github-actions" {
 		fmt.Printf("🔑 Fetching GitHub runner registration token...\n")
 	}
-	registrationToken, err := getGitHubRegistrationToken(githubToken, repoOwner, repoName)
+	emitEvent("token.requested", map[string]any{"repository": manifest.Repository})
+	var registrationToken string
+	err := runPhase("fetching the registration token", tokenTimeout, func(ctx context.Context) error {
+		token, err := getGitHubRegistrationToken(githubToken, repoOwner, repoName)
+		registrationToken = token
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get GitHub registration token: %v", err)
+		return "", fmt.Errorf("failed to get GitHub registration token: %w", err)
+	}
+	var state launchState
+	if state.removalToken, err = fetchSpotRemovalToken(githubToken, repoOwner, repoName); err != nil {
+		return "", err
 	}
 
 	svc, err := createEC2Client()
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	// A retried create of the same job re-attaches to the instance it launched before
+	if instance, err := findRetriedLaunch(svc, correlationID); err != nil {
+		return "", err
+	} else if instance != nil {
+		launched := manifestFromInstance(*instance)
+		launched.SecurityGroupID = firstNonEmpty(launched.SecurityGroupID, securityGroupID)
+		launched.CorrelationID = firstNonEmpty(launched.CorrelationID, correlationID)
+		launched.Phase = phaseLaunched
+		if err := saveManifest(manifestPath, launched); err != nil {
+			return launched.InstanceID, err
+		}
+		return launched.InstanceID, finishCreate(svc, &launched, githubToken)
+	}
+	if err := waitForPendingAMI(svc, imageID); err != nil {
+		return "", err
+	}
+	if err := checkAMI(svc, imageID); err != nil {
+		return "", err
+	}
+
+	// Let the placement script adjust instance type, subnet and pricing
+	if placementScript != "" {
+		spec, err := runPlacementScript(svc, placementScript, PlacementSpec{
+			InstanceType:       instanceType,
+			SubnetID:           subnetID,
+			InstanceMarketType: instanceMarketType,
+			SpotMaxPrice:       spotMaxPrice,
+		}, manifest)
+		if err != nil {
+			return "", err
+		}
+
+		if spec.SubnetID != subnetID {
+			subnets = splitList(spec.SubnetID)
+		}
+		instanceType, subnetID = spec.InstanceType, spec.SubnetID
+		instanceMarketType, spotMaxPrice = spec.InstanceMarketType, spec.SpotMaxPrice
+		manifest.InstanceType, manifest.SubnetID = instanceType, subnetID
+		manifest.InstanceMarketType, manifest.SpotMaxPrice = instanceMarketType, spotMaxPrice
+
+		if outputFormat != "github-actions" {
+			fmt.Printf("🧭 Placement script selected %s (%s) in %s\n", instanceType, instanceMarketType, subnetID)
+		}
+		emitEvent("placement.resolved", map[string]any{
+			"instance_type":        instanceType,
+			"subnet_id":            subnetID,
+			"instance_market_type": instanceMarketType,
+			"spot_max_price":       spotMaxPrice,
+		})
+	}
+
+	// Pick the runner package and labels for the instance type's architecture
+	state.arch, err = resolveRunnerArch(svc, instanceType, imageID)
+	if err != nil {
+		return "", err
+	}
+	runnerLabels = archLabels(runnerLabels, state.arch)
+	manifest.Labels = runnerLabels
+	if err := checkInstanceTypes(svc, instanceType, state.arch); err != nil {
+		return "", err
+	}
+	if subnets, err = checkSpotPlacementScores(svc, subnets); err != nil {
+		return "", err
+	}
+	if len(subnets) > 0 {
+		subnetID = subnets[0]
+		manifest.SubnetID = subnetID
+	}
+
+	if probe {
+		if err := runProbe(svc, imageID, subnetID, securityGroupID, preRunnerScript, state.arch, manifest); err != nil {
+			return "", err
+		}
 	}
 
 	// Generate comprehensive user data script with registration token
-	userData := generateUserData(registrationToken, repoOwner, repoName, runnerLabels, preRunnerScript, runnerName)
+	userData, hash := state.userData(registrationToken, repoOwner, repoName, runnerLabels, preRunnerScript, runnerName)
+	manifest.UserDataHash = hash
 
 	// Base64 encode the user data
 	userDataEncoded := base64.StdEncoding.EncodeToString([]byte(userData))
@@ -280,9 +532,8 @@ func createEC2Instance(
 			fmt.Printf("🎯 Configuring spot instance...\n")
 		}
 
-		spotOptions := &types.SpotMarketOptions{
-			SpotInstanceType: types.SpotInstanceTypeOneTime,
-		}
+		spotOptions := &types.SpotMarketOptions{}
+		applySpotRequestType(spotOptions)
 
 		// Set max price if specified
 		if spotMaxPrice != "" {
@@ -311,14 +562,16 @@ func createEC2Instance(
 		SecurityGroupIds: []string{
 			securityGroupID,
 		},
-		UserData: aws.String(userDataEncoded),
+		UserData:            aws.String(userDataEncoded),
+		ClientToken:         aws.String(correlationID),
+		BlockDeviceMappings: volumeBlockDeviceMappings(),
 	}
 
 	// Build tags dynamically
 	tags := []types.Tag{
 		{
 			Key:   aws.String("Name"),
-			Value: aws.String(fmt.Sprintf("GitHub Actions Runner - %s/%s", repoOwner, repoName)),
+			Value: aws.String(fmt.Sprintf("GitHub Actions Runner - %s", manifest.Repository)),
 		},
 		{
 			Key:   aws.String("Purpose"),
@@ -326,7 +579,7 @@ func createEC2Instance(
 		},
 		{
 			Key:   aws.String("Repository"),
-			Value: aws.String(fmt.Sprintf("%s/%s", repoOwner, repoName)),
+			Value: aws.String(manifest.Repository),
 		},
 		{
 			Key:   aws.String("Labels"),
@@ -342,6 +595,20 @@ func createEC2Instance(
 		},
 	}
 
+	// Record the runner release the user data installs (a baked AMI carries its
+	// own) and the hash of the user data, which drift compares
+	if !useBakedAMI {
+		manifest.RunnerVersion = runnerVersion
+		tags = append(tags, types.Tag{
+			Key:   aws.String("RunnerVersion"),
+			Value: aws.String(runnerVersion),
+		})
+	}
+	tags = append(tags, types.Tag{
+		Key:   aws.String("UserDataHash"),
+		Value: aws.String(manifest.UserDataHash),
+	})
+
 	// Add spot price tag if specified
 	if instanceMarketType == "spot" && spotMaxPrice != "" {
 		tags = append(tags, types.Tag{
@@ -349,12 +616,65 @@ func createEC2Instance(
 			Value: aws.String(spotMaxPrice),
 		})
 	}
+	// Terminate cancels persistent spot requests so they don't launch a replacement
+	if instanceMarketType == "spot" && spotRequestType == spotRequestPersistent {
+		tags = append(tags, types.Tag{
+			Key:   aws.String("SpotRequestType"),
+			Value: aws.String(spotRequestPersistent),
+		})
+	}
 
-	runInput.TagSpecifications = []types.TagSpecification{
-		{
-			ResourceType: types.ResourceTypeInstance,
+	// Add workflow run correlation tags so launches can be traced back to their run
+	runTags := workflowTags(correlationID)
+	runTagKeys := make([]string, 0, len(runTags))
+	for key := range runTags {
+		runTagKeys = append(runTagKeys, key)
+	}
+	sort.Strings(runTagKeys)
+	for _, key := range runTagKeys {
+		tags = append(tags, types.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(runTags[key]),
+		})
+	}
+
+	// Add the --tag tags, e.g. mandated cost allocation tags; they were validated with the other flags
+	extraTags, err := parseCustomTags()
+	if err != nil {
+		return "", err
+	}
+	tags = append(tags, extraTags...)
+
+	// Tag the volumes and network interfaces created with the instance too, so cost allocation covers them
+	for _, name := range splitList(tagResourceTypes) {
+		runInput.TagSpecifications = append(runInput.TagSpecifications, types.TagSpecification{
+			ResourceType: taggableResourceTypes[name],
 			Tags:         tags,
-		},
+		})
+	}
+
+	// The instance profile gives the runner's own scripts AWS access, e.g. for job tags and diagnostics
+	if strings.HasPrefix(instanceProfile, "arn:") {
+		runInput.IamInstanceProfile = &types.IamInstanceProfileSpecification{Arn: aws.String(instanceProfile)}
+	} else if instanceProfile != "" {
+		runInput.IamInstanceProfile = &types.IamInstanceProfileSpecification{Name: aws.String(instanceProfile)}
+	}
+
+	applyPublicIP(runInput)
+	applyProtection(runInput)
+	if err := applyCPUOptions(svc, runInput); err != nil {
+		return "", err
+	}
+	if err := applyEncryption(svc, runInput); err != nil {
+		return "", err
+	}
+	if err := checkInstanceStore(svc, runInput.InstanceType); err != nil {
+		return "", err
+	}
+
+	// A key pair allows SSH access for debugging failed bootstraps
+	if keyName != "" {
+		runInput.KeyName = aws.String(keyName)
 	}
 
 	// Add spot instance configuration if specified
@@ -362,94 +682,237 @@ func createEC2Instance(
 		runInput.InstanceMarketOptions = instanceMarketOptions
 	}
 
+	// Record the correlation ID before launching so an interrupted create can be resumed
+	manifest.Phase = phaseLaunching
+	if err := saveManifest(manifestPath, manifest); err != nil {
+		return "", err
+	}
+
 	if outputFormat != "github-actions" {
 		fmt.Printf("🚀 Launching EC2 instance...\n")
 	}
-	result, err := svc.RunInstances(context.TODO(), runInput)
+	emitEvent("instance.launching", map[string]any{
+		"instance_type":        instanceType,
+		"image_id":             imageID,
+		"subnet_id":            subnetID,
+		"instance_market_type": instanceMarketType,
+	})
+	var result *ec2.RunInstancesOutput
+	launch := func() error {
+		return runPhase("launching the instance", launchTimeout, func(ctx context.Context) error {
+			var err error
+			if launchMode == launchModeFleet {
+				result, err = createFleetInstance(ctx, svc, runInput, subnets)
+			} else {
+				result, err = runInstancesIdempotent(ctx, svc, runInput, correlationID)
+			}
+			return classifyAWSError(err)
+		})
+	}
+	launchTypes := withFallbackInstanceTypes(instanceType)
+	launchedSubnet, err := launchInstanceTypes(runInput, launchTypes, subnets, correlationID, launch)
+	if errors.Is(err, ErrDeadline) {
+		rollbackCreate(manifest)
+		return "", fmt.Errorf("failed to create EC2 instance: %w", err)
+	}
 	if err != nil {
 		// Check if this is a spot capacity issue and we were trying spot instances
-		if instanceMarketType == "spot" && strings.Contains(err.Error(), "InsufficientInstanceCapacity") {
+		if instanceMarketType == "spot" && fallbackToOnDemand && isSpotFallbackError(err) {
 			if outputFormat != "github-actions" {
 				fmt.Printf("⚠️  Spot capacity unavailable, falling back to on-demand instance...\n")
 			}
+			emitEvent("instance.spot_fallback", map[string]any{"reason": err.Error()})
 
-			// Remove spot instance configuration for fallback
+			// Remove spot instance configuration for fallback; the changed request needs its own client token
 			runInput.InstanceMarketOptions = nil
 
 			// Update instance market type variable
 			instanceMarketType = "on-demand"
 
-			// Update tags to reflect the fallback
-			for i, tag := range runInput.TagSpecifications[0].Tags {
+			// Update tags to reflect the fallback; all tag specifications share the same tags
+			tags = slices.DeleteFunc(tags, func(tag types.Tag) bool { return *tag.Key == "SpotRequestType" })
+			for i, tag := range tags {
 				if *tag.Key == "InstanceMarketType" {
-					runInput.TagSpecifications[0].Tags[i].Value = aws.String("on-demand")
+					tags[i].Value = aws.String("on-demand")
 					break
 				}
 			}
+			for i := range runInput.TagSpecifications {
+				runInput.TagSpecifications[i].Tags = tags
+			}
 
 			// Retry with on-demand configuration
-			result, err = svc.RunInstances(context.TODO(), runInput)
+			launchedSubnet, err = launchInstanceTypes(runInput, launchTypes, subnets, correlationID+"-od", launch)
 			if err != nil {
-				return fmt.Errorf("failed to create EC2 instance (tried spot and on-demand): %v", err)
+				if errors.Is(err, ErrDeadline) {
+					rollbackCreate(manifest)
+				}
+				return "", fmt.Errorf("failed to create EC2 instance (tried spot and on-demand): %w", err)
 			}
 
 			if outputFormat != "github-actions" {
 				fmt.Printf("✅ Successfully created on-demand instance as fallback!\n")
 			}
+		} else if instanceMarketType == "spot" && isSpotFallbackError(err) {
+			return "", fmt.Errorf("failed to create spot instance (on-demand fallback disabled): %w", err)
 		} else {
-			return fmt.Errorf("failed to create EC2 instance: %v", err)
+			return "", fmt.Errorf("failed to create EC2 instance: %w", err)
 		}
 	}
 
-	if len(result.Instances) > 0 {
-		instanceID := *result.Instances[0].InstanceId
+	if len(result.Instances) == 0 {
+		emitEvent("create.completed", nil)
+		return "", nil
+	}
 
-		if outputFormat == "github-actions" {
-			// GitHub Actions compatible output
-			fmt.Printf("Instance ID: %s\n", instanceID)
-			fmt.Printf("Runner Name: %s\n", runnerName)
-			fmt.Printf("Labels: %s\n", runnerLabels)
-			fmt.Printf("Instance Market Type: %s\n", instanceMarketType)
-			if instanceMarketType == "spot" && spotMaxPrice != "" {
-				fmt.Printf("Spot Max Price: %s\n", spotMaxPrice)
-			}
-		} else {
-			// Human-readable output
-			fmt.Printf("✅ EC2 instance created successfully!\n")
-			fmt.Printf("Instance ID: %s\n", instanceID)
-			fmt.Printf("Instance Type: %s\n", instanceType)
-			fmt.Printf("Instance Market Type: %s\n", instanceMarketType)
-			if instanceMarketType == "spot" && spotMaxPrice != "" {
-				fmt.Printf("Spot Max Price: $%s/hour\n", spotMaxPrice)
-			}
-			fmt.Printf("Image ID: %s\n", imageID)
-			fmt.Printf("Subnet ID: %s\n", subnetID)
-			fmt.Printf("Security Group ID: %s\n", securityGroupID)
-			fmt.Printf("Repository: %s/%s\n", repoOwner, repoName)
-			fmt.Printf("Runner Labels: %s\n", runnerLabels)
-			fmt.Printf("Runner Name: %s\n", runnerName)
+	instanceID := *result.Instances[0].InstanceId
+	emitEvent("instance.launched", map[string]any{
+		"instance_id":          instanceID,
+		"runner_name":          runnerName,
+		"labels":               runnerLabels,
+		"instance_market_type": instanceMarketType,
+	})
+
+	manifest.InstanceID = instanceID
+	manifest.InstanceMarketType = instanceMarketType
+	if launchedSubnet != "" {
+		manifest.SubnetID = launchedSubnet
+	}
+	// Create may have fallen back to another instance type; a fleet picks the
+	// instance type and subnet itself
+	manifest.InstanceType = string(runInput.InstanceType)
+	if runInput.InstanceMarketOptions != nil && spotRequestType == spotRequestPersistent {
+		manifest.SpotRequestID = aws.ToString(result.Instances[0].SpotInstanceRequestId)
+	}
+	if launchMode == launchModeFleet {
+		manifest.InstanceType = string(result.Instances[0].InstanceType)
+		manifest.SubnetID = aws.ToString(result.Instances[0].SubnetId)
+	}
+	manifest.Phase = phaseLaunched
+	if err := saveManifest(manifestPath, manifest); err != nil {
+		return instanceID, fmt.Errorf("instance %s was created but %w", instanceID, err)
+	}
+
+	if err := finishCreate(svc, &manifest, githubToken); err != nil {
+		// Out of time: don't leave a half-started runner behind
+		if errors.Is(err, ErrDeadline) {
+			rollbackCreate(manifest)
 		}
+		return instanceID, err
+	}
+	return instanceID, nil
+}
 
-		// Wait for instance to be running
-		if outputFormat != "github-actions" {
-			fmt.Printf("⏳ Waiting for instance to be running...\n")
+// finishCreate prints the launch output, waits for the instance to be running
+// (and its runner to register, if requested) and runs post-create hooks; it is
+// shared by create and resume so an interrupted create can be completed later
+func finishCreate(svc *ec2.Client, manifest *RunManifest, githubToken string) error {
+	instanceID := manifest.InstanceID
+
+	switch {
+	case outputFormat == "github-actions" && launchCount > 1:
+		// createManyEC2Instances prints the outputs of all --count runners together
+	case outputFormat == "github-actions":
+		// GitHub Actions compatible output
+		fmt.Printf("Instance ID: %s\n", instanceID)
+		fmt.Printf("Runner Name: %s\n", manifest.RunnerName)
+		fmt.Printf("Labels: %s\n", manifest.Labels)
+		fmt.Printf("Instance Market Type: %s\n", manifest.InstanceMarketType)
+		if manifest.InstanceMarketType == "spot" && manifest.SpotMaxPrice != "" {
+			fmt.Printf("Spot Max Price: %s\n", manifest.SpotMaxPrice)
+		}
+		fmt.Printf("Correlation ID: %s\n", manifest.CorrelationID)
+	default:
+		// Human-readable output
+		fmt.Printf("✅ EC2 instance created successfully!\n")
+		fmt.Printf("Instance ID: %s\n", instanceID)
+		fmt.Printf("Instance Type: %s\n", manifest.InstanceType)
+		fmt.Printf("Instance Market Type: %s\n", manifest.InstanceMarketType)
+		if manifest.InstanceMarketType == "spot" && manifest.SpotMaxPrice != "" {
+			fmt.Printf("Spot Max Price: $%s/hour\n", manifest.SpotMaxPrice)
 		}
-		waiter := ec2.NewInstanceRunningWaiter(svc)
-		err = waiter.Wait(context.TODO(), &ec2.DescribeInstancesInput{
+		fmt.Printf("Image ID: %s\n", manifest.ImageID)
+		fmt.Printf("Subnet ID: %s\n", manifest.SubnetID)
+		fmt.Printf("Security Group ID: %s\n", manifest.SecurityGroupID)
+		fmt.Printf("Repository: %s\n", manifest.Repository)
+		fmt.Printf("Runner Labels: %s\n", manifest.Labels)
+		fmt.Printf("Runner Name: %s\n", manifest.RunnerName)
+		fmt.Printf("Correlation ID: %s\n", manifest.CorrelationID)
+	}
+
+	// Wait for instance to be running
+	if outputFormat != "github-actions" {
+		fmt.Printf("⏳ Waiting for instance to be running...\n")
+	}
+	waiter := ec2.NewInstanceRunningWaiter(svc)
+	// The context enforces --running-timeout, so the waiter's own limit is then only a backstop
+	waitLimit := defaultRunningWait
+	if runningTimeout > 0 {
+		waitLimit = runningTimeout + time.Minute
+	}
+	err := runPhase("waiting for the instance to run", runningTimeout, func(ctx context.Context) error {
+		return waiter.Wait(ctx, &ec2.DescribeInstancesInput{
 			InstanceIds: []string{instanceID},
-		}, time.Minute*5)
-		if err != nil {
-			if outputFormat != "github-actions" {
-				fmt.Printf("⚠️  Instance created but failed to wait for running state: %v\n", err)
-			}
-		} else {
-			if outputFormat != "github-actions" {
-				fmt.Printf("🎉 Instance is now running!\n")
-				fmt.Printf("📋 Check the user data log: ssh into the instance and run 'sudo tail -f /var/log/user-data.log'\n")
+		}, waitLimit)
+	})
+	if errors.Is(err, ErrDeadline) {
+		emitEvent("instance.wait_failed", map[string]any{"instance_id": instanceID, "error": err.Error()})
+		return fmt.Errorf("instance %s was created but %w", instanceID, err)
+	}
+	if err != nil {
+		emitEvent("instance.wait_failed", map[string]any{"instance_id": instanceID, "error": err.Error()})
+		if outputFormat != "github-actions" {
+			fmt.Printf("⚠️  Instance created but failed to wait for running state: %v\n", err)
+		}
+	} else {
+		emitEvent("instance.running", map[string]any{"instance_id": instanceID})
+		if outputFormat != "github-actions" {
+			fmt.Printf("🎉 Instance is now running!\n")
+			fmt.Printf("📋 Check the user data log: ssh into the instance and run 'sudo tail -f /var/log/user-data.log'\n")
+			if keyName == "" && sshCAPublicKey == "" {
+				fmt.Printf("💡 Pass --key-name or --ssh-ca-public-key to be able to SSH into runner instances\n")
 			}
 		}
+		if err := associateElasticIP(svc, instanceID); err != nil {
+			return fmt.Errorf("instance %s is running but %w", instanceID, err)
+		}
+		if err := registerRunnerDNS(svc, manifest); err != nil {
+			return fmt.Errorf("instance %s is running but %w", instanceID, err)
+		}
+		if err := createStuckAlarm(svc, manifest); err != nil {
+			return fmt.Errorf("instance %s is running but %w", instanceID, err)
+		}
+		manifest.Phase = phaseRunning
+		if err := saveManifest(manifestPath, *manifest); err != nil {
+			return err
+		}
+	}
+
+	if registrationWait > 0 {
+		limit := phaseTimeout(registrationWait)
+		if limit < 0 {
+			return fmt.Errorf("instance %s is running but %w: deadline of %s exceeded before the runner "+
+				"registered", instanceID, ErrDeadline, createDeadline)
+		}
+		if err := waitForRunnerRegistration(svc, instanceID, githubToken, manifest.Repository,
+			manifest.RunnerName, limit); err != nil {
+			return fmt.Errorf("instance %s is running but %w", instanceID, err)
+		}
+		manifest.Phase = phaseRegistered
+		if err := saveManifest(manifestPath, *manifest); err != nil {
+			return err
+		}
 	}
 
+	if err := runHooks(hookPostCreate, *manifest); err != nil {
+		return fmt.Errorf("instance %s was created but %w", instanceID, err)
+	}
+
+	manifest.Phase = phaseCompleted
+	if err := saveManifest(manifestPath, *manifest); err != nil {
+		return err
+	}
+	emitEvent("create.completed", nil)
 	return nil
 }
 
@@ -467,15 +930,17 @@ func terminateEC2Instance(instanceID string, force bool, timeoutSeconds int) err
 
 	result, err := svc.DescribeInstances(context.TODO(), describeInput)
 	if err != nil {
-		return fmt.Errorf("failed to find instance %s: %v", instanceID, err)
+		return fmt.Errorf("failed to find instance %s: %w", instanceID, classifyAWSError(err))
 	}
 
 	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
-		return fmt.Errorf("instance %s not found", instanceID)
+		return fmt.Errorf("%w: instance %s", ErrNotFound, instanceID)
 	}
 
 	instance := result.Reservations[0].Instances[0]
 	currentState := string(instance.State.Name)
+	resources := auxResourcesFromInstance(instance)
+	emitEvent("instance.state", map[string]any{"instance_id": instanceID, "state": currentState})
 
 	if outputFormat != "github-actions" {
 		fmt.Printf("📊 Instance %s current state: %s\n", instanceID, currentState)
@@ -483,32 +948,61 @@ func terminateEC2Instance(instanceID string, force bool, timeoutSeconds int) err
 		// Check if it's a spot instance (Note: InstanceMarketOptions might not be available in all SDK versions)
 	}
 
+	// Remove the runner from GitHub once the instance is going away; a failure
+	// must not keep the instance running
+	runnerName, repository := instanceTag(instance, "RunnerName"), instanceTag(instance, "Repository")
+	deregister := func() {
+		if githubToken == "" || runnerName == "" || repository == "" {
+			return
+		}
+		if err := deregisterRunner(githubToken, repository, runnerName); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Failed to remove runner %s from %s: %v\n", runnerName, repository, err)
+		}
+	}
+
 	// Check if instance is already terminated
 	if currentState == "terminated" {
+		deregister()
 		if outputFormat == "github-actions" {
 			fmt.Printf("Termination Status: %s\n", currentState)
 		} else {
 			fmt.Printf("ℹ️  Instance %s is already terminated\n", instanceID)
 		}
-		return nil
+		return deleteAuxResources(resources, keepVolumes)
 	}
 
 	// Check if instance is in a terminable state
 	if currentState == "shutting-down" {
+		deregister()
 		if outputFormat == "github-actions" {
 			fmt.Printf("Termination Status: %s\n", currentState)
 		} else {
 			fmt.Printf("✅ Instance %s is shutting down - success\n", instanceID)
 		}
-		// Return success immediately for shutting-down state
+		// Return success immediately for shutting-down state, unless resources must be cleaned up afterwards
+		if len(resources) > 0 {
+			return finishTermination(svc, instanceID, timeoutSeconds, resources)
+		}
 		return nil
 	}
 
-	// Only attempt termination if instance is in running, stopping, or stopped state
-	if currentState != "running" && currentState != "stopping" && currentState != "stopped" {
+	// Only attempt termination if instance is in pending, running, stopping, or stopped state
+	if currentState != "pending" && currentState != "running" && currentState != "stopping" &&
+		currentState != "stopped" {
 		return fmt.Errorf("instance %s is in state '%s' and cannot be terminated", instanceID, currentState)
 	}
 
+	if err := runHooks(hookPreTerminate, manifestFromInstance(instance)); err != nil {
+		return err
+	}
+	if requestID := manifestFromInstance(instance).SpotRequestID; requestID != "" {
+		if err := cancelSpotRequests(svc, requestID); err != nil {
+			return err
+		}
+	}
+	// Only after the hooks and checks, which may keep the instance running
+	deregister()
+
 	// Attempt graceful termination first
 	if outputFormat != "github-actions" {
 		if force {
@@ -534,7 +1028,11 @@ func terminateEC2Instance(instanceID string, force bool, timeoutSeconds int) err
 			terminateResult, err := svc.TerminateInstances(context.TODO(), terminateInput)
 			if err != nil {
 				// Check for specific AWS errors
-				if strings.Contains(err.Error(), "IncorrectInstanceState") {
+				if isTerminationProtected(err) {
+					return fmt.Errorf("instance %s has termination protection (use --force to lift it): %w",
+						instanceID, classifyAWSError(err))
+				}
+				if awsErrorCode(err) == "IncorrectInstanceState" {
 					if outputFormat != "github-actions" {
 						fmt.Printf("⚠️  Instance is in a state that prevents termination: %s\n", currentState)
 						fmt.Printf("💡 Try using --force flag for force termination\n")
@@ -551,12 +1049,18 @@ func terminateEC2Instance(instanceID string, force bool, timeoutSeconds int) err
 					time.Sleep(time.Duration(attempt) * time.Second)
 					continue
 				}
-				return fmt.Errorf("failed to terminate instance %s after %d attempts: %v", instanceID, maxRetries, err)
+				return fmt.Errorf(
+					"failed to terminate instance %s after %d attempts: %w",
+					instanceID,
+					maxRetries,
+					classifyAWSError(err),
+				)
 			}
 
 			// Success - break out of retry loop
 			if len(terminateResult.TerminatingInstances) > 0 {
 				newState := string(terminateResult.TerminatingInstances[0].CurrentState.Name)
+				emitEvent("terminate.initiated", map[string]any{"instance_id": instanceID, "state": newState})
 
 				if outputFormat == "github-actions" {
 					fmt.Printf("Termination Status: %s\n", newState)
@@ -566,7 +1070,7 @@ func terminateEC2Instance(instanceID string, force bool, timeoutSeconds int) err
 				}
 
 				// Wait for termination to complete
-				return waitForInstanceTermination(svc, instanceID, timeoutSeconds)
+				return finishTermination(svc, instanceID, timeoutSeconds, resources)
 			}
 		}
 	} else {
@@ -581,8 +1085,15 @@ func terminateEC2Instance(instanceID string, force bool, timeoutSeconds int) err
 		}
 
 		terminateResult, err := svc.TerminateInstances(context.TODO(), terminateInput)
+		if isTerminationProtected(err) {
+			if err := liftTerminationProtection(svc, instanceID); err != nil {
+				return err
+			}
+			terminateResult, err = svc.TerminateInstances(context.TODO(), terminateInput)
+		}
 		if err == nil && len(terminateResult.TerminatingInstances) > 0 {
 			newState := string(terminateResult.TerminatingInstances[0].CurrentState.Name)
+			emitEvent("terminate.initiated", map[string]any{"instance_id": instanceID, "state": newState, "force": true})
 
 			if outputFormat == "github-actions" {
 				fmt.Printf("Termination Status: %s\n", newState)
@@ -596,7 +1107,7 @@ func terminateEC2Instance(instanceID string, force bool, timeoutSeconds int) err
 			if forceTimeout < 120 {
 				forceTimeout = 120 // Minimum 2 minutes for force termination
 			}
-			return waitForInstanceTermination(svc, instanceID, forceTimeout)
+			return finishTermination(svc, instanceID, forceTimeout, resources)
 		}
 
 		// Method 2: If standard termination fails, try stop + terminate for stubborn instances
@@ -624,11 +1135,12 @@ func terminateEC2Instance(instanceID string, force bool, timeoutSeconds int) err
 		// Try termination again after stop
 		terminateResult, err = svc.TerminateInstances(context.TODO(), terminateInput)
 		if err != nil {
-			return fmt.Errorf("force termination failed for instance %s: %v", instanceID, err)
+			return fmt.Errorf("force termination failed for instance %s: %w", instanceID, classifyAWSError(err))
 		}
 
 		if len(terminateResult.TerminatingInstances) > 0 {
 			newState := string(terminateResult.TerminatingInstances[0].CurrentState.Name)
+			emitEvent("terminate.initiated", map[string]any{"instance_id": instanceID, "state": newState, "force": true})
 
 			if outputFormat == "github-actions" {
 				fmt.Printf("Termination Status: %s\n", newState)
@@ -642,7 +1154,7 @@ func terminateEC2Instance(instanceID string, force bool, timeoutSeconds int) err
 			if forceTimeout < 120 {
 				forceTimeout = 120 // Minimum 2 minutes for force termination
 			}
-			return waitForInstanceTermination(svc, instanceID, forceTimeout)
+			return finishTermination(svc, instanceID, forceTimeout, resources)
 		}
 	}
 
@@ -657,6 +1169,7 @@ func waitForInstanceTermination(svc *ec2.Client, instanceID string, timeoutSecon
 	if outputFormat != "github-actions" {
 		fmt.Printf("⏳ Waiting for instance %s to terminate...\n", instanceID)
 	}
+	emitEvent("terminate.waiting", map[string]any{"instance_id": instanceID, "timeout_seconds": timeoutSeconds})
 
 	timeout := time.After(time.Duration(timeoutSeconds) * time.Second)
 	ticker := time.NewTicker(10 * time.Second) // Check every 10 seconds
@@ -678,24 +1191,29 @@ func waitForInstanceTermination(svc *ec2.Client, instanceID string, timeoutSecon
 
 			result, err := svc.DescribeInstances(context.TODO(), describeInput)
 			if err != nil {
+				err = classifyAWSError(err)
+
 				// If we can't describe the instance, it might be terminated
-				if strings.Contains(err.Error(), "InvalidInstanceId.NotFound") {
+				if errors.Is(err, ErrNotFound) {
+					emitEvent("instance.terminated", map[string]any{"instance_id": instanceID})
 					if outputFormat != "github-actions" {
 						fmt.Printf("🎉 Instance %s has been terminated!\n", instanceID)
 					}
 					return nil
 				}
-				return fmt.Errorf("error checking instance state: %v", err)
+				return fmt.Errorf("error checking instance state: %w", err)
 			}
 
 			if len(result.Reservations) > 0 && len(result.Reservations[0].Instances) > 0 {
 				state := string(result.Reservations[0].Instances[0].State.Name)
+				emitEvent("instance.state", map[string]any{"instance_id": instanceID, "state": state})
 
 				if outputFormat != "github-actions" {
 					fmt.Printf("📊 Instance state: %s\n", state)
 				}
 
 				if state == "terminated" {
+					emitEvent("instance.terminated", map[string]any{"instance_id": instanceID})
 					if outputFormat != "github-actions" {
 						fmt.Printf("🎉 Instance %s has been successfully terminated!\n", instanceID)
 					}
@@ -721,6 +1239,183 @@ var rootCmd = &cobra.Command{
 	Use:   "gh-workflow",
 	Short: "A CLI tool to manage GitHub Actions EC2 runners",
 	Long:  "A command-line tool to create and terminate EC2 instances for GitHub Actions runners",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateEventsFormat(); err != nil {
+			return err
+		}
+		if err := applyConfig(cmd); err != nil {
+			return err
+		}
+		if err := validateDisplayTZ(); err != nil {
+			return err
+		}
+		if err := validateGitHubURLs(); err != nil {
+			return err
+		}
+		if err := validateAssumeRoleFlags(); err != nil {
+			return err
+		}
+		if err := validateAWSEndpointURL(); err != nil {
+			return err
+		}
+		if err := validatePartitionFlags(); err != nil {
+			return err
+		}
+		if err := validateAWSRetryFlags(); err != nil {
+			return err
+		}
+		if err := setupCassette(); err != nil {
+			return err
+		}
+		if err := readGitHubTokenInput(cmd); err != nil {
+			return err
+		}
+		if err := resolveSecrets(cmd); err != nil {
+			return err
+		}
+		if err := resolveGitHubToken(cmd); err != nil {
+			return err
+		}
+		historyStarted = true
+		return nil
+	},
+}
+
+// requireGitHubToken checks that a GitHub token was given or found
+func requireGitHubToken() error {
+	if githubToken == "" {
+		return fmt.Errorf("github-token is required (GitHub personal access token or `gh-workflow auth login`)")
+	}
+	return nil
+}
+
+// validateCreateFlags checks the flags shared by create, run and validate; the
+// GitHub token is checked separately, as validate runs without one
+func validateCreateFlags() error {
+	// Validate required flags
+	if imageID == "" {
+		return fmt.Errorf("image-id is required")
+	}
+	splitInstanceTypes()
+	if err := applyPreset(); err != nil {
+		return err
+	}
+	if err := validateNestedVirt(); err != nil {
+		return err
+	}
+	if err := validateFallbackInstanceTypes(); err != nil {
+		return err
+	}
+	if instanceType == "" {
+		return fmt.Errorf("instance-type is required")
+	}
+	if subnetID == "" {
+		return fmt.Errorf("subnet-id is required")
+	}
+	if securityGroupID == "" {
+		return fmt.Errorf("security-group is required")
+	}
+	if runnerOrg == "" && repoOwner == "" {
+		return fmt.Errorf("repo-owner is required (or --org for an organization runner)")
+	}
+	if runnerOrg == "" && repoName == "" {
+		return fmt.Errorf("repo-name is required (or --org for an organization runner)")
+	}
+
+	// Validate instance market type
+	if instanceMarketType != "on-demand" && instanceMarketType != "spot" {
+		return fmt.Errorf("instance-market-type must be 'on-demand' or 'spot'")
+	}
+	resourceTypes := splitList(tagResourceTypes)
+	if !slices.Contains(resourceTypes, "instance") {
+		return fmt.Errorf("tag-resource-types must include 'instance'")
+	}
+	for _, name := range resourceTypes {
+		if _, ok := taggableResourceTypes[name]; !ok {
+			return fmt.Errorf("tag-resource-types must be a comma-separated list of instance, volume and network-interface")
+		}
+	}
+	if amiCheck != "warn" && amiCheck != "fail" && amiCheck != "off" {
+		return fmt.Errorf("ami-check must be 'warn', 'fail' or 'off'")
+	}
+	if err := validateWindowsFlags(); err != nil {
+		return err
+	}
+	if probe && runnerOS != "linux" {
+		return fmt.Errorf("probe is only supported with --os linux")
+	}
+	if err := validateRunnerVersion(); err != nil {
+		return err
+	}
+	if err := validateLocaleFlags(); err != nil {
+		return err
+	}
+	if err := validateSysctlProfiles(); err != nil {
+		return err
+	}
+	if err := validateVolumes(); err != nil {
+		return err
+	}
+	if err := validateSwapSize(); err != nil {
+		return err
+	}
+	if err := validatePublicIPFlags(); err != nil {
+		return err
+	}
+	if err := validateCPUFlags(); err != nil {
+		return err
+	}
+	if err := validateProtectionFlags(); err != nil {
+		return err
+	}
+	if err := validateEncryptionFlags(); err != nil {
+		return err
+	}
+	if err := validateAlarmFlags(); err != nil {
+		return err
+	}
+	if err := validateInstanceStoreFlags(); err != nil {
+		return err
+	}
+	if err := validateCountFlags(); err != nil {
+		return err
+	}
+	if err := validateFleetFlags(); err != nil {
+		return err
+	}
+	if err := validateSpotRequestFlags(); err != nil {
+		return err
+	}
+	if err := validateSpotInterruptionFlags(); err != nil {
+		return err
+	}
+	if err := validateSpotPlacementFlags(); err != nil {
+		return err
+	}
+	if err := validateCustomTags(); err != nil {
+		return err
+	}
+	if err := validateDiagFlags(); err != nil {
+		return err
+	}
+	if err := validateDeadlineFlags(); err != nil {
+		return err
+	}
+	if err := validateRegistrationWaitFlags(); err != nil {
+		return err
+	}
+	if err := validateProviderFlags(); err != nil {
+		return err
+	}
+
+	if sshCAPublicKey != "" {
+		key, err := readSSHCAPublicKey(sshCAPublicKey)
+		if err != nil {
+			return err
+		}
+		sshCAPublicKey = key
+	}
+	return nil
 }
 
 var createCmd = &cobra.Command{
@@ -728,51 +1423,45 @@ var createCmd = &cobra.Command{
 	Short: "Create a new EC2 instance for GitHub Actions runner",
 	Long:  "Create a new EC2 instance configured as a GitHub Actions runner",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Validate required flags
-		if githubToken == "" {
-			return fmt.Errorf("github-token is required (GitHub personal access token)")
+		if providerName != defaultProvider {
+			return createWithProvider(cmd)
 		}
-		if imageID == "" {
-			return fmt.Errorf("image-id is required")
+		if err := requireGitHubToken(); err != nil {
+			return err
 		}
-		if instanceType == "" {
-			return fmt.Errorf("instance-type is required")
+		if err := validateCreateFlags(); err != nil {
+			return err
 		}
-		if subnetID == "" {
-			return fmt.Errorf("subnet-id is required")
-		}
-		if securityGroupID == "" {
-			return fmt.Errorf("security-group is required")
-		}
-		if repoOwner == "" {
-			return fmt.Errorf("repo-owner is required")
-		}
-		if repoName == "" {
-			return fmt.Errorf("repo-name is required")
-		}
-
-		// Validate instance market type
-		if instanceMarketType != "on-demand" && instanceMarketType != "spot" {
-			return fmt.Errorf("instance-market-type must be 'on-demand' or 'spot'")
+		if err := checkUnfinishedManifest(manifestPath); err != nil {
+			return err
 		}
 
 		if outputFormat != "github-actions" {
 			fmt.Printf("🚀 Creating EC2 instance for GitHub Actions runner...\n")
 		}
-		return createEC2Instance(
-			githubToken,
-			imageID,
-			instanceType,
-			subnetID,
-			securityGroupID,
-			repoOwner,
-			repoName,
-			runnerLabels,
-			preRunnerScript,
-			runnerName,
-			instanceMarketType,
-			spotMaxPrice,
-		)
+		emitEvent("create.started", nil)
+		startCreateDeadline()
+		create := func(runnerName string) (string, error) {
+			return createEC2Instance(
+				githubToken,
+				imageID,
+				instanceType,
+				subnetID,
+				securityGroupID,
+				repoOwner,
+				repoName,
+				runnerLabels,
+				preRunnerScript,
+				runnerName,
+				instanceMarketType,
+				spotMaxPrice,
+			)
+		}
+		if launchCount > 1 {
+			return createManyEC2Instances(githubToken, runnerName, create)
+		}
+		_, err := create(runnerName)
+		return err
 	},
 }
 
@@ -781,8 +1470,11 @@ var terminateCmd = &cobra.Command{
 	Short: "Terminate an existing EC2 instance",
 	Long:  "Terminate an existing EC2 instance by its instance ID",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if instanceID == "" {
-			return fmt.Errorf("instance-id is required")
+		if providerName != defaultProvider {
+			return terminateWithProvider(instanceID)
+		}
+		if (instanceID == "") == (terminateRunID == "") {
+			return fmt.Errorf("exactly one of instance-id or by-run-id is required")
 		}
 
 		// Validate timeout range
@@ -793,6 +1485,10 @@ var terminateCmd = &cobra.Command{
 			return fmt.Errorf("timeout cannot exceed 3600 seconds (1 hour)")
 		}
 
+		if terminateRunID != "" {
+			return terminateByRunID(terminateRunID, forceTerminate, terminationTimeout)
+		}
+
 		if outputFormat != "github-actions" {
 			if forceTerminate {
 				fmt.Printf("🛑 Force terminating EC2 instance %s (timeout: %ds)...\n", instanceID, terminationTimeout)
@@ -800,7 +1496,8 @@ var terminateCmd = &cobra.Command{
 				fmt.Printf("🛑 Terminating EC2 instance %s (timeout: %ds)...\n", instanceID, terminationTimeout)
 			}
 		}
-		return terminateEC2Instance(instanceID, forceTerminate, terminationTimeout)
+		emitEvent("terminate.started", map[string]any{"instance_id": instanceID, "force": forceTerminate})
+		return terminateAndVerify(instanceID, forceTerminate, terminationTimeout)
 	},
 }
 
@@ -810,7 +1507,8 @@ func init() {
 		StringVar(&githubToken, "github-token", "", "GitHub personal access token (not registration token)")
 	createCmd.Flags().StringVar(&imageID, "image-id", "", "EC2 AMI image ID")
 	createCmd.Flags().StringVar(&instanceType, "instance-type", "", "EC2 instance type")
-	createCmd.Flags().StringVar(&subnetID, "subnet-id", "", "VPC subnet ID")
+	createCmd.Flags().StringVar(&subnetID, "subnet-id", "",
+		"VPC subnet ID, or comma-separated subnets in several AZs tried in order when one is out of capacity")
 	createCmd.Flags().StringVar(&securityGroupID, "security-group", "", "Security group ID")
 	createCmd.Flags().StringVar(&repoOwner, "repo-owner", "", "GitHub repository owner")
 	createCmd.Flags().StringVar(&repoName, "repo-name", "", "GitHub repository name")
@@ -824,23 +1522,69 @@ func init() {
 		StringVar(&instanceMarketType, "instance-market-type", "on-demand", "Instance market type (on-demand or spot)")
 	createCmd.Flags().
 		StringVar(&spotMaxPrice, "spot-max-price", "", "Maximum price for spot instances (per hour in USD, optional)")
+	createCmd.Flags().BoolVar(&fallbackToOnDemand, "fallback-to-on-demand", true,
+		"Launch on-demand if there is no spot capacity or the spot price is above --spot-max-price")
+	createCmd.Flags().
+		StringVar(&placementScript, "placement-script", "", "Starlark script that chooses instance type, subnet and price")
+	createCmd.Flags().
+		StringVar(&runID, "run-id", os.Getenv("GITHUB_RUN_ID"), "Workflow run ID recorded in tags and the client token")
+	createCmd.Flags().
+		BoolVar(&useBakedAMI, "use-baked-ami", false, "The AMI already has the runner installed (see ami build)")
+	createCmd.Flags().
+		StringVar(&sshCAPublicKey, "ssh-ca-public-key", "", "SSH CA public key (or path) trusted for debug certificates")
+	createCmd.Flags().StringVar(&awsRegion, "region", "", "AWS region (overrides AWS_REGION and AWS_DEFAULT_REGION)")
+	createCmd.Flags().StringVar(&instanceProfile, "iam-instance-profile", "",
+		"Instance profile (name or ARN) giving the runner instance AWS permissions")
+	createCmd.Flags().StringVar(&keyName, "key-name", "",
+		"EC2 key pair to allow SSH access to the instance, e.g. to debug bootstraps")
+	createCmd.Flags().StringVar(&tagResourceTypes, "tag-resource-types", "instance,volume,network-interface",
+		"Resources created with the instance to tag (instance, volume, network-interface)")
 
 	// Terminate command flags
 	terminateCmd.Flags().StringVar(&instanceID, "instance-id", "", "EC2 instance ID to terminate")
+	terminateCmd.Flags().StringVar(&awsRegion, "region", "", "AWS region (overrides AWS_REGION and AWS_DEFAULT_REGION)")
+	terminateCmd.Flags().StringVar(&githubToken, "github-token", "",
+		"GitHub token used to remove the instance's runner from GitHub before terminating")
+	terminateCmd.Flags().
+		BoolVar(&keepVolumes, "keep-volumes", false, "Keep extra EBS volumes instead of deleting them")
+	terminateCmd.Flags().StringVar(&terminateRunID, "by-run-id", "",
+		"Terminate every runner instance tagged with this workflow run ID")
 	terminateCmd.Flags().
 		StringVar(&outputFormat, "output-format", "", "Output format (github-actions for GitHub Actions compatibility)")
 	terminateCmd.Flags().BoolVar(&forceTerminate, "force", false, "Force termination even if graceful shutdown fails")
 	terminateCmd.Flags().
 		IntVar(&terminationTimeout, "timeout", 300, "Maximum time in seconds to wait for termination (60-3600, default: 300)")
 
+	// Global flags
+	rootCmd.PersistentFlags().
+		StringVar(&eventsFormat, "events-format", "", "Emit lifecycle events as JSON lines on stderr (ndjson)")
+
 	// Add commands to root
 	rootCmd.AddCommand(createCmd)
 	rootCmd.AddCommand(terminateCmd)
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	// run and validate accept every create flag; added here so flags registered by any file's init are included
+	runCmd.Flags().AddFlagSet(createCmd.Flags())
+	replaceCmd.Flags().AddFlagSet(createCmd.Flags())
+	daemonCmd.Flags().AddFlagSet(createCmd.Flags())
+	validateCmd.Flags().AddFlagSet(createCmd.Flags())
+	driftCmd.Flags().AddFlagSet(createCmd.Flags())
+	for _, cmd := range userDataCmd.Commands() {
+		cmd.Flags().AddFlagSet(createCmd.Flags())
+	}
+
+	started := time.Now()
+	cmd, err := rootCmd.ExecuteC()
+	sendUsageMetric(cmd, started, err)
+	recordHistory(cmd, started, err)
+	if err != nil {
+		emitEvent("error", map[string]any{"message": err.Error(), "exit_code": exitCode(err)})
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		if hint := remediationHint(err); hint != "" {
+			fmt.Fprintf(os.Stderr, "💡 %s\n", hint)
+		}
+		os.Exit(exitCode(err))
 	}
 }
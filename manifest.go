@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// RunManifest describes a runner instance and the parameters it was launched with
+type RunManifest struct {
+	Event              string        `json:"event,omitempty"`
+	Phase              string        `json:"phase,omitempty"`
+	InstanceID         string        `json:"instance_id,omitempty"`
+	CorrelationID      string        `json:"correlation_id,omitempty"`
+	RunID              string        `json:"run_id,omitempty"`
+	RunnerName         string        `json:"runner_name,omitempty"`
+	Labels             string        `json:"labels,omitempty"`
+	Repository         string        `json:"repository,omitempty"`
+	InstanceType       string        `json:"instance_type,omitempty"`
+	InstanceMarketType string        `json:"instance_market_type,omitempty"`
+	SpotMaxPrice       string        `json:"spot_max_price,omitempty"`
+	SpotRequestID      string        `json:"spot_request_id,omitempty"`
+	RebalancedAt       string        `json:"rebalance_recommended_at,omitempty"`
+	ReplacedBy         string        `json:"replaced_by,omitempty"`
+	ImageID            string        `json:"image_id,omitempty"`
+	RunnerVersion      string        `json:"runner_version,omitempty"`
+	UserDataHash       string        `json:"user_data_hash,omitempty"`
+	SubnetID           string        `json:"subnet_id,omitempty"`
+	AvailabilityZone   string        `json:"availability_zone,omitempty"`
+	SecurityGroupID    string        `json:"security_group_id,omitempty"`
+	Region             string        `json:"region,omitempty"`
+	State              string        `json:"state,omitempty"`
+	LaunchTime         *time.Time    `json:"launch_time,omitempty"`
+	Resources          []AuxResource `json:"resources,omitempty"`
+	Jobs               []RunnerJob   `json:"jobs,omitempty"`
+}
+
+// Create phases recorded in the manifest file, in order
+const (
+	phaseLaunching  = "launching"
+	phaseLaunched   = "launched"
+	phaseRunning    = "running"
+	phaseRegistered = "registered"
+	phaseCompleted  = "completed"
+)
+
+// saveManifest writes the manifest as JSON to path; an empty path is a no-op
+func saveManifest(path string, manifest RunManifest) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %v", path, err)
+	}
+	return nil
+}
+
+// loadManifest reads a manifest written by saveManifest
+func loadManifest(path string) (RunManifest, error) {
+	var manifest RunManifest
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, fmt.Errorf("%w: manifest %s", ErrNotFound, path)
+	}
+	if err != nil {
+		return manifest, fmt.Errorf("failed to read manifest %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("failed to parse manifest %s: %v", path, err)
+	}
+	return manifest, nil
+}
+
+// instanceTag returns the value of the named tag on an instance, or ""
+func instanceTag(instance types.Instance, key string) string {
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
+
+// manifestFromInstance builds a manifest from a described instance and its tags
+func manifestFromInstance(instance types.Instance) RunManifest {
+	manifest := RunManifest{
+		InstanceID:         aws.ToString(instance.InstanceId),
+		CorrelationID:      instanceTag(instance, "CorrelationId"),
+		RunID:              instanceTag(instance, "RunId"),
+		RunnerName:         instanceTag(instance, "RunnerName"),
+		Labels:             instanceTag(instance, "Labels"),
+		Repository:         instanceTag(instance, "Repository"),
+		InstanceType:       string(instance.InstanceType),
+		InstanceMarketType: instanceTag(instance, "InstanceMarketType"),
+		SpotMaxPrice:       instanceTag(instance, "SpotMaxPrice"),
+		RebalancedAt:       instanceTag(instance, rebalanceTag),
+		ReplacedBy:         instanceTag(instance, rebalanceReplacedTag),
+		ImageID:            aws.ToString(instance.ImageId),
+		RunnerVersion:      instanceTag(instance, "RunnerVersion"),
+		UserDataHash:       instanceTag(instance, "UserDataHash"),
+		SubnetID:           aws.ToString(instance.SubnetId),
+		Region:             resolveRegion(),
+		LaunchTime:         instance.LaunchTime,
+		Resources:          auxResourcesFromInstance(instance),
+		Jobs:               jobsFromInstance(instance),
+	}
+	if instanceTag(instance, "SpotRequestType") == spotRequestPersistent {
+		manifest.SpotRequestID = aws.ToString(instance.SpotInstanceRequestId)
+	}
+	if instance.State != nil {
+		manifest.State = string(instance.State.Name)
+	}
+	if instance.Placement != nil {
+		manifest.AvailabilityZone = aws.ToString(instance.Placement.AvailabilityZone)
+	}
+	if len(instance.SecurityGroups) > 0 {
+		manifest.SecurityGroupID = aws.ToString(instance.SecurityGroups[0].GroupId)
+	}
+	return manifest
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/core"
+	"github.com/oracle/oci-go-sdk/v65/identity"
+	"github.com/spf13/cobra"
+)
+
+// ociProvider launches runner instances on Oracle Cloud compute; credentials
+// come from the OCI CLI config file (~/.oci/config), and the tags are stored as
+// freeform tags
+type ociProvider struct {
+	options     map[string]string
+	config      common.ConfigurationProvider
+	compute     core.ComputeClient
+	compartment string
+}
+
+// Defaults for flexible shapes, within the Always Free allowance of
+// VM.Standard.A1.Flex (4 OCPUs and 24 GB in total)
+const (
+	ociDefaultOcpus    = 1
+	ociDefaultMemoryGB = 6
+)
+
+func newOCIProvider(options map[string]string) (Provider, error) {
+	config := common.DefaultConfigProvider()
+	path, profile := options["config-file"], providerOption(options, "profile", "OCI_CLI_PROFILE")
+	if path != "" || profile != "" {
+		if path == "" {
+			home, _ := os.UserHomeDir()
+			path = filepath.Join(home, ".oci", "config")
+		}
+		if profile == "" {
+			profile = "DEFAULT"
+		}
+		config = common.CustomProfileConfigProvider(path, profile)
+	}
+
+	compute, err := core.NewComputeClientWithConfigurationProvider(config)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to load the OCI configuration: %v", ErrAuth, err)
+	}
+	if region := options["region"]; region != "" {
+		compute.SetRegion(region)
+	}
+
+	// The tenancy is the root compartment
+	compartment := options["compartment"]
+	if compartment == "" {
+		if compartment, err = config.TenancyOCID(); err != nil {
+			return nil, fmt.Errorf("%w: failed to read the tenancy from the OCI configuration: %v", ErrAuth, err)
+		}
+	}
+	return &ociProvider{options: options, config: config, compute: compute, compartment: compartment}, nil
+}
+
+// availabilityDomain returns the availability domain to launch in, defaulting to the first one
+func (p *ociProvider) availabilityDomain() (string, error) {
+	if ad := p.options["availability-domain"]; ad != "" {
+		return ad, nil
+	}
+	client, err := identity.NewIdentityClientWithConfigurationProvider(p.config)
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCI identity client: %v", err)
+	}
+	if region := p.options["region"]; region != "" {
+		client.SetRegion(region)
+	}
+	resp, err := client.ListAvailabilityDomains(context.TODO(), identity.ListAvailabilityDomainsRequest{
+		CompartmentId: common.String(p.compartment),
+	})
+	if err != nil {
+		return "", classifyOCIError(err)
+	}
+	if len(resp.Items) == 0 {
+		return "", fmt.Errorf("%w: no availability domains in %s", ErrNotFound, p.compartment)
+	}
+	return *resp.Items[0].Name, nil
+}
+
+func (p *ociProvider) Create(spec ProviderSpec) (ProviderInstance, error) {
+	shape := spec.InstanceType
+	if shape == "" {
+		shape = p.options["shape"]
+	}
+	image := spec.Image
+	if image == "" {
+		image = p.options["image"]
+	}
+	subnet := p.options["subnet"]
+	if shape == "" || image == "" || subnet == "" {
+		return ProviderInstance{}, fmt.Errorf("oci provider needs a shape (--instance-type or --oci-shape), " +
+			"an image OCID (--image-id or --oci-image) and --oci-subnet")
+	}
+	// Ampere shapes only run arm64 images and runners
+	if strings.Contains(shape, ".A1.") && spec.Arch != "arm64" {
+		return ProviderInstance{}, fmt.Errorf("shape %s is arm64: add the arm64 label (e.g. --labels "+
+			"self-hosted,linux,arm64)", shape)
+	}
+
+	ad, err := p.availabilityDomain()
+	if err != nil {
+		return ProviderInstance{}, err
+	}
+
+	metadata := map[string]string{"user_data": base64.StdEncoding.EncodeToString([]byte(spec.UserData))}
+	if key := p.options["ssh-authorized-keys"]; key != "" {
+		metadata["ssh_authorized_keys"] = key
+	}
+	assignPublicIP := true
+	if value := p.options["assign-public-ip"]; value != "" {
+		if assignPublicIP, err = strconv.ParseBool(value); err != nil {
+			return ProviderInstance{}, fmt.Errorf("invalid assign-public-ip %q", value)
+		}
+	}
+	details := core.LaunchInstanceDetails{
+		AvailabilityDomain: common.String(ad),
+		CompartmentId:      common.String(p.compartment),
+		DisplayName:        common.String(spec.Name),
+		Shape:              common.String(shape),
+		SourceDetails:      core.InstanceSourceViaImageDetails{ImageId: common.String(image)},
+		CreateVnicDetails: &core.CreateVnicDetails{
+			SubnetId:       common.String(subnet),
+			AssignPublicIp: common.Bool(assignPublicIP),
+		},
+		Metadata:     metadata,
+		FreeformTags: spec.Tags,
+	}
+	if strings.HasSuffix(shape, ".Flex") {
+		ocpus, memory := float32(ociDefaultOcpus), float32(ociDefaultMemoryGB)
+		if value, err := strconv.ParseFloat(p.options["ocpus"], 32); err == nil {
+			ocpus = float32(value)
+		}
+		if value, err := strconv.ParseFloat(p.options["memory-gb"], 32); err == nil {
+			memory = float32(value)
+		}
+		details.ShapeConfig = &core.LaunchInstanceShapeConfigDetails{
+			Ocpus:       common.Float32(ocpus),
+			MemoryInGBs: common.Float32(memory),
+		}
+	}
+
+	resp, err := p.compute.LaunchInstance(context.TODO(), core.LaunchInstanceRequest{LaunchInstanceDetails: details})
+	if err != nil {
+		return ProviderInstance{}, classifyOCIError(err)
+	}
+	return ProviderInstance{
+		ID:    *resp.Id,
+		Name:  spec.Name,
+		State: string(resp.LifecycleState),
+		Tags:  spec.Tags,
+	}, nil
+}
+
+func (p *ociProvider) Terminate(id string) error {
+	_, err := p.compute.TerminateInstance(context.TODO(), core.TerminateInstanceRequest{InstanceId: common.String(id)})
+	if err != nil {
+		return classifyOCIError(err)
+	}
+	return nil
+}
+
+func (p *ociProvider) List() ([]ProviderInstance, error) {
+	instances := []ProviderInstance{}
+	request := core.ListInstancesRequest{CompartmentId: common.String(p.compartment)}
+	for {
+		resp, err := p.compute.ListInstances(context.TODO(), request)
+		if err != nil {
+			return nil, classifyOCIError(err)
+		}
+		for _, instance := range resp.Items {
+			if instance.FreeformTags["Purpose"] != "GitHub Actions" ||
+				instance.LifecycleState == core.InstanceLifecycleStateTerminated {
+				continue
+			}
+			instances = append(instances, ProviderInstance{
+				ID:    *instance.Id,
+				Name:  *instance.DisplayName,
+				State: string(instance.LifecycleState),
+				Tags:  instance.FreeformTags,
+			})
+		}
+		if resp.OpcNextPage == nil {
+			return instances, nil
+		}
+		request.Page = resp.OpcNextPage
+	}
+}
+
+// classifyOCIError maps OCI service errors to the error taxonomy
+func classifyOCIError(err error) error {
+	serviceErr, ok := common.IsServiceError(err)
+	if !ok {
+		return err
+	}
+	switch {
+	case serviceErr.GetHTTPStatusCode() == http.StatusUnauthorized:
+		return fmt.Errorf("%w: %v", ErrAuth, err)
+	case serviceErr.GetHTTPStatusCode() == http.StatusNotFound:
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case serviceErr.GetHTTPStatusCode() == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %v", ErrThrottle, err)
+	case serviceErr.GetCode() == "LimitExceeded" || serviceErr.GetCode() == "QuotaExceeded":
+		return fmt.Errorf("%w: %v", ErrQuota, err)
+	// Always Free Ampere capacity is often exhausted
+	case strings.Contains(serviceErr.GetMessage(), "Out of host capacity"):
+		return fmt.Errorf("%w: %v", ErrCapacity, err)
+	}
+	return err
+}
+
+// checkOCID returns a check that a value is the OCID of one of the given resource types
+func checkOCID(resourceTypes ...string) func(value string) error {
+	return func(value string) error {
+		for _, resourceType := range resourceTypes {
+			if strings.HasPrefix(value, "ocid1."+resourceType+".") {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a %s OCID (ocid1.%s...)", value, resourceTypes[0], resourceTypes[0])
+	}
+}
+
+func init() {
+	providerFactories["oci"] = newOCIProvider
+
+	addProviderFlag([]*cobra.Command{createCmd, terminateCmd, listCmd}, "oci", "compartment",
+		"OCI compartment OCID of the runners (default: the tenancy)", checkOCID("compartment", "tenancy"))
+	create := []*cobra.Command{createCmd}
+	addProviderFlag(create, "oci", "shape", "OCI shape, if --instance-type isn't given, e.g. VM.Standard.A1.Flex", nil)
+	addProviderFlag(create, "oci", "image", "OCI image OCID, if --image-id isn't given", checkOCID("image"))
+	addProviderFlag(create, "oci", "subnet", "OCI subnet OCID to launch in (required with --provider oci)",
+		checkOCID("subnet"))
+}
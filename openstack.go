@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/gophercloud/gophercloud/v2/openstack"
+	"github.com/gophercloud/gophercloud/v2/openstack/compute/v2/flavors"
+	"github.com/gophercloud/gophercloud/v2/openstack/compute/v2/keypairs"
+	"github.com/gophercloud/gophercloud/v2/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/v2/openstack/image/v2/images"
+	"github.com/spf13/cobra"
+)
+
+// openstackUUIDRegex matches the IDs of OpenStack resources, which are used as is
+var openstackUUIDRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// openstackProvider launches runner servers with Nova; the credentials come
+// from the usual OS_* environment variables (e.g. a sourced openrc file), and
+// the tags are stored as server metadata
+type openstackProvider struct {
+	options map[string]string
+	compute *gophercloud.ServiceClient
+	image   *gophercloud.ServiceClient
+}
+
+func newOpenStackProvider(options map[string]string) (Provider, error) {
+	authOptions, err := openstack.AuthOptionsFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("%w: openstack provider needs OS_AUTH_URL and credentials in the environment: %v",
+			ErrAuth, err)
+	}
+	authOptions.AllowReauth = true
+
+	client, err := openstack.AuthenticatedClient(context.TODO(), authOptions)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to authenticate with OpenStack: %v", ErrAuth, err)
+	}
+	endpoint := gophercloud.EndpointOpts{Region: providerOption(options, "region", "OS_REGION_NAME")}
+	compute, err := openstack.NewComputeV2(client, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the OpenStack compute service: %v", err)
+	}
+	image, err := openstack.NewImageV2(client, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the OpenStack image service: %v", err)
+	}
+	return &openstackProvider{options: options, compute: compute, image: image}, nil
+}
+
+// flavorID returns the ID of a flavor given by ID or name
+func (p *openstackProvider) flavorID(flavor string) (string, error) {
+	pager := flavors.ListDetail(p.compute, flavors.ListOpts{AccessType: flavors.AllAccess})
+	pages, err := pager.AllPages(context.TODO())
+	if err != nil {
+		return "", fmt.Errorf("failed to list flavors: %v", err)
+	}
+	list, err := flavors.ExtractFlavors(pages)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse flavors: %v", err)
+	}
+	for _, f := range list {
+		if f.ID == flavor || f.Name == flavor {
+			return f.ID, nil
+		}
+	}
+	return "", fmt.Errorf("%w: flavor %s", ErrNotFound, flavor)
+}
+
+// imageID returns the ID of an image given by ID or name
+func (p *openstackProvider) imageID(image string) (string, error) {
+	if openstackUUIDRegex.MatchString(image) {
+		return image, nil
+	}
+	pages, err := images.List(p.image, images.ListOpts{Name: image}).AllPages(context.TODO())
+	if err != nil {
+		return "", fmt.Errorf("failed to list images: %v", err)
+	}
+	list, err := images.ExtractImages(pages)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse images: %v", err)
+	}
+	switch len(list) {
+	case 0:
+		return "", fmt.Errorf("%w: image %s", ErrNotFound, image)
+	case 1:
+		return list[0].ID, nil
+	}
+	return "", fmt.Errorf("%d images are named %s, pass the image ID instead", len(list), image)
+}
+
+func (p *openstackProvider) Create(spec ProviderSpec) (ProviderInstance, error) {
+	flavor := spec.InstanceType
+	if flavor == "" {
+		flavor = p.options["flavor"]
+	}
+	image := spec.Image
+	if image == "" {
+		image = p.options["image"]
+	}
+	if flavor == "" || image == "" {
+		return ProviderInstance{}, fmt.Errorf("openstack provider needs a flavor (--instance-type or " +
+			"--openstack-flavor) and an image (--image-id or --openstack-image)")
+	}
+
+	flavorRef, err := p.flavorID(flavor)
+	if err != nil {
+		return ProviderInstance{}, err
+	}
+	imageRef, err := p.imageID(image)
+	if err != nil {
+		return ProviderInstance{}, err
+	}
+
+	opts := servers.CreateOpts{
+		Name:             spec.Name,
+		FlavorRef:        flavorRef,
+		ImageRef:         imageRef,
+		UserData:         []byte(spec.UserData),
+		Metadata:         spec.Tags,
+		SecurityGroups:   splitList(p.options["security-groups"]),
+		AvailabilityZone: p.options["availability-zone"],
+	}
+	if network := p.options["network"]; network != "" {
+		opts.Networks = []servers.Network{{UUID: network}}
+	}
+	var builder servers.CreateOptsBuilder = opts
+	if keypair := p.options["keypair"]; keypair != "" {
+		builder = keypairs.CreateOptsExt{CreateOptsBuilder: opts, KeyName: keypair}
+	}
+
+	server, err := servers.Create(context.TODO(), p.compute, builder, nil).Extract()
+	if err != nil {
+		return ProviderInstance{}, classifyOpenStackError(err)
+	}
+	return ProviderInstance{ID: server.ID, Name: spec.Name, State: "BUILD", Tags: spec.Tags}, nil
+}
+
+func (p *openstackProvider) Terminate(id string) error {
+	if err := servers.Delete(context.TODO(), p.compute, id).ExtractErr(); err != nil {
+		return classifyOpenStackError(err)
+	}
+	return nil
+}
+
+func (p *openstackProvider) List() ([]ProviderInstance, error) {
+	pages, err := servers.List(p.compute, servers.ListOpts{}).AllPages(context.TODO())
+	if err != nil {
+		return nil, classifyOpenStackError(err)
+	}
+	list, err := servers.ExtractServers(pages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse servers: %v", err)
+	}
+
+	instances := []ProviderInstance{}
+	for _, server := range list {
+		if server.Metadata["Purpose"] != "GitHub Actions" {
+			continue
+		}
+		publicIP, privateIP := openstackAddresses(server.Addresses)
+		instances = append(instances, ProviderInstance{
+			ID:        server.ID,
+			Name:      server.Name,
+			State:     server.Status,
+			PublicIP:  publicIP,
+			PrivateIP: privateIP,
+			Tags:      server.Metadata,
+		})
+	}
+	return instances, nil
+}
+
+// openstackAddresses returns the first floating and fixed IPv4 addresses of a server
+func openstackAddresses(addresses map[string]any) (publicIP, privateIP string) {
+	for _, network := range addresses {
+		entries, _ := network.([]any)
+		for _, entry := range entries {
+			address, _ := entry.(map[string]any)
+			addr, _ := address["addr"].(string)
+			version, _ := address["version"].(float64)
+			if addr == "" || version == 6 {
+				continue
+			}
+			if kind, _ := address["OS-EXT-IPS:type"].(string); kind == "floating" {
+				if publicIP == "" {
+					publicIP = addr
+				}
+			} else if privateIP == "" {
+				privateIP = addr
+			}
+		}
+	}
+	return publicIP, privateIP
+}
+
+// classifyOpenStackError maps OpenStack API errors to the error taxonomy
+func classifyOpenStackError(err error) error {
+	switch {
+	case gophercloud.ResponseCodeIs(err, 401):
+		return fmt.Errorf("%w: %v", ErrAuth, err)
+	case gophercloud.ResponseCodeIs(err, 403) && strings.Contains(err.Error(), "Quota exceeded"):
+		return fmt.Errorf("%w: %v", ErrQuota, err)
+	case gophercloud.ResponseCodeIs(err, 403):
+		return fmt.Errorf("%w: %v", ErrAuth, err)
+	case gophercloud.ResponseCodeIs(err, 404):
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case gophercloud.ResponseCodeIs(err, 429):
+		return fmt.Errorf("%w: %v", ErrThrottle, err)
+	}
+	return err
+}
+
+// checkOpenStackID checks that a value is an OpenStack resource ID
+func checkOpenStackID(value string) error {
+	if !openstackUUIDRegex.MatchString(value) {
+		return fmt.Errorf("%q is not an OpenStack ID", value)
+	}
+	return nil
+}
+
+func init() {
+	providerFactories["openstack"] = newOpenStackProvider
+
+	create := []*cobra.Command{createCmd}
+	addProviderFlag(create, "openstack", "flavor", "OpenStack flavor name or ID, if --instance-type isn't given", nil)
+	addProviderFlag(create, "openstack", "image", "OpenStack image name or ID, if --image-id isn't given", nil)
+	addProviderFlag(create, "openstack", "network",
+		"OpenStack network ID to attach (default: the project's networks)", checkOpenStackID)
+	addProviderFlag(create, "openstack", "keypair", "OpenStack key pair injected for SSH access", nil)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	runnerOrg   string
+	runnerGroup string
+)
+
+// runnerScope returns what the runner registers with: the organization given
+// with --org, otherwise the "<owner>/<repo>" repository
+func runnerScope(repoOwner, repoName string) string {
+	if runnerOrg != "" {
+		return runnerOrg
+	}
+	return fmt.Sprintf("%s/%s", repoOwner, repoName)
+}
+
+// isOrgScope reports whether a runner scope is an organization rather than a repository
+func isOrgScope(scope string) bool {
+	return !strings.Contains(scope, "/")
+}
+
+// runnersAPIPath returns the REST API path of a scope's self-hosted runners
+func runnersAPIPath(scope string) string {
+	if isOrgScope(scope) {
+		return "/orgs/" + scope + "/actions/runners"
+	}
+	return "/repos/" + scope + "/actions/runners"
+}
+
+// runnerGroupArg returns the config.sh/config.cmd argument selecting --runner-group, if any
+func runnerGroupArg() string {
+	if runnerGroup == "" {
+		return ""
+	}
+	return fmt.Sprintf(` --runnergroup "%s"`, runnerGroup)
+}
+
+// checkRunnerGroup verifies that --runner-group exists in the organization
+// before an instance is launched into it
+func checkRunnerGroup(githubToken, scope string) error {
+	if runnerGroup == "" || !isOrgScope(scope) {
+		return nil
+	}
+
+	for page := 1; ; page++ {
+		body, err := githubAPIRequest("GET", fmt.Sprintf("/orgs/%s/actions/runner-groups?per_page=100&page=%d",
+			scope, page), githubToken, nil, http.StatusOK)
+		if err != nil {
+			return fmt.Errorf("failed to list the runner groups of %s: %w", scope, err)
+		}
+		var list struct {
+			TotalCount   int `json:"total_count"`
+			RunnerGroups []struct {
+				Name string `json:"name"`
+			} `json:"runner_groups"`
+		}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return fmt.Errorf("failed to parse response: %v", err)
+		}
+		for _, group := range list.RunnerGroups {
+			if group.Name == runnerGroup {
+				return nil
+			}
+		}
+		if len(list.RunnerGroups) == 0 || page*100 >= list.TotalCount {
+			return fmt.Errorf("%w: runner group %q does not exist in %s", ErrNotFound, runnerGroup, scope)
+		}
+	}
+}
+
+func init() {
+	createCmd.Flags().StringVar(&runnerOrg, "org", "",
+		"Register the runner with this organization instead of a repository (needs admin:org)")
+	createCmd.Flags().StringVar(&runnerGroup, "runner-group", "",
+		"Runner group to add the runner to (default: the Default group)")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	orgDiscovery bool
+	orgOUs       []string
+	orgTags      []string
+	orgRoleName  string
+)
+
+// defaultOrgRoleName is the role AWS Organizations creates in member accounts
+const defaultOrgRoleName = "OrganizationAccountAccessRole"
+
+// OrganizationDiscovery configures account discovery through AWS Organizations,
+// as set under "organization" in the config file
+type OrganizationDiscovery struct {
+	RoleName   string            `yaml:"role-name,omitempty"`
+	ExternalID string            `yaml:"external-id,omitempty"`
+	OUs        []string          `yaml:"ous,omitempty"`
+	Tags       map[string]string `yaml:"tags,omitempty"`
+}
+
+// orgDiscoverySettings merges the config file settings with the command-line flags
+func orgDiscoverySettings(cmd *cobra.Command) (OrganizationDiscovery, error) {
+	settings := OrganizationDiscovery{}
+	if activeConfig != nil && activeConfig.Organization != nil {
+		settings = *activeConfig.Organization
+	}
+
+	if cmd.Flags().Changed("org-role-name") || settings.RoleName == "" {
+		settings.RoleName = orgRoleName
+	}
+	if len(orgOUs) > 0 {
+		settings.OUs = orgOUs
+	}
+	if len(orgTags) > 0 {
+		settings.Tags = map[string]string{}
+		for _, tag := range orgTags {
+			key, value, ok := strings.Cut(tag, "=")
+			if !ok || key == "" {
+				return settings, fmt.Errorf("invalid --org-tag %q (expected key=value)", tag)
+			}
+			settings.Tags[key] = value
+		}
+	}
+	return settings, nil
+}
+
+// discoverOrgAccounts lists the active member accounts of the organization,
+// restricted to the configured OUs (including nested OUs) and account tags
+func discoverOrgAccounts(cfg aws.Config, settings OrganizationDiscovery) ([]FleetAccount, error) {
+	svc := organizations.NewFromConfig(cfg)
+
+	var accounts []types.Account
+	if len(settings.OUs) == 0 {
+		paginator := organizations.NewListAccountsPaginator(svc, &organizations.ListAccountsInput{})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(context.TODO())
+			if err != nil {
+				return nil, fmt.Errorf("failed to list organization accounts: %w", classifyAWSError(err))
+			}
+			accounts = append(accounts, page.Accounts...)
+		}
+	} else {
+		for _, ou := range settings.OUs {
+			ouAccounts, err := listOUAccounts(svc, ou)
+			if err != nil {
+				return nil, err
+			}
+			accounts = append(accounts, ouAccounts...)
+		}
+	}
+
+	discovered := []FleetAccount{}
+	seen := map[string]bool{}
+	for _, account := range accounts {
+		id := aws.ToString(account.Id)
+		if account.Status != types.AccountStatusActive || seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if len(settings.Tags) > 0 {
+			matches, err := accountHasTags(svc, id, settings.Tags)
+			if err != nil {
+				return nil, err
+			}
+			if !matches {
+				continue
+			}
+		}
+
+		discovered = append(discovered, FleetAccount{
+			Name:       aws.ToString(account.Name),
+			RoleARN:    fmt.Sprintf("arn:%s:iam::%s:role/%s", awsPartition(), id, settings.RoleName),
+			ExternalID: settings.ExternalID,
+		})
+	}
+	return discovered, nil
+}
+
+// listOUAccounts returns the accounts in an OU and all of its child OUs
+func listOUAccounts(svc *organizations.Client, ou string) ([]types.Account, error) {
+	accounts := []types.Account{}
+
+	accountPages := organizations.NewListAccountsForParentPaginator(svc, &organizations.ListAccountsForParentInput{
+		ParentId: aws.String(ou),
+	})
+	for accountPages.HasMorePages() {
+		page, err := accountPages.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list accounts in %s: %w", ou, classifyAWSError(err))
+		}
+		accounts = append(accounts, page.Accounts...)
+	}
+
+	childPages := organizations.NewListOrganizationalUnitsForParentPaginator(svc,
+		&organizations.ListOrganizationalUnitsForParentInput{ParentId: aws.String(ou)})
+	for childPages.HasMorePages() {
+		page, err := childPages.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list child OUs of %s: %w", ou, classifyAWSError(err))
+		}
+		for _, child := range page.OrganizationalUnits {
+			childAccounts, err := listOUAccounts(svc, aws.ToString(child.Id))
+			if err != nil {
+				return nil, err
+			}
+			accounts = append(accounts, childAccounts...)
+		}
+	}
+	return accounts, nil
+}
+
+// accountHasTags reports whether the account carries every wanted tag
+func accountHasTags(svc *organizations.Client, accountID string, wanted map[string]string) (bool, error) {
+	tags := map[string]string{}
+	paginator := organizations.NewListTagsForResourcePaginator(svc, &organizations.ListTagsForResourceInput{
+		ResourceId: aws.String(accountID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return false, fmt.Errorf("failed to list tags for account %s: %w", accountID, classifyAWSError(err))
+		}
+		for _, tag := range page.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	for key, value := range wanted {
+		if tags[key] != value {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// addOrgFlags registers the Organizations discovery flags shared by fleet commands
+func addOrgFlags(cmd *cobra.Command) {
+	cmd.Flags().
+		BoolVar(&orgDiscovery, "org-accounts", false, "Discover target accounts through AWS Organizations")
+	cmd.Flags().
+		StringSliceVar(&orgOUs, "org-ou", nil, "Only include accounts under this organizational unit (repeatable)")
+	cmd.Flags().
+		StringSliceVar(&orgTags, "org-tag", nil, "Only include accounts with this tag, as key=value (repeatable)")
+	cmd.Flags().
+		StringVar(&orgRoleName, "org-role-name", defaultOrgRoleName, "Role name to assume in discovered accounts")
+}
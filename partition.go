@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	awsPartitionFlag string
+	useFIPSEndpoint  bool
+)
+
+// awsPartitionInfo describes an AWS partition: its region prefix, the region
+// used when none is configured and where its Price List API serves USD prices
+type awsPartitionInfo struct {
+	RegionPrefix  string
+	DefaultRegion string
+	PricingRegion string
+}
+
+// awsPartitions are the partitions gh-workflow knows; regions without a known
+// prefix are in the commercial "aws" partition
+var awsPartitions = map[string]awsPartitionInfo{
+	"aws":        {DefaultRegion: "us-east-1", PricingRegion: "us-east-1"},
+	"aws-us-gov": {RegionPrefix: "us-gov-", DefaultRegion: "us-gov-west-1"},
+	"aws-cn":     {RegionPrefix: "cn-", DefaultRegion: "cn-north-1"},
+}
+
+// regionPartition returns the partition a region belongs to
+func regionPartition(region string) string {
+	for name, partition := range awsPartitions {
+		if partition.RegionPrefix != "" && strings.HasPrefix(region, partition.RegionPrefix) {
+			return name
+		}
+	}
+	return "aws"
+}
+
+// awsPartition returns the partition to build ARNs and pick endpoints for:
+// --partition, or else the partition of the region
+func awsPartition() string {
+	if awsPartitionFlag != "" {
+		return awsPartitionFlag
+	}
+	return regionPartition(resolveRegion())
+}
+
+// validatePartitionFlags checks --partition and that it matches the region
+func validatePartitionFlags() error {
+	if awsPartitionFlag == "" {
+		return nil
+	}
+	if _, ok := awsPartitions[awsPartitionFlag]; !ok {
+		return fmt.Errorf("partition must be 'aws', 'aws-us-gov' or 'aws-cn', got %q", awsPartitionFlag)
+	}
+	if region := resolveRegion(); regionPartition(region) != awsPartitionFlag {
+		return fmt.Errorf("region %s is not in partition %s", region, awsPartitionFlag)
+	}
+	return nil
+}
+
+// partitionARN rewrites an ARN of the commercial partition for partition
+func partitionARN(arn, partition string) string {
+	return strings.Replace(arn, "arn:aws:", "arn:"+partition+":", 1)
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&awsPartitionFlag, "partition", "",
+		"AWS partition (aws, aws-us-gov or aws-cn; default: that of the region)")
+	flags.BoolVar(&useFIPSEndpoint, "use-fips-endpoint", false,
+		"Use the FIPS endpoints of AWS services (also AWS_USE_FIPS_ENDPOINT=true)")
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var (
+	spotRequestType  string
+	resumeInstanceID string
+)
+
+// Spot request types: a one-time request ends with its instance, a persistent
+// one stops the instance on interruption and restarts it once capacity is back
+const (
+	spotRequestOneTime    = "one-time"
+	spotRequestPersistent = "persistent"
+)
+
+// spotTokenMarker prefixes the user data line in which resume leaves a new
+// registration token for the restart service of a persistent spot runner
+const spotTokenMarker = "# gh-workflow-registration-token: "
+
+// validateSpotRequestFlags checks --spot-request-type
+func validateSpotRequestFlags() error {
+	switch spotRequestType {
+	case spotRequestOneTime:
+		return nil
+	case spotRequestPersistent:
+	default:
+		return fmt.Errorf("spot-request-type must be %s or %s, got %q",
+			spotRequestOneTime, spotRequestPersistent, spotRequestType)
+	}
+	if instanceMarketType != "spot" {
+		return fmt.Errorf("spot-request-type persistent requires --instance-market-type spot")
+	}
+	if launchMode == launchModeFleet {
+		return fmt.Errorf("spot-request-type persistent can't be used with --launch-mode fleet, " +
+			"whose instant fleets only make one-time requests")
+	}
+	if runnerOS != "linux" {
+		return fmt.Errorf("spot-request-type persistent is only supported with --os linux")
+	}
+	return nil
+}
+
+// applySpotRequestType sets the spot request type; a persistent request stops
+// its instance on interruption instead of terminating it
+func applySpotRequestType(spotOptions *types.SpotMarketOptions) {
+	spotOptions.SpotInstanceType = types.SpotInstanceTypeOneTime
+	if spotRequestType == spotRequestPersistent {
+		spotOptions.SpotInstanceType = types.SpotInstanceTypePersistent
+		spotOptions.InstanceInterruptionBehavior = types.InstanceInterruptionBehaviorStop
+	}
+}
+
+// spotRestartUserData returns user data lines installing a service that
+// brings the runner back online when the instance boots again, e.g. when EC2
+// restarts a persistent spot instance: with its existing registration, or with
+// configCommand and the registration token resume left in the user data. The
+// first boot starts the runner itself, so the service is only enabled
+func spotRestartUserData(configCommand string) []string {
+	if spotRequestType != spotRequestPersistent {
+		return nil
+	}
+	credentials := ".runner .credentials .credentials_rsaparams"
+	return []string{
+		"# Bring the runner back online when EC2 restarts the persistent spot instance",
+		"cat > /usr/local/bin/gh-workflow-spot-restart << 'EOF'",
+		"#!/bin/bash",
+		"cd /actions-runner || exit 1",
+		"export RUNNER_ALLOW_RUNASROOT=1",
+		"IMDS=http://169.254.169.254/latest",
+		"IMDS_TOKEN=$(curl -s -X PUT $IMDS/api/token -H 'X-aws-ec2-metadata-token-ttl-seconds: 60')",
+		"TOKEN=$(curl -s -H \"X-aws-ec2-metadata-token: $IMDS_TOKEN\" $IMDS/user-data | " +
+			"sed -n 's/^" + spotTokenMarker + "//p' | tail -n 1)",
+		"if [ -n \"$TOKEN\" ] && [ \"$TOKEN\" != \"$(cat .registration-token 2>/dev/null)\" ]; then",
+		"    echo \"$TOKEN\" > .registration-token",
+		"    mkdir -p .registration-backup && mv " + credentials + " .registration-backup/ 2>/dev/null",
+		"    if " + configCommand + "; then",
+		"        echo 'Runner re-registered'",
+		"    else",
+		"        echo 'Re-registration failed, keeping the existing registration'",
+		"        mv .registration-backup/.[!.]* . 2>/dev/null",
+		"    fi",
+		"fi",
+		"exec ./run.sh",
+		"EOF",
+		"chmod +x /usr/local/bin/gh-workflow-spot-restart",
+		"cat > /etc/systemd/system/gh-workflow-spot-restart.service << 'EOF'",
+		"[Unit]",
+		"Description=gh-workflow runner restart",
+		"After=network-online.target",
+		"Wants=network-online.target",
+		"[Service]",
+		"ExecStart=/usr/local/bin/gh-workflow-spot-restart",
+		"Restart=on-failure",
+		"RestartSec=30",
+		"[Install]",
+		"WantedBy=multi-user.target",
+		"EOF",
+		"systemctl daemon-reload && systemctl enable gh-workflow-spot-restart",
+		"",
+	}
+}
+
+// cancelSpotRequests cancels persistent spot requests, which would otherwise
+// launch a replacement for their terminated instances
+func cancelSpotRequests(svc *ec2.Client, requestIDs ...string) error {
+	if len(requestIDs) == 0 {
+		return nil
+	}
+	_, err := svc.CancelSpotInstanceRequests(context.TODO(), &ec2.CancelSpotInstanceRequestsInput{
+		SpotInstanceRequestIds: requestIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel spot request %s: %w", strings.Join(requestIDs, ", "),
+			classifyAWSError(err))
+	}
+	emitEvent("spot.requests_cancelled", map[string]any{"spot_request_ids": requestIDs})
+	if outputFormat != "github-actions" {
+		fmt.Printf("🧾 Cancelled persistent spot request %s\n", strings.Join(requestIDs, ", "))
+	}
+	return nil
+}
+
+// resumeSpotInstance picks up a persistent spot runner. While EC2 keeps the
+// interrupted instance stopped, a new registration token goes into its user
+// data, for the restart service to re-register the runner with; once it runs
+// again, resume waits for the runner to come back online
+func resumeSpotInstance(svc *ec2.Client, instanceID string) error {
+	result, err := svc.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find instance %s: %w", instanceID, classifyAWSError(err))
+	}
+	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
+		return fmt.Errorf("%w: instance %s", ErrNotFound, instanceID)
+	}
+	instance := result.Reservations[0].Instances[0]
+	manifest := manifestFromInstance(instance)
+	if manifest.SpotRequestID == "" {
+		return fmt.Errorf("instance %s is not a persistent spot instance (see --spot-request-type)", instanceID)
+	}
+	emitEvent("spot.resumed", map[string]any{"instance_id": instanceID, "state": manifest.State})
+
+	switch manifest.State {
+	case "stopped":
+		return requestReregistration(svc, manifest)
+	case "pending", "running":
+		if outputFormat == "github-actions" {
+			fmt.Printf("Instance ID: %s\n", instanceID)
+			fmt.Printf("Runner Name: %s\n", manifest.RunnerName)
+		} else {
+			fmt.Printf("🔁 Instance %s is %s again\n", instanceID, manifest.State)
+		}
+		if registrationWait == 0 {
+			return nil
+		}
+		return waitForRunnerRegistration(svc, instanceID, githubToken, manifest.Repository, manifest.RunnerName,
+			registrationWait)
+	default:
+		return fmt.Errorf("instance %s is %s and can't be resumed", instanceID, manifest.State)
+	}
+}
+
+// requestReregistration leaves a new registration token in the user data of
+// a stopped persistent spot instance; EC2 only allows changing the user data
+// of stopped instances
+func requestReregistration(svc *ec2.Client, manifest RunManifest) error {
+	if githubToken == "" {
+		return fmt.Errorf("github-token is required to re-register the runner of instance %s", manifest.InstanceID)
+	}
+	// A runner registered with an organization records the organization as its repository
+	repoOwner, repoName, isRepo := strings.Cut(manifest.Repository, "/")
+	if !isRepo {
+		runnerOrg = manifest.Repository
+	}
+	token, err := getGitHubRegistrationToken(githubToken, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to get registration token: %w", err)
+	}
+
+	attribute, err := svc.DescribeInstanceAttribute(context.TODO(), &ec2.DescribeInstanceAttributeInput{
+		InstanceId: aws.String(manifest.InstanceID),
+		Attribute:  types.InstanceAttributeNameUserData,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read the user data of instance %s: %w", manifest.InstanceID,
+			classifyAWSError(err))
+	}
+	var userData []byte
+	if attribute.UserData != nil {
+		if userData, err = base64.StdEncoding.DecodeString(aws.ToString(attribute.UserData.Value)); err != nil {
+			return fmt.Errorf("failed to decode the user data of instance %s: %v", manifest.InstanceID, err)
+		}
+	}
+	lines := []string{}
+	for _, line := range strings.Split(strings.TrimRight(string(userData), "\n"), "\n") {
+		if !strings.HasPrefix(line, spotTokenMarker) {
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, spotTokenMarker+token, "")
+
+	_, err = svc.ModifyInstanceAttribute(context.TODO(), &ec2.ModifyInstanceAttributeInput{
+		InstanceId: aws.String(manifest.InstanceID),
+		UserData:   &types.BlobAttributeValue{Value: []byte(strings.Join(lines, "\n"))},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the user data of instance %s: %w", manifest.InstanceID,
+			classifyAWSError(err))
+	}
+	emitEvent("runner.reregistration_requested", map[string]any{
+		"instance_id": manifest.InstanceID,
+		"runner_name": manifest.RunnerName,
+	})
+	if outputFormat != "github-actions" {
+		fmt.Printf("🔁 Runner %s re-registers when EC2 restarts instance %s once spot capacity is back\n",
+			manifest.RunnerName, manifest.InstanceID)
+		fmt.Printf("💡 The registration token expires in an hour; after that the runner reconnects with its " +
+			"existing registration\n")
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().StringVar(&spotRequestType, "spot-request-type", spotRequestOneTime,
+		"Spot request type: one-time, or persistent to stop on interruption and restart once capacity is back")
+	resumeCmd.Flags().StringVar(&resumeInstanceID, "instance-id", "",
+		"Persistent spot instance whose runner to re-register (when stopped) or wait for (when running again)")
+}
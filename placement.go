@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+var placementScript string
+
+// placementMaxSteps bounds the work a placement script may do
+const placementMaxSteps = 10_000_000
+
+// PlacementSpec is the part of the launch request a placement script may change
+type PlacementSpec struct {
+	InstanceType       string
+	SubnetID           string
+	InstanceMarketType string
+	SpotMaxPrice       string
+}
+
+// runPlacementScript evaluates the Starlark placement script and returns the
+// adjusted spec. The script must define place(inputs) returning a dict with any
+// of instance_type, subnet_id, instance_market_type and spot_max_price; keys it
+// omits keep their current value. Live EC2 data is available through the
+// spot_price(instance_type, availability_zone=None) and subnet(subnet_id) builtins.
+func runPlacementScript(svc *ec2.Client, path string, spec PlacementSpec, manifest RunManifest) (PlacementSpec, error) {
+	thread := &starlark.Thread{
+		Name: "placement",
+		Print: func(_ *starlark.Thread, msg string) {
+			fmt.Fprintf(os.Stderr, "[placement] %s\n", msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(placementMaxSteps)
+
+	predeclared := starlark.StringDict{
+		"spot_price": starlark.NewBuiltin("spot_price", placementSpotPrice(svc)),
+		"subnet":     starlark.NewBuiltin("subnet", placementSubnet(svc)),
+	}
+
+	globals, err := starlark.ExecFile(thread, path, nil, predeclared)
+	if err != nil {
+		return spec, fmt.Errorf("failed to load placement script %s: %v", path, err)
+	}
+
+	place, ok := globals["place"].(starlark.Callable)
+	if !ok {
+		return spec, fmt.Errorf("placement script %s must define a place(inputs) function", path)
+	}
+
+	inputs := starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"instance_type":        starlark.String(spec.InstanceType),
+		"subnet_id":            starlark.String(spec.SubnetID),
+		"instance_market_type": starlark.String(spec.InstanceMarketType),
+		"spot_max_price":       starlark.String(spec.SpotMaxPrice),
+		"repository":           starlark.String(manifest.Repository),
+		"labels":               starlark.String(manifest.Labels),
+		"runner_name":          starlark.String(manifest.RunnerName),
+		"image_id":             starlark.String(manifest.ImageID),
+		"region":               starlark.String(manifest.Region),
+	})
+
+	result, err := starlark.Call(thread, place, starlark.Tuple{inputs}, nil)
+	if err != nil {
+		return spec, fmt.Errorf("placement script failed: %v", err)
+	}
+	if result == starlark.None {
+		return spec, nil
+	}
+
+	dict, ok := result.(*starlark.Dict)
+	if !ok {
+		return spec, fmt.Errorf("placement script must return a dict or None, got %s", result.Type())
+	}
+
+	fields := map[string]*string{
+		"instance_type":        &spec.InstanceType,
+		"subnet_id":            &spec.SubnetID,
+		"instance_market_type": &spec.InstanceMarketType,
+		"spot_max_price":       &spec.SpotMaxPrice,
+	}
+	for _, item := range dict.Items() {
+		key, ok := starlark.AsString(item[0])
+		if !ok {
+			return spec, fmt.Errorf("placement script returned a non-string key %s", item[0])
+		}
+		field, ok := fields[key]
+		if !ok {
+			return spec, fmt.Errorf("placement script returned unknown key %q", key)
+		}
+		switch value := item[1].(type) {
+		case starlark.String:
+			*field = string(value)
+		case starlark.Int, starlark.Float:
+			f, _ := starlark.AsFloat(value)
+			*field = strconv.FormatFloat(f, 'f', -1, 64)
+		default:
+			return spec, fmt.Errorf("placement script returned %s for %q, expected a string", value.Type(), key)
+		}
+	}
+
+	if spec.InstanceMarketType != "on-demand" && spec.InstanceMarketType != "spot" {
+		return spec, fmt.Errorf("placement script set instance_market_type to %q (must be 'on-demand' or 'spot')",
+			spec.InstanceMarketType)
+	}
+	return spec, nil
+}
+
+// placementSpotPrice implements spot_price(instance_type, availability_zone=None),
+// returning the lowest current Linux spot price in USD/hour, or None if unknown
+func placementSpotPrice(
+	svc *ec2.Client,
+) func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error) {
+	return func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var instanceType, zone string
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "instance_type", &instanceType,
+			"availability_zone?", &zone); err != nil {
+			return nil, err
+		}
+
+		input := &ec2.DescribeSpotPriceHistoryInput{
+			InstanceTypes:       []types.InstanceType{types.InstanceType(instanceType)},
+			ProductDescriptions: []string{"Linux/UNIX"},
+			StartTime:           aws.Time(time.Now()),
+		}
+		if zone != "" {
+			input.AvailabilityZone = aws.String(zone)
+		}
+
+		result, err := svc.DescribeSpotPriceHistory(context.TODO(), input)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", b.Name(), classifyAWSError(err))
+		}
+
+		prices := []float64{}
+		for _, entry := range result.SpotPriceHistory {
+			if price, err := strconv.ParseFloat(aws.ToString(entry.SpotPrice), 64); err == nil {
+				prices = append(prices, price)
+			}
+		}
+		if len(prices) == 0 {
+			return starlark.None, nil
+		}
+		sort.Float64s(prices)
+		return starlark.Float(prices[0]), nil
+	}
+}
+
+// placementSubnet implements subnet(subnet_id), returning a struct with
+// availability_zone, vpc_id and available_ips
+func placementSubnet(
+	svc *ec2.Client,
+) func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error) {
+	return func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var subnetID string
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "subnet_id", &subnetID); err != nil {
+			return nil, err
+		}
+
+		result, err := svc.DescribeSubnets(context.TODO(), &ec2.DescribeSubnetsInput{
+			SubnetIds: []string{subnetID},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", b.Name(), classifyAWSError(err))
+		}
+		if len(result.Subnets) == 0 {
+			return nil, fmt.Errorf("%s: subnet %s not found", b.Name(), subnetID)
+		}
+
+		subnet := result.Subnets[0]
+		return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"availability_zone": starlark.String(aws.ToString(subnet.AvailabilityZone)),
+			"vpc_id":            starlark.String(aws.ToString(subnet.VpcId)),
+			"available_ips":     starlark.MakeInt(int(aws.ToInt32(subnet.AvailableIpAddressCount))),
+		}), nil
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// pluginPrefix prefixes the executable names of provider plugins
+const pluginPrefix = "gh-workflow-provider-"
+
+// pluginProtocolVersion is the version of the JSON protocol spoken with plugins
+const pluginProtocolVersion = 1
+
+// PluginRequest is written as JSON to a plugin's stdin
+type PluginRequest struct {
+	Version int               `json:"version"`
+	Action  string            `json:"action"`
+	Options map[string]string `json:"options"`
+	Params  any               `json:"params,omitempty"`
+}
+
+// PluginResponse is read as JSON from a plugin's stdout
+type PluginResponse struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// execProvider is a provider implemented by a gh-workflow-provider-<name>
+// executable: each action runs it once with a PluginRequest on stdin and reads
+// a PluginResponse from stdout, while its stderr is passed through for logs
+type execProvider struct {
+	path    string
+	options map[string]string
+}
+
+// call runs the plugin for one action, decoding the response's result into result
+func (p *execProvider) call(action string, params, result any) error {
+	request, err := json.Marshal(PluginRequest{
+		Version: pluginProtocolVersion,
+		Action:  action,
+		Options: p.options,
+		Params:  params,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode plugin request: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(p.path)
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	var response PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		if runErr != nil {
+			return fmt.Errorf("plugin %s failed: %v", filepath.Base(p.path), runErr)
+		}
+		return fmt.Errorf("plugin %s returned an invalid response: %v", filepath.Base(p.path), err)
+	}
+	if response.Error != "" {
+		return fmt.Errorf("plugin %s: %s", filepath.Base(p.path), response.Error)
+	}
+	if runErr != nil {
+		return fmt.Errorf("plugin %s failed: %v", filepath.Base(p.path), runErr)
+	}
+	if result != nil && len(response.Result) > 0 {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("plugin %s returned an invalid result: %v", filepath.Base(p.path), err)
+		}
+	}
+	return nil
+}
+
+func (p *execProvider) Create(spec ProviderSpec) (ProviderInstance, error) {
+	var instance ProviderInstance
+	if err := p.call("create", spec, &instance); err != nil {
+		return instance, err
+	}
+	if instance.ID == "" {
+		return instance, fmt.Errorf("plugin %s returned no instance ID", filepath.Base(p.path))
+	}
+	return instance, nil
+}
+
+func (p *execProvider) Terminate(id string) error {
+	return p.call("terminate", map[string]string{"id": id}, nil)
+}
+
+func (p *execProvider) List() ([]ProviderInstance, error) {
+	instances := []ProviderInstance{}
+	err := p.call("list", nil, &instances)
+	return instances, err
+}
+
+// pluginDirs returns the directories searched for plugins: the one holding the
+// gh-workflow binary, then $PATH
+func pluginDirs() []string {
+	dirs := []string{}
+	if executable, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(executable))
+	}
+	return append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+}
+
+// isExecutable reports whether path is an executable regular file
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0
+}
+
+// findPlugin returns the path of the gh-workflow-provider-<name> plugin
+func findPlugin(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid provider name %q", name)
+	}
+	for _, dir := range pluginDirs() {
+		path := filepath.Join(dir, pluginPrefix+name)
+		if isExecutable(path) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%w: unknown provider %q (no built-in provider or %s%s plugin on PATH)",
+		ErrNotFound, name, pluginPrefix, name)
+}
+
+// discoverPlugins returns the plugins found on the search path by provider
+// name; the first one found for a name wins
+func discoverPlugins() map[string]string {
+	plugins := map[string]string{}
+	for _, dir := range pluginDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), pluginPrefix)
+			if !ok || name == "" || plugins[name] != "" {
+				continue
+			}
+			if path := filepath.Join(dir, entry.Name()); isExecutable(path) {
+				plugins[name] = path
+			}
+		}
+	}
+	return plugins
+}
+
+var providersCmd = &cobra.Command{
+	Use:   "providers",
+	Short: "List the available runner providers",
+	Long: "List the built-in runner providers and the gh-workflow-provider-<name> plugins found next to " +
+		"the gh-workflow binary and on PATH",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names := []string{defaultProvider}
+		for name := range providerFactories {
+			names = append(names, name)
+		}
+		sort.Strings(names[1:])
+		for _, name := range names {
+			fmt.Printf("%s\tbuilt-in\n", name)
+		}
+
+		plugins := discoverPlugins()
+		pluginNames := make([]string, 0, len(plugins))
+		for name := range plugins {
+			pluginNames = append(pluginNames, name)
+		}
+		sort.Strings(pluginNames)
+		for _, name := range pluginNames {
+			fmt.Printf("%s\t%s\n", name, plugins[name])
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(providersCmd)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// oauthScopes returns the scopes of a classic token from a response's
+// X-OAuth-Scopes header, or nil for fine-grained, app and Actions tokens
+func oauthScopes(resp *http.Response) []string {
+	header, ok := resp.Header["X-Oauth-Scopes"]
+	if !ok {
+		return nil
+	}
+	scopes := []string{}
+	for _, scope := range strings.Split(strings.Join(header, ","), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// hasAnyScope reports whether scopes include one of wanted
+func hasAnyScope(scopes []string, wanted ...string) bool {
+	for _, scope := range wanted {
+		if slices.Contains(scopes, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// preflightGitHubToken checks that the token can create registration tokens
+// for a repository ("<owner>/<repo>") or organization before anything is
+// launched, so a wrong token fails fast with a clear message
+func preflightGitHubToken(githubToken, scope string) error {
+	path := "/repos/" + scope
+	if isOrgScope(scope) {
+		path = "/orgs/" + scope
+	}
+
+	resp, body, err := doGitHubRequest("GET", githubAPIURL()+path, githubToken, nil)
+	if err != nil {
+		return fmt.Errorf("failed to check GitHub token: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return fmt.Errorf("%w: the GitHub token is invalid or expired", ErrAuth)
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s does not exist or the GitHub token cannot access it", ErrNotFound, scope)
+	default:
+		return classifyGitHubResponse(resp, body)
+	}
+
+	scopes := oauthScopes(resp)
+	if isOrgScope(scope) {
+		if scopes != nil && !hasAnyScope(scopes, "admin:org", "manage_runners:org") {
+			return fmt.Errorf("%w: registering organization runners needs a token with the admin:org scope "+
+				"(token has: %s)", ErrAuth, strings.Join(scopes, ", "))
+		}
+		return nil
+	}
+
+	var repo struct {
+		Private     bool `json:"private"`
+		Permissions *struct {
+			Admin bool `json:"admin"`
+		} `json:"permissions"`
+	}
+	if err := json.Unmarshal(body, &repo); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+	if scopes != nil && !hasAnyScope(scopes, "repo") && (repo.Private || !hasAnyScope(scopes, "public_repo")) {
+		return fmt.Errorf("%w: registering runners for %s needs a token with the repo scope (token has: %s)",
+			ErrAuth, scope, strings.Join(scopes, ", "))
+	}
+	// Tokens that report repository permissions must grant admin access
+	if repo.Permissions != nil && !repo.Permissions.Admin {
+		return fmt.Errorf("%w: registering runners needs admin access to %s, which the token's user lacks",
+			ErrAuth, scope)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var (
+	runnerPreset      string
+	requireNestedVirt bool
+)
+
+// androidCmdlineToolsURL is the Android SDK command-line tools release installed by the android preset
+const androidCmdlineToolsURL = "https://dl.google.com/android/repository/commandlinetools-linux-11076708_latest.zip"
+
+// Preset bundles the instance type, labels and bootstrap steps for a kind of workload
+type Preset struct {
+	// InstanceType is used when --instance-type is not given
+	InstanceType string
+	// Labels are appended to the runner labels
+	Labels []string
+	// RequireKVM restricts the instance type to ones exposing /dev/kvm
+	RequireKVM bool
+	// RequireGPU restricts the instance type to GPU instance families
+	RequireGPU bool
+	// Volumes are used when no --volume is given
+	Volumes []string
+	// SysctlProfiles are used when --sysctl-profile is not given
+	SysctlProfiles string
+	// SwapSize is used when --swap-size is not given
+	SwapSize string
+	// UserData returns the preset's bootstrap lines
+	UserData func() []string
+}
+
+// presets are the built-in presets selectable with --preset
+var presets = map[string]Preset{
+	"small-linux": {
+		InstanceType: "t3.medium",
+		Labels:       []string{"small"},
+		SwapSize:     "4G",
+	},
+	"docker-builder": {
+		InstanceType:   "c6i.2xlarge",
+		Labels:         []string{"docker"},
+		Volumes:        []string{"device=/dev/sdf,size=200,type=gp3,iops=6000,throughput=500,mount=/var/lib/docker"},
+		SysctlProfiles: "docker-default",
+		UserData:       dockerUserData,
+	},
+	"gpu-ml": {
+		InstanceType: "g5.xlarge",
+		Labels:       []string{"gpu"},
+		RequireGPU:   true,
+		Volumes:      []string{"device=/dev/sdf,size=500,type=gp3,throughput=250,mount=/data"},
+		SwapSize:     "16G",
+		UserData:     gpuUserData,
+	},
+	"android": {
+		InstanceType: "m5zn.metal",
+		Labels:       []string{"kvm", "android"},
+		RequireKVM:   true,
+		UserData:     androidUserData,
+	},
+}
+
+// presetNames returns the built-in preset names in sorted order
+func presetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isKVMCapable reports whether instances of the type expose /dev/kvm to the
+// guest; on EC2 that means bare metal
+func isKVMCapable(instanceType string) bool {
+	return strings.Contains(instanceType, ".metal")
+}
+
+// gpuInstanceFamilies are the EC2 instance families with NVIDIA GPUs
+var gpuInstanceFamilies = []string{"g4dn", "g5", "g6", "g6e", "gr6", "p3", "p3dn", "p4d", "p4de", "p5", "p5e"}
+
+// isGPUCapable reports whether instances of the type have an NVIDIA GPU
+func isGPUCapable(instanceType string) bool {
+	family, _, _ := strings.Cut(instanceType, ".")
+	return slices.Contains(gpuInstanceFamilies, family)
+}
+
+// kvmUserData returns user data lines making /dev/kvm usable by the runner
+func kvmUserData() []string {
+	return []string{
+		"# Enable KVM for hardware-accelerated virtual machines",
+		"apt-get update -y",
+		"apt-get install -y qemu-kvm cpu-checker",
+		`echo 'KERNEL=="kvm", GROUP="kvm", MODE="0666", OPTIONS+="static_node=kvm"' > /etc/udev/rules.d/99-kvm.rules`,
+		"udevadm control --reload-rules && udevadm trigger --name-match=kvm",
+		"kvm-ok || echo '⚠️  KVM acceleration is not available on this instance'",
+		"",
+	}
+}
+
+// libvirtUserData returns user data lines installing QEMU tooling and libvirt
+// for jobs that build and boot VM images
+func libvirtUserData() []string {
+	return []string{
+		"# Install libvirt and QEMU tooling",
+		"apt-get install -y qemu-utils qemu-system-x86 libvirt-daemon-system libvirt-clients virtinst",
+		"systemctl enable --now libvirtd",
+		"chmod 0666 /var/run/libvirt/libvirt-sock",
+		"",
+	}
+}
+
+// androidUserData returns user data lines installing the Android SDK
+// command-line tools, platform tools and emulator in /opt/android-sdk
+func androidUserData() []string {
+	return []string{
+		"# Install the Android SDK and emulator dependencies",
+		"apt-get install -y openjdk-17-jdk-headless unzip libpulse0 libgl1 libnss3 libxcomposite1 libxcursor1 " +
+			"libxi6 libxtst6 libxdamage1",
+		"apt-get install -y libasound2t64 || apt-get install -y libasound2",
+		"export ANDROID_HOME=/opt/android-sdk ANDROID_SDK_ROOT=/opt/android-sdk",
+		"mkdir -p $ANDROID_HOME/cmdline-tools",
+		fmt.Sprintf("curl -fsSL -o /tmp/cmdline-tools.zip %s", androidCmdlineToolsURL),
+		"unzip -q /tmp/cmdline-tools.zip -d $ANDROID_HOME/cmdline-tools && rm /tmp/cmdline-tools.zip",
+		"mv $ANDROID_HOME/cmdline-tools/cmdline-tools $ANDROID_HOME/cmdline-tools/latest",
+		"yes | $ANDROID_HOME/cmdline-tools/latest/bin/sdkmanager --licenses > /dev/null",
+		"$ANDROID_HOME/cmdline-tools/latest/bin/sdkmanager 'platform-tools' 'emulator'",
+		"echo \"ANDROID_HOME=$ANDROID_HOME\" >> /etc/environment",
+		"echo \"ANDROID_SDK_ROOT=$ANDROID_HOME\" >> /etc/environment",
+		"export PATH=$PATH:$ANDROID_HOME/cmdline-tools/latest/bin:$ANDROID_HOME/platform-tools:$ANDROID_HOME/emulator",
+		"",
+	}
+}
+
+// dockerUserData returns user data lines installing Docker with Buildx and
+// letting the runner's jobs use it
+func dockerUserData() []string {
+	return []string{
+		"# Install Docker with Buildx",
+		"if ! command -v docker >/dev/null; then",
+		"    apt-get update -y",
+		"    apt-get install -y docker.io docker-buildx || apt-get install -y docker.io",
+		"fi",
+		"systemctl enable --now docker",
+		"docker info --format 'Docker {{.ServerVersion}} on {{.Driver}}'",
+		"",
+	}
+}
+
+// gpuUserData returns user data lines installing the NVIDIA driver unless the
+// AMI has one (e.g. a Deep Learning AMI) and the NVIDIA container toolkit
+func gpuUserData() []string {
+	return []string{
+		"# Install the NVIDIA driver and container toolkit",
+		"apt-get update -y",
+		"if ! command -v nvidia-smi >/dev/null; then",
+		"    apt-get install -y ubuntu-drivers-common && ubuntu-drivers install --gpgpu",
+		"fi",
+		"curl -fsSL https://nvidia.github.io/libnvidia-container/gpgkey | " +
+			"gpg --dearmor -o /usr/share/keyrings/nvidia-container-toolkit.gpg",
+		"curl -fsSL https://nvidia.github.io/libnvidia-container/stable/deb/nvidia-container-toolkit.list | " +
+			"sed 's#deb https://#deb [signed-by=/usr/share/keyrings/nvidia-container-toolkit.gpg] https://#' " +
+			"> /etc/apt/sources.list.d/nvidia-container-toolkit.list",
+		"apt-get update -y && apt-get install -y nvidia-container-toolkit",
+		"if command -v docker >/dev/null; then nvidia-ctk runtime configure --runtime=docker && " +
+			"systemctl restart docker; fi",
+		"nvidia-smi || echo '⚠️  The NVIDIA driver is not loaded yet (reboot, or use a Deep Learning AMI)'",
+		"",
+	}
+}
+
+// applyPreset fills in the selected preset's instance type, labels, volumes,
+// kernel settings and swap where the flags leave them unset, and validates the
+// instance type against its requirements
+func applyPreset() error {
+	if runnerPreset == "" {
+		return nil
+	}
+	preset, ok := presets[runnerPreset]
+	if !ok {
+		return fmt.Errorf("unknown preset %q (available: %s)", runnerPreset, strings.Join(presetNames(), ", "))
+	}
+	if runnerOS != "linux" {
+		return fmt.Errorf("preset %s requires --os linux", runnerPreset)
+	}
+
+	if instanceType == "" {
+		instanceType = preset.InstanceType
+	}
+	if preset.RequireKVM && !isKVMCapable(instanceType) {
+		return fmt.Errorf("preset %s needs KVM, which EC2 only exposes on metal instance types (e.g. %s), not %s",
+			runnerPreset, preset.InstanceType, instanceType)
+	}
+	if preset.RequireGPU && !isGPUCapable(instanceType) {
+		return fmt.Errorf("preset %s needs an NVIDIA GPU instance type (e.g. %s), not %s",
+			runnerPreset, preset.InstanceType, instanceType)
+	}
+	if len(volumeSpecs) == 0 {
+		volumeSpecs = preset.Volumes
+	}
+	if sysctlProfiles == "" {
+		sysctlProfiles = preset.SysctlProfiles
+	}
+	if swapSize == "" {
+		swapSize = preset.SwapSize
+	}
+	for _, label := range preset.Labels {
+		if !strings.Contains(","+runnerLabels+",", ","+label+",") {
+			runnerLabels += "," + label
+		}
+	}
+	return nil
+}
+
+// validateNestedVirt restricts the instance type to ones that can run VMs when
+// --require-nested-virt is set, defaulting it to a metal type
+func validateNestedVirt() error {
+	if !requireNestedVirt {
+		return nil
+	}
+	if instanceType == "" {
+		instanceType = "c5.metal"
+	}
+	if !isKVMCapable(instanceType) {
+		return fmt.Errorf("require-nested-virt needs a metal instance type (e.g. c5.metal, m5zn.metal), not %s",
+			instanceType)
+	}
+	if runnerOS == "linux" && !strings.Contains(","+runnerLabels+",", ",kvm,") {
+		runnerLabels += ",kvm"
+	}
+	return nil
+}
+
+// presetUserData returns the bootstrap lines of the selected preset and of
+// --require-nested-virt
+func presetUserData() []string {
+	preset, ok := presets[runnerPreset]
+	lines := []string{}
+	if requireNestedVirt || (ok && preset.RequireKVM) {
+		lines = append(lines, kvmUserData()...)
+	}
+	if requireNestedVirt {
+		lines = append(lines, libvirtUserData()...)
+	}
+	if ok && preset.UserData != nil {
+		lines = append(lines, preset.UserData()...)
+	}
+	return lines
+}
+
+func init() {
+	createCmd.Flags().StringVar(&runnerPreset, "preset", "",
+		fmt.Sprintf("Workload preset setting instance type, labels, volumes and bootstrap (%s)",
+			strings.Join(presetNames(), ", ")))
+	createCmd.Flags().BoolVar(&requireNestedVirt, "require-nested-virt", false,
+		"Require an instance type that can run VMs (metal) and set up KVM and libvirt")
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var (
+	probe             bool
+	probeInstanceType string
+	probeTimeout      time.Duration
+)
+
+// Markers the probe user data prints to the serial console when it finishes
+const (
+	probeOKMarker     = "GH-WORKFLOW-PROBE: OK"
+	probeFailedMarker = "GH-WORKFLOW-PROBE: FAILED"
+)
+
+// probeLogLines is how many lines of the probe's console log a failure includes
+const probeLogLines = 40
+
+// generateProbeUserData creates the user data of a probe instance: the
+// pre-runner script, GitHub connectivity and the runner download, without
+// registering a runner. It prints a marker to the console when done.
+func generateProbeUserData(preRunnerScript, arch string) string {
+	lines := []string{
+		"#!/bin/bash",
+		"exec > >(tee /var/log/user-data.log|logger -t user-data -s 2>/dev/console) 2>&1",
+		"echo 'Starting gh-workflow probe...'",
+		"# Safety net in case the probe is never terminated",
+		"shutdown -h +30",
+		fmt.Sprintf("trap 'echo \"%s at line $LINENO\"; exit 1' ERR", probeFailedMarker),
+		"set -E",
+		"mkdir -p /tmp/probe && cd /tmp/probe",
+		fmt.Sprintf(`echo "%s" > pre-runner-script.sh`,
+			strings.ReplaceAll(resolvePreRunnerScript(preRunnerScript), `"`, `\"`)),
+		"source pre-runner-script.sh",
+		"echo 'Checking GitHub connectivity...'",
+		fmt.Sprintf("curl -fsS -o /dev/null %s", githubAPIURL()),
+	}
+	if !useBakedAMI {
+		lines = append(lines,
+			fmt.Sprintf("ARCH=%s", arch),
+			"echo 'Checking the runner download...'",
+			fmt.Sprintf(
+				"curl -fsSL -r 0-1023 -o /dev/null https://github.com/actions/runner/releases/download/v%[1]s/actions-runner-linux-${ARCH}-%[1]s.tar.gz",
+				runnerVersion,
+			),
+		)
+	}
+	lines = append(lines, fmt.Sprintf("echo '%s'", probeOKMarker))
+	return strings.Join(lines, "\n")
+}
+
+// probeInstanceTypeFor returns the probe instance type matching the AMI's architecture
+func probeInstanceTypeFor(svc *ec2.Client, imageID string) (string, error) {
+	if probeInstanceType != "" {
+		return probeInstanceType, nil
+	}
+	result, err := svc.DescribeImages(context.TODO(), &ec2.DescribeImagesInput{
+		ImageIds:          []string{imageID},
+		IncludeDeprecated: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe AMI %s: %w", imageID, classifyAWSError(err))
+	}
+	if len(result.Images) > 0 && result.Images[0].Architecture == types.ArchitectureValuesArm64 {
+		return "t4g.micro", nil
+	}
+	return "t3.micro", nil
+}
+
+// tailLines returns the last n lines of text
+func tailLines(text string, n int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runProbe boots a micro instance with only the bootstrap prologue and waits
+// for it to report success on its serial console, so a broken AMI, subnet or
+// pre-runner script fails before the full-size instance is launched. The probe
+// instance is always terminated.
+func runProbe(svc *ec2.Client, imageID, subnetID, securityGroupID, preRunnerScript, arch string,
+	manifest RunManifest) error {
+	probeType, err := probeInstanceTypeFor(svc, imageID)
+	if err != nil {
+		return err
+	}
+
+	if outputFormat != "github-actions" {
+		fmt.Printf("🩺 Probing AMI %s with a %s instance...\n", imageID, probeType)
+	}
+	emitEvent("probe.started", map[string]any{"image_id": imageID, "instance_type": probeType})
+
+	userData := generateProbeUserData(preRunnerScript, arch)
+	runInput := &ec2.RunInstancesInput{
+		ImageId:                           aws.String(imageID),
+		MinCount:                          aws.Int32(1),
+		MaxCount:                          aws.Int32(1),
+		InstanceType:                      types.InstanceType(probeType),
+		SubnetId:                          aws.String(subnetID),
+		SecurityGroupIds:                  []string{securityGroupID},
+		UserData:                          aws.String(base64.StdEncoding.EncodeToString([]byte(userData))),
+		InstanceInitiatedShutdownBehavior: types.ShutdownBehaviorTerminate,
+		ClientToken:                       aws.String(manifest.CorrelationID + "-probe"),
+		TagSpecifications: []types.TagSpecification{
+			{
+				ResourceType: types.ResourceTypeInstance,
+				Tags: []types.Tag{
+					{
+						Key:   aws.String("Name"),
+						Value: aws.String("GitHub Actions Runner Probe - " + manifest.Repository),
+					},
+					{Key: aws.String("Purpose"), Value: aws.String("GitHub Actions")},
+					{Key: aws.String("Repository"), Value: aws.String(manifest.Repository)},
+					{Key: aws.String("ProbeFor"), Value: aws.String(manifest.CorrelationID)},
+				},
+			},
+		},
+	}
+	// Accounts that require encrypted volumes would reject an unencrypted probe
+	if err := applyEncryption(svc, runInput); err != nil {
+		return err
+	}
+	result, err := svc.RunInstances(context.TODO(), runInput)
+	if err != nil {
+		return fmt.Errorf("failed to launch probe instance: %w", classifyAWSError(err))
+	}
+	if len(result.Instances) == 0 {
+		return fmt.Errorf("failed to launch probe instance: no instance returned")
+	}
+	probeID := aws.ToString(result.Instances[0].InstanceId)
+
+	defer func() {
+		_, err := svc.TerminateInstances(context.TODO(), &ec2.TerminateInstancesInput{InstanceIds: []string{probeID}})
+		if err != nil && outputFormat != "github-actions" {
+			fmt.Printf("⚠️  Failed to terminate probe instance %s: %v\n", probeID, classifyAWSError(err))
+		}
+	}()
+
+	console, err := waitForProbe(svc, probeID)
+	if err != nil {
+		emitEvent("probe.failed", map[string]any{"instance_id": probeID, "reason": err.Error()})
+		if console != "" {
+			return fmt.Errorf("%v; probe log:\n%s", err, tailLines(console, probeLogLines))
+		}
+		return err
+	}
+
+	emitEvent("probe.passed", map[string]any{"instance_id": probeID})
+	if outputFormat != "github-actions" {
+		fmt.Printf("✅ Probe instance %s bootstrapped successfully\n", probeID)
+	}
+	return nil
+}
+
+// waitForProbe polls the probe instance's serial console until it prints a
+// marker, returning the console output seen so far
+func waitForProbe(svc *ec2.Client, probeID string) (string, error) {
+	timeout := time.After(probeTimeout)
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	console := ""
+	for {
+		select {
+		case <-timeout:
+			return console, fmt.Errorf("probe instance %s did not finish within %s", probeID, probeTimeout)
+
+		case <-ticker.C:
+			output, err := svc.GetConsoleOutput(context.TODO(), &ec2.GetConsoleOutputInput{
+				InstanceId: aws.String(probeID),
+				Latest:     aws.Bool(true),
+			})
+			if err != nil {
+				err = classifyAWSError(err)
+				// A just-launched instance may not be visible to the API yet
+				if errors.Is(err, ErrNotFound) {
+					continue
+				}
+				return console, fmt.Errorf("failed to read probe console output: %w", err)
+			}
+			if decoded, err := base64.StdEncoding.DecodeString(aws.ToString(output.Output)); err == nil &&
+				len(decoded) > 0 {
+				console = string(decoded)
+			}
+
+			switch {
+			case strings.Contains(console, probeFailedMarker):
+				return console, fmt.Errorf("probe instance %s failed to bootstrap", probeID)
+			case strings.Contains(console, probeOKMarker):
+				return console, nil
+			}
+		}
+	}
+}
+
+func init() {
+	createCmd.Flags().BoolVar(&probe, "probe", false,
+		"Boot a micro instance with the bootstrap prologue first and fail early if it does not succeed")
+	createCmd.Flags().StringVar(&probeInstanceType, "probe-instance-type", "",
+		"Instance type of the probe (default t3.micro, or t4g.micro for arm64 AMIs)")
+	createCmd.Flags().DurationVar(&probeTimeout, "probe-timeout", 10*time.Minute,
+		"How long to wait for the probe to finish bootstrapping")
+}
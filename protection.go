@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var (
+	disableAPITermination bool
+	shutdownBehavior      string
+)
+
+// validateProtectionFlags checks --disable-api-termination and
+// --instance-initiated-shutdown-behavior; EC2 allows neither termination
+// protection nor stopping on shutdown for spot instances
+func validateProtectionFlags() error {
+	if shutdownBehavior != "" && shutdownBehavior != "stop" && shutdownBehavior != "terminate" {
+		return fmt.Errorf("instance-initiated-shutdown-behavior must be 'stop' or 'terminate'")
+	}
+	if instanceMarketType == "spot" && disableAPITermination {
+		return fmt.Errorf("disable-api-termination is not supported for spot instances")
+	}
+	if instanceMarketType == "spot" && shutdownBehavior == "stop" {
+		return fmt.Errorf("instance-initiated-shutdown-behavior stop is not supported for spot instances")
+	}
+	return nil
+}
+
+// applyProtection sets termination protection and what a shutdown from within
+// the instance does; with terminate, an ephemeral runner can remove itself
+// with `shutdown -h now` once its job is done
+func applyProtection(runInput *ec2.RunInstancesInput) {
+	if disableAPITermination {
+		runInput.DisableApiTermination = aws.Bool(true)
+	}
+	if shutdownBehavior != "" {
+		runInput.InstanceInitiatedShutdownBehavior = types.ShutdownBehavior(shutdownBehavior)
+	}
+}
+
+// isTerminationProtected reports whether a TerminateInstances call failed
+// because of termination protection
+func isTerminationProtected(err error) bool {
+	return awsErrorCode(err) == "OperationNotPermitted"
+}
+
+// liftTerminationProtection turns off the termination protection of an
+// instance, so terminate --force can terminate it
+func liftTerminationProtection(svc *ec2.Client, instanceID string) error {
+	_, err := svc.ModifyInstanceAttribute(context.TODO(), &ec2.ModifyInstanceAttributeInput{
+		InstanceId:            aws.String(instanceID),
+		DisableApiTermination: &types.AttributeBooleanValue{Value: aws.Bool(false)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to lift termination protection of instance %s: %w", instanceID,
+			classifyAWSError(err))
+	}
+	emitEvent("instance.protection_lifted", map[string]any{"instance_id": instanceID})
+	if outputFormat != "github-actions" {
+		fmt.Printf("🔓 Lifted termination protection of instance %s\n", instanceID)
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().BoolVar(&disableAPITermination, "disable-api-termination", false,
+		"Enable termination protection; only terminate --force can then terminate the instance")
+	createCmd.Flags().StringVar(&shutdownBehavior, "instance-initiated-shutdown-behavior", "",
+		"What a shutdown from within the instance does: stop or terminate (default: stop, or terminate for spot)")
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	providerName    string
+	providerOptions []string
+)
+
+// defaultProvider is the built-in EC2 provider, which keeps its own code path
+const defaultProvider = "aws"
+
+// ProviderSpec describes the runner machine a provider should create
+type ProviderSpec struct {
+	Name         string            `json:"name"`
+	Repository   string            `json:"repository"`
+	Labels       string            `json:"labels"`
+	OS           string            `json:"os"`
+	Arch         string            `json:"arch"`
+	InstanceType string            `json:"instance_type,omitempty"`
+	Image        string            `json:"image,omitempty"`
+	UserData     string            `json:"user_data"`
+	Tags         map[string]string `json:"tags"`
+}
+
+// ProviderInstance is a runner machine managed by a provider
+type ProviderInstance struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name,omitempty"`
+	State     string            `json:"state,omitempty"`
+	PublicIP  string            `json:"public_ip,omitempty"`
+	PrivateIP string            `json:"private_ip,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// Provider creates, terminates and lists runner machines on a platform other than EC2
+type Provider interface {
+	Create(spec ProviderSpec) (ProviderInstance, error)
+	Terminate(id string) error
+	List() ([]ProviderInstance, error)
+}
+
+// providerFactories are the built-in providers besides aws, keyed by name;
+// each gets the --provider-opt options
+var providerFactories = map[string]func(options map[string]string) (Provider, error){}
+
+// providerFlag is a dedicated flag of a built-in provider, --<provider>-<option>,
+// which sets one of its main options; --provider-opt is left for the others
+type providerFlag struct {
+	provider string
+	option   string
+	value    string
+	check    func(value string) error
+}
+
+// providerFlags are the dedicated flags of all built-in providers
+var providerFlags []*providerFlag
+
+// addProviderFlag registers --<provider>-<option> on cmds; check, if not nil,
+// validates a value before the provider is created
+func addProviderFlag(cmds []*cobra.Command, provider, option, usage string, check func(value string) error) {
+	flag := &providerFlag{provider: provider, option: option, check: check}
+	providerFlags = append(providerFlags, flag)
+	for _, cmd := range cmds {
+		cmd.Flags().StringVar(&flag.value, provider+"-"+option, "", usage)
+	}
+}
+
+// validateProviderFlags checks that the dedicated flags given belong to the
+// selected provider and that their values are well-formed
+func validateProviderFlags() error {
+	for _, flag := range providerFlags {
+		if flag.value == "" {
+			continue
+		}
+		if flag.provider != providerName {
+			return fmt.Errorf("%s-%s needs --provider %s", flag.provider, flag.option, flag.provider)
+		}
+		if flag.check != nil {
+			if err := flag.check(flag.value); err != nil {
+				return fmt.Errorf("invalid %s-%s: %v", flag.provider, flag.option, err)
+			}
+		}
+	}
+	return nil
+}
+
+// providerOptionMap parses the --provider-opt key=value pairs and adds the
+// dedicated flags of the selected provider
+func providerOptionMap() (map[string]string, error) {
+	if err := validateProviderFlags(); err != nil {
+		return nil, err
+	}
+	options := map[string]string{}
+	for _, option := range providerOptions {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid provider-opt %q (expected key=value)", option)
+		}
+		options[key] = value
+	}
+	for _, flag := range providerFlags {
+		if flag.provider != providerName {
+			continue
+		}
+		if _, ok := options[flag.option]; ok {
+			return nil, fmt.Errorf("use --%s-%s instead of --provider-opt %s", flag.provider, flag.option, flag.option)
+		}
+		if flag.value != "" {
+			options[flag.option] = flag.value
+		}
+	}
+	return options, nil
+}
+
+// providerOption returns a provider option, falling back to an environment variable
+func providerOption(options map[string]string, key, env string) string {
+	if value := options[key]; value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
+
+// newProvider returns the provider selected with --provider: a built-in one or
+// a gh-workflow-provider-<name> plugin
+func newProvider() (Provider, error) {
+	options, err := providerOptionMap()
+	if err != nil {
+		return nil, err
+	}
+	if factory, ok := providerFactories[providerName]; ok {
+		return factory(options)
+	}
+	path, err := findPlugin(providerName)
+	if err != nil {
+		return nil, err
+	}
+	return &execProvider{path: path, options: options}, nil
+}
+
+// archFromLabels returns the runner architecture named in the labels, defaulting to x64
+func archFromLabels(labels string) string {
+	for _, label := range splitList(labels) {
+		if strings.EqualFold(label, "arm64") {
+			return "arm64"
+		}
+	}
+	return "x64"
+}
+
+// providerCreateFlags are the create flags other providers honour besides
+// their own: the runner registration, the machine and the user data
+var providerCreateFlags = map[string]bool{
+	"provider": true, "provider-opt": true, "github-token": true, "region": true,
+	"repo-owner": true, "repo-name": true, "org": true, "runner-group": true, "max-runners": true,
+	"labels": true, "runner-name": true, "run-id": true, "image-id": true, "instance-type": true, "os": true,
+	"pre-runner-script": true, "runner-version": true, "disable-runner-autoupdate": true, "use-baked-ami": true,
+	"locale": true, "timezone": true, "swap-size": true, "sysctl-profile": true, "time-sync-timeout": true,
+	"windows-containers": true, "wsl2": true,
+}
+
+// validateProviderCreateFlags rejects the create flags given on the command
+// line that only apply to EC2, such as subnets, spot and --count, rather than
+// ignoring them
+func validateProviderCreateFlags(cmd *cobra.Command) error {
+	own := map[string]bool{}
+	for _, flag := range providerFlags {
+		own[flag.provider+"-"+flag.option] = true
+	}
+	unsupported := []string{}
+	cmd.LocalNonPersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed && !providerCreateFlags[flag.Name] && !own[flag.Name] {
+			unsupported = append(unsupported, "--"+flag.Name)
+		}
+	})
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%s only apply to EC2, not --provider %s", strings.Join(unsupported, ", "), providerName)
+	}
+	return nil
+}
+
+// createWithProvider registers a runner and creates its machine with the
+// selected provider, reusing the EC2 user data generator
+func createWithProvider(cmd *cobra.Command) error {
+	if err := validateProviderCreateFlags(cmd); err != nil {
+		return err
+	}
+	if err := requireGitHubToken(); err != nil {
+		return err
+	}
+	if runnerOrg == "" && (repoOwner == "" || repoName == "") {
+		return fmt.Errorf("repo-owner and repo-name (or --org) are required")
+	}
+	if err := validateWindowsFlags(); err != nil {
+		return err
+	}
+	if err := checkReadOnly(fmt.Sprintf("create a runner with provider %s", providerName)); err != nil {
+		return err
+	}
+	provider, err := newProvider()
+	if err != nil {
+		return err
+	}
+
+	name := runnerName
+	if name == "" {
+		suffix := make([]byte, 4)
+		_, _ = rand.Read(suffix)
+		name = "gh-workflow-" + hex.EncodeToString(suffix)
+	}
+	repository := runnerScope(repoOwner, repoName)
+	if err := preflightGitHubToken(githubToken, repository); err != nil {
+		return err
+	}
+	if err := checkRunnerLimit(githubToken, repository, 1); err != nil {
+		return err
+	}
+	if err := checkRunnerGroup(githubToken, repository); err != nil {
+		return err
+	}
+
+	if outputFormat != "github-actions" {
+		fmt.Printf("🔑 Fetching GitHub runner registration token...\n")
+	}
+	emitEvent("token.requested", map[string]any{"repository": repository})
+	registrationToken, err := getGitHubRegistrationToken(githubToken, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to get GitHub registration token: %w", err)
+	}
+
+	runnerArch = archFromLabels(runnerLabels)
+	correlationID := newCorrelationID(runID)
+	tags := workflowTags(correlationID)
+	tags["Purpose"] = "GitHub Actions"
+	tags["Repository"] = repository
+	tags["Labels"] = runnerLabels
+	tags["RunnerName"] = name
+
+	if outputFormat != "github-actions" {
+		fmt.Printf("🚀 Creating runner %s with provider %s...\n", name, providerName)
+	}
+	emitEvent("instance.launching", map[string]any{"provider": providerName, "runner_name": name})
+	instance, err := provider.Create(ProviderSpec{
+		Name:         name,
+		Repository:   repository,
+		Labels:       runnerLabels,
+		OS:           runnerOS,
+		Arch:         runnerArch,
+		InstanceType: instanceType,
+		Image:        imageID,
+		UserData:     generateUserData(registrationToken, repoOwner, repoName, runnerLabels, preRunnerScript, name),
+		Tags:         tags,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create runner with provider %s: %w", providerName, err)
+	}
+	emitEvent("instance.launched", map[string]any{
+		"provider":    providerName,
+		"instance_id": instance.ID,
+		"runner_name": name,
+		"labels":      runnerLabels,
+	})
+
+	if outputFormat == "github-actions" {
+		fmt.Printf("Instance ID: %s\n", instance.ID)
+		fmt.Printf("Runner Name: %s\n", name)
+		fmt.Printf("Labels: %s\n", runnerLabels)
+		fmt.Printf("Correlation ID: %s\n", correlationID)
+		return nil
+	}
+	fmt.Printf("✅ Runner created with provider %s!\n", providerName)
+	fmt.Printf("Instance ID: %s\n", instance.ID)
+	if instance.PublicIP != "" {
+		fmt.Printf("Public IP: %s\n", instance.PublicIP)
+	}
+	if instance.PrivateIP != "" {
+		fmt.Printf("Private IP: %s\n", instance.PrivateIP)
+	}
+	fmt.Printf("Repository: %s\n", repository)
+	fmt.Printf("Runner Labels: %s\n", runnerLabels)
+	fmt.Printf("Runner Name: %s\n", name)
+	fmt.Printf("Correlation ID: %s\n", correlationID)
+	return nil
+}
+
+// terminateWithProvider deletes a runner machine with the selected provider
+func terminateWithProvider(id string) error {
+	if id == "" {
+		return fmt.Errorf("instance-id is required with --provider %s", providerName)
+	}
+	if err := checkReadOnly(fmt.Sprintf("terminate %s with provider %s", id, providerName)); err != nil {
+		return err
+	}
+	provider, err := newProvider()
+	if err != nil {
+		return err
+	}
+
+	if outputFormat != "github-actions" {
+		fmt.Printf("🛑 Terminating %s with provider %s...\n", id, providerName)
+	}
+	emitEvent("terminate.started", map[string]any{"provider": providerName, "instance_id": id})
+	if err := provider.Terminate(id); err != nil {
+		return fmt.Errorf("failed to terminate %s with provider %s: %w", id, providerName, err)
+	}
+	emitEvent("instance.terminated", map[string]any{"provider": providerName, "instance_id": id})
+	if outputFormat != "github-actions" {
+		fmt.Printf("🎉 %s has been terminated\n", id)
+	}
+	return nil
+}
+
+// listWithProvider prints the runner machines of the selected provider
+func listWithProvider() error {
+	provider, err := newProvider()
+	if err != nil {
+		return err
+	}
+	instances, err := provider.List()
+	if err != nil {
+		return fmt.Errorf("failed to list runners with provider %s: %w", providerName, err)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Name < instances[j].Name })
+
+	if outputFormat == "json" {
+		data, err := json.MarshalIndent(instances, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode instances: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATE\tREPOSITORY\tPUBLIC IP\tPRIVATE IP")
+	for _, instance := range instances {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", instance.ID, instance.Name, instance.State,
+			instance.Tags["Repository"], instance.PublicIP, instance.PrivateIP)
+	}
+	return w.Flush()
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{createCmd, terminateCmd, listCmd} {
+		cmd.Flags().StringVar(&providerName, "provider", defaultProvider,
+			"Where runners run: aws, a built-in provider or a gh-workflow-provider-<name> plugin")
+		cmd.Flags().StringArrayVar(&providerOptions, "provider-opt", nil,
+			"Provider option as key=value (repeatable), passed to the provider")
+	}
+}
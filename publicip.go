@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var (
+	associatePublicIP bool
+	eipAllocationID   string
+)
+
+// eipAllocationPattern matches an Elastic IP allocation ID
+var eipAllocationPattern = regexp.MustCompile(`^eipalloc-[0-9a-f]{8}([0-9a-f]{9})?$`)
+
+// validatePublicIPFlags checks --eip-allocation-id
+func validatePublicIPFlags() error {
+	if eipAllocationID != "" && !eipAllocationPattern.MatchString(eipAllocationID) {
+		return fmt.Errorf("eip-allocation-id %q is not an Elastic IP allocation ID (eipalloc-...)", eipAllocationID)
+	}
+	return nil
+}
+
+// applyPublicIP requests a public IPv4 address for the instance with
+// --associate-public-ip, regardless of the subnet's auto-assign setting; this
+// needs the subnet and security group on the network interface instead
+func applyPublicIP(runInput *ec2.RunInstancesInput) {
+	if !associatePublicIP {
+		return
+	}
+	runInput.NetworkInterfaces = []types.InstanceNetworkInterfaceSpecification{{
+		DeviceIndex:              aws.Int32(0),
+		SubnetId:                 runInput.SubnetId,
+		Groups:                   runInput.SecurityGroupIds,
+		AssociatePublicIpAddress: aws.Bool(true),
+		DeleteOnTermination:      aws.Bool(true),
+	}}
+	runInput.SubnetId = nil
+	runInput.SecurityGroupIds = nil
+}
+
+// associateElasticIP associates --eip-allocation-id with the running instance,
+// giving the runner a stable egress address, e.g. for firewall allowlists; the
+// association ends when the instance is terminated
+func associateElasticIP(svc *ec2.Client, instanceID string) error {
+	if eipAllocationID == "" {
+		return nil
+	}
+	result, err := svc.AssociateAddress(context.TODO(), &ec2.AssociateAddressInput{
+		AllocationId:       aws.String(eipAllocationID),
+		InstanceId:         aws.String(instanceID),
+		AllowReassociation: aws.Bool(false),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to associate Elastic IP %s: %w", eipAllocationID, classifyAWSError(err))
+	}
+	emitEvent("instance.eip_associated", map[string]any{
+		"instance_id":    instanceID,
+		"allocation_id":  eipAllocationID,
+		"association_id": aws.ToString(result.AssociationId),
+	})
+	if outputFormat != "github-actions" {
+		fmt.Printf("🌐 Associated Elastic IP %s\n", eipAllocationID)
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().BoolVar(&associatePublicIP, "associate-public-ip", false,
+		"Give the instance a public IPv4 address even if the subnet does not assign one")
+	createCmd.Flags().StringVar(&eipAllocationID, "eip-allocation-id", "",
+		"Elastic IP (eipalloc-...) to associate with the instance for a stable egress address")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+var readOnly bool
+
+// readOnlyPrefixes are the AWS operation name prefixes that never mutate resources
+var readOnlyPrefixes = []string{"Describe", "List", "Get", "Lookup", "Search", "BatchGet"}
+
+// readOnlyOperations are AWS operations without a read-only prefix that are
+// still safe, e.g. assuming the fleet roles
+var readOnlyOperations = map[string]bool{
+	"AssumeRole":                true,
+	"AssumeRoleWithWebIdentity": true,
+}
+
+// isReadOnlyOperation reports whether an AWS API operation only reads
+func isReadOnlyOperation(operation string) bool {
+	if readOnlyOperations[operation] {
+		return true
+	}
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReadOnly refuses an action when --read-only is set
+func checkReadOnly(action string) error {
+	if readOnly {
+		return fmt.Errorf("%w: %s", ErrReadOnly, action)
+	}
+	return nil
+}
+
+// addReadOnlyMiddleware refuses every mutating AWS API call before it is sent
+// when --read-only is set, whichever command or client makes it
+func addReadOnlyMiddleware(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("GhWorkflowReadOnly",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (
+			middleware.InitializeOutput, middleware.Metadata, error,
+		) {
+			operation := middleware.GetOperationName(ctx)
+			if readOnly && !isReadOnlyOperation(operation) {
+				action := fmt.Sprintf("%s %s", awsmiddleware.GetServiceID(ctx), operation)
+				return middleware.InitializeOutput{}, middleware.Metadata{}, checkReadOnly(action)
+			}
+			return next.HandleInitialize(ctx, in)
+		}), middleware.After)
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&readOnly, "read-only", false,
+		"Refuse every call that would create, change or delete AWS or GitHub resources")
+}
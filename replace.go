@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/spf13/cobra"
+)
+
+// samePool reports whether a runner instance belongs to the repository or
+// organization and labels of the create flags; create replaces an x64 or
+// arm64 label with the architecture of the instance type
+func samePool(manifest RunManifest, repository, labels string) bool {
+	return manifest.Repository == repository && archLabels(manifest.Labels, "x64") == archLabels(labels, "x64")
+}
+
+// launchReplacement launches a runner with the create flags. With --run-id
+// and --runner-name, create re-attaches to the instance it launched for them
+// before instead, which launches nothing and is an error here
+func launchReplacement() (string, error) {
+	startCreateDeadline()
+	reattachedInstanceID = ""
+	instanceID, err := createEC2Instance(githubToken, imageID, instanceType, subnetID, securityGroupID, repoOwner,
+		repoName, runnerLabels, preRunnerScript, runnerName, instanceMarketType, spotMaxPrice)
+	if err == nil && reattachedInstanceID != "" {
+		return "", fmt.Errorf("create re-attached to %s instead of launching a runner; "+
+			"use another --runner-name or --run-id", reattachedInstanceID)
+	}
+	return instanceID, err
+}
+
+// validateReplacementFlags rejects --run-id with --runner-name, with which
+// every launch of the command would re-attach to the same instance
+func validateReplacementFlags(command string) error {
+	if runID != "" && runnerName != "" {
+		return fmt.Errorf("%s can't use --runner-name within a workflow run (--run-id or GITHUB_RUN_ID), "+
+			"as create would re-attach to the instance launched for them", command)
+	}
+	return nil
+}
+
+// recordReplacement tags a retired instance with its replacement
+func recordReplacement(retiredID, replacementID string) error {
+	svc, err := createEC2Client()
+	if err != nil {
+		return err
+	}
+	_, err = svc.CreateTags(context.TODO(), &ec2.CreateTagsInput{
+		Resources: []string{retiredID},
+		Tags:      []types.Tag{{Key: aws.String(rebalanceReplacedTag), Value: aws.String(replacementID)}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to tag %s with its replacement: %w", retiredID, classifyAWSError(err))
+	}
+	return nil
+}
+
+// rebalancedRunners returns the runner instances of the repository or
+// organization and labels that retired on a rebalance recommendation
+func rebalancedRunners(repository, labels string) ([]RunManifest, error) {
+	svc, err := createEC2Client()
+	if err != nil {
+		return nil, err
+	}
+	instances, err := listRunnerInstances(svc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runner instances: %w", err)
+	}
+	rebalanced := []RunManifest{}
+	for _, instance := range instances {
+		manifest := manifestFromInstance(instance)
+		if manifest.RebalancedAt != "" && samePool(manifest, repository, labels) {
+			rebalanced = append(rebalanced, manifest)
+		}
+	}
+	return rebalanced, nil
+}
+
+// replaceRetiredRunner launches a replacement for a runner that retired on a
+// rebalance recommendation, tags the retired instance with it and terminates
+// the instance, returning the replacement. If the launch fails, the retired
+// instance stays, to be replaced by the next try; an instance already
+// replaced (ReplacedBy) is only terminated
+func replaceRetiredRunner(retired RunManifest) (string, error) {
+	replacementID := retired.ReplacedBy
+	if replacementID == "" {
+		if outputFormat != "github-actions" {
+			fmt.Printf("♻️  Replacing %s (runner %s, rebalance recommendation at %s)...\n",
+				retired.InstanceID, retired.RunnerName, retired.RebalancedAt)
+		}
+		var err error
+		if replacementID, err = launchReplacement(); err != nil {
+			return "", fmt.Errorf("failed to replace %s: %w", retired.InstanceID, err)
+		}
+		emitEvent("instance.rebalance_replaced", map[string]any{
+			"instance_id":    retired.InstanceID,
+			"replacement_id": replacementID,
+		})
+		if err := recordReplacement(retired.InstanceID, replacementID); err != nil {
+			return replacementID, fmt.Errorf("replaced %s with %s but %w", retired.InstanceID, replacementID, err)
+		}
+	} else if outputFormat != "github-actions" {
+		fmt.Printf("♻️  Terminating %s, already replaced with %s...\n", retired.InstanceID, replacementID)
+	}
+	if err := terminateEC2Instance(retired.InstanceID, false, terminationTimeout); err != nil {
+		return replacementID, fmt.Errorf("replaced %s with %s but failed to terminate it: %w",
+			retired.InstanceID, replacementID, err)
+	}
+	return replacementID, nil
+}
+
+var replaceCmd = &cobra.Command{
+	Use:   "replace",
+	Short: "Replace spot runners that retired on a rebalance recommendation",
+	Long: "Launch a runner with the create flags for every runner of the repository and labels that retired " +
+		"on a rebalance recommendation (create --spot-rebalance replace), then terminate the retired instance",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireGitHubToken(); err != nil {
+			return err
+		}
+		if err := validateCreateFlags(); err != nil {
+			return err
+		}
+		if launchCount != 1 {
+			return fmt.Errorf("replace launches one runner per retired runner; count is not supported")
+		}
+		if err := validateReplacementFlags("replace"); err != nil {
+			return err
+		}
+
+		repository := runnerScope(repoOwner, repoName)
+		rebalanced, err := rebalancedRunners(repository, runnerLabels)
+		if err != nil {
+			return err
+		}
+		if len(rebalanced) == 0 {
+			if outputFormat != "github-actions" {
+				fmt.Printf("ℹ️  No runner of %s retired on a rebalance recommendation\n", repository)
+			}
+			return nil
+		}
+
+		var errs []error
+		for _, retired := range rebalanced {
+			_, err := replaceRetiredRunner(retired)
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replaceCmd)
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/spf13/cobra"
+)
+
+var reportSince time.Duration
+
+// SpotSavings compares what a spot runner was charged with what the same
+// instance-hours would have cost on demand
+type SpotSavings struct {
+	Account          string    `json:"account"`
+	InstanceID       string    `json:"instance_id"`
+	InstanceType     string    `json:"instance_type"`
+	AvailabilityZone string    `json:"availability_zone"`
+	Repository       string    `json:"repository,omitempty"`
+	LaunchTime       time.Time `json:"launch_time"`
+	EndTime          time.Time `json:"end_time"`
+	Running          bool      `json:"running,omitempty"`
+	Hours            float64   `json:"hours"`
+	SpotCost         float64   `json:"spot_cost_usd"`
+	OnDemandCost     float64   `json:"on_demand_cost_usd"`
+	Savings          float64   `json:"savings_usd"`
+	SavingsPercent   float64   `json:"savings_percent"`
+}
+
+// spotSavingsColumns are the CSV columns, named like the JSON fields
+var spotSavingsColumns = []string{
+	"account", "instance_id", "instance_type", "availability_zone", "repository", "launch_time", "end_time",
+	"running", "hours", "spot_cost_usd", "on_demand_cost_usd", "savings_usd", "savings_percent",
+}
+
+// instanceEndEvents are the CloudTrail events ending a runner instance's
+// charges: a termination, or EC2 reclaiming the spot capacity
+var instanceEndEvents = []string{"TerminateInstances", "BidEvictedEvent"}
+
+// transitionTimePattern extracts the time from a state transition reason,
+// e.g. "User initiated (2026-10-15 14:03:00 GMT)"
+var transitionTimePattern = regexp.MustCompile(`\((\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) GMT\)`)
+
+// lookupEvents returns the CloudTrail events named name since start
+func lookupEvents(client *cloudtrail.Client, name string, start time.Time) ([]types.Event, error) {
+	paginator := cloudtrail.NewLookupEventsPaginator(client, &cloudtrail.LookupEventsInput{
+		StartTime: aws.Time(start),
+		EndTime:   aws.Time(time.Now()),
+		LookupAttributes: []types.LookupAttribute{
+			{AttributeKey: types.LookupAttributeKeyEventName, AttributeValue: aws.String(name)},
+		},
+	})
+	events := []types.Event{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up %s events: %w", name, classifyAWSError(err))
+		}
+		events = append(events, page.Events...)
+	}
+	return events, nil
+}
+
+// spotLaunches returns the spot runners launched since start, from the
+// RunInstances calls in CloudTrail, without their end time and charges
+func spotLaunches(client *cloudtrail.Client, account string, start time.Time) ([]SpotSavings, error) {
+	events, err := lookupEvents(client, "RunInstances", start)
+	if err != nil {
+		return nil, err
+	}
+	launches := []SpotSavings{}
+	for _, event := range events {
+		var detail cloudTrailRunInstances
+		if err := json.Unmarshal([]byte(aws.ToString(event.CloudTrailEvent)), &detail); err != nil {
+			return nil, fmt.Errorf("failed to parse CloudTrail event: %v", err)
+		}
+		tags := map[string]string{}
+		for _, spec := range detail.RequestParameters.TagSpecificationSet.Items {
+			for _, tag := range spec.Tags {
+				tags[tag.Key] = tag.Value
+			}
+		}
+		// The on-demand fallback launches with the tag switched to on-demand
+		if tags["Purpose"] != "GitHub Actions" || tags["InstanceMarketType"] != "spot" {
+			continue
+		}
+		for _, item := range detail.ResponseElements.InstancesSet.Items {
+			launches = append(launches, SpotSavings{
+				Account:          account,
+				InstanceID:       item.InstanceID,
+				InstanceType:     detail.RequestParameters.InstanceType,
+				AvailabilityZone: item.Placement.AvailabilityZone,
+				Repository:       tags["Repository"],
+				LaunchTime:       aws.ToTime(event.EventTime).UTC(),
+			})
+		}
+	}
+	return launches, nil
+}
+
+// instanceEnds returns when each instance terminated or was reclaimed since
+// start, from CloudTrail
+func instanceEnds(client *cloudtrail.Client, start time.Time) (map[string]time.Time, error) {
+	ends := map[string]time.Time{}
+	for _, name := range instanceEndEvents {
+		events, err := lookupEvents(client, name, start)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			// A failed call is recorded with its error code and ended nothing
+			var detail struct {
+				ErrorCode string `json:"errorCode"`
+			}
+			if err := json.Unmarshal([]byte(aws.ToString(event.CloudTrailEvent)), &detail); err == nil &&
+				detail.ErrorCode != "" {
+				continue
+			}
+			for _, resource := range event.Resources {
+				if aws.ToString(resource.ResourceType) != "AWS::EC2::Instance" {
+					continue
+				}
+				id, at := aws.ToString(resource.ResourceName), aws.ToTime(event.EventTime).UTC()
+				if end, ok := ends[id]; !ok || at.Before(end) {
+					ends[id] = at
+				}
+			}
+		}
+	}
+	return ends, nil
+}
+
+// liveInstanceEnds fills in the end time of the launches CloudTrail has none
+// for from DescribeInstances: now for a live instance, or else the time of its
+// last state transition. Terminated instances stay visible for about an hour
+func liveInstanceEnds(svc *ec2.Client, launches []SpotSavings) error {
+	pending := map[string]*SpotSavings{}
+	ids := []string{}
+	for i := range launches {
+		if launches[i].EndTime.IsZero() {
+			pending[launches[i].InstanceID] = &launches[i]
+			ids = append(ids, launches[i].InstanceID)
+		}
+	}
+	// A filter takes at most 200 values
+	for len(ids) > 0 {
+		batch := ids[:min(len(ids), 200)]
+		ids = ids[len(batch):]
+		paginator := ec2.NewDescribeInstancesPaginator(svc, &ec2.DescribeInstancesInput{
+			Filters: []ec2types.Filter{{Name: aws.String("instance-id"), Values: batch}},
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(context.TODO())
+			if err != nil {
+				return fmt.Errorf("failed to describe instances: %w", classifyAWSError(err))
+			}
+			for _, reservation := range page.Reservations {
+				for _, instance := range reservation.Instances {
+					launch := pending[aws.ToString(instance.InstanceId)]
+					if launch == nil || instance.State == nil {
+						continue
+					}
+					switch instance.State.Name {
+					case ec2types.InstanceStateNameTerminated, ec2types.InstanceStateNameStopped:
+						match := transitionTimePattern.FindStringSubmatch(aws.ToString(instance.StateTransitionReason))
+						if match == nil {
+							continue
+						}
+						if end, err := time.Parse(time.DateTime, match[1]); err == nil {
+							launch.EndTime = end
+						}
+					default:
+						launch.EndTime = time.Now().UTC()
+						launch.Running = true
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// spotCharge returns what a spot instance was charged from launch until end:
+// the price history of its pool integrated over the time, as spot instances
+// are billed per second at the price in effect
+func spotCharge(history []ec2types.SpotPrice, launch, end time.Time) float64 {
+	charge := 0.0
+	for i, entry := range history {
+		price, err := strconv.ParseFloat(aws.ToString(entry.SpotPrice), 64)
+		if err != nil {
+			continue
+		}
+		// A price holds from its change until the next one; the first covers the start
+		from, until := aws.ToTime(entry.Timestamp), end
+		if i == 0 || from.Before(launch) {
+			from = launch
+		}
+		if i+1 < len(history) {
+			if next := aws.ToTime(history[i+1].Timestamp); next.Before(until) {
+				until = next
+			}
+		}
+		if hours := until.Sub(from).Hours(); hours > 0 {
+			charge += price * hours
+		}
+	}
+	return charge
+}
+
+// roundUSD rounds a dollar amount to a millionth, the precision of spot prices
+func roundUSD(amount float64) float64 {
+	return math.Round(amount*1e6) / 1e6
+}
+
+// spotSavings reports the spot runners one account launched since start
+func spotSavings(target fleetTarget, start time.Time) ([]SpotSavings, error) {
+	client := cloudtrail.NewFromConfig(target.Config)
+	launches, err := spotLaunches(client, target.Account, start)
+	if err != nil || len(launches) == 0 {
+		return nil, err
+	}
+	ends, err := instanceEnds(client, start)
+	if err != nil {
+		return nil, err
+	}
+	instanceTypes := []string{}
+	for i := range launches {
+		launches[i].EndTime = ends[launches[i].InstanceID]
+		if !slices.Contains(instanceTypes, launches[i].InstanceType) {
+			instanceTypes = append(instanceTypes, launches[i].InstanceType)
+		}
+	}
+	svc := ec2.NewFromConfig(target.Config)
+	if err := liveInstanceEnds(svc, launches); err != nil {
+		return nil, err
+	}
+	// onDemandPrice only knows Linux prices
+	pools, err := spotPriceHistory(svc, instanceTypes, "Linux/UNIX", start)
+	if err != nil {
+		return nil, err
+	}
+
+	report := []SpotSavings{}
+	var errs []error
+	for _, launch := range launches {
+		if launch.EndTime.IsZero() {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipping %s: no termination found, and it is no longer visible in EC2\n",
+				launch.InstanceID)
+			continue
+		}
+		history := pools[[2]string{launch.InstanceType, launch.AvailabilityZone}]
+		if len(history) == 0 {
+			errs = append(errs, fmt.Errorf("account %s: %s: %w: no spot price history for %s in %s",
+				target.Account, launch.InstanceID, ErrNotFound, launch.InstanceType, launch.AvailabilityZone))
+			continue
+		}
+		price, err := onDemandPrice(target.Config, launch.InstanceType, target.Config.Region)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("account %s: %s: %w", target.Account, launch.InstanceID, err))
+			continue
+		}
+		hours := launch.EndTime.Sub(launch.LaunchTime).Hours()
+		launch.Hours = math.Round(hours*1e4) / 1e4
+		launch.SpotCost = roundUSD(spotCharge(history, launch.LaunchTime, launch.EndTime))
+		launch.OnDemandCost = roundUSD(price * hours)
+		launch.Savings = roundUSD(launch.OnDemandCost - launch.SpotCost)
+		if launch.OnDemandCost > 0 {
+			launch.SavingsPercent = math.Round(launch.Savings/launch.OnDemandCost*1000) / 10
+		}
+		report = append(report, launch)
+	}
+	return report, errors.Join(errs...)
+}
+
+// writeSpotSavingsCSV writes the report as CSV with a header row
+func writeSpotSavingsCSV(report []SpotSavings) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write(spotSavingsColumns); err != nil {
+		return err
+	}
+	float := func(value float64) string { return strconv.FormatFloat(value, 'f', -1, 64) }
+	for _, row := range report {
+		if err := w.Write([]string{
+			row.Account, row.InstanceID, row.InstanceType, row.AvailabilityZone, row.Repository,
+			row.LaunchTime.Format(time.RFC3339), row.EndTime.Format(time.RFC3339), strconv.FormatBool(row.Running),
+			float(row.Hours), float(row.SpotCost), float(row.OnDemandCost), float(row.Savings),
+			float(row.SavingsPercent),
+		}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report on runner usage",
+	Long:  "Report on the runners launched over a time window, e.g. for dashboards",
+}
+
+var reportSpotSavingsCmd = &cobra.Command{
+	Use:   "spot-savings",
+	Short: "Compare spot runner charges to on-demand cost",
+	Long: "Compare what the spot runners launched over a time window were charged, from their instance-hours " +
+		"and the spot price history, with what the same hours would have cost on demand",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if reportSince <= 0 || reportSince > 90*24*time.Hour {
+			return fmt.Errorf("since must be between 0 and 90 days (CloudTrail event history retention)")
+		}
+		targets, err := fleetTargets(cmd)
+		if err != nil {
+			return err
+		}
+
+		start := time.Now().Add(-reportSince)
+		report := []SpotSavings{}
+		var errs []error
+		for _, target := range targets {
+			rows, err := spotSavings(target, start)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			report = append(report, rows...)
+		}
+		sort.SliceStable(report, func(i, j int) bool { return report[i].LaunchTime.Before(report[j].LaunchTime) })
+
+		switch outputFormat {
+		case "json":
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode spot savings: %v", err)
+			}
+			fmt.Println(string(data))
+			return errors.Join(errs...)
+		case "csv":
+			if err := writeSpotSavingsCSV(report); err != nil {
+				return fmt.Errorf("failed to write spot savings: %v", err)
+			}
+			return errors.Join(errs...)
+		}
+
+		if len(report) == 0 {
+			fmt.Printf("ℹ️  No spot runners launched in the last %s\n", reportSince)
+			return errors.Join(errs...)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tINSTANCE ID\tTYPE\tZONE\tREPOSITORY\tLAUNCHED\tHOURS\tSPOT ($)\tON-DEMAND ($)\tSAVED")
+		spot, onDemand := 0.0, 0.0
+		for _, row := range report {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%.1f\t%.2f\t%.2f\t%.0f%%\n",
+				row.Account, row.InstanceID, row.InstanceType, row.AvailabilityZone, row.Repository,
+				formatTime(row.LaunchTime), row.Hours, row.SpotCost, row.OnDemandCost, row.SavingsPercent)
+			spot += row.SpotCost
+			onDemand += row.OnDemandCost
+		}
+		w.Flush()
+		saved := 0.0
+		if onDemand > 0 {
+			saved = (onDemand - spot) / onDemand * 100
+		}
+		fmt.Printf("💰 %d spot runner(s): $%.2f, $%.2f on demand, saved $%.2f (%.0f%%)\n",
+			len(report), spot, onDemand, onDemand-spot, saved)
+
+		return errors.Join(errs...)
+	},
+}
+
+func init() {
+	addFleetFlags(reportSpotSavingsCmd)
+	reportSpotSavingsCmd.Flags().
+		DurationVar(&reportSince, "since", 7*24*time.Hour, "Report on the runners launched within this window")
+	reportSpotSavingsCmd.Flags().
+		StringVar(&outputFormat, "output-format", "", "Output format (json or csv for machine-readable output)")
+
+	reportCmd.AddCommand(reportSpotSavingsCmd)
+	rootCmd.AddCommand(reportCmd)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	manifestPath        string
+	registrationWait    time.Duration
+	resumeCorrelationID string
+)
+
+// GitHubRunner is a self-hosted runner as returned by the GitHub API
+type GitHubRunner struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Busy   bool   `json:"busy"`
+}
+
+// findRepoRunner returns the self-hosted runner with the given name of a
+// repository ("<owner>/<repo>") or organization, or nil
+func findRepoRunner(githubToken, repository, runnerName string) (*GitHubRunner, error) {
+	path := fmt.Sprintf("%s?name=%s", runnersAPIPath(repository), url.QueryEscape(runnerName))
+	body, err := githubAPIRequest("GET", path, githubToken, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Runners []GitHubRunner `json:"runners"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	for _, runner := range list.Runners {
+		if runner.Name == runnerName {
+			return &runner, nil
+		}
+	}
+	return nil, nil
+}
+
+// deregisterRunner removes the self-hosted runner with the given name from a
+// repository or organization, so terminated instances don't leave offline
+// runners behind; a runner that is not registered is not an error
+func deregisterRunner(githubToken, repository, runnerName string) error {
+	runner, err := findRepoRunner(githubToken, repository, runnerName)
+	if err != nil {
+		return err
+	}
+	if runner == nil {
+		return nil
+	}
+	path := fmt.Sprintf("%s/%d", runnersAPIPath(repository), runner.ID)
+	if _, err := githubAPIRequest("DELETE", path, githubToken, nil, http.StatusNoContent); err != nil {
+		return err
+	}
+	emitEvent("runner.deregistered", map[string]any{"runner_name": runnerName, "runner_id": runner.ID})
+	if outputFormat != "github-actions" {
+		fmt.Printf("🗑️  Removed runner %s from %s\n", runnerName, repository)
+	}
+	return nil
+}
+
+// waitForRunnerRegistration polls GitHub until the runner is registered and
+// online, failing early with the reported reason when the bootstrap of the
+// instance fails
+func waitForRunnerRegistration(svc *ec2.Client, instanceID, githubToken, repository, runnerName string,
+	timeout time.Duration) error {
+	if githubToken == "" {
+		return fmt.Errorf("github-token is required to wait for runner registration")
+	}
+	if runnerName == "" {
+		return fmt.Errorf("instance %s has no runner name to wait for; its runner is named after its host",
+			instanceID)
+	}
+	if outputFormat != "github-actions" {
+		fmt.Printf("⏳ Waiting for runner %s to register with GitHub...\n", runnerName)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		runner, err := findRepoRunner(githubToken, repository, runnerName)
+		if err != nil {
+			return fmt.Errorf("failed to check runner registration: %w", err)
+		}
+		if runner != nil && runner.Status == "online" {
+			emitEvent("runner.registered", map[string]any{"runner_name": runnerName, "runner_id": runner.ID})
+			if outputFormat != "github-actions" {
+				fmt.Printf("✅ Runner %s is online\n", runnerName)
+			}
+			return nil
+		}
+		// Reading the status is best effort; a failed call only means another round of polling
+		if summary, err := bootstrapFailure(svc, instanceID); err == nil && summary != "" {
+			emitEvent("runner.bootstrap_failed", map[string]any{
+				"instance_id": instanceID, "runner_name": runnerName, "error": summary,
+			})
+			return fmt.Errorf("its bootstrap failed: %s", summary)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: runner %s did not come online within %s", ErrDeadline, runnerName,
+				timeout.Round(time.Second))
+		}
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// findInstanceByCorrelationID returns the instance launched with the given
+// correlation ID (also used as the RunInstances idempotency token), or nil
+func findInstanceByCorrelationID(svc *ec2.Client, correlationID string) (*types.Instance, error) {
+	result, err := svc.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("tag:CorrelationId"), Values: []string{correlationID}},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []string{"pending", "running", "stopping", "stopped"},
+			},
+		},
+	})
+	if err != nil {
+		return nil, classifyAWSError(err)
+	}
+	for _, reservation := range result.Reservations {
+		if len(reservation.Instances) > 0 {
+			return &reservation.Instances[0], nil
+		}
+	}
+	return nil, nil
+}
+
+// checkUnfinishedManifest refuses to start a new launch over a manifest whose
+// create was interrupted, so a retried CI step resumes instead of launching twice
+func checkUnfinishedManifest(path string) error {
+	if path == "" {
+		return nil
+	}
+	manifest, err := loadManifest(path)
+	if err != nil {
+		// A missing or unreadable manifest is simply overwritten
+		return nil
+	}
+	if manifest.Phase != "" && manifest.Phase != phaseCompleted {
+		return fmt.Errorf("manifest %s records an unfinished create (phase %s, correlation ID %s); "+
+			"run `gh-workflow resume --manifest %s` or remove the file",
+			path, manifest.Phase, manifest.CorrelationID, path)
+	}
+	return nil
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume an interrupted create",
+	Long: "Re-attach to the instance launched by an interrupted create, identified by its manifest file " +
+		"or correlation ID, then finish waiting and output generation without launching again. " +
+		"With --instance-id, re-register the runner of a persistent spot instance EC2 stopped",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		given := 0
+		for _, value := range []string{manifestPath, resumeCorrelationID, resumeInstanceID} {
+			if value != "" {
+				given++
+			}
+		}
+		if given != 1 {
+			return fmt.Errorf("exactly one of manifest, correlation-id or instance-id is required")
+		}
+		if outputFormat != "" && outputFormat != "github-actions" {
+			return fmt.Errorf("output-format must be 'github-actions' or empty")
+		}
+
+		manifest := RunManifest{CorrelationID: resumeCorrelationID}
+		if manifestPath != "" {
+			var err error
+			if manifest, err = loadManifest(manifestPath); err != nil {
+				return err
+			}
+			if manifest.Phase == phaseCompleted {
+				fmt.Printf("ℹ️  Create already completed for instance %s\n", manifest.InstanceID)
+				return nil
+			}
+		}
+
+		svc, err := createEC2Client()
+		if err != nil {
+			return err
+		}
+		if resumeInstanceID != "" {
+			return resumeSpotInstance(svc, resumeInstanceID)
+		}
+
+		if manifest.InstanceID == "" {
+			if manifest.CorrelationID == "" {
+				return fmt.Errorf("manifest has neither an instance ID nor a correlation ID")
+			}
+			instance, err := findInstanceByCorrelationID(svc, manifest.CorrelationID)
+			if err != nil {
+				return fmt.Errorf("failed to look up instance: %w", err)
+			}
+			if instance == nil {
+				// Nothing to resume: drop the manifest so create doesn't refuse to run again
+				if manifestPath != "" {
+					if err := os.Remove(manifestPath); err != nil {
+						return fmt.Errorf("%w: no live instance was launched with correlation ID %s; "+
+							"remove %s and run create again", ErrNotFound, manifest.CorrelationID, manifestPath)
+					}
+					return fmt.Errorf("%w: no live instance was launched with correlation ID %s; "+
+						"removed manifest %s, run create again", ErrNotFound, manifest.CorrelationID, manifestPath)
+				}
+				return fmt.Errorf("%w: no live instance was launched with correlation ID %s; run create again",
+					ErrNotFound, manifest.CorrelationID)
+			}
+
+			found := manifestFromInstance(*instance)
+			found.SecurityGroupID = firstNonEmpty(manifest.SecurityGroupID, found.SecurityGroupID)
+			manifest = found
+		}
+
+		emitEvent("create.resumed", map[string]any{
+			"instance_id":    manifest.InstanceID,
+			"correlation_id": manifest.CorrelationID,
+		})
+		if outputFormat != "github-actions" {
+			fmt.Printf("🔁 Resuming create for instance %s\n", manifest.InstanceID)
+		}
+		return finishCreate(svc, &manifest, githubToken)
+	},
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// validateRegistrationWaitFlags checks that --wait-for-registration has a
+// runner name to look the runner up by: without --runner-name, the user data
+// names the runner after its host, which is only known on the instance
+func validateRegistrationWaitFlags() error {
+	if registrationWait > 0 && runnerName == "" {
+		return fmt.Errorf("wait-for-registration requires --runner-name, as the runner is otherwise named " +
+			"after its host")
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().StringVar(&manifestPath, "manifest", "",
+		"Write the run manifest to this file as create progresses, for use with resume")
+	createCmd.Flags().DurationVar(&registrationWait, "wait-for-registration", 0,
+		"Wait up to this long for the runner to come online in GitHub (0 disables; needs --runner-name)")
+
+	resumeCmd.Flags().StringVar(&manifestPath, "manifest", "", "Manifest file written by create --manifest")
+	resumeCmd.Flags().StringVar(&resumeCorrelationID, "correlation-id", "",
+		"Correlation ID (idempotency key) of the interrupted launch")
+	resumeCmd.Flags().DurationVar(&registrationWait, "wait-for-registration", 0,
+		"Wait up to this long for the runner to come online in GitHub (0 disables)")
+	resumeCmd.Flags().StringVar(&githubToken, "github-token", "",
+		"GitHub personal access token (needed with wait-for-registration)")
+	resumeCmd.Flags().
+		StringVar(&outputFormat, "output-format", "", "Output format (github-actions for workflow outputs)")
+
+	rootCmd.AddCommand(resumeCmd)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	runWorkflow    string
+	runRef         string
+	runInputs      []string
+	runRunnerInput string
+	runTimeout     time.Duration
+)
+
+// WorkflowRun is a GitHub Actions workflow run as returned by the GitHub API
+type WorkflowRun struct {
+	ID         int64     `json:"id"`
+	Status     string    `json:"status"`
+	Conclusion string    `json:"conclusion"`
+	HTMLURL    string    `json:"html_url"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// dispatchWorkflow triggers a workflow_dispatch event for a workflow file name or ID
+func dispatchWorkflow(githubToken, repository, workflow, ref string, inputs map[string]string) error {
+	path := fmt.Sprintf("/repos/%s/actions/workflows/%s/dispatches", repository, url.PathEscape(workflow))
+	_, err := githubAPIRequest("POST", path, githubToken, map[string]any{"ref": ref, "inputs": inputs},
+		http.StatusNoContent)
+	return err
+}
+
+// runHasLabel reports whether any job of a workflow run targets the given runner label
+func runHasLabel(githubToken, repository string, runID int64, label string) (bool, error) {
+	body, err := githubAPIRequest("GET", fmt.Sprintf("/repos/%s/actions/runs/%d/jobs", repository, runID),
+		githubToken, nil, http.StatusOK)
+	if err != nil {
+		return false, err
+	}
+
+	var list struct {
+		Jobs []struct {
+			Labels []string `json:"labels"`
+		} `json:"jobs"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return false, fmt.Errorf("failed to parse response: %v", err)
+	}
+	for _, job := range list.Jobs {
+		for _, jobLabel := range job.Labels {
+			if jobLabel == label {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// findDispatchedRun returns the workflow_dispatch run created since the
+// dispatch whose jobs target the runner label, or nil if it doesn't exist yet
+func findDispatchedRun(githubToken, repository, workflow, ref, label string, since time.Time) (*WorkflowRun, error) {
+	query := url.Values{
+		"event":   {"workflow_dispatch"},
+		"branch":  {ref},
+		"created": {">=" + since.Add(-time.Minute).UTC().Format(time.RFC3339)},
+	}
+	body, err := githubAPIRequest("GET",
+		fmt.Sprintf("/repos/%s/actions/workflows/%s/runs?%s", repository, url.PathEscape(workflow), query.Encode()),
+		githubToken, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		WorkflowRuns []WorkflowRun `json:"workflow_runs"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	for _, run := range list.WorkflowRuns {
+		ours, err := runHasLabel(githubToken, repository, run.ID, label)
+		if err != nil {
+			return nil, err
+		}
+		if ours {
+			return &run, nil
+		}
+	}
+	return nil, nil
+}
+
+// getWorkflowRun returns a workflow run by ID
+func getWorkflowRun(githubToken, repository string, runID int64) (*WorkflowRun, error) {
+	body, err := githubAPIRequest("GET", fmt.Sprintf("/repos/%s/actions/runs/%d", repository, runID),
+		githubToken, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	var run WorkflowRun
+	if err := json.Unmarshal(body, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	return &run, nil
+}
+
+// followWorkflowRun finds the run started by a dispatch and polls it until it
+// completes, printing status changes; on cancellation the run is cancelled too
+func followWorkflowRun(
+	ctx context.Context, githubToken, repository, workflow, ref, label string, since time.Time,
+) (*WorkflowRun, error) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	var run *WorkflowRun
+	lastStatus := ""
+	for {
+		select {
+		case <-ctx.Done():
+			if run != nil {
+				_, _ = githubAPIRequest("POST", fmt.Sprintf("/repos/%s/actions/runs/%d/cancel", repository, run.ID),
+					githubToken, nil, http.StatusAccepted)
+			}
+			return run, ctx.Err()
+		case <-ticker.C:
+		}
+
+		var err error
+		if run == nil {
+			run, err = findDispatchedRun(githubToken, repository, workflow, ref, label, since)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find the dispatched run: %w", err)
+			}
+			if run == nil {
+				continue
+			}
+			emitEvent("workflow.run_found", map[string]any{"run_id": run.ID, "url": run.HTMLURL})
+			if outputFormat == "github-actions" {
+				fmt.Printf("Run URL: %s\n", run.HTMLURL)
+			} else {
+				fmt.Printf("🔗 Workflow run: %s\n", run.HTMLURL)
+			}
+		} else if run, err = getWorkflowRun(githubToken, repository, run.ID); err != nil {
+			return nil, fmt.Errorf("failed to check the workflow run: %w", err)
+		}
+
+		if run.Status != lastStatus {
+			lastStatus = run.Status
+			emitEvent("workflow.run_status", map[string]any{"run_id": run.ID, "status": run.Status})
+			if outputFormat != "github-actions" {
+				fmt.Printf("📊 Run status: %s\n", run.Status)
+			}
+		}
+		if run.Status == "completed" {
+			return run, nil
+		}
+	}
+}
+
+// dispatchAndFollow dispatches --workflow with the given inputs and waits for
+// the run to conclude successfully
+func dispatchAndFollow(ctx context.Context, repository, label string, inputs map[string]string) error {
+	dispatched := time.Now()
+	if err := dispatchWorkflow(githubToken, repository, runWorkflow, runRef, inputs); err != nil {
+		return fmt.Errorf("failed to dispatch %s: %w", runWorkflow, err)
+	}
+	if outputFormat != "github-actions" {
+		fmt.Printf("📨 Dispatched %s on %s\n", runWorkflow, runRef)
+	}
+
+	run, err := followWorkflowRun(ctx, githubToken, repository, runWorkflow, runRef, label, dispatched)
+	if err != nil {
+		return fmt.Errorf("workflow run did not complete: %w", err)
+	}
+	emitEvent("run.completed", map[string]any{"run_id": run.ID, "conclusion": run.Conclusion})
+	if outputFormat == "github-actions" {
+		fmt.Printf("Conclusion: %s\n", run.Conclusion)
+	} else {
+		fmt.Printf("🏁 Run concluded: %s\n", run.Conclusion)
+	}
+	if run.Conclusion != "success" {
+		return fmt.Errorf("workflow run %d concluded %s", run.ID, run.Conclusion)
+	}
+	return nil
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run a workflow on a one-off runner",
+	Long: "Provision an ephemeral runner, dispatch a workflow pinned to the runner's unique label, wait for " +
+		"the run to conclude and tear the runner down again",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if runWorkflow == "" {
+			return fmt.Errorf("workflow is required")
+		}
+		if err := requireGitHubToken(); err != nil {
+			return err
+		}
+		// The runner is named after its label unless named explicitly, which
+		// also gives --wait-for-registration a name to look it up by
+		suffix := make([]byte, 4)
+		_, _ = rand.Read(suffix)
+		label := "gh-workflow-run-" + hex.EncodeToString(suffix)
+		if runnerName == "" {
+			runnerName = label
+		}
+		if err := validateCreateFlags(); err != nil {
+			return err
+		}
+		if repoOwner == "" || repoName == "" {
+			return fmt.Errorf("repo-owner and repo-name are required to dispatch the workflow")
+		}
+		if launchCount != 1 {
+			return fmt.Errorf("run provisions a single runner; count is not supported")
+		}
+
+		inputs := map[string]string{}
+		for _, input := range runInputs {
+			key, value, ok := strings.Cut(input, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid input %q (expected key=value)", input)
+			}
+			inputs[key] = value
+		}
+
+		inputs[runRunnerInput] = label
+		repository := fmt.Sprintf("%s/%s", repoOwner, repoName)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx, cancel := context.WithTimeout(ctx, runTimeout)
+		defer cancel()
+
+		emitEvent("run.started", map[string]any{"workflow": runWorkflow, "ref": runRef, "label": label})
+		if outputFormat != "github-actions" {
+			fmt.Printf("🚀 Provisioning runner %s for %s@%s...\n", label, runWorkflow, runRef)
+		}
+		startCreateDeadline()
+		instanceID, err := createEC2Instance(githubToken, imageID, instanceType, subnetID, securityGroupID,
+			repoOwner, repoName, runnerLabels+","+label, preRunnerScript, runnerName, instanceMarketType, spotMaxPrice)
+		if err == nil {
+			err = dispatchAndFollow(ctx, repository, label, inputs)
+		}
+
+		// Tear the runner down whatever happened, as long as one was launched
+		if instanceID != "" {
+			if outputFormat != "github-actions" {
+				fmt.Printf("🛑 Tearing down runner %s...\n", instanceID)
+			}
+			if termErr := terminateEC2Instance(instanceID, false, terminationTimeout); termErr != nil {
+				if err == nil {
+					return fmt.Errorf("failed to terminate runner %s: %w", instanceID, termErr)
+				}
+				return fmt.Errorf("%w (and failed to terminate runner %s: %v)", err, instanceID, termErr)
+			}
+		}
+		return err
+	},
+}
+
+func init() {
+	runCmd.Flags().StringVar(&runWorkflow, "workflow", "", "Workflow file name (e.g. build.yml) or ID to dispatch")
+	runCmd.Flags().StringVar(&runRef, "ref", "main", "Git ref to run the workflow on")
+	runCmd.Flags().StringArrayVar(&runInputs, "input", nil, "Workflow input as key=value (repeatable)")
+	runCmd.Flags().StringVar(&runRunnerInput, "runner-input", "runner",
+		"Workflow input receiving the runner's unique label (use it in runs-on)")
+	runCmd.Flags().DurationVar(&runTimeout, "run-timeout", 2*time.Hour, "Maximum time to wait for the run to conclude")
+
+	rootCmd.AddCommand(runCmd)
+}
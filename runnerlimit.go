@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+var maxRunners int
+
+// githubMaxRunners is GitHub's limit of self-hosted runners per repository,
+// organization or runner group
+const githubMaxRunners = 10000
+
+// runnerLimitWarnPercent is the share of the limit above which create warns
+const runnerLimitWarnPercent = 90
+
+// countRunners returns how many self-hosted runners are registered with a
+// repository ("<owner>/<repo>") or organization
+func countRunners(githubToken, scope string) (int, error) {
+	body, err := githubAPIRequest("GET", runnersAPIPath(scope)+"?per_page=1", githubToken, nil, http.StatusOK)
+	if err != nil {
+		return 0, err
+	}
+	var list struct {
+		TotalCount int `json:"total_count"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %v", err)
+	}
+	return list.TotalCount, nil
+}
+
+// checkRunnerLimit refuses to launch the given number of runners when they
+// would take the scope past --max-runners, and warns when it is getting close,
+// rather than letting config.sh fail inside the user data
+func checkRunnerLimit(githubToken, scope string, launching int) error {
+	if maxRunners <= 0 {
+		return nil
+	}
+
+	count, err := countRunners(githubToken, scope)
+	if err != nil {
+		return fmt.Errorf("failed to count the runners of %s: %w", scope, err)
+	}
+	emitEvent("runners.counted", map[string]any{"scope": scope, "count": count, "max": maxRunners})
+
+	if count+launching > maxRunners {
+		return fmt.Errorf("%w: %s already has %d of %d self-hosted runners registered, too many to launch %d "+
+			"more; remove offline runners first", ErrQuota, scope, count, maxRunners, launching)
+	}
+	if (count+launching)*100 >= maxRunners*runnerLimitWarnPercent {
+		message := fmt.Sprintf("%s has %d of %d self-hosted runners registered", scope, count, maxRunners)
+		if launching > 1 {
+			message += fmt.Sprintf(" before launching %d more", launching)
+		}
+		if outputFormat == "github-actions" {
+			fmt.Fprintf(os.Stderr, "::warning::%s\n", message)
+		} else {
+			fmt.Fprintf(os.Stderr, "⚠️  %s\n", message)
+		}
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().IntVar(&maxRunners, "max-runners", 0,
+		fmt.Sprintf("Fail before launching past this many registered runners, e.g. %d (GitHub's limit); "+
+			"0 skips the check", githubMaxRunners))
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// Correlation states of list-runners
+const (
+	runnerMatched    = "ok"
+	runnerNoInstance = "no-instance"
+	instanceNoRunner = "no-runner"
+)
+
+// RunnerRecord is a GitHub runner and/or the EC2 instance tagged with its name
+type RunnerRecord struct {
+	RunnerName string         `json:"runner_name"`
+	Status     string         `json:"status"`
+	Runner     *GitHubRunner  `json:"runner,omitempty"`
+	Instance   *FleetInstance `json:"instance,omitempty"`
+}
+
+// listGitHubRunners returns every self-hosted runner of a repository or organization
+func listGitHubRunners(githubToken, scope string) ([]GitHubRunner, error) {
+	runners := []GitHubRunner{}
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("%s?per_page=100&page=%d", runnersAPIPath(scope), page)
+		body, err := githubAPIRequest("GET", path, githubToken, nil, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		var list struct {
+			TotalCount int            `json:"total_count"`
+			Runners    []GitHubRunner `json:"runners"`
+		}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %v", err)
+		}
+		runners = append(runners, list.Runners...)
+		if len(list.Runners) == 0 || len(runners) >= list.TotalCount {
+			return runners, nil
+		}
+	}
+}
+
+// correlateRunners matches GitHub runners with runner instances by the
+// RunnerName tag
+func correlateRunners(runners []GitHubRunner, fleet []FleetInstance) []RunnerRecord {
+	byName := map[string]*RunnerRecord{}
+	records := []*RunnerRecord{}
+	for i := range runners {
+		record := &RunnerRecord{RunnerName: runners[i].Name, Status: runnerNoInstance, Runner: &runners[i]}
+		byName[record.RunnerName] = record
+		records = append(records, record)
+	}
+	for i := range fleet {
+		if record, ok := byName[fleet[i].RunnerName]; ok && record.Instance == nil {
+			record.Instance = &fleet[i]
+			record.Status = runnerMatched
+			continue
+		}
+		records = append(records, &RunnerRecord{
+			RunnerName: fleet[i].RunnerName,
+			Status:     instanceNoRunner,
+			Instance:   &fleet[i],
+		})
+	}
+
+	result := make([]RunnerRecord, 0, len(records))
+	for _, record := range records {
+		result = append(result, *record)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].RunnerName < result[j].RunnerName })
+	return result
+}
+
+var listRunnersCmd = &cobra.Command{
+	Use:   "list-runners",
+	Short: "List GitHub runners together with their EC2 instances",
+	Long: "List the self-hosted runners of a repository or organization, match them with runner instances by " +
+		"the RunnerName tag, and flag runners without an instance and instances without a runner",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireGitHubToken(); err != nil {
+			return err
+		}
+		if runnerOrg == "" && (repoOwner == "" || repoName == "") {
+			return fmt.Errorf("repo-owner and repo-name (or --org) are required")
+		}
+		scope := runnerScope(repoOwner, repoName)
+
+		runners, err := listGitHubRunners(githubToken, scope)
+		if err != nil {
+			return fmt.Errorf("failed to list the runners of %s: %w", scope, err)
+		}
+		targets, err := fleetTargets(cmd)
+		if err != nil {
+			return err
+		}
+		fleet, listErr := listFleet(targets)
+		scoped := []FleetInstance{}
+		for _, instance := range fleet {
+			if instance.Repository == scope {
+				scoped = append(scoped, instance)
+			}
+		}
+		records := correlateRunners(runners, scoped)
+
+		if outputFormat == "json" {
+			data, err := json.MarshalIndent(records, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode runners: %v", err)
+			}
+			fmt.Println(string(data))
+			return listErr
+		}
+
+		mismatches := 0
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RUNNER\tGITHUB STATUS\tBUSY\tACCOUNT\tINSTANCE ID\tSTATE\tAGE\tCHECK")
+		for _, record := range records {
+			githubStatus, busy, account, id, state, age := "-", "-", "-", "-", "-", "-"
+			if record.Runner != nil {
+				githubStatus = record.Runner.Status
+				busy = fmt.Sprintf("%t", record.Runner.Busy)
+			}
+			if record.Instance != nil {
+				account = record.Instance.Account
+				id = record.Instance.InstanceID
+				state = record.Instance.State
+				age = humanizeDuration(instanceAge(*record.Instance))
+			}
+			check := "✅ " + record.Status
+			if record.Status != runnerMatched {
+				check = "⚠️  " + record.Status
+				mismatches++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				record.RunnerName, githubStatus, busy, account, id, state, age, check)
+		}
+		w.Flush()
+
+		if mismatches > 0 {
+			fmt.Printf("\n⚠️  %d mismatch(es): %s means GitHub has a runner without an instance (e.g. a leftover "+
+				"offline runner), %s an instance whose runner is not registered (still booting, or failed)\n",
+				mismatches, runnerNoInstance, instanceNoRunner)
+		}
+		if listErr != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Some accounts could not be listed: %v\n", listErr)
+		}
+		return listErr
+	},
+}
+
+func init() {
+	listRunnersCmd.Flags().StringVar(&githubToken, "github-token", "", "GitHub personal access token")
+	listRunnersCmd.Flags().StringVar(&repoOwner, "repo-owner", "", "GitHub repository owner")
+	listRunnersCmd.Flags().StringVar(&repoName, "repo-name", "", "GitHub repository name")
+	listRunnersCmd.Flags().
+		StringVar(&runnerOrg, "org", "", "List the runners of this organization instead of a repository")
+	listRunnersCmd.Flags().
+		StringVar(&outputFormat, "output-format", "", "Output format (json for machine-readable output)")
+	addFleetFlags(listRunnersCmd)
+
+	rootCmd.AddCommand(listRunnersCmd)
+}
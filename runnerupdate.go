@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	runnerVersion           = defaultRunnerVersion
+	disableRunnerAutoupdate bool
+)
+
+// runnerVersionPattern matches a runner release such as 2.313.0
+var runnerVersionPattern = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// validateRunnerVersion checks --runner-version
+func validateRunnerVersion() error {
+	if !runnerVersionPattern.MatchString(runnerVersion) {
+		return fmt.Errorf("runner-version must be a runner release such as %s, got %q",
+			defaultRunnerVersion, runnerVersion)
+	}
+	return nil
+}
+
+// runnerUpdateArg returns the config.sh/config.cmd argument turning off the
+// runner's self-update with --disable-runner-autoupdate, if any
+func runnerUpdateArg() string {
+	if !disableRunnerAutoupdate {
+		return ""
+	}
+	return " --disableupdate"
+}
+
+func init() {
+	createCmd.Flags().StringVar(&runnerVersion, "runner-version", defaultRunnerVersion,
+		"GitHub Actions runner release to install, recorded in the RunnerVersion tag")
+	createCmd.Flags().BoolVar(&disableRunnerAutoupdate, "disable-runner-autoupdate", false,
+		"Keep the runner on --runner-version instead of letting it update itself between jobs")
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	advisorHistoryDays int
+	advisorDataURL     string
+	advisorTop         int
+)
+
+// defaultSpotAdvisorURL serves the data behind the AWS Spot Instance Advisor,
+// the only public source of interruption frequencies
+const defaultSpotAdvisorURL = "https://spot-bid-advisor.s3.amazonaws.com/spot-advisor-data.json"
+
+// spotMaxPriceHeadroom is added to the highest spot price seen for the
+// suggested --spot-max-price, so a usual price swing doesn't stop launches
+const spotMaxPriceHeadroom = 1.2
+
+// unknownInterruptionRank ranks pools without interruption data last
+const unknownInterruptionRank = math.MaxInt
+
+// SpotRecommendation is a spot pool, an instance type in an availability zone,
+// as ranked by spot-advisor
+type SpotRecommendation struct {
+	Rank              int     `json:"rank"`
+	InstanceType      string  `json:"instance_type"`
+	AvailabilityZone  string  `json:"availability_zone"`
+	CurrentPrice      float64 `json:"current_price_usd"`
+	AveragePrice      float64 `json:"average_price_usd"`
+	MaxPrice          float64 `json:"max_price_usd"`
+	OnDemandPrice     float64 `json:"on_demand_price_usd,omitempty"`
+	Savings           float64 `json:"savings_percent,omitempty"`
+	Interruption      string  `json:"interruption_frequency,omitempty"`
+	SuggestedMaxPrice float64 `json:"suggested_max_price_usd"`
+
+	interruptionRank int
+}
+
+// spotAdvisorData is the Spot Instance Advisor data: per region, operating
+// system and instance type, the savings over on-demand and the index of the
+// interruption frequency range
+type spotAdvisorData struct {
+	Ranges []struct {
+		Index int    `json:"index"`
+		Label string `json:"label"`
+	} `json:"ranges"`
+	SpotAdvisor map[string]map[string]map[string]struct {
+		Savings int `json:"s"`
+		Range   int `json:"r"`
+	} `json:"spot_advisor"`
+}
+
+// fetchSpotAdvisorData downloads the Spot Instance Advisor data
+func fetchSpotAdvisorData(url string) (*spotAdvisorData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	var data spotAdvisorData
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to parse spot advisor data: %v", err)
+	}
+	return &data, nil
+}
+
+// interruption returns the interruption frequency label of an instance type
+// and its rank among the ranges, lowest frequency first
+func (data *spotAdvisorData) interruption(region, osName, instanceType string) (string, int) {
+	if data == nil {
+		return "", unknownInterruptionRank
+	}
+	entry, ok := data.SpotAdvisor[region][osName][instanceType]
+	if !ok {
+		return "", unknownInterruptionRank
+	}
+	for _, r := range data.Ranges {
+		if r.Index == entry.Range {
+			return r.Label, r.Index
+		}
+	}
+	return "", entry.Range
+}
+
+// spotPriceHistory returns the spot price history of the instance types since
+// start by pool, instance type and availability zone, oldest price first. The
+// first price of a pool is the one in effect at start
+func spotPriceHistory(svc *ec2.Client, instanceTypes []string, productDescription string,
+	start time.Time) (map[[2]string][]types.SpotPrice, error) {
+	requested := make([]types.InstanceType, 0, len(instanceTypes))
+	for _, instanceType := range instanceTypes {
+		requested = append(requested, types.InstanceType(instanceType))
+	}
+	pools := map[[2]string][]types.SpotPrice{}
+	paginator := ec2.NewDescribeSpotPriceHistoryPaginator(svc, &ec2.DescribeSpotPriceHistoryInput{
+		InstanceTypes:       requested,
+		ProductDescriptions: []string{productDescription},
+		StartTime:           aws.Time(start),
+		EndTime:             aws.Time(time.Now()),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get spot price history: %w", classifyAWSError(err))
+		}
+		for _, entry := range page.SpotPriceHistory {
+			key := [2]string{string(entry.InstanceType), aws.ToString(entry.AvailabilityZone)}
+			pools[key] = append(pools[key], entry)
+		}
+	}
+	for _, history := range pools {
+		sort.Slice(history, func(i, j int) bool {
+			return aws.ToTime(history[i].Timestamp).Before(aws.ToTime(history[j].Timestamp))
+		})
+	}
+	return pools, nil
+}
+
+// spotPriceStats returns the latest, time-weighted average and highest price
+// of a pool's price history over the window since start
+func spotPriceStats(history []types.SpotPrice, start time.Time) (current, average, highest float64) {
+	sort.Slice(history, func(i, j int) bool {
+		return aws.ToTime(history[i].Timestamp).Before(aws.ToTime(history[j].Timestamp))
+	})
+	now := time.Now()
+	weighted, total := 0.0, 0.0
+	for i, entry := range history {
+		price, err := strconv.ParseFloat(aws.ToString(entry.SpotPrice), 64)
+		if err != nil {
+			continue
+		}
+		// A price holds from its change until the next one; the first entry predates the window
+		from, until := aws.ToTime(entry.Timestamp), now
+		if from.Before(start) {
+			from = start
+		}
+		if i+1 < len(history) {
+			until = aws.ToTime(history[i+1].Timestamp)
+		}
+		if seconds := until.Sub(from).Seconds(); seconds > 0 {
+			weighted += price * seconds
+			total += seconds
+		}
+		current = price
+		highest = max(highest, price)
+	}
+	average = current
+	if total > 0 {
+		average = math.Round(weighted/total*1e6) / 1e6
+	}
+	return current, average, highest
+}
+
+// subnetZones returns the availability zones of the subnets
+func subnetZones(svc *ec2.Client, subnets []string) ([]string, error) {
+	result, err := svc.DescribeSubnets(context.TODO(), &ec2.DescribeSubnetsInput{SubnetIds: subnets})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe subnets: %w", classifyAWSError(err))
+	}
+	zones := []string{}
+	for _, subnet := range result.Subnets {
+		zones = append(zones, aws.ToString(subnet.AvailabilityZone))
+	}
+	return zones, nil
+}
+
+// adviseSpot ranks the spot pools of the instance types: lowest interruption
+// frequency first, then lowest current price
+func adviseSpot(cfg aws.Config, instanceTypes, zones []string) ([]SpotRecommendation, error) {
+	productDescription, advisorOS := "Linux/UNIX", "Linux"
+	if runnerOS == "windows" {
+		productDescription, advisorOS = "Windows", "Windows"
+	}
+
+	start := time.Now().AddDate(0, 0, -advisorHistoryDays)
+	pools, err := spotPriceHistory(ec2.NewFromConfig(cfg), instanceTypes, productDescription, start)
+	if err != nil {
+		return nil, err
+	}
+	for pool := range pools {
+		if len(zones) > 0 && !slices.Contains(zones, pool[1]) {
+			delete(pools, pool)
+		}
+	}
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("%w: no spot price history for %s", ErrNotFound, strings.Join(instanceTypes, ", "))
+	}
+
+	// Interruption frequencies and on-demand prices only improve the ranking, so they are best effort
+	data, err := fetchSpotAdvisorData(advisorDataURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  No interruption frequencies, ranking by price only: %v\n", err)
+	}
+	// onDemandPrice only knows Linux prices
+	onDemand := map[string]float64{}
+	for _, instanceType := range instanceTypes {
+		if runnerOS != "linux" {
+			continue
+		}
+		price, err := onDemandPrice(cfg, instanceType, cfg.Region)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  No savings for %s: %v\n", instanceType, err)
+			continue
+		}
+		onDemand[instanceType] = price
+	}
+
+	recommendations := []SpotRecommendation{}
+	for key, history := range pools {
+		recommendation := SpotRecommendation{
+			InstanceType:     key[0],
+			AvailabilityZone: key[1],
+			OnDemandPrice:    onDemand[key[0]],
+		}
+		recommendation.CurrentPrice, recommendation.AveragePrice, recommendation.MaxPrice =
+			spotPriceStats(history, start)
+		recommendation.Interruption, recommendation.interruptionRank =
+			data.interruption(cfg.Region, advisorOS, key[0])
+		recommendation.SuggestedMaxPrice = math.Ceil(recommendation.MaxPrice*spotMaxPriceHeadroom*10000) / 10000
+		if recommendation.OnDemandPrice > 0 {
+			recommendation.Savings = math.Round(100 * (1 - recommendation.CurrentPrice/recommendation.OnDemandPrice))
+			recommendation.SuggestedMaxPrice = min(recommendation.SuggestedMaxPrice, recommendation.OnDemandPrice)
+		}
+		recommendations = append(recommendations, recommendation)
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		a, b := recommendations[i], recommendations[j]
+		if a.interruptionRank != b.interruptionRank {
+			return a.interruptionRank < b.interruptionRank
+		}
+		if a.CurrentPrice != b.CurrentPrice {
+			return a.CurrentPrice < b.CurrentPrice
+		}
+		return a.InstanceType+a.AvailabilityZone < b.InstanceType+b.AvailabilityZone
+	})
+	for i := range recommendations {
+		recommendations[i].Rank = i + 1
+	}
+	return recommendations, nil
+}
+
+// suggestedSpotFlags returns the create flags for the best ranked pools: their
+// instance types in rank order and a max price covering all of them
+func suggestedSpotFlags(recommendations []SpotRecommendation, top int) string {
+	instanceTypes := []string{}
+	maxPrice := 0.0
+	for _, recommendation := range recommendations[:min(top, len(recommendations))] {
+		if !slices.Contains(instanceTypes, recommendation.InstanceType) {
+			instanceTypes = append(instanceTypes, recommendation.InstanceType)
+		}
+		maxPrice = max(maxPrice, recommendation.SuggestedMaxPrice)
+	}
+	return fmt.Sprintf("--instance-market-type spot --instance-type %s --spot-max-price %s",
+		strings.Join(instanceTypes, ","), strconv.FormatFloat(maxPrice, 'f', -1, 64))
+}
+
+var spotAdvisorCmd = &cobra.Command{
+	Use:   "spot-advisor",
+	Short: "Rank spot instance types and zones by interruption frequency and price",
+	Long: "Rank the spot pools (instance type and availability zone) of candidate instance types by their " +
+		"interruption frequency from the AWS Spot Instance Advisor, then by current price, and suggest " +
+		"--instance-type and --spot-max-price for create",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		instanceTypes := splitList(instanceType)
+		if len(instanceTypes) == 0 {
+			return fmt.Errorf("instance-type is required")
+		}
+		if advisorHistoryDays < 1 || advisorHistoryDays > 90 {
+			return fmt.Errorf("history-days must be between 1 and 90 (the spot price history AWS keeps)")
+		}
+		if advisorTop < 1 {
+			return fmt.Errorf("top must be at least 1")
+		}
+		if runnerOS != "linux" && runnerOS != "windows" {
+			return fmt.Errorf("os must be 'linux' or 'windows'")
+		}
+
+		cfg, err := loadAWSConfig()
+		if err != nil {
+			return err
+		}
+		var zones []string
+		if subnetID != "" {
+			if zones, err = subnetZones(ec2.NewFromConfig(cfg), splitList(subnetID)); err != nil {
+				return err
+			}
+		}
+		recommendations, err := adviseSpot(cfg, instanceTypes, zones)
+		if err != nil {
+			return err
+		}
+
+		if outputFormat == "json" {
+			data, err := json.MarshalIndent(recommendations, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode recommendations: %v", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RANK\tTYPE\tZONE\tINTERRUPTIONS\tPRICE ($/h)\tAVG ($/h)\tMAX ($/h)\tON-DEMAND ($/h)\tSAVINGS")
+		for _, r := range recommendations {
+			interruption, onDemandRate, savings := "-", "-", "-"
+			if r.Interruption != "" {
+				interruption = r.Interruption
+			}
+			if r.OnDemandPrice > 0 {
+				onDemandRate = fmt.Sprintf("%.4f", r.OnDemandPrice)
+				savings = fmt.Sprintf("%.0f%%", r.Savings)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%.4f\t%.4f\t%.4f\t%s\t%s\n", r.Rank, r.InstanceType, r.AvailabilityZone,
+				interruption, r.CurrentPrice, r.AveragePrice, r.MaxPrice, onDemandRate, savings)
+		}
+		w.Flush()
+
+		fmt.Printf("💡 Suggested create flags: %s\n", suggestedSpotFlags(recommendations, advisorTop))
+		return nil
+	},
+}
+
+func init() {
+	spotAdvisorCmd.Flags().StringVar(&instanceType, "instance-type", "",
+		"Comma-separated candidate instance types, e.g. c6i.2xlarge,c5.2xlarge,m6i.2xlarge")
+	spotAdvisorCmd.Flags().StringVar(&subnetID, "subnet-id", "",
+		"Comma-separated subnets whose availability zones to consider (default: all zones of the region)")
+	spotAdvisorCmd.Flags().IntVar(&advisorHistoryDays, "history-days", 7, "Days of spot price history to look at")
+	spotAdvisorCmd.Flags().IntVar(&advisorTop, "top", 3, "Number of best ranked pools the suggested flags cover")
+	spotAdvisorCmd.Flags().StringVar(&runnerOS, "os", "linux", "Operating system of the runner (linux or windows)")
+	spotAdvisorCmd.Flags().StringVar(&advisorDataURL, "advisor-data-url", defaultSpotAdvisorURL,
+		"URL of the Spot Instance Advisor data, e.g. a mirror")
+	spotAdvisorCmd.Flags().
+		StringVar(&awsRegion, "region", "", "AWS region (overrides AWS_REGION and AWS_DEFAULT_REGION)")
+	spotAdvisorCmd.Flags().
+		StringVar(&outputFormat, "output-format", "", "Output format (json for machine-readable output)")
+
+	rootCmd.AddCommand(spotAdvisorCmd)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	spotInterruptionHandler bool
+	spotDrainSeconds        int
+	spotDeregister          bool
+	spotRemovalToken        string
+	spotRebalance           string
+)
+
+// Reactions to a rebalance recommendation, EC2's early warning of an elevated
+// interruption risk: none, retiring the runner after its job, or retiring it
+// and tagging the instance for `replace` to launch a replacement
+const (
+	rebalanceIgnore  = "ignore"
+	rebalanceDrain   = "drain"
+	rebalanceReplace = "replace"
+)
+
+// rebalanceTag marks an instance whose runner retired on a rebalance
+// recommendation, for `replace`
+const rebalanceTag = "RebalanceRecommended"
+
+// rebalanceReplacedTag records the replacement of a retired instance before it
+// is terminated, so a failed termination is retried without launching again
+const rebalanceReplacedTag = "RebalanceReplacedBy"
+
+// maxSpotDrainSeconds bounds --spot-drain-seconds: EC2 reclaims the instance
+// two minutes after the notice, and stopping, deregistering and flushing the
+// logs need the rest
+const maxSpotDrainSeconds = 90
+
+// userDataRemovalPlaceholder stands in for the removal token in rendered user data
+const userDataRemovalPlaceholder = "<removal-token>"
+
+// validateSpotInterruptionFlags checks --spot-drain-seconds and --spot-rebalance
+func validateSpotInterruptionFlags() error {
+	if spotDrainSeconds < 0 || spotDrainSeconds > maxSpotDrainSeconds {
+		return fmt.Errorf("spot-drain-seconds must be between 0 and %d", maxSpotDrainSeconds)
+	}
+	switch spotRebalance {
+	case rebalanceIgnore:
+		return nil
+	case rebalanceDrain, rebalanceReplace:
+	default:
+		return fmt.Errorf("spot-rebalance must be %s, %s or %s, got %q",
+			rebalanceIgnore, rebalanceDrain, rebalanceReplace, spotRebalance)
+	}
+	if !spotInterruptionHandled() {
+		return fmt.Errorf("spot-rebalance needs the spot interruption handler " +
+			"(--instance-market-type spot and --os linux)")
+	}
+	if spotRequestType == spotRequestPersistent {
+		return fmt.Errorf("spot-rebalance can't be used with --spot-request-type persistent, " +
+			"whose runners wait out interruptions")
+	}
+	return nil
+}
+
+// spotInterruptionHandled reports whether the user data installs the spot
+// interruption watcher: for Linux spot instances launched on EC2
+func spotInterruptionHandled() bool {
+	return spotInterruptionHandler && instanceMarketType == "spot" && runnerOS == "linux" &&
+		providerName == defaultProvider
+}
+
+// spotDeregisters reports whether the watcher removes the runner from GitHub;
+// a persistent spot runner keeps its registration to come back online with
+func spotDeregisters() bool {
+	return spotDeregister && spotInterruptionHandled() && spotRequestType != spotRequestPersistent
+}
+
+// getGitHubRemovalToken fetches a token that config.sh remove accepts; like
+// a registration token, it expires after an hour
+func getGitHubRemovalToken(githubToken, repoOwner, repoName string) (string, error) {
+	if err := checkReadOnly("create a GitHub runner removal token"); err != nil {
+		return "", err
+	}
+	path := runnersAPIPath(runnerScope(repoOwner, repoName)) + "/remove-token"
+	body, err := githubAPIRequest("POST", path, githubToken, nil, http.StatusCreated)
+	if err != nil {
+		return "", err
+	}
+	var tokenResponse GitHubRegistrationTokenResponse
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return "", fmt.Errorf("failed to parse response: %v", err)
+	}
+	return tokenResponse.Token, nil
+}
+
+// fetchSpotRemovalToken returns the removal token the spot interruption
+// watcher deregisters the runner with, or "" when it doesn't
+func fetchSpotRemovalToken(githubToken, repoOwner, repoName string) (string, error) {
+	if !spotDeregisters() {
+		return "", nil
+	}
+	token, err := getGitHubRemovalToken(githubToken, repoOwner, repoName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get GitHub removal token: %w", err)
+	}
+	return token, nil
+}
+
+// spotInterruptionUserData returns user data lines installing a service that
+// polls the instance metadata for a spot interruption notice. On notice, it
+// gives the running job --spot-drain-seconds to finish, stops the runner,
+// which cancels a job still running, removes the runner from GitHub and
+// flushes the logs before EC2 reclaims the instance two minutes later. With
+// --spot-rebalance, a rebalance recommendation retires the runner the same
+// way once it is idle; see rebalanceUserData
+func spotInterruptionUserData() []string {
+	if !spotInterruptionHandled() {
+		return nil
+	}
+	lines := []string{
+		"# Drain the runner when EC2 announces a spot interruption",
+		"cat > /usr/local/bin/gh-workflow-spot-interruption << 'EOF'",
+		"#!/bin/bash",
+		"IMDS=http://169.254.169.254/latest",
+		"imds() {",
+		"    local token",
+		"    token=$(curl -s -X PUT $IMDS/api/token -H 'X-aws-ec2-metadata-token-ttl-seconds: 60')",
+		"    curl -sf -H \"X-aws-ec2-metadata-token: $token\" \"$IMDS/$1\"",
+		"}",
+		"log() {",
+		"    echo \"$(date -u +%Y-%m-%dT%H:%M:%SZ) $*\" | tee -a /var/log/gh-workflow-spot-interruption.log | " +
+			"logger -t gh-workflow-spot-interruption",
+		"}",
+		"# On-demand instances, e.g. launched by the on-demand fallback, are never interrupted",
+		"[ \"$(imds meta-data/instance-life-cycle)\" = spot ] || exit 0",
+		fmt.Sprintf("DRAIN=%d", spotDrainSeconds),
+		"while true; do",
+		"    if NOTICE=$(imds meta-data/spot/instance-action); then",
+		"        log \"Spot interruption notice: $NOTICE\"",
+		"        break",
+		"    fi",
+	}
+	lines = append(lines, rebalanceUserData()...)
+	lines = append(lines,
+		"    sleep 2",
+		"done",
+		"DEADLINE=$((SECONDS + DRAIN))",
+		"while pgrep -f Runner.Worker >/dev/null && [ $SECONDS -lt $DEADLINE ]; do sleep 2; done",
+		"pgrep -f Runner.Worker >/dev/null && log 'Cancelling the running job'",
+		"# The runner cancels a running job and reports it when interrupted",
+		"pkill -INT -f Runner.Listener",
+		"for _ in $(seq 15); do pgrep -f Runner.Listener >/dev/null || break; sleep 1; done",
+		"pkill -KILL -f 'Runner.Listener|Runner.Worker|run.sh'",
+		"cd /actions-runner || exit 1",
+	)
+	if spotDeregisters() {
+		lines = append(lines,
+			"if RUNNER_ALLOW_RUNASROOT=1 ./config.sh remove --token "+
+				firstNonEmpty(spotRemovalToken, userDataRemovalPlaceholder)+"; then",
+			"    log 'Runner removed from GitHub'",
+			"else",
+			"    log 'Failed to remove the runner from GitHub, the removal token may have expired'",
+			"fi",
+		)
+	}
+	if diagS3URI != "" {
+		lines = append(lines,
+			"if command -v aws >/dev/null; then",
+			"    IID=$(imds meta-data/instance-id)",
+			"    REGION=$(imds meta-data/placement/region)",
+			"    tar czf /tmp/gh-workflow-diag.tar.gz -C /actions-runner _diag \\",
+			"        -C /var/log user-data.log gh-workflow-spot-interruption.log 2>/dev/null",
+			"    aws s3 cp --region \"$REGION\" --only-show-errors /tmp/gh-workflow-diag.tar.gz \\",
+			fmt.Sprintf("        \"%s/spot-interruptions/${IID}.tar.gz\" || true", strings.TrimSuffix(diagS3URI, "/")),
+			"fi",
+		)
+	}
+	lines = append(lines,
+		"journalctl --flush --sync 2>/dev/null",
+		"sync",
+	)
+	if spotRebalance == rebalanceDrain {
+		lines = append(lines,
+			"if [ -z \"$NOTICE\" ]; then",
+			"    log 'Runner retired, shutting down'",
+			"    shutdown -h now",
+			"fi",
+		)
+	}
+	return append(lines,
+		"log 'Runner retired'",
+		"EOF",
+		"chmod +x /usr/local/bin/gh-workflow-spot-interruption",
+		"cat > /etc/systemd/system/gh-workflow-spot-interruption.service << 'EOF'",
+		"[Unit]",
+		"Description=gh-workflow spot interruption handler",
+		"After=network-online.target",
+		"[Service]",
+		"ExecStart=/usr/local/bin/gh-workflow-spot-interruption",
+		"[Install]",
+		"WantedBy=multi-user.target",
+		"EOF",
+		"systemctl daemon-reload && systemctl enable --now gh-workflow-spot-interruption",
+		"",
+	)
+}
+
+// rebalanceUserData returns the lines of the watcher loop that react to a
+// rebalance recommendation: from then on, the runner is retired as soon as it
+// is not running a job, rather than picking up another one. With replace, the
+// instance is tagged for `replace`, which needs the AWS CLI and ec2:CreateTags
+// on the instance
+func rebalanceUserData() []string {
+	if spotRebalance == rebalanceIgnore {
+		return nil
+	}
+	lines := []string{
+		"    if [ -z \"$REBALANCE\" ] && REBALANCE=$(imds meta-data/events/recommendations/rebalance); then",
+		"        log \"Rebalance recommendation: $REBALANCE, retiring the runner once it is idle\"",
+	}
+	if spotRebalance == rebalanceReplace {
+		lines = append(lines,
+			"        if command -v aws >/dev/null; then",
+			"            aws ec2 create-tags --region \"$(imds meta-data/placement/region)\" \\",
+			"                --resources \"$(imds meta-data/instance-id)\" \\",
+			"                --tags \"Key="+rebalanceTag+",Value=$(date -u +%Y-%m-%dT%H:%M:%SZ)\" || true",
+			"        fi",
+		)
+	}
+	return append(lines,
+		"    fi",
+		"    if [ -n \"$REBALANCE\" ] && ! pgrep -f Runner.Worker >/dev/null; then",
+		"        DRAIN=0",
+		"        break",
+		"    fi",
+	)
+}
+
+func init() {
+	createCmd.Flags().BoolVar(&spotInterruptionHandler, "spot-interruption-handler", true,
+		"Drain spot runners when EC2 announces an interruption (Linux)")
+	createCmd.Flags().IntVar(&spotDrainSeconds, "spot-drain-seconds", 60,
+		"Seconds a running job gets to finish after a spot interruption notice before it is cancelled")
+	createCmd.Flags().BoolVar(&spotDeregister, "spot-deregister", true,
+		"Remove drained spot runners from GitHub, with a removal token that expires an hour after create")
+	createCmd.Flags().StringVar(&spotRebalance, "spot-rebalance", rebalanceIgnore,
+		"On a rebalance recommendation: ignore, drain (retire the runner after its job and shut down) or "+
+			"replace (retire it and tag the instance for the replace command)")
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+var (
+	spotPlacementScores   bool
+	minSpotPlacementScore int
+)
+
+// maxSpotPlacementScore is the best score GetSpotPlacementScores gives, for a
+// request very likely to succeed
+const maxSpotPlacementScore = 10
+
+// validateSpotPlacementFlags checks --spot-placement-scores and
+// --min-spot-placement-score, which implies the former
+func validateSpotPlacementFlags() error {
+	if minSpotPlacementScore < 0 || minSpotPlacementScore > maxSpotPlacementScore {
+		return fmt.Errorf("min-spot-placement-score must be between 0 and %d", maxSpotPlacementScore)
+	}
+	if minSpotPlacementScore > 0 {
+		spotPlacementScores = true
+	}
+	if spotPlacementScores && instanceMarketType != "spot" {
+		return fmt.Errorf("spot-placement-scores and min-spot-placement-score require --instance-market-type spot")
+	}
+	return nil
+}
+
+// subnetZoneIDs returns the availability zone ID (e.g. use1-az1) of each
+// subnet; placement scores are given per zone ID, as zone names differ
+// between accounts
+func subnetZoneIDs(svc *ec2.Client, subnets []string) (map[string]string, error) {
+	result, err := svc.DescribeSubnets(context.TODO(), &ec2.DescribeSubnetsInput{SubnetIds: subnets})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe subnets: %w", classifyAWSError(err))
+	}
+	zoneIDs := map[string]string{}
+	for _, subnet := range result.Subnets {
+		zoneIDs[aws.ToString(subnet.SubnetId)] = aws.ToString(subnet.AvailabilityZoneId)
+	}
+	return zoneIDs, nil
+}
+
+// checkSpotPlacementScores prints the spot placement score of the instance
+// types in every zone of the region and returns the subnets ordered by the
+// score of their zone, best first, so create tries the most likely zone
+// first. Subnets scored below --min-spot-placement-score are dropped; the
+// check fails when none is left. Without subnets, the best zone of the
+// region must meet the minimum
+func checkSpotPlacementScores(svc *ec2.Client, subnets []string) ([]string, error) {
+	if !spotPlacementScores {
+		return subnets, nil
+	}
+	instanceTypes := fleetTypes()
+	scores := map[string]int32{}
+	paginator := ec2.NewGetSpotPlacementScoresPaginator(svc, &ec2.GetSpotPlacementScoresInput{
+		InstanceTypes:          instanceTypes,
+		TargetCapacity:         aws.Int32(int32(launchCount)),
+		SingleAvailabilityZone: aws.Bool(true),
+		RegionNames:            []string{resolveRegion()},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get spot placement scores: %w", classifyAWSError(err))
+		}
+		for _, score := range page.SpotPlacementScores {
+			scores[aws.ToString(score.AvailabilityZoneId)] = aws.ToInt32(score.Score)
+		}
+	}
+
+	zoneIDs := map[string]string{}
+	if len(subnets) > 0 {
+		var err error
+		if zoneIDs, err = subnetZoneIDs(svc, subnets); err != nil {
+			return nil, err
+		}
+	}
+	emitEvent("spot.placement_scores", map[string]any{"instance_types": instanceTypes, "scores": scores})
+	if outputFormat != "github-actions" {
+		fmt.Printf("🎯 Spot placement scores (1-%d) of %d x %s:\n", maxSpotPlacementScore, launchCount,
+			strings.Join(instanceTypes, ", "))
+		zones := make([]string, 0, len(scores))
+		for zone := range scores {
+			zones = append(zones, zone)
+		}
+		sort.Slice(zones, func(i, j int) bool {
+			if scores[zones[i]] != scores[zones[j]] {
+				return scores[zones[i]] > scores[zones[j]]
+			}
+			return zones[i] < zones[j]
+		})
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, zone := range zones {
+			zoneSubnets := []string{}
+			for _, subnet := range subnets {
+				if zoneIDs[subnet] == zone {
+					zoneSubnets = append(zoneSubnets, subnet)
+				}
+			}
+			fmt.Fprintf(w, "   %s\t%d\t%s\n", zone, scores[zone], firstNonEmpty(strings.Join(zoneSubnets, ", "), "-"))
+		}
+		w.Flush()
+	}
+
+	if len(subnets) == 0 {
+		best := int32(0)
+		for _, score := range scores {
+			best = max(best, score)
+		}
+		if best < int32(minSpotPlacementScore) {
+			return nil, fmt.Errorf("%w: the best spot placement score in %s is %d, below %d",
+				ErrCapacity, resolveRegion(), best, minSpotPlacementScore)
+		}
+		return subnets, nil
+	}
+
+	// A zone without a score counts as 0
+	ordered := []string{}
+	for _, subnet := range subnets {
+		if scores[zoneIDs[subnet]] >= int32(minSpotPlacementScore) {
+			ordered = append(ordered, subnet)
+		}
+	}
+	if len(ordered) == 0 {
+		return nil, fmt.Errorf("%w: no subnet has a spot placement score of at least %d",
+			ErrCapacity, minSpotPlacementScore)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return scores[zoneIDs[ordered[i]]] > scores[zoneIDs[ordered[j]]]
+	})
+	return ordered, nil
+}
+
+func init() {
+	createCmd.Flags().BoolVar(&spotPlacementScores, "spot-placement-scores", false,
+		"Print the spot placement scores of the subnets' zones and try the best scored subnet first")
+	createCmd.Flags().IntVar(&minSpotPlacementScore, "min-spot-placement-score", 0,
+		"Fail before launching spot runners if no subnet's zone has at least this placement score (1-10)")
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	sshCAPublicKey string
+	sshInstanceID  string
+	sshUser        string
+	sshTTL         time.Duration
+	sshCAKeyPath   string
+	sshVaultMount  string
+	sshVaultRole   string
+	sshPrivateIP   bool
+)
+
+// sshCAKeysPath is where the CA public key is installed on the instance
+const sshCAKeysPath = "/etc/ssh/trusted-user-ca-keys.pem"
+
+// maxSSHTTL bounds the lifetime of debug certificates
+const maxSSHTTL = 12 * time.Hour
+
+// readSSHCAPublicKey returns the CA public key in authorized_keys format from a
+// literal key or a file path, validating it
+func readSSHCAPublicKey(value string) (string, error) {
+	key := value
+	if !strings.HasPrefix(value, "ssh-") && !strings.HasPrefix(value, "ecdsa-") {
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to read SSH CA public key %s: %v", value, err)
+		}
+		key = string(data)
+	}
+
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return "", fmt.Errorf("invalid SSH CA public key: %v", err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(parsed))), nil
+}
+
+// sshCAUserData returns bootstrap lines that make sshd trust certificates signed by the CA
+func sshCAUserData(caPublicKey string) []string {
+	if caPublicKey == "" {
+		return nil
+	}
+	return []string{
+		"# Trust SSH certificates signed by the debug access CA",
+		fmt.Sprintf("echo '%s' > %s", caPublicKey, sshCAKeysPath),
+		fmt.Sprintf("chmod 644 %s", sshCAKeysPath),
+		fmt.Sprintf(
+			"grep -q '^TrustedUserCAKeys' /etc/ssh/sshd_config || echo 'TrustedUserCAKeys %s' >> /etc/ssh/sshd_config",
+			sshCAKeysPath,
+		),
+		"systemctl restart ssh 2>/dev/null || systemctl restart sshd 2>/dev/null || true",
+		"",
+	}
+}
+
+// signWithLocalCA signs the public key with a CA private key file
+func signWithLocalCA(
+	caKeyPath string,
+	pub ssh.PublicKey,
+	principal string,
+	ttl time.Duration,
+) (*ssh.Certificate, error) {
+	data, err := os.ReadFile(caKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH CA key %s: %v", caKeyPath, err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH CA key %s: %v", caKeyPath, err)
+	}
+
+	serial := make([]byte, 8)
+	if _, err := rand.Read(serial); err != nil {
+		return nil, fmt.Errorf("failed to generate certificate serial: %v", err)
+	}
+
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             pub,
+		Serial:          binary.BigEndian.Uint64(serial),
+		CertType:        ssh.UserCert,
+		KeyId:           fmt.Sprintf("gh-workflow-debug-%s-%d", principal, now.Unix()),
+		ValidPrincipals: []string{principal},
+		ValidAfter:      uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-pty":              "",
+				"permit-port-forwarding":  "",
+				"permit-agent-forwarding": "",
+			},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %v", err)
+	}
+	return cert, nil
+}
+
+// signWithVault signs the public key through Vault's SSH secrets engine
+func signWithVault(
+	mount, role string,
+	pub ssh.PublicKey,
+	principal string,
+	ttl time.Duration,
+) (*ssh.Certificate, error) {
+	client, err := newVaultClient()
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := client.do("POST", fmt.Sprintf("%s/sign/%s", mount, role), map[string]string{
+		"public_key":       string(ssh.MarshalAuthorizedKey(pub)),
+		"valid_principals": principal,
+		"ttl":              ttl.String(),
+		"cert_type":        "user",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign certificate with Vault: %w", err)
+	}
+
+	signedKey, _ := signed.Data["signed_key"].(string)
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signedKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signed key from Vault: %v", err)
+	}
+	cert, ok := parsed.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("Vault did not return an SSH certificate")
+	}
+	return cert, nil
+}
+
+// instanceAddress returns the public IP of an instance, or its private IP when
+// preferPrivate is set or it has no public address
+func instanceAddress(svc *ec2.Client, instanceID string, preferPrivate bool) (string, error) {
+	result, err := svc.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instanceID},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to find instance %s: %w", instanceID, classifyAWSError(err))
+	}
+	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
+		return "", fmt.Errorf("%w: instance %s", ErrNotFound, instanceID)
+	}
+
+	instance := result.Reservations[0].Instances[0]
+	if !preferPrivate && aws.ToString(instance.PublicIpAddress) != "" {
+		return aws.ToString(instance.PublicIpAddress), nil
+	}
+	if aws.ToString(instance.PrivateIpAddress) != "" {
+		return aws.ToString(instance.PrivateIpAddress), nil
+	}
+	return "", fmt.Errorf("instance %s has no IP address", instanceID)
+}
+
+// writeDebugIdentity writes an ephemeral key pair and its certificate to dir,
+// returning the private key path
+func writeDebugIdentity(dir string, priv ed25519.PrivateKey, cert *ssh.Certificate) (string, error) {
+	block, err := ssh.MarshalPrivateKey(priv, "gh-workflow debug key")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode private key: %v", err)
+	}
+
+	keyPath := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write private key: %v", err)
+	}
+	if err := os.WriteFile(keyPath+"-cert.pub", ssh.MarshalAuthorizedKey(cert), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write certificate: %v", err)
+	}
+	return keyPath, nil
+}
+
+var sshCmd = &cobra.Command{
+	Use:   "ssh [-- ssh-args...]",
+	Short: "Open a debug SSH session to a runner with a short-lived certificate",
+	Long: "Mint an ephemeral key pair, sign it with the SSH CA (a local CA key or Vault) for --ttl, " +
+		"and open an SSH session to the runner instance",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if sshInstanceID == "" {
+			return fmt.Errorf("instance-id is required")
+		}
+		if sshTTL <= 0 || sshTTL > maxSSHTTL {
+			return fmt.Errorf("ttl must be between 0 and %s", maxSSHTTL)
+		}
+		if (sshCAKeyPath == "") == (sshVaultRole == "") {
+			return fmt.Errorf("exactly one of ca-key or vault-role is required")
+		}
+
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("failed to generate key pair: %v", err)
+		}
+		sshPub, err := ssh.NewPublicKey(pub)
+		if err != nil {
+			return fmt.Errorf("failed to encode public key: %v", err)
+		}
+
+		var cert *ssh.Certificate
+		if sshCAKeyPath != "" {
+			cert, err = signWithLocalCA(sshCAKeyPath, sshPub, sshUser, sshTTL)
+		} else {
+			cert, err = signWithVault(sshVaultMount, sshVaultRole, sshPub, sshUser, sshTTL)
+		}
+		if err != nil {
+			return err
+		}
+
+		svc, err := createEC2Client()
+		if err != nil {
+			return err
+		}
+		var address, proxyCommand string
+		if sshEICEndpoint != "" || sshCreateEICEndpoint {
+			address, proxyCommand, err = eicProxyCommand(svc, sshInstanceID)
+		} else {
+			address, err = instanceAddress(svc, sshInstanceID, sshPrivateIP)
+		}
+		if err != nil {
+			return err
+		}
+
+		dir, err := os.MkdirTemp("", "gh-workflow-ssh-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		keyPath, err := writeDebugIdentity(dir, priv, cert)
+		if err != nil {
+			return err
+		}
+
+		expires := time.Unix(int64(cert.ValidBefore), 0)
+		fmt.Fprintf(os.Stderr, "🔐 Certificate %s valid until %s\n", cert.KeyId, formatTimeRelative(expires))
+		emitEvent("ssh.certificate_issued", map[string]any{
+			"instance_id": sshInstanceID,
+			"key_id":      cert.KeyId,
+			"principal":   sshUser,
+			"expires_at":  expires.UTC(),
+		})
+
+		sshArgs := []string{
+			"-i", keyPath,
+			"-o", "CertificateFile=" + keyPath + "-cert.pub",
+			"-o", "IdentitiesOnly=yes",
+		}
+		if proxyCommand != "" {
+			sshArgs = append(sshArgs, "-o", "ProxyCommand="+proxyCommand)
+		}
+		sshArgs = append(append(sshArgs, fmt.Sprintf("%s@%s", sshUser, address)), args...)
+		session := exec.Command("ssh", sshArgs...)
+		session.Stdin, session.Stdout, session.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := session.Run(); err != nil {
+			return fmt.Errorf("ssh session failed: %v", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	sshCmd.Flags().StringVar(&sshInstanceID, "instance-id", "", "EC2 instance ID to connect to")
+	sshCmd.Flags().StringVar(&sshUser, "user", "ubuntu", "Login user (also the certificate principal)")
+	sshCmd.Flags().DurationVar(&sshTTL, "ttl", 30*time.Minute, "Certificate lifetime")
+	sshCmd.Flags().StringVar(&sshCAKeyPath, "ca-key", "", "Path to the SSH CA private key used to sign locally")
+	sshCmd.Flags().StringVar(&sshVaultMount, "vault-ssh-mount", "ssh-client-signer", "Vault SSH secrets engine mount")
+	sshCmd.Flags().StringVar(&sshVaultRole, "vault-role", "", "Vault SSH role used to sign the certificate")
+	sshCmd.Flags().BoolVar(&sshPrivateIP, "private-ip", false, "Connect to the private IP even if a public IP exists")
+
+	rootCmd.AddCommand(sshCmd)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// isSubnetFallbackError reports whether a launch failed for a reason another
+// availability zone may not have: no capacity for the instance type, or the
+// instance type not being offered there at all
+func isSubnetFallbackError(err error) bool {
+	return errors.Is(err, ErrCapacity) || awsErrorCode(err) == "Unsupported"
+}
+
+// setLaunchSubnet points the launch request at subnet, which sits on the
+// network interface with --associate-public-ip
+func setLaunchSubnet(runInput *ec2.RunInstancesInput, subnet string) {
+	if len(runInput.NetworkInterfaces) > 0 {
+		runInput.NetworkInterfaces[0].SubnetId = aws.String(subnet)
+		return
+	}
+	runInput.SubnetId = aws.String(subnet)
+}
+
+// launchInSubnets tries launch in each of the subnets in turn until one is not
+// short of capacity, and returns the subnet it launched in; every subnet gets
+// its own client token, as EC2 rejects a token reused with other parameters.
+// A fleet launch is a single try, the fleet choosing among the subnets itself
+func launchInSubnets(runInput *ec2.RunInstancesInput, subnets []string, clientToken string,
+	launch func() error) (string, error) {
+	if len(subnets) == 0 || launchMode == launchModeFleet {
+		runInput.ClientToken = aws.String(clientToken)
+		return "", launch()
+	}
+	var err error
+	for i, subnet := range subnets {
+		setLaunchSubnet(runInput, subnet)
+		runInput.ClientToken = aws.String(clientToken)
+		if i > 0 {
+			runInput.ClientToken = aws.String(fmt.Sprintf("%s-%d", clientToken, i))
+		}
+		err = launch()
+		if err == nil || !isSubnetFallbackError(err) || i == len(subnets)-1 {
+			return subnet, err
+		}
+
+		if outputFormat != "github-actions" {
+			fmt.Printf("⚠️  No capacity in %s (%s), trying %s...\n", subnet, awsErrorCode(err), subnets[i+1])
+		}
+		emitEvent("instance.subnet_fallback", map[string]any{
+			"subnet_id":      subnet,
+			"next_subnet_id": subnets[i+1],
+			"reason":         err.Error(),
+		})
+	}
+	return "", err
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// launchAttempt records the parameters of one fake RunInstances call
+type launchAttempt struct {
+	instanceType, subnet, clientToken string
+}
+
+// fakeLaunch returns a launch func that records its attempts and fails with
+// the next of errs, succeeding once they run out
+func fakeLaunch(runInput *ec2.RunInstancesInput, errs ...error) (func() error, *[]launchAttempt) {
+	attempts := []launchAttempt{}
+	return func() error {
+		attempts = append(attempts, launchAttempt{
+			instanceType: string(runInput.InstanceType),
+			subnet:       aws.ToString(runInput.SubnetId),
+			clientToken:  aws.ToString(runInput.ClientToken),
+		})
+		if len(attempts) <= len(errs) {
+			return errs[len(attempts)-1]
+		}
+		return nil
+	}, &attempts
+}
+
+func TestLaunchInstanceTypes(t *testing.T) {
+	defer func(format string) { outputFormat = format }(outputFormat)
+	outputFormat = "github-actions"
+
+	capacity := classifyAWSError(apiError("InsufficientInstanceCapacity"))
+	unsupported := classifyAWSError(apiError("Unsupported"))
+	limit := classifyAWSError(apiError("InstanceLimitExceeded"))
+
+	tests := []struct {
+		name          string
+		instanceTypes []string
+		subnets       []string
+		errs          []error
+		wantSubnet    string
+		wantErr       error
+		want          []launchAttempt
+	}{
+		{
+			name:          "first subnet and type",
+			instanceTypes: []string{"c6i.large", "c5.large"},
+			subnets:       []string{"subnet-a", "subnet-b"},
+			wantSubnet:    "subnet-a",
+			want:          []launchAttempt{{"c6i.large", "subnet-a", "tok"}},
+		},
+		{
+			name:          "every subnet before the next type",
+			instanceTypes: []string{"c6i.large", "c5.large"},
+			subnets:       []string{"subnet-a", "subnet-b"},
+			errs:          []error{capacity, unsupported},
+			wantSubnet:    "subnet-a",
+			want: []launchAttempt{
+				{"c6i.large", "subnet-a", "tok"},
+				{"c6i.large", "subnet-b", "tok-1"},
+				{"c5.large", "subnet-a", "tok-t1"},
+			},
+		},
+		{
+			name:          "other errors stop the fallback",
+			instanceTypes: []string{"c6i.large", "c5.large"},
+			subnets:       []string{"subnet-a", "subnet-b"},
+			errs:          []error{capacity, limit},
+			wantSubnet:    "subnet-b",
+			wantErr:       limit,
+			want: []launchAttempt{
+				{"c6i.large", "subnet-a", "tok"},
+				{"c6i.large", "subnet-b", "tok-1"},
+			},
+		},
+		{
+			name:          "last error when everything is short of capacity",
+			instanceTypes: []string{"c6i.large", "c5.large"},
+			subnets:       []string{"subnet-a", "subnet-b"},
+			errs:          []error{capacity, capacity, capacity, unsupported},
+			wantSubnet:    "subnet-b",
+			wantErr:       unsupported,
+			want: []launchAttempt{
+				{"c6i.large", "subnet-a", "tok"},
+				{"c6i.large", "subnet-b", "tok-1"},
+				{"c5.large", "subnet-a", "tok-t1"},
+				{"c5.large", "subnet-b", "tok-t1-1"},
+			},
+		},
+		{
+			name:          "single type keeps the request's instance type",
+			instanceTypes: []string{"c6i.large"},
+			subnets:       []string{"subnet-a", "subnet-b"},
+			errs:          []error{capacity},
+			wantSubnet:    "subnet-b",
+			want: []launchAttempt{
+				{"t3.micro", "subnet-a", "tok"},
+				{"t3.micro", "subnet-b", "tok-1"},
+			},
+		},
+		{
+			name:          "no subnets",
+			instanceTypes: []string{"c6i.large", "c5.large"},
+			errs:          []error{capacity},
+			want: []launchAttempt{
+				{"c6i.large", "", "tok"},
+				{"c5.large", "", "tok-t1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runInput := &ec2.RunInstancesInput{InstanceType: "t3.micro"}
+			launch, attempts := fakeLaunch(runInput, tt.errs...)
+
+			subnet, err := launchInstanceTypes(runInput, tt.instanceTypes, tt.subnets, "tok", launch)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("launchInstanceTypes error = %v, want %v", err, tt.wantErr)
+			}
+			if subnet != tt.wantSubnet {
+				t.Errorf("launchInstanceTypes subnet = %q, want %q", subnet, tt.wantSubnet)
+			}
+			if !slices.Equal(*attempts, tt.want) {
+				t.Errorf("launch attempts = %+v, want %+v", *attempts, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFallbackInstanceTypes(t *testing.T) {
+	defer func(types []string) { fallbackInstanceTypes = types }(fallbackInstanceTypes)
+	fallbackInstanceTypes = []string{"c5.large", "c6a.large"}
+
+	tests := []struct {
+		first string
+		want  []string
+	}{
+		{"c6i.large", []string{"c6i.large", "c5.large", "c6a.large"}},
+		{"c6a.large", []string{"c6a.large", "c5.large"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.first, func(t *testing.T) {
+			if got := withFallbackInstanceTypes(tt.first); !slices.Equal(got, tt.want) {
+				t.Errorf("withFallbackInstanceTypes(%q) = %q, want %q", tt.first, got, tt.want)
+			}
+		})
+	}
+}
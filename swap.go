@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var swapSize string
+
+// swapSizePattern matches a --swap-size such as 512M or 8G
+var swapSizePattern = regexp.MustCompile(`^([1-9][0-9]*)([MG])$`)
+
+// validateSwapSize checks --swap-size
+func validateSwapSize() error {
+	if swapSize == "" {
+		return nil
+	}
+	if !swapSizePattern.MatchString(swapSize) {
+		return fmt.Errorf("swap-size must be a size in megabytes or gigabytes such as 512M or 8G, got %q", swapSize)
+	}
+	if runnerOS != "linux" {
+		return fmt.Errorf("swap-size is only supported with --os linux")
+	}
+	return nil
+}
+
+// swapUserData returns user data lines creating and enabling a swap file of
+// --swap-size, falling back to dd where the file system cannot preallocate
+func swapUserData() []string {
+	match := swapSizePattern.FindStringSubmatch(swapSize)
+	if match == nil {
+		return nil
+	}
+	megabytes, _ := strconv.Atoi(match[1])
+	if match[2] == "G" {
+		megabytes *= 1024
+	}
+	return []string{
+		fmt.Sprintf("# Add %s of swap", swapSize),
+		fmt.Sprintf("fallocate -l %dM /swapfile || dd if=/dev/zero of=/swapfile bs=1M count=%d", megabytes, megabytes),
+		"chmod 600 /swapfile",
+		"mkswap /swapfile && swapon /swapfile",
+		"echo '/swapfile none swap sw 0 0' >> /etc/fstab",
+		"",
+	}
+}
+
+func init() {
+	createCmd.Flags().StringVar(&swapSize, "swap-size", "",
+		"Create a swap file of this size, e.g. 8G, so memory spikes do not get builds OOM-killed")
+}
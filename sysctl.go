@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var sysctlProfiles string
+
+// SysctlProfile is a curated set of kernel settings and limits for a kind of workload
+type SysctlProfile struct {
+	// Sysctls are key=value settings written to /etc/sysctl.d
+	Sysctls []string
+	// Modules are kernel modules loaded first, e.g. for bridge settings
+	Modules []string
+	// NoFile is the open file limit of the runner and of login sessions
+	NoFile int
+}
+
+// sysctlProfileSet are the profiles selectable with --sysctl-profile
+var sysctlProfileSet = map[string]SysctlProfile{
+	"fs-heavy": {
+		Sysctls: []string{
+			"fs.inotify.max_user_watches=1048576",
+			"fs.inotify.max_user_instances=8192",
+			"fs.inotify.max_queued_events=65536",
+			"fs.file-max=2097152",
+			"vm.max_map_count=262144",
+		},
+		NoFile: 1048576,
+	},
+	"network-heavy": {
+		Sysctls: []string{
+			"net.core.somaxconn=65535",
+			"net.core.netdev_max_backlog=16384",
+			"net.ipv4.tcp_max_syn_backlog=8192",
+			"net.ipv4.ip_local_port_range=1024 65535",
+			"net.ipv4.tcp_tw_reuse=1",
+			"net.ipv4.tcp_fin_timeout=15",
+		},
+		NoFile: 1048576,
+	},
+	"docker-default": {
+		Sysctls: []string{
+			"net.ipv4.ip_forward=1",
+			"net.bridge.bridge-nf-call-iptables=1",
+			"net.bridge.bridge-nf-call-ip6tables=1",
+			"fs.inotify.max_user_watches=524288",
+			"fs.inotify.max_user_instances=8192",
+			"vm.max_map_count=262144",
+		},
+		Modules: []string{"overlay", "br_netfilter"},
+		NoFile:  1048576,
+	},
+}
+
+// sysctlProfileNames returns the profile names in sorted order
+func sysctlProfileNames() []string {
+	names := make([]string, 0, len(sysctlProfileSet))
+	for name := range sysctlProfileSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateSysctlProfiles checks the comma-separated --sysctl-profile names
+func validateSysctlProfiles() error {
+	for _, name := range splitList(sysctlProfiles) {
+		if _, ok := sysctlProfileSet[name]; !ok {
+			return fmt.Errorf("sysctl-profile must be a comma-separated list of %s, got %q",
+				strings.Join(sysctlProfileNames(), ", "), name)
+		}
+	}
+	if len(splitList(sysctlProfiles)) > 0 && runnerOS != "linux" {
+		return fmt.Errorf("sysctl-profile is only supported with --os linux")
+	}
+	return nil
+}
+
+// sysctlUserData returns user data lines applying the selected profiles;
+// a setting repeated by a later profile wins, and the highest file limit is
+// used. The limit is raised in the bootstrap shell, which starts the runner
+func sysctlUserData() []string {
+	names := splitList(sysctlProfiles)
+	if len(names) == 0 {
+		return nil
+	}
+
+	settings := map[string]string{}
+	keys := []string{}
+	modules := []string{}
+	noFile := 0
+	for _, name := range names {
+		profile := sysctlProfileSet[name]
+		for _, setting := range profile.Sysctls {
+			key, value, _ := strings.Cut(setting, "=")
+			if _, seen := settings[key]; !seen {
+				keys = append(keys, key)
+			}
+			settings[key] = value
+		}
+		for _, module := range profile.Modules {
+			if !slices.Contains(modules, module) {
+				modules = append(modules, module)
+			}
+		}
+		noFile = max(noFile, profile.NoFile)
+	}
+
+	lines := []string{fmt.Sprintf("# Kernel tuning (%s)", strings.Join(names, ", "))}
+	for _, module := range modules {
+		lines = append(lines,
+			fmt.Sprintf("modprobe %s || true", module),
+			fmt.Sprintf("echo %s >> /etc/modules-load.d/gh-workflow.conf", module),
+		)
+	}
+	lines = append(lines, "cat > /etc/sysctl.d/90-gh-workflow.conf << 'EOF'")
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s = %s", key, settings[key]))
+	}
+	lines = append(lines, "EOF", "sysctl -p /etc/sysctl.d/90-gh-workflow.conf")
+	if noFile > 0 {
+		lines = append(lines,
+			fmt.Sprintf("printf '* soft nofile %[1]d\\n* hard nofile %[1]d\\n' "+
+				"> /etc/security/limits.d/90-gh-workflow.conf", noFile),
+			fmt.Sprintf("ulimit -n %d", noFile),
+		)
+	}
+	return append(lines, "")
+}
+
+func init() {
+	createCmd.Flags().StringVar(&sysctlProfiles, "sysctl-profile", "",
+		fmt.Sprintf("Kernel settings and limits to apply, as a comma-separated list of %s",
+			strings.Join(sysctlProfileNames(), ", ")))
+}
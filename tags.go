@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var customTags []string
+
+// builtinTagKeys are the tags gh-workflow sets and reads itself, which --tag
+// cannot override
+var builtinTagKeys = []string{
+	"Name", "Purpose", "Repository", "Labels", "RunnerName", "InstanceMarketType", "RunnerVersion", "UserDataHash",
+	"SpotMaxPrice", "CorrelationId", "RunId", "RunAttempt", "Workflow", "Actor", bootstrapStatusTag,
+	rebalanceTag, rebalanceReplacedTag,
+}
+
+// maxCustomTags leaves room for the built-in tags and job tags within the 50
+// tags EC2 allows per resource
+const maxCustomTags = 25
+
+// parseCustomTags parses the --tag key=value pairs
+func parseCustomTags() ([]types.Tag, error) {
+	if len(customTags) > maxCustomTags {
+		return nil, fmt.Errorf("at most %d tags can be added with --tag, got %d", maxCustomTags, len(customTags))
+	}
+	tags := []types.Tag{}
+	seen := map[string]bool{}
+	for _, spec := range customTags {
+		key, value, ok := strings.Cut(spec, "=")
+		key = strings.TrimSpace(key)
+		switch {
+		case !ok || key == "":
+			return nil, fmt.Errorf("tag %q must be key=value", spec)
+		case len(key) > 128 || len(value) > 256:
+			return nil, fmt.Errorf("tag %q is too long (keys up to 128, values up to 256 characters)", spec)
+		case strings.HasPrefix(strings.ToLower(key), "aws:"):
+			return nil, fmt.Errorf("tag key %q uses the reserved aws: prefix", key)
+		case strings.HasPrefix(key, jobTagPrefix) || containsFold(builtinTagKeys, key):
+			return nil, fmt.Errorf("tag key %q is set by gh-workflow itself", key)
+		case seen[key]:
+			return nil, fmt.Errorf("tag key %q is given more than once", key)
+		}
+		seen[key] = true
+		tags = append(tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return tags, nil
+}
+
+// containsFold reports whether list contains s, ignoring case, as tag keys that
+// only differ in case are easily confused in cost reports
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCustomTags checks the --tag flags
+func validateCustomTags() error {
+	_, err := parseCustomTags()
+	return err
+}
+
+func init() {
+	createCmd.Flags().StringArrayVar(&customTags, "tag", nil,
+		"Extra tag as key=value (repeatable) for the instance and the resources of --tag-resource-types")
+}
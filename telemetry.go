@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Telemetry configures the anonymous usage metric, read from the "telemetry"
+// section of the config file; nothing is sent unless enabled is true
+type Telemetry struct {
+	Enabled  bool   `yaml:"enabled"`
+	Endpoint string `yaml:"endpoint"`
+}
+
+// UsageMetric is the anonymous record sent per command invocation; it carries
+// no repository, account, instance or token information
+type UsageMetric struct {
+	Command    string `json:"command"`
+	DurationMS int64  `json:"duration_ms"`
+	Outcome    string `json:"outcome"`
+	ExitCode   int    `json:"exit_code"`
+	Version    string `json:"version"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+}
+
+// telemetryEnabled reports whether the config file opts in to telemetry;
+// DO_NOT_TRACK or GH_WORKFLOW_NO_TELEMETRY always disable it
+func telemetryEnabled() bool {
+	if os.Getenv("DO_NOT_TRACK") != "" || os.Getenv("GH_WORKFLOW_NO_TELEMETRY") != "" {
+		return false
+	}
+	return activeConfig != nil && activeConfig.Telemetry != nil &&
+		activeConfig.Telemetry.Enabled && activeConfig.Telemetry.Endpoint != ""
+}
+
+// sendUsageMetric posts the command, duration and outcome to the configured
+// endpoint when telemetry is enabled; failures are ignored
+func sendUsageMetric(cmd *cobra.Command, started time.Time, err error) {
+	if cmd == nil || !telemetryEnabled() {
+		return
+	}
+
+	metric := UsageMetric{
+		Command:    cmd.CommandPath(),
+		DurationMS: time.Since(started).Milliseconds(),
+		Outcome:    "success",
+		Version:    Version,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+	}
+	if err != nil {
+		metric.Outcome = "error"
+		metric.ExitCode = exitCode(err)
+	}
+
+	payload, merr := json.Marshal(metric)
+	if merr != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	req, rerr := http.NewRequestWithContext(ctx, "POST", activeConfig.Telemetry.Endpoint, bytes.NewReader(payload))
+	if rerr != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+
+	if resp, derr := http.DefaultClient.Do(req); derr == nil {
+		resp.Body.Close()
+	}
+}
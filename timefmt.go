@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var displayTZ string
+
+// displayLocation is the time zone human-readable output shows times in;
+// JSON output and events always use UTC
+var displayLocation = time.UTC
+
+// displayTimeLayout is how human-readable output shows times, with the zone
+// abbreviation so times from different team members' runs compare at a glance
+const displayTimeLayout = "2006-01-02 15:04:05 MST"
+
+// validateDisplayTZ resolves --tz: UTC, local or an IANA time zone name
+func validateDisplayTZ() error {
+	switch strings.ToLower(displayTZ) {
+	case "", "utc":
+		displayLocation = time.UTC
+	case "local":
+		displayLocation = time.Local
+	default:
+		location, err := time.LoadLocation(displayTZ)
+		if err != nil {
+			return fmt.Errorf("tz must be UTC, local or an IANA time zone such as Europe/Berlin, got %q", displayTZ)
+		}
+		displayLocation = location
+	}
+	return nil
+}
+
+// formatTime renders t in the --tz time zone
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.In(displayLocation).Format(displayTimeLayout)
+}
+
+// humanizeDuration renders d with its two largest units, e.g. 3d4h, 2h13m or 42s
+func humanizeDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	days, hours, minutes := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm", minutes)
+	default:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	}
+}
+
+// formatTimeRelative renders t in the --tz time zone together with how long
+// ago or from now it is, e.g. "2026-10-15 14:03:00 CEST (2h13m ago)"
+func formatTimeRelative(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	relative := time.Until(t)
+	if relative < 0 {
+		return fmt.Sprintf("%s (%s ago)", formatTime(t), humanizeDuration(relative))
+	}
+	return fmt.Sprintf("%s (in %s)", formatTime(t), humanizeDuration(relative))
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&displayTZ, "tz", "UTC",
+		"Time zone of the times in human-readable output: UTC, local or an IANA name such as America/New_York")
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var amiWaitTimeout time.Duration
+
+// awsRetryableCodes are the AWS error codes the SDK does not retry but that
+// clear up on their own within the retry backoff: network interfaces of
+// recently terminated instances are released within a minute or two
+var awsRetryableCodes = map[string]bool{
+	"NetworkInterfaceLimitExceeded": true,
+}
+
+// isRetryableAWSError makes the retryer retry awsRetryableCodes, leaving the
+// other errors to the SDK's own retryables
+func isRetryableAWSError(err error) aws.Ternary {
+	if awsRetryableCodes[awsErrorCode(err)] {
+		return aws.TrueTernary
+	}
+	return aws.UnknownTernary
+}
+
+// awsErrorHints suggest how to work around AWS errors the tool cannot, or
+// could not, work around itself
+var awsErrorHints = map[string]string{
+	"InsufficientFreeAddressesInSubnet": "The subnet has no free IP addresses left; pass several subnets to " +
+		"--subnet-id (e.g. subnet-a,subnet-b) so create falls back to the next one",
+	"InsufficientInstanceCapacity": "AWS has no capacity for the instance type in this availability zone; pass " +
+		"subnets in several AZs to --subnet-id, or try another instance type",
+	"NetworkInterfaceLimitExceeded": "The region's network interface quota is used up; interfaces of terminated " +
+		"instances are released within minutes, so run `gh-workflow gc` or request a quota increase",
+	"AttachmentLimitExceeded": "The instance type supports fewer network interfaces than requested; choose a " +
+		"larger instance type",
+	"InvalidAMIID.Unavailable": "The AMI is not available (still pending, failed or deregistered); check it with " +
+		"`aws ec2 describe-images`, or raise --ami-wait-timeout for AMIs that are still being built",
+	"VcpuLimitExceeded": "The account's vCPU quota for this instance family is used up; terminate idle runners " +
+		"(`gh-workflow gc`) or request a quota increase in Service Quotas",
+	"InstanceLimitExceeded": "The account's instance quota is used up; terminate idle runners (`gh-workflow gc`) " +
+		"or request a quota increase in Service Quotas",
+	"MaxSpotInstanceCountExceeded": "The account's spot instance quota is used up; use --instance-market-type " +
+		"on-demand or request a quota increase",
+	"OperationNotPermitted": "The instance has termination protection (--disable-api-termination); " +
+		"`gh-workflow terminate --force` lifts it",
+	"Unsupported": "The instance type is not offered in this availability zone; pass subnets in other AZs to " +
+		"--subnet-id",
+}
+
+// remediationHint returns the hint for the AWS error err wraps, or ""
+func remediationHint(err error) string {
+	if errors.Is(err, ErrDeadline) {
+		return ""
+	}
+	return awsErrorHints[awsErrorCode(err)]
+}
+
+// waitForPendingAMI waits for an AMI that is still being created, e.g. right
+// after `ami build` or a copy to another region, instead of failing the launch
+func waitForPendingAMI(svc *ec2.Client, imageID string) error {
+	input := &ec2.DescribeImagesInput{ImageIds: []string{imageID}, IncludeDeprecated: aws.Bool(true)}
+	result, err := svc.DescribeImages(context.TODO(), input)
+	if err != nil {
+		return fmt.Errorf("failed to describe AMI %s: %w", imageID, classifyAWSError(err))
+	}
+	if len(result.Images) == 0 {
+		return fmt.Errorf("%w: AMI %s does not exist in %s", ErrNotFound, imageID, resolveRegion())
+	}
+	switch state := result.Images[0].State; state {
+	case types.ImageStatePending:
+	case types.ImageStateAvailable, "":
+		return nil
+	default:
+		return fmt.Errorf("AMI %s is %s and cannot be launched", imageID, state)
+	}
+
+	if amiWaitTimeout <= 0 {
+		return fmt.Errorf("AMI %s is still pending (set --ami-wait-timeout to wait for it)", imageID)
+	}
+	emitEvent("ami.pending", map[string]any{"image_id": imageID, "timeout": amiWaitTimeout.String()})
+	if outputFormat != "github-actions" {
+		fmt.Fprintf(os.Stderr, "⏳ AMI %s is still pending, waiting up to %s for it to become available...\n",
+			imageID, amiWaitTimeout)
+	}
+	waiter := ec2.NewImageAvailableWaiter(svc, func(o *ec2.ImageAvailableWaiterOptions) {
+		o.MinDelay = 15 * time.Second
+		o.MaxDelay = 30 * time.Second
+	})
+	if err := waiter.Wait(context.TODO(), input, amiWaitTimeout); err != nil {
+		return fmt.Errorf("AMI %s did not become available: %w", imageID, classifyAWSError(err))
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().DurationVar(&amiWaitTimeout, "ami-wait-timeout", 20*time.Minute,
+		"Wait up to this long for a pending AMI, e.g. one still being built (0 to fail right away)")
+}
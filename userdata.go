@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	userDataHashOnly   bool
+	userDataAgainst    string
+	againstConfig      string
+	againstBinary      string
+	failOnUserDataDiff bool
+)
+
+// Placeholders for the per-launch parts of the user data, so its hash only
+// changes with the configuration
+const (
+	userDataTokenPlaceholder  = "<registration-token>"
+	userDataRunnerPlaceholder = "<runner-name>"
+)
+
+// maxUserDataSize is the most user data EC2 accepts, before base64 encoding
+const maxUserDataSize = 16 * 1024
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// renderUserData returns the user data a create with the current flags
+// generates, with placeholders for the registration token and runner name
+func renderUserData(repoOwner, repoName, runnerLabels, preRunnerScript string) string {
+	return generateUserData(userDataTokenPlaceholder, repoOwner, repoName, runnerLabels, preRunnerScript,
+		userDataRunnerPlaceholder)
+}
+
+// userDataHash returns a short hash of the rendered user data, which only
+// changes with the configuration
+func userDataHash(repoOwner, repoName, runnerLabels, preRunnerScript string) string {
+	sum := sha256.Sum256([]byte(renderUserData(repoOwner, repoName, runnerLabels, preRunnerScript)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// lintUserData returns the problems of rendered user data: its size, leftover
+// formatting directives and, for Linux, the shell syntax and shellcheck errors
+// when bash and shellcheck are installed; the pre-runner script is checked on
+// its own, as the user data only writes it to a file
+func lintUserData(userData, preRunnerScript string) []string {
+	problems := []string{}
+	if len(userData) > maxUserDataSize {
+		problems = append(problems, fmt.Sprintf("user data is %d bytes, EC2 accepts at most %d", len(userData),
+			maxUserDataSize))
+	}
+	for i, line := range strings.Split(userData, "\n") {
+		if strings.Contains(line, "%!") {
+			problems = append(problems, fmt.Sprintf("line %d has a formatting error: %s", i+1, strings.TrimSpace(line)))
+		}
+	}
+
+	if runnerOS == "windows" {
+		if !strings.HasPrefix(userData, "<powershell>") || !strings.Contains(userData, "</powershell>") {
+			problems = append(problems, "user data is not wrapped in <powershell> tags")
+		}
+		return problems
+	}
+
+	if !strings.HasPrefix(userData, "#!") {
+		problems = append(problems, "user data does not start with a #! line")
+	}
+	if _, err := exec.LookPath("bash"); err == nil {
+		scripts := []struct{ name, source string }{
+			{"user data", userData},
+			{"pre-runner script", resolvePreRunnerScript(preRunnerScript)},
+		}
+		for _, script := range scripts {
+			check := exec.Command("bash", "-n")
+			check.Stdin = strings.NewReader(script.source)
+			if output, err := check.CombinedOutput(); err != nil {
+				problems = append(problems, fmt.Sprintf("%s has a shell syntax error: %s", script.name,
+					strings.TrimSpace(string(output))))
+			}
+		}
+	}
+	if _, err := exec.LookPath("shellcheck"); err == nil {
+		// Only errors; the generated script relies on word splitting and the like on purpose
+		check := exec.Command("shellcheck", "--shell=bash", "--severity=error", "--format=gcc", "-")
+		check.Stdin = strings.NewReader(userData)
+		output, _ := check.Output()
+		for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+			if line != "" {
+				problems = append(problems, "shellcheck: "+strings.TrimPrefix(line, "-:"))
+			}
+		}
+	}
+	return problems
+}
+
+// diffOp is a line of an edit script: ' ' kept, '-' removed or '+' added
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// diffLines returns the edit script turning a into b, from their longest
+// common subsequence; user data is small enough for the quadratic table
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := []diffOp{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// splitLines splits text into lines without a trailing empty line
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// unifiedDiff returns the differences between two texts in unified diff
+// format, or "" when they are equal
+func unifiedDiff(fromName, toName, from, to string) string {
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	// fromLine[i] and toLine[i] count the lines of each text before ops[i]
+	fromLine, toLine := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		fromLine[i+1], toLine[i+1] = fromLine[i], toLine[i]
+		if op.kind != '+' {
+			fromLine[i+1]++
+		}
+		if op.kind != '-' {
+			toLine[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+
+		// Changes closer than twice the context share a hunk
+		start, last := max(i-diffContext, 0), i
+		for j := i; j < len(ops) && j <= last+2*diffContext; j++ {
+			if ops[j].kind != ' ' {
+				last = j
+			}
+		}
+		end := min(last+diffContext+1, len(ops))
+
+		fromStart, fromCount := fromLine[start]+1, fromLine[end]-fromLine[start]
+		toStart, toCount := toLine[start]+1, toLine[end]-toLine[start]
+		if fromCount == 0 {
+			fromStart--
+		}
+		if toCount == 0 {
+			toStart--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(&out, "%c%s\n", op.kind, op.text)
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// renderWithOtherFlags renders the user data with another gh-workflow binary
+// and/or config file by running `userdata render` with this command's other
+// arguments, so the two flag sets don't mix
+func renderWithOtherFlags(binary, config string) (string, error) {
+	if binary == "" {
+		self, err := os.Executable()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate gh-workflow: %v", err)
+		}
+		binary = self
+	}
+
+	args := []string{}
+	skip := false
+	renamed := false
+	for _, arg := range os.Args[1:] {
+		if skip {
+			skip = false
+			continue
+		}
+		name, _, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--against", "--against-config", "--against-binary":
+			skip = !hasValue
+			continue
+		case "--fail-on-diff":
+			continue
+		case "diff":
+			if !renamed {
+				arg, renamed = "render", true
+			}
+		}
+		args = append(args, arg)
+	}
+	if config != "" {
+		args = append(args, "--config", config)
+	}
+
+	var stderr bytes.Buffer
+	render := exec.Command(binary, args...)
+	render.Stderr = &stderr
+	output, err := render.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = fmt.Errorf("%v: %s", err, message)
+		}
+		return "", fmt.Errorf("failed to render the user data with %s %s: %v", binary, strings.Join(args, " "), err)
+	}
+	return string(output), nil
+}
+
+var userDataCmd = &cobra.Command{
+	Use:   "userdata",
+	Short: "Render, lint and diff runner user data",
+	Long: "Render, lint and diff the user data create generates, so bootstrap changes can be reviewed, e.g. in " +
+		"PRs of workflow repositories; these commands run without network access",
+	PersistentPreRunE: offlinePreRun,
+}
+
+var userDataRenderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Print the user data create would generate",
+	Long: "Print the user data create would generate with the given flags and config file, with placeholders for " +
+		"the registration token and runner name",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateCreateFlags(); err != nil {
+			return err
+		}
+		if userDataHashOnly {
+			fmt.Println(userDataHash(repoOwner, repoName, runnerLabels, preRunnerScript))
+			return nil
+		}
+		fmt.Print(renderUserData(repoOwner, repoName, runnerLabels, preRunnerScript))
+		return nil
+	},
+}
+
+var userDataLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check the user data create would generate",
+	Long: "Check the user data create would generate for its size limit and formatting errors and, on Linux, " +
+		"shell syntax errors (with bash) and shellcheck errors (when shellcheck is installed)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateCreateFlags(); err != nil {
+			return err
+		}
+		userData := renderUserData(repoOwner, repoName, runnerLabels, preRunnerScript)
+		problems := lintUserData(userData, preRunnerScript)
+		if len(problems) == 0 {
+			fmt.Printf("✅ User data is valid (%d bytes)\n", len(userData))
+			return nil
+		}
+		for _, problem := range problems {
+			if outputFormat == "github-actions" {
+				fmt.Printf("::error::%s\n", problem)
+			} else {
+				fmt.Printf("❌ %s\n", problem)
+			}
+		}
+		return fmt.Errorf("found %d problem(s) in the user data", len(problems))
+	},
+}
+
+var userDataDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the user data of two configurations or versions",
+	Long: "Compare the user data create would generate with the given flags against a file rendered before " +
+		"(--against), another config file (--against-config) or another gh-workflow binary (--against-binary)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if userDataAgainst == "" && againstConfig == "" && againstBinary == "" {
+			return fmt.Errorf("one of --against, --against-config or --against-binary is required")
+		}
+		if userDataAgainst != "" && (againstConfig != "" || againstBinary != "") {
+			return fmt.Errorf("against cannot be combined with --against-config or --against-binary")
+		}
+		if err := validateCreateFlags(); err != nil {
+			return err
+		}
+		userData := renderUserData(repoOwner, repoName, runnerLabels, preRunnerScript)
+
+		var base, baseName string
+		if userDataAgainst != "" {
+			data, err := os.ReadFile(userDataAgainst)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %v", userDataAgainst, err)
+			}
+			base, baseName = string(data), userDataAgainst
+		} else {
+			rendered, err := renderWithOtherFlags(againstBinary, againstConfig)
+			if err != nil {
+				return err
+			}
+			base, baseName = rendered, strings.TrimSpace(againstBinary+" "+againstConfig)
+		}
+
+		diff := unifiedDiff(baseName, "current", base, userData)
+		if diff == "" {
+			fmt.Fprintln(os.Stderr, "✅ The user data is the same")
+			return nil
+		}
+		fmt.Print(diff)
+		if failOnUserDataDiff {
+			return fmt.Errorf("the user data differs from %s", baseName)
+		}
+		return nil
+	},
+}
+
+func init() {
+	userDataRenderCmd.Flags().BoolVar(&userDataHashOnly, "hash", false,
+		"Print only the hash recorded in the UserDataHash tag of instances")
+	userDataDiffCmd.Flags().StringVar(&userDataAgainst, "against", "", "User data file rendered before to compare with")
+	userDataDiffCmd.Flags().StringVar(&againstConfig, "against-config", "",
+		"Config file to render the other user data with, keeping the other flags")
+	userDataDiffCmd.Flags().StringVar(&againstBinary, "against-binary", "",
+		"gh-workflow binary, e.g. another version, to render the other user data with")
+	userDataDiffCmd.Flags().BoolVar(&failOnUserDataDiff, "fail-on-diff", false,
+		"Exit with an error when the user data differs")
+	userDataCmd.AddCommand(userDataRenderCmd, userDataLintCmd, userDataDiffCmd)
+	rootCmd.AddCommand(userDataCmd)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"go.starlark.net/starlark"
+)
+
+// Formats of the AWS identifiers and names validate checks offline
+var (
+	imageIDPattern       = regexp.MustCompile(`^ami-[0-9a-f]{8}([0-9a-f]{9})?$`)
+	subnetIDPattern      = regexp.MustCompile(`^subnet-[0-9a-f]{8}([0-9a-f]{9})?$`)
+	securityGroupPattern = regexp.MustCompile(`^sg-[0-9a-f]{8}([0-9a-f]{9})?$`)
+	instanceTypePattern  = regexp.MustCompile(`^[a-z0-9-]+\.[a-z0-9-]+$`)
+)
+
+// maxRunnerNameLength is the longest runner name GitHub accepts
+const maxRunnerNameLength = 64
+
+// maxKeyNameLength is the longest EC2 key pair name
+const maxKeyNameLength = 255
+
+// offlineProblems returns the problems with the create flags that can be found
+// without calling AWS or GitHub, beyond the checks create itself makes
+func offlineProblems() []string {
+	problems := []string{}
+	check := func(failed bool, format string, args ...any) {
+		if failed {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(imageID != "" && !imageIDPattern.MatchString(imageID), "image-id %q is not an AMI ID (ami-...)", imageID)
+	for _, subnet := range splitList(subnetID) {
+		check(!subnetIDPattern.MatchString(subnet), "subnet-id %q is not a subnet ID (subnet-...)", subnet)
+	}
+	check(securityGroupID != "" && !securityGroupPattern.MatchString(securityGroupID),
+		"security-group %q is not a security group ID (sg-...)", securityGroupID)
+	check(len(keyName) > maxKeyNameLength, "key-name is %d characters long (max %d)", len(keyName), maxKeyNameLength)
+	for _, launchType := range withFallbackInstanceTypes(instanceType) {
+		check(launchType != "" && !instanceTypePattern.MatchString(launchType),
+			"instance-type %q is not an instance type (e.g. t3.medium)", launchType)
+	}
+
+	if spotMaxPrice != "" {
+		price, err := strconv.ParseFloat(spotMaxPrice, 64)
+		check(err != nil || price <= 0, "spot-max-price %q is not a positive price in USD", spotMaxPrice)
+		check(instanceMarketType != "spot", "spot-max-price requires --instance-market-type spot")
+	}
+
+	for _, label := range strings.Split(runnerLabels, ",") {
+		check(strings.TrimSpace(label) == "", "labels %q contains an empty label", runnerLabels)
+		check(strings.ContainsAny(label, " \t"), "label %q contains whitespace", label)
+	}
+	check(len(runnerName) > maxRunnerNameLength,
+		"runner-name is %d characters long (max %d)", len(runnerName), maxRunnerNameLength)
+
+	check(registrationWait < 0, "wait-for-registration must not be negative")
+	check(amiMaxAgeDays < 0, "max-ami-age-days must not be negative")
+	check(maxRunners < 0, "max-runners must not be negative")
+	check(dnsTTL < 0, "dns-ttl must not be negative")
+	check(probeTimeout <= 0, "probe-timeout must be positive")
+	check(timeSyncTimeout < 0, "time-sync-timeout must not be negative")
+	if err := validateAssumeRoleFlags(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := validateAWSEndpointURL(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := validatePartitionFlags(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := validateAWSRetryFlags(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if _, err := template.New("dns-name").Option("missingkey=error").Parse(dnsNameTemplate); err != nil {
+		problems = append(problems, fmt.Sprintf("dns-name-template is not a valid Go template: %v", err))
+	}
+	if placementScript != "" {
+		src, err := os.ReadFile(placementScript)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("failed to read placement script: %v", err))
+		} else if _, _, err := starlark.SourceProgram(placementScript, src, func(name string) bool {
+			return name == "spot_price" || name == "subnet"
+		}); err != nil {
+			problems = append(problems, fmt.Sprintf("placement script %s is invalid: %v", placementScript, err))
+		}
+	}
+	return problems
+}
+
+// offlinePreRun replaces the root hooks for commands that work without network
+// access: it applies the config file but resolves no secrets or tokens
+func offlinePreRun(cmd *cobra.Command, args []string) error {
+	if err := validateEventsFormat(); err != nil {
+		return err
+	}
+	if err := applyConfig(cmd); err != nil {
+		return err
+	}
+	return validateGitHubURLs()
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check create flags and config without calling AWS or GitHub",
+	Long: "Check the create flags and config file for missing, malformed and conflicting values and invalid " +
+		"templates and scripts, without any network access, e.g. in PR checks of workflow repositories",
+	// Skip the root hooks that resolve secrets and tokens, which may need the network
+	PersistentPreRunE: offlinePreRun,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		problems := []string{}
+		if err := validateCreateFlags(); err != nil {
+			problems = append(problems, err.Error())
+		}
+		problems = append(problems, offlineProblems()...)
+
+		if len(problems) == 0 {
+			fmt.Println("✅ Configuration is valid")
+			return nil
+		}
+		for _, problem := range problems {
+			if outputFormat == "github-actions" {
+				fmt.Printf("::error::%s\n", problem)
+			} else {
+				fmt.Printf("❌ %s\n", problem)
+			}
+		}
+		return fmt.Errorf("found %d problem(s)", len(problems))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/spf13/cobra"
+)
+
+var (
+	secretsBackend        string
+	vaultAddr             string
+	vaultNamespace        string
+	vaultAuthMethod       string
+	vaultAuthMount        string
+	vaultK8sRole          string
+	vaultGitHubTokenPath  string
+	vaultAWSCredsPath     string
+	vaultServiceTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// secretAWSCredentials, when set, takes precedence over environment credentials
+var secretAWSCredentials aws.CredentialsProvider
+
+// vaultClient is a minimal Vault HTTP API client
+type vaultClient struct {
+	addr      string
+	namespace string
+	token     string
+	http      *http.Client
+}
+
+// vaultSecret is the common envelope of Vault read and login responses
+type vaultSecret struct {
+	Data          map[string]any `json:"data"`
+	LeaseDuration int            `json:"lease_duration"`
+	Auth          *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// newVaultClient creates a Vault client authenticated with the configured method
+func newVaultClient() (*vaultClient, error) {
+	if vaultAddr == "" {
+		return nil, fmt.Errorf("vault-addr (or VAULT_ADDR) is required with the vault secrets backend")
+	}
+
+	client := &vaultClient{
+		addr:      strings.TrimRight(vaultAddr, "/"),
+		namespace: vaultNamespace,
+		http:      &http.Client{Timeout: 30 * time.Second},
+	}
+
+	switch vaultAuthMethod {
+	case "token":
+		client.token = os.Getenv("VAULT_TOKEN")
+		if client.token == "" {
+			return nil, fmt.Errorf("%w: VAULT_TOKEN is required for vault-auth token", ErrAuth)
+		}
+	case "approle":
+		roleID, secretID := os.Getenv("VAULT_ROLE_ID"), os.Getenv("VAULT_SECRET_ID")
+		if roleID == "" || secretID == "" {
+			return nil, fmt.Errorf("%w: VAULT_ROLE_ID and VAULT_SECRET_ID are required for vault-auth approle", ErrAuth)
+		}
+		if err := client.login(vaultAuthMountOr("approle"), map[string]string{
+			"role_id":   roleID,
+			"secret_id": secretID,
+		}); err != nil {
+			return nil, err
+		}
+	case "kubernetes":
+		if vaultK8sRole == "" {
+			return nil, fmt.Errorf("vault-k8s-role is required for vault-auth kubernetes")
+		}
+		jwt, err := os.ReadFile(vaultServiceTokenPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Kubernetes service account token: %v", err)
+		}
+		if err := client.login(vaultAuthMountOr("kubernetes"), map[string]string{
+			"role": vaultK8sRole,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		}); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("vault-auth must be 'token', 'approle' or 'kubernetes'")
+	}
+
+	return client, nil
+}
+
+// vaultAuthMountOr returns the configured auth mount or the method's default mount
+func vaultAuthMountOr(defaultMount string) string {
+	if vaultAuthMount != "" {
+		return vaultAuthMount
+	}
+	return defaultMount
+}
+
+// do sends a request to the Vault API and decodes the response
+func (c *vaultClient) do(method, path string, payload any) (*vaultSecret, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode Vault request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.addr+"/v1/"+strings.TrimLeft(path, "/"), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", userAgent())
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Vault: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Vault response: %v", err)
+	}
+
+	var secret vaultSecret
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &secret); err != nil {
+			return nil, fmt.Errorf("failed to parse Vault response: %v", err)
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized:
+		return nil, fmt.Errorf("%w: Vault %s %s: %s", ErrAuth, method, path, strings.Join(secret.Errors, "; "))
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: Vault path %s", ErrNotFound, path)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, fmt.Errorf("Vault %s %s returned status %d: %s",
+			method, path, resp.StatusCode, strings.Join(secret.Errors, "; "))
+	}
+	return &secret, nil
+}
+
+// login authenticates against an auth mount and stores the client token
+func (c *vaultClient) login(mount string, payload map[string]string) error {
+	secret, err := c.do("POST", "auth/"+mount+"/login", payload)
+	if err != nil {
+		return fmt.Errorf("Vault login failed: %w", err)
+	}
+	if secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return fmt.Errorf("%w: Vault login returned no client token", ErrAuth)
+	}
+	c.token = secret.Auth.ClientToken
+	return nil
+}
+
+// read returns the data stored at path, unwrapping KV version 2 responses
+func (c *vaultClient) read(path string) (map[string]any, error) {
+	secret, err := c.do("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if inner, ok := secret.Data["data"].(map[string]any); ok {
+		if _, isKV2 := secret.Data["metadata"]; isKV2 {
+			return inner, nil
+		}
+	}
+	return secret.Data, nil
+}
+
+// readField reads a single string field from a "path#field" reference
+func (c *vaultClient) readField(ref, defaultField string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok {
+		field = defaultField
+	}
+
+	data, err := c.read(path)
+	if err != nil {
+		return "", err
+	}
+	value, ok := data[field].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("%w: field %q at Vault path %s", ErrNotFound, field, path)
+	}
+	return value, nil
+}
+
+// readAWSCredentials reads credentials from a Vault AWS secrets engine path
+// (e.g. aws/creds/runner-launcher or aws/sts/runner-launcher), expiring with their lease
+func (c *vaultClient) readAWSCredentials(path string) (aws.Credentials, error) {
+	secret, err := c.do("GET", path, nil)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	accessKey, _ := secret.Data["access_key"].(string)
+	secretKey, _ := secret.Data["secret_key"].(string)
+	sessionToken, _ := secret.Data["security_token"].(string)
+	if accessKey == "" || secretKey == "" {
+		return aws.Credentials{}, fmt.Errorf("Vault path %s did not return AWS credentials", path)
+	}
+	creds := aws.Credentials{
+		AccessKeyID:     accessKey,
+		SecretAccessKey: secretKey,
+		SessionToken:    sessionToken,
+		Source:          "Vault " + path,
+	}
+	if secret.LeaseDuration > 0 {
+		creds.CanExpire = true
+		creds.Expires = time.Now().Add(time.Duration(secret.LeaseDuration) * time.Second)
+	}
+	return creds, nil
+}
+
+// vaultAWSCredentials returns a provider for the credentials at a Vault AWS
+// secrets engine path; they are read once up front, so a bad path fails
+// early, and read again shortly before their lease runs out during long waits
+func (c *vaultClient) vaultAWSCredentials(path string) (aws.CredentialsProvider, error) {
+	first, err := c.readAWSCredentials(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &first
+	provider := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		if pending != nil {
+			creds := *pending
+			pending = nil
+			return creds, nil
+		}
+		return c.readAWSCredentials(path)
+	})
+	return refreshingCredentials(provider), nil
+}
+
+// resolveSecrets fetches the GitHub token and AWS credentials from the
+// configured secrets backend; explicitly passed flags are left untouched
+func resolveSecrets(cmd *cobra.Command) error {
+	switch secretsBackend {
+	case "":
+		return nil
+	case "vault":
+	default:
+		return fmt.Errorf("secrets-backend must be 'vault' when set")
+	}
+
+	tokenFlag := cmd.Flags().Lookup("github-token")
+	needToken := vaultGitHubTokenPath != "" && tokenFlag != nil && tokenFlag.Value.String() == ""
+	if !needToken && vaultAWSCredsPath == "" {
+		return nil
+	}
+
+	client, err := newVaultClient()
+	if err != nil {
+		return err
+	}
+
+	if needToken {
+		token, err := client.readField(vaultGitHubTokenPath, "token")
+		if err != nil {
+			return fmt.Errorf("failed to read GitHub token from Vault: %w", err)
+		}
+		if err := tokenFlag.Value.Set(token); err != nil {
+			return err
+		}
+	}
+
+	if vaultAWSCredsPath != "" {
+		creds, err := client.vaultAWSCredentials(vaultAWSCredsPath)
+		if err != nil {
+			return fmt.Errorf("failed to read AWS credentials from Vault: %w", err)
+		}
+		secretAWSCredentials = creds
+	}
+	return nil
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&secretsBackend, "secrets-backend", "", "Fetch secrets from a backend (vault)")
+	flags.StringVar(&vaultAddr, "vault-addr", os.Getenv("VAULT_ADDR"), "Vault address")
+	flags.StringVar(&vaultNamespace, "vault-namespace", os.Getenv("VAULT_NAMESPACE"), "Vault namespace")
+	flags.StringVar(&vaultAuthMethod, "vault-auth", "token", "Vault auth method (token, approle or kubernetes)")
+	flags.StringVar(&vaultAuthMount, "vault-auth-mount", "", "Vault auth mount (default: the method name)")
+	flags.StringVar(&vaultK8sRole, "vault-k8s-role", "", "Vault role for Kubernetes auth")
+	flags.StringVar(&vaultGitHubTokenPath, "vault-github-token-path", "",
+		"Vault path holding the GitHub token, as path#field (default field: token)")
+	flags.StringVar(&vaultAWSCredsPath, "vault-aws-path", "",
+		"Vault AWS secrets engine path for credentials, e.g. aws/creds/runner-launcher")
+}
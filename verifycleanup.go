@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+var verifyCleanup bool
+
+// CleanupCheck is the outcome of checking one thing a terminated runner must
+// not leave behind
+type CleanupCheck struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Cleanup check statuses; only left-behind checks fail the verification
+const (
+	cleanupGone       = "gone"
+	cleanupKept       = "kept"
+	cleanupLeftBehind = "left-behind"
+	cleanupUnverified = "unverified"
+)
+
+// terminateAndVerify terminates an instance, then verifies that the instance,
+// its GitHub runner and its auxiliary resources are all gone
+func terminateAndVerify(instanceID string, force bool, timeoutSeconds int) error {
+	err := terminateEC2Instance(instanceID, force, timeoutSeconds)
+	if !verifyCleanup || errors.Is(err, ErrReadOnly) || errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return errors.Join(err, verifyInstanceCleanup(instanceID))
+}
+
+// verifyInstanceCleanup checks what a terminated runner instance left behind,
+// reports every check and returns an ErrIncompleteCleanup error listing the
+// leftovers. Terminated instances stay visible for about an hour, which is
+// where the runner name and the auxiliary resources are read from
+func verifyInstanceCleanup(instanceID string) error {
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return err
+	}
+	svc := ec2.NewFromConfig(cfg)
+
+	result, err := svc.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{InstanceIds: []string{instanceID}})
+	if err != nil && !errors.Is(classifyAWSError(err), ErrNotFound) {
+		return fmt.Errorf("failed to verify cleanup of instance %s: %w", instanceID, classifyAWSError(err))
+	}
+	if err != nil || len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
+		return reportCleanup(instanceID, []CleanupCheck{{Type: "instance", ID: instanceID, Status: cleanupGone}})
+	}
+
+	instance := result.Reservations[0].Instances[0]
+	checks := []CleanupCheck{{Type: "instance", ID: instanceID, Status: cleanupGone}}
+	if state := string(instance.State.Name); state != "terminated" {
+		checks[0] = CleanupCheck{Type: "instance", ID: instanceID, Status: cleanupLeftBehind, Detail: state}
+	}
+	checks = append(checks, checkRunnerCleanup(instance))
+	for _, resource := range auxResourcesFromInstance(instance) {
+		checks = append(checks, checkAuxResourceCleanup(cfg, svc, resource))
+	}
+	return reportCleanup(instanceID, checks)
+}
+
+// checkRunnerCleanup checks that the instance's runner was removed from GitHub
+func checkRunnerCleanup(instance types.Instance) CleanupCheck {
+	runnerName, repository := instanceTag(instance, "RunnerName"), instanceTag(instance, "Repository")
+	check := CleanupCheck{Type: "runner", ID: runnerName, Detail: repository}
+	switch {
+	case runnerName == "" || repository == "":
+		check.Status, check.Detail = cleanupUnverified, "instance has no RunnerName or Repository tag"
+	case githubToken == "":
+		check.Status, check.Detail = cleanupUnverified, "no --github-token"
+	default:
+		runner, err := findRepoRunner(githubToken, repository, runnerName)
+		switch {
+		case err != nil:
+			check.Status, check.Detail = cleanupUnverified, err.Error()
+		case runner != nil:
+			check.Status, check.Detail = cleanupLeftBehind, fmt.Sprintf("still registered in %s (%s)",
+				repository, runner.Status)
+		default:
+			check.Status = cleanupGone
+		}
+	}
+	return check
+}
+
+// checkAuxResourceCleanup checks that an auxiliary resource was deleted;
+// volumes kept with --keep-volumes are expected to remain
+func checkAuxResourceCleanup(cfg aws.Config, svc *ec2.Client, resource AuxResource) CleanupCheck {
+	check := CleanupCheck{Type: resource.Type, ID: resource.ID}
+	var (
+		exists bool
+		err    error
+	)
+	switch resource.Type {
+	case auxVolume:
+		var result *ec2.DescribeVolumesOutput
+		result, err = svc.DescribeVolumes(context.TODO(), &ec2.DescribeVolumesInput{VolumeIds: []string{resource.ID}})
+		if err == nil && len(result.Volumes) > 0 {
+			state := string(result.Volumes[0].State)
+			exists = state != "deleting" && state != "deleted"
+			check.Detail = state
+		}
+		if exists && keepVolumes {
+			check.Status, check.Detail = cleanupKept, "--keep-volumes"
+			return check
+		}
+	case auxEIP:
+		var result *ec2.DescribeAddressesOutput
+		result, err = svc.DescribeAddresses(context.TODO(), &ec2.DescribeAddressesInput{
+			AllocationIds: []string{resource.ID},
+		})
+		exists = err == nil && len(result.Addresses) > 0
+	case auxSSMParameter:
+		_, err = ssm.NewFromConfig(cfg).GetParameter(context.TODO(), &ssm.GetParameterInput{
+			Name: aws.String(resource.ID),
+		})
+		exists = err == nil
+	case auxDNSRecord:
+		recordType, name, _ := strings.Cut(resource.ID, "/")
+		record, findErr := findDNSRecord(route53.NewFromConfig(cfg), resource.Detail, name, recordType)
+		exists, err = record != nil, findErr
+	case auxAlarm:
+		exists, err = alarmExists(cfg, resource.ID)
+	default:
+		check.Status, check.Detail = cleanupUnverified, "unknown resource type"
+		return check
+	}
+
+	switch {
+	case err != nil && !errors.Is(classifyAWSError(err), ErrNotFound):
+		check.Status, check.Detail = cleanupUnverified, classifyAWSError(err).Error()
+	case exists:
+		check.Status = cleanupLeftBehind
+	default:
+		check.Status = cleanupGone
+	}
+	return check
+}
+
+// reportCleanup prints the cleanup checks of an instance and returns an
+// ErrIncompleteCleanup error naming everything left behind
+func reportCleanup(instanceID string, checks []CleanupCheck) error {
+	var leftovers []string
+	for _, check := range checks {
+		if check.Status == cleanupLeftBehind {
+			leftovers = append(leftovers, check.Type+" "+check.ID)
+		}
+	}
+	emitEvent("cleanup.verified", map[string]any{
+		"instance_id": instanceID, "checks": checks, "complete": len(leftovers) == 0,
+	})
+
+	if outputFormat == "github-actions" {
+		status := "complete"
+		if len(leftovers) > 0 {
+			status = "incomplete"
+		}
+		fmt.Printf("Cleanup Status: %s\n", status)
+		fmt.Printf("Leftover Resources: %s\n", strings.Join(leftovers, ","))
+	} else {
+		fmt.Printf("🔎 Cleanup of instance %s:\n", instanceID)
+		icons := map[string]string{
+			cleanupGone: "✅", cleanupKept: "💾", cleanupLeftBehind: "❌", cleanupUnverified: "❔",
+		}
+		for _, check := range checks {
+			line := fmt.Sprintf("   %s %s %s: %s", icons[check.Status], check.Type, check.ID, check.Status)
+			if check.Detail != "" {
+				line += " (" + check.Detail + ")"
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if len(leftovers) > 0 {
+		return fmt.Errorf("%w: instance %s left behind %s", ErrIncompleteCleanup, instanceID,
+			strings.Join(leftovers, ", "))
+	}
+	return nil
+}
+
+func init() {
+	terminateCmd.Flags().BoolVar(&verifyCleanup, "verify-cleanup", true,
+		"After terminating, check that the instance, its GitHub runner and its resources are gone, "+
+			"and fail listing anything left behind")
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+)
+
+// Build information, set at link time by build.sh via -ldflags -X
+var (
+	Version    = "dev"
+	BuildTime  = "unknown"
+	CommitHash = "unknown"
+)
+
+// userAgent returns the User-Agent sent on GitHub, Vault and hook requests
+func userAgent() string {
+	return fmt.Sprintf("gh-workflow/%s (%s; %s/%s)", Version, CommitHash, runtime.GOOS, runtime.GOARCH)
+}
+
+// setGitHubHeaders sets the authentication, API version and User-Agent
+// headers common to all GitHub REST API requests
+func setGitHubHeaders(req *http.Request, token string) {
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", userAgent())
+}
+
+func init() {
+	rootCmd.Version = fmt.Sprintf("%s (commit %s, built %s)", Version, CommitHash, BuildTime)
+}
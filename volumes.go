@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var volumeSpecs []string
+
+// DataVolume is an extra EBS volume attached at launch, and formatted and
+// mounted by the user data when it has a mount point
+type DataVolume struct {
+	Device     string
+	SizeGiB    int32
+	Type       string
+	IOPS       int32
+	Throughput int32
+	Mount      string
+	Filesystem string
+}
+
+// Formats and limits of --volume fields
+var (
+	volumeDevicePattern = regexp.MustCompile(`^/dev/(sd|xvd)[f-p]$`)
+	volumeTypes         = []string{"gp2", "gp3", "io1", "io2", "st1", "sc1", "standard"}
+	volumeFilesystems   = []string{"ext4", "xfs"}
+)
+
+// parseVolume parses a --volume spec such as
+// device=/dev/sdf,size=100,type=gp3,mount=/var/lib/docker
+func parseVolume(spec string) (DataVolume, error) {
+	volume := DataVolume{Type: "gp3", Filesystem: "ext4"}
+	for _, field := range splitList(spec) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return DataVolume{}, fmt.Errorf("invalid volume field %q in %q (expected key=value)", field, spec)
+		}
+		var err error
+		switch key {
+		case "device":
+			volume.Device = value
+		case "size":
+			volume.SizeGiB, err = parseVolumeNumber(key, value)
+		case "type":
+			volume.Type = value
+		case "iops":
+			volume.IOPS, err = parseVolumeNumber(key, value)
+		case "throughput":
+			volume.Throughput, err = parseVolumeNumber(key, value)
+		case "mount":
+			volume.Mount = value
+		case "fs":
+			volume.Filesystem = value
+		default:
+			err = fmt.Errorf("unknown volume field %q (device, size, type, iops, throughput, mount or fs)", key)
+		}
+		if err != nil {
+			return DataVolume{}, err
+		}
+	}
+
+	switch {
+	case !volumeDevicePattern.MatchString(volume.Device):
+		return DataVolume{}, fmt.Errorf("volume %q needs a device from /dev/sdf to /dev/sdp", spec)
+	case volume.SizeGiB < 1 || volume.SizeGiB > 16384:
+		return DataVolume{}, fmt.Errorf("volume %q needs a size between 1 and 16384 GiB", spec)
+	case !slices.Contains(volumeTypes, volume.Type):
+		return DataVolume{}, fmt.Errorf("volume type must be one of %s, got %q",
+			strings.Join(volumeTypes, ", "), volume.Type)
+	case !slices.Contains(volumeFilesystems, volume.Filesystem):
+		return DataVolume{}, fmt.Errorf("volume fs must be ext4 or xfs, got %q", volume.Filesystem)
+	case volume.Mount != "" && (!strings.HasPrefix(volume.Mount, "/") || strings.ContainsAny(volume.Mount, " '\"$`;")):
+		return DataVolume{}, fmt.Errorf("volume mount %q must be an absolute path", volume.Mount)
+	}
+	return volume, nil
+}
+
+// parseVolumeNumber parses a positive numeric --volume field
+func parseVolumeNumber(key, value string) (int32, error) {
+	number, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("volume %s must be a positive number, got %q", key, value)
+	}
+	return int32(number), nil
+}
+
+// dataVolumes parses every --volume
+func dataVolumes() ([]DataVolume, error) {
+	volumes := []DataVolume{}
+	for _, spec := range volumeSpecs {
+		volume, err := parseVolume(spec)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range volumes {
+			if other.Device == volume.Device {
+				return nil, fmt.Errorf("volume device %s is used twice", volume.Device)
+			}
+			if volume.Mount != "" && other.Mount == volume.Mount {
+				return nil, fmt.Errorf("volume mount %s is used twice", volume.Mount)
+			}
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes, nil
+}
+
+// validateVolumes checks --volume
+func validateVolumes() error {
+	volumes, err := dataVolumes()
+	if err != nil {
+		return err
+	}
+	for _, volume := range volumes {
+		if volume.Mount != "" && runnerOS != "linux" {
+			return fmt.Errorf("volume mount is only supported with --os linux; attach without mount= instead")
+		}
+	}
+	return nil
+}
+
+// volumeBlockDeviceMappings returns the block device mappings of --volume;
+// the volumes are deleted with the instance
+func volumeBlockDeviceMappings() []types.BlockDeviceMapping {
+	volumes, _ := dataVolumes()
+	var mappings []types.BlockDeviceMapping
+	for _, volume := range volumes {
+		ebs := &types.EbsBlockDevice{
+			VolumeSize:          aws.Int32(volume.SizeGiB),
+			VolumeType:          types.VolumeType(volume.Type),
+			DeleteOnTermination: aws.Bool(true),
+		}
+		if volume.IOPS > 0 {
+			ebs.Iops = aws.Int32(volume.IOPS)
+		}
+		if volume.Throughput > 0 {
+			ebs.Throughput = aws.Int32(volume.Throughput)
+		}
+		mappings = append(mappings, types.BlockDeviceMapping{DeviceName: aws.String(volume.Device), Ebs: ebs})
+	}
+	return mappings
+}
+
+// volumeUserData returns user data lines formatting and mounting the volumes
+// with a mount point; on Nitro instances the volume shows up as an NVMe
+// device, which is matched by the device name in its controller data
+func volumeUserData() []string {
+	volumes, _ := dataVolumes()
+	lines := []string{}
+	for _, volume := range volumes {
+		if volume.Mount == "" {
+			continue
+		}
+		if len(lines) == 0 {
+			lines = append(lines,
+				"# Format and mount the data volumes",
+				"find_volume() {",
+				"    for i in $(seq 1 60); do",
+				`        for DEV in "/dev/$1" "/dev/xvd${1#sd}"; do [ -b "$DEV" ] && echo "$DEV" && return 0; done`,
+				"        command -v nvme >/dev/null ||",
+				"            (apt-get install -y nvme-cli || yum install -y nvme-cli) >/dev/null 2>&1",
+				"        for DEV in /dev/nvme*n1; do",
+				`            NAME=$(nvme id-ctrl --raw-binary "$DEV" 2>/dev/null |`,
+				`                dd bs=1 skip=3072 count=32 2>/dev/null | tr -d ' \0')`,
+				`            [ "${NAME#/dev/}" = "$1" ] && echo "$DEV" && return 0`,
+				"        done",
+				"        sleep 2",
+				"    done",
+				"    return 1",
+				"}",
+			)
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(volume.Device, "/dev/"), "xvd")
+		if !strings.HasPrefix(name, "sd") {
+			name = "sd" + name
+		}
+		lines = append(lines,
+			fmt.Sprintf("DEV=$(find_volume %s) || bootstrap_failed 'volume %s did not show up'", name, volume.Device),
+			fmt.Sprintf(`blkid "$DEV" >/dev/null || mkfs -t %s "$DEV"`, volume.Filesystem),
+			fmt.Sprintf("mkdir -p '%s'", volume.Mount),
+			fmt.Sprintf(`echo "UUID=$(blkid -s UUID -o value "$DEV") %s %s defaults,nofail 0 2" >> /etc/fstab`,
+				volume.Mount, volume.Filesystem),
+			fmt.Sprintf("mount '%s'", volume.Mount),
+		)
+	}
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+func init() {
+	createCmd.Flags().StringArrayVar(&volumeSpecs, "volume", nil,
+		"Extra EBS volume as device=/dev/sdf,size=100[,type=gp3,iops=,throughput=,mount=/var/lib/docker,fs=ext4] "+
+			"(repeatable)")
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// vsphereProvider clones runner VMs from a template whose guest runs
+// cloud-init; the user data is passed in the guestinfo.userdata property read
+// by cloud-init's VMware datasource, and the tags are stored as JSON in the
+// VM's annotation
+type vsphereProvider struct {
+	options map[string]string
+	client  *govmomi.Client
+	finder  *find.Finder
+}
+
+func newVSphereProvider(options map[string]string) (Provider, error) {
+	rawURL := providerOption(options, "url", "GOVC_URL")
+	if rawURL == "" {
+		return nil, fmt.Errorf("vsphere provider needs --provider-opt url=https://vcenter.example.com or GOVC_URL")
+	}
+	u, err := soap.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vSphere URL: %v", err)
+	}
+	if username := providerOption(options, "username", "GOVC_USERNAME"); username != "" {
+		u.User = url.UserPassword(username, providerOption(options, "password", "GOVC_PASSWORD"))
+	}
+	insecure, _ := strconv.ParseBool(providerOption(options, "insecure", "GOVC_INSECURE"))
+
+	ctx := context.TODO()
+	client, err := govmomi.NewClient(ctx, u, insecure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to vSphere: %w", err)
+	}
+	finder := find.NewFinder(client.Client, true)
+	datacenter, err := finder.DatacenterOrDefault(ctx, providerOption(options, "datacenter", "GOVC_DATACENTER"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find datacenter: %w", err)
+	}
+	finder.SetDatacenter(datacenter)
+	return &vsphereProvider{options: options, client: client, finder: finder}, nil
+}
+
+func (p *vsphereProvider) Create(spec ProviderSpec) (ProviderInstance, error) {
+	ctx := context.TODO()
+	templateName := spec.Image
+	if templateName == "" {
+		templateName = p.options["template"]
+	}
+	if templateName == "" {
+		return ProviderInstance{}, fmt.Errorf(
+			"vsphere provider needs a template VM (--image-id or --provider-opt template=...)")
+	}
+
+	template, err := p.finder.VirtualMachine(ctx, templateName)
+	if err != nil {
+		return ProviderInstance{}, fmt.Errorf("failed to find template %s: %w", templateName, err)
+	}
+	folder, err := p.finder.FolderOrDefault(ctx, p.options["folder"])
+	if err != nil {
+		return ProviderInstance{}, fmt.Errorf("failed to find folder: %w", err)
+	}
+	pool, err := p.finder.ResourcePoolOrDefault(ctx, p.options["resource-pool"])
+	if err != nil {
+		return ProviderInstance{}, fmt.Errorf("failed to find resource pool: %w", err)
+	}
+
+	tags, err := json.Marshal(spec.Tags)
+	if err != nil {
+		return ProviderInstance{}, fmt.Errorf("failed to encode tags: %v", err)
+	}
+	userData := base64.StdEncoding.EncodeToString([]byte(spec.UserData))
+	metadata := base64.StdEncoding.EncodeToString(
+		[]byte(fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", spec.Name, spec.Name)))
+	config := &types.VirtualMachineConfigSpec{
+		Annotation: string(tags),
+		ExtraConfig: []types.BaseOptionValue{
+			&types.OptionValue{Key: "guestinfo.userdata", Value: userData},
+			&types.OptionValue{Key: "guestinfo.userdata.encoding", Value: "base64"},
+			&types.OptionValue{Key: "guestinfo.metadata", Value: metadata},
+			&types.OptionValue{Key: "guestinfo.metadata.encoding", Value: "base64"},
+		},
+	}
+	if cpus, err := strconv.Atoi(p.options["cpus"]); err == nil {
+		config.NumCPUs = int32(cpus)
+	}
+	if memory, err := strconv.ParseInt(p.options["memory-mb"], 10, 64); err == nil {
+		config.MemoryMB = memory
+	}
+
+	poolRef := pool.Reference()
+	cloneSpec := types.VirtualMachineCloneSpec{
+		Location: types.VirtualMachineRelocateSpec{Pool: &poolRef},
+		Config:   config,
+		PowerOn:  true,
+	}
+	if name := p.options["datastore"]; name != "" {
+		datastore, err := p.finder.Datastore(ctx, name)
+		if err != nil {
+			return ProviderInstance{}, fmt.Errorf("failed to find datastore %s: %w", name, err)
+		}
+		datastoreRef := datastore.Reference()
+		cloneSpec.Location.Datastore = &datastoreRef
+	}
+
+	task, err := template.Clone(ctx, folder, spec.Name, cloneSpec)
+	if err != nil {
+		return ProviderInstance{}, fmt.Errorf("failed to clone %s: %w", templateName, err)
+	}
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return ProviderInstance{}, fmt.Errorf("failed to clone %s: %w", templateName, err)
+	}
+	ref, ok := info.Result.(types.ManagedObjectReference)
+	if !ok {
+		return ProviderInstance{}, fmt.Errorf("clone of %s returned no VM", templateName)
+	}
+	return ProviderInstance{ID: ref.Value, Name: spec.Name, State: "poweredOn", Tags: spec.Tags}, nil
+}
+
+func (p *vsphereProvider) Terminate(id string) error {
+	ctx := context.TODO()
+	vm := object.NewVirtualMachine(p.client.Client, types.ManagedObjectReference{Type: "VirtualMachine", Value: id})
+
+	state, err := vm.PowerState(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get the power state of %s: %w", id, err)
+	}
+	if state == types.VirtualMachinePowerStatePoweredOn {
+		task, err := vm.PowerOff(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to power off %s: %w", id, err)
+		}
+		if err := task.Wait(ctx); err != nil {
+			return fmt.Errorf("failed to power off %s: %w", id, err)
+		}
+	}
+
+	task, err := vm.Destroy(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to destroy %s: %w", id, err)
+	}
+	return task.Wait(ctx)
+}
+
+func (p *vsphereProvider) List() ([]ProviderInstance, error) {
+	ctx := context.TODO()
+	folder, err := p.finder.FolderOrDefault(ctx, p.options["folder"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to find folder: %w", err)
+	}
+
+	vms, err := p.finder.VirtualMachineList(ctx, path.Join(folder.InventoryPath, "*"))
+	var notFound *find.NotFoundError
+	if errors.As(err, &notFound) {
+		return []ProviderInstance{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+	refs := make([]types.ManagedObjectReference, 0, len(vms))
+	for _, vm := range vms {
+		refs = append(refs, vm.Reference())
+	}
+
+	var props []mo.VirtualMachine
+	err = property.DefaultCollector(p.client.Client).Retrieve(ctx, refs,
+		[]string{"name", "config.annotation", "runtime.powerState", "guest.ipAddress"}, &props)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read VM properties: %w", err)
+	}
+
+	instances := []ProviderInstance{}
+	for _, vm := range props {
+		if vm.Config == nil {
+			continue
+		}
+		tags := map[string]string{}
+		if json.Unmarshal([]byte(vm.Config.Annotation), &tags) != nil || tags["Purpose"] != "GitHub Actions" {
+			continue
+		}
+		instance := ProviderInstance{
+			ID:    vm.Self.Value,
+			Name:  vm.Name,
+			State: string(vm.Runtime.PowerState),
+			Tags:  tags,
+		}
+		if vm.Guest != nil {
+			instance.PrivateIP = vm.Guest.IpAddress
+		}
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+func init() {
+	providerFactories["vsphere"] = newVSphereProvider
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// vultrProvider creates Vultr cloud compute instances with cloud-init user
+// data; the API key comes from --provider-opt token or VULTR_API_KEY
+type vultrProvider struct {
+	api     *cloudAPIClient
+	options map[string]string
+}
+
+type vultrInstance struct {
+	ID         string `json:"id"`
+	Label      string `json:"label"`
+	Status     string `json:"status"`
+	MainIP     string `json:"main_ip"`
+	InternalIP string `json:"internal_ip"`
+}
+
+func newVultrProvider(options map[string]string) (Provider, error) {
+	token := providerOption(options, "token", "VULTR_API_KEY")
+	if token == "" {
+		return nil, fmt.Errorf("%w: vultr provider needs --provider-opt token=... or VULTR_API_KEY", ErrAuth)
+	}
+	return &vultrProvider{api: newCloudAPIClient("Vultr", "https://api.vultr.com/v2", token), options: options}, nil
+}
+
+func (p *vultrProvider) instance(vultr vultrInstance) ProviderInstance {
+	instance := ProviderInstance{
+		ID:        vultr.ID,
+		Name:      vultr.Label,
+		State:     vultr.Status,
+		PrivateIP: vultr.InternalIP,
+		Tags:      map[string]string{"Purpose": "GitHub Actions", "RunnerName": vultr.Label},
+	}
+	// The main IP is 0.0.0.0 until one is assigned
+	if vultr.MainIP != "0.0.0.0" {
+		instance.PublicIP = vultr.MainIP
+	}
+	return instance
+}
+
+func (p *vultrProvider) Create(spec ProviderSpec) (ProviderInstance, error) {
+	plan, image, err := requireSizeAndImage("vultr", spec)
+	if err != nil {
+		return ProviderInstance{}, err
+	}
+	region := p.options["region"]
+	if region == "" {
+		return ProviderInstance{}, fmt.Errorf("vultr provider needs --provider-opt region=... (e.g. ewr)")
+	}
+
+	request := map[string]any{
+		"label":     spec.Name,
+		"hostname":  spec.Name,
+		"region":    region,
+		"plan":      plan,
+		"user_data": base64.StdEncoding.EncodeToString([]byte(spec.UserData)),
+		"tags":      []string{providerRunnerTag},
+	}
+	// Images are OS IDs (e.g. 1743 for Ubuntu 22.04) or snapshot IDs
+	if osID, err := strconv.Atoi(image); err == nil {
+		request["os_id"] = osID
+	} else {
+		request["snapshot_id"] = image
+	}
+	if keys := splitList(p.options["ssh-keys"]); len(keys) > 0 {
+		request["sshkey_id"] = keys
+	}
+
+	var response struct {
+		Instance vultrInstance `json:"instance"`
+	}
+	if err := p.api.request("POST", "/instances", nil, request, &response, http.StatusAccepted); err != nil {
+		return ProviderInstance{}, err
+	}
+	instance := p.instance(response.Instance)
+	instance.Tags = spec.Tags
+	return instance, nil
+}
+
+func (p *vultrProvider) Terminate(id string) error {
+	return p.api.request("DELETE", "/instances/"+url.PathEscape(id), nil, nil, nil, http.StatusNoContent)
+}
+
+func (p *vultrProvider) List() ([]ProviderInstance, error) {
+	instances := []ProviderInstance{}
+	cursor := ""
+	for {
+		var response struct {
+			Instances []vultrInstance `json:"instances"`
+			Meta      struct {
+				Links struct {
+					Next string `json:"next"`
+				} `json:"links"`
+			} `json:"meta"`
+		}
+		path := "/instances?per_page=500&tag=" + providerRunnerTag
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+		if err := p.api.request("GET", path, nil, nil, &response, http.StatusOK); err != nil {
+			return nil, err
+		}
+		for _, vultr := range response.Instances {
+			instances = append(instances, p.instance(vultr))
+		}
+		if cursor = response.Meta.Links.Next; cursor == "" {
+			return instances, nil
+		}
+	}
+}
+
+func init() {
+	providerFactories["vultr"] = newVultrProvider
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	runnerOS          string
+	windowsContainers string
+	windowsWSL2       bool
+)
+
+// Versions of the container runtimes installed on Windows runners
+const (
+	windowsDockerVersion     = "27.3.1"
+	windowsContainerdVersion = "1.7.22"
+)
+
+// windowsRuntimeUserData returns the PowerShell lines installing the selected
+// container runtime; the Containers feature must already be enabled
+func windowsRuntimeUserData() []string {
+	switch windowsContainers {
+	case "docker":
+		return []string{
+			"if (-not (Get-Service docker -ErrorAction SilentlyContinue)) {",
+			"    Write-Output 'Installing Docker...'",
+			fmt.Sprintf("    Invoke-WebRequest -UseBasicParsing -OutFile C:\\docker.zip "+
+				"https://download.docker.com/win/static/stable/x86_64/docker-%s.zip", windowsDockerVersion),
+			"    Expand-Archive -Path C:\\docker.zip -DestinationPath $Env:ProgramFiles -Force",
+			"    Remove-Item C:\\docker.zip",
+			"    $Path = [Environment]::GetEnvironmentVariable('Path', 'Machine') + \";$Env:ProgramFiles\\docker\"",
+			"    [Environment]::SetEnvironmentVariable('Path', $Path, 'Machine')",
+			"    & \"$Env:ProgramFiles\\docker\\dockerd.exe\" --register-service",
+			"}",
+			"Start-Service docker",
+		}
+	case "containerd":
+		return []string{
+			"if (-not (Get-Service containerd -ErrorAction SilentlyContinue)) {",
+			"    Write-Output 'Installing containerd...'",
+			fmt.Sprintf("    Invoke-WebRequest -UseBasicParsing -OutFile C:\\containerd.tar.gz "+
+				"https://github.com/containerd/containerd/releases/download/v%[1]s/containerd-%[1]s-windows-amd64.tar.gz",
+				windowsContainerdVersion),
+			"    New-Item -ItemType Directory -Force -Path \"$Env:ProgramFiles\\containerd\" | Out-Null",
+			"    tar.exe -xzf C:\\containerd.tar.gz -C \"$Env:ProgramFiles\\containerd\"",
+			"    Remove-Item C:\\containerd.tar.gz",
+			"    $Bin = \"$Env:ProgramFiles\\containerd\\bin\"",
+			"    $Path = [Environment]::GetEnvironmentVariable('Path', 'Machine') + \";$Bin\"",
+			"    [Environment]::SetEnvironmentVariable('Path', $Path, 'Machine')",
+			"    & \"$Bin\\containerd.exe\" config default | Out-File \"$Env:ProgramFiles\\containerd\\config.toml\" -Encoding ascii",
+			"    & \"$Bin\\containerd.exe\" --register-service",
+			"}",
+			"Start-Service containerd",
+		}
+	}
+	return nil
+}
+
+// generateWindowsUserData creates the EC2Launch PowerShell user data for a
+// Windows runner. Enabling the Containers or WSL2 features needs a reboot, so
+// the script persists across boots and skips the steps that already ran.
+func generateWindowsUserData(
+	registrationToken, repoOwner, repoName, runnerLabels, preRunnerScript, runnerName string,
+) string {
+	if runnerName == "" {
+		runnerName = "$($Env:COMPUTERNAME)-runner"
+	}
+
+	features := []string{}
+	if windowsContainers != "" {
+		features = append(features, "Containers")
+	}
+	if windowsWSL2 {
+		features = append(features, "Microsoft-Windows-Subsystem-Linux", "VirtualMachinePlatform")
+	}
+
+	lines := []string{
+		"<powershell>",
+		"$ErrorActionPreference = 'Stop'",
+		"Start-Transcript -Path C:\\user-data.log -Append",
+		"[Net.ServicePointManager]::SecurityProtocol = [Net.SecurityProtocolType]::Tls12",
+		"if (Test-Path C:\\actions-runner\\.runner) { Write-Output 'Runner already configured'; exit 0 }",
+		"Write-Output 'Starting GitHub Actions Runner setup...'",
+	}
+
+	if len(features) > 0 {
+		lines = append(lines,
+			"# Enable Windows features, rebooting once if any of them was missing",
+			"$Reboot = $false",
+			fmt.Sprintf("foreach ($Feature in @('%s')) {", strings.Join(features, "', '")),
+			"    $State = (Get-WindowsOptionalFeature -Online -FeatureName $Feature).State",
+			"    if ($State -ne 'Enabled') {",
+			"        Write-Output \"Enabling $Feature...\"",
+			"        Enable-WindowsOptionalFeature -Online -FeatureName $Feature -All -NoRestart | Out-Null",
+			"        $Reboot = $true",
+			"    }",
+			"}",
+			"if ($Reboot) { Write-Output 'Rebooting to finish enabling features...'; Restart-Computer -Force; exit 0 }",
+		)
+	}
+	lines = append(lines, windowsRuntimeUserData()...)
+	lines = append(lines, windowsLocaleUserData()...)
+	if windowsWSL2 {
+		lines = append(lines,
+			"Write-Output 'Installing WSL2...'",
+			"wsl.exe --update",
+			"wsl.exe --set-default-version 2",
+			"wsl.exe --install --distribution Ubuntu --no-launch",
+		)
+	}
+
+	lines = append(lines,
+		"New-Item -ItemType Directory -Force -Path C:\\actions-runner | Out-Null",
+		"Set-Location C:\\actions-runner",
+	)
+	if preRunnerScript != "" {
+		lines = append(lines, "# Pre-runner script", preRunnerScript)
+	}
+	lines = append(lines,
+		fmt.Sprintf("Invoke-WebRequest -UseBasicParsing -OutFile runner.zip "+
+			"https://github.com/actions/runner/releases/download/v%[1]s/actions-runner-win-x64-%[1]s.zip",
+			runnerVersion),
+		"Expand-Archive -Path runner.zip -DestinationPath . -Force",
+		"Remove-Item runner.zip",
+		fmt.Sprintf(
+			`.\config.cmd --unattended --url %s/%s --token %s --labels %s --name "%s" `+
+				`--work _work --replace --runasservice --windowslogonaccount "NT AUTHORITY\SYSTEM"%s%s`,
+			githubServerURL(),
+			runnerScope(repoOwner, repoName),
+			registrationToken,
+			runnerLabels,
+			runnerName,
+			runnerGroupArg(),
+			runnerUpdateArg(),
+		),
+		"Write-Output 'GitHub Actions Runner configured and started as a service'",
+		"</powershell>",
+		"<persist>true</persist>",
+	)
+
+	return strings.Join(lines, "\n")
+}
+
+// validateWindowsFlags checks the OS-specific create flags and switches the
+// default runner labels to Windows ones
+func validateWindowsFlags() error {
+	if runnerOS != "linux" && runnerOS != "windows" {
+		return fmt.Errorf("os must be 'linux' or 'windows'")
+	}
+	if windowsContainers != "" && windowsContainers != "docker" && windowsContainers != "containerd" {
+		return fmt.Errorf("windows-containers must be 'docker' or 'containerd'")
+	}
+
+	if runnerOS == "linux" {
+		if windowsContainers != "" || windowsWSL2 {
+			return fmt.Errorf("windows-containers and wsl2 require --os windows")
+		}
+		return nil
+	}
+
+	if sshCAPublicKey != "" || trackJobs || useBakedAMI {
+		return fmt.Errorf("ssh-ca-public-key, track-jobs and use-baked-ami are only supported with --os linux")
+	}
+	if runnerLabels == "self-hosted,linux,x64" {
+		runnerLabels = "self-hosted,windows,x64"
+	}
+	return nil
+}
+
+func init() {
+	createCmd.Flags().StringVar(&runnerOS, "os", "linux", "Operating system of the AMI (linux or windows)")
+	createCmd.Flags().StringVar(&windowsContainers, "windows-containers", "",
+		"Enable Windows containers with this runtime (docker or containerd; --os windows only)")
+	createCmd.Flags().BoolVar(&windowsWSL2, "wsl2", false,
+		"Install WSL2 with Ubuntu (--os windows only; needs a metal or nested-virtualization instance type)")
+}